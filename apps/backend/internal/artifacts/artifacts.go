@@ -0,0 +1,96 @@
+// Package artifacts stores files produced by runs, such as the binary response of an
+// HTTP node, so that step outputs only carry a small reference instead of the bytes.
+package artifacts
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+)
+
+// Artifact is a row of the run_artifacts table. Content is base64 and only read by Get.
+type Artifact struct {
+	ID          string `json:"id,omitempty"`
+	OrgID       string `json:"org_id"`
+	WorkflowID  string `json:"workflow_id,omitempty"`
+	RunID       string `json:"run_id,omitempty"`
+	NodeID      string `json:"node_id,omitempty"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	Content     string `json:"content,omitempty"`
+	CreatedAt   string `json:"created_at,omitempty"`
+}
+
+// ErrNotFound is returned by Get for an artifact that doesn't exist in the org.
+var ErrNotFound = errors.New("artifact not found")
+
+// Save stores data under the metadata of a (ID, size and checksum are filled in) and
+// returns the stored artifact, without its content.
+func Save(a Artifact, data []byte) (*Artifact, error) {
+	sum := sha256.Sum256(data)
+	a.ID = ""
+	a.Size = int64(len(data))
+	a.SHA256 = hex.EncodeToString(sum[:])
+	a.Content = base64.StdEncoding.EncodeToString(data)
+	if a.ContentType == "" {
+		a.ContentType = "application/octet-stream"
+	}
+
+	var rows []Artifact
+	if err := database.GetClient().DB.From("run_artifacts").Insert(a).Execute(&rows); err != nil {
+		return nil, fmt.Errorf("failed to store artifact: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("failed to store artifact: no row returned")
+	}
+	saved := rows[0]
+	saved.Content = ""
+	return &saved, nil
+}
+
+// Get loads an artifact of the org with its content.
+func Get(orgID, id string) (*Artifact, []byte, error) {
+	var rows []Artifact
+	err := database.GetClient().DB.From("run_artifacts").Select("*").Eq("org_id", orgID).Eq("id", id).Execute(&rows)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load artifact: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	artifact := rows[0]
+	data, err := base64.StdEncoding.DecodeString(artifact.Content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("artifact %s is corrupted: %w", id, err)
+	}
+	artifact.Content = ""
+	return &artifact, data, nil
+}
+
+// Reference describes an artifact in a step output. Other steps pass it on as is, e.g.
+// an email attachment with "content": "{{ steps.download.data }}".
+func (a *Artifact) Reference() map[string]interface{} {
+	return map[string]interface{}{
+		"artifact_id":  a.ID,
+		"filename":     a.Filename,
+		"content_type": a.ContentType,
+		"size":         a.Size,
+		"sha256":       a.SHA256,
+	}
+}
+
+// ReferenceID returns the artifact ID of a value built by Reference, if it is one.
+func ReferenceID(v interface{}) (string, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	id, ok := m["artifact_id"].(string)
+	return id, ok && id != ""
+}
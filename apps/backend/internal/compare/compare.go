@@ -0,0 +1,350 @@
+// Package compare is the comparison library shared by the Filter, Condition and Switch
+// nodes, so the same data compares the same way wherever it is tested.
+//
+// Values are classified by kind: null, number, string, boolean, date, list and object.
+// Loose comparisons (the default) coerce between kinds as follows:
+//
+//   - null equals only null, and is never ordered before or after anything.
+//   - A number and a string compare as numbers when the string is a number ("10", " 2.5 ");
+//     otherwise they are different, and ordering them is an error.
+//   - A boolean and the string "true" or "false" (any case) compare as booleans. Booleans
+//     never coerce to numbers, and are not ordered.
+//   - A date (time.Time) compares with an ISO-8601 string, or with a unix timestamp in
+//     the date operators, as instants.
+//   - Two strings are equal as text. They are ordered as numbers when both are numbers,
+//     as instants when both are ISO-8601 dates, and as text otherwise; a number-like
+//     string can't be ordered against one that isn't.
+//   - Lists and objects are equal when their contents are, and are not ordered.
+//
+// Strict comparisons skip the coercions: both sides must be of the same kind, and a
+// mismatch makes equality false and ordering an error. Case-insensitive comparisons
+// fold the case of text (equality, ordering, substrings and regexes alike).
+package compare
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options adjust how values are compared. The zero value is loose and case-sensitive.
+type Options struct {
+	Strict     bool // no coercion between kinds
+	IgnoreCase bool // text compares case-insensitively
+}
+
+// WithOverrides applies the "strict" and "caseSensitive" flags of a node, clause or
+// case configuration on top of o. Flags that aren't set keep the value from o.
+func (o Options) WithOverrides(config map[string]interface{}) Options {
+	if strict, ok := config["strict"].(bool); ok {
+		o.Strict = strict
+	}
+	if caseSensitive, ok := config["caseSensitive"].(bool); ok {
+		o.IgnoreCase = !caseSensitive
+	}
+	return o
+}
+
+// Kinds of values.
+const (
+	KindNull   = "null"
+	KindNumber = "number"
+	KindString = "string"
+	KindBool   = "boolean"
+	KindDate   = "date"
+	KindList   = "list"
+	KindObject = "object"
+)
+
+// KindOf classifies a value. Anything that isn't one of the JSON types or a time.Time
+// counts as a string.
+func KindOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return KindNull
+	case float64, float32, int, int32, int64, uint, uint32, uint64:
+		return KindNumber
+	case string:
+		return KindString
+	case bool:
+		return KindBool
+	case time.Time:
+		return KindDate
+	case []interface{}:
+		return KindList
+	case map[string]interface{}:
+		return KindObject
+	}
+	return KindString
+}
+
+// ErrMismatch is returned when two values can't be compared under the coercion rules.
+var ErrMismatch = errors.New("values cannot be compared")
+
+func mismatch(a, b interface{}) error {
+	return fmt.Errorf("%w: %s and %s", ErrMismatch, describe(a), describe(b))
+}
+
+// Equal tells whether a and b are equal.
+func Equal(a, b interface{}, opts Options) bool {
+	ka, kb := KindOf(a), KindOf(b)
+	if ka == KindNull || kb == KindNull {
+		return ka == kb
+	}
+	if ka != kb {
+		if opts.Strict {
+			return false
+		}
+		if na, nb, ok := asNumbers(a, b); ok {
+			return na == nb
+		}
+		if ba, bb, ok := asBools(a, b); ok {
+			return ba == bb
+		}
+		if ka == KindDate || kb == KindDate {
+			ta, errA := ParseTime(a)
+			tb, errB := ParseTime(b)
+			return errA == nil && errB == nil && ta.Equal(tb)
+		}
+		return false
+	}
+
+	switch ka {
+	case KindNumber:
+		na, _ := number(a)
+		nb, _ := number(b)
+		return na == nb
+	case KindString:
+		return foldText(Text(a), opts) == foldText(Text(b), opts)
+	case KindDate:
+		return a.(time.Time).Equal(b.(time.Time))
+	case KindList:
+		la, lb := a.([]interface{}), b.([]interface{})
+		if len(la) != len(lb) {
+			return false
+		}
+		for i := range la {
+			if !Equal(la[i], lb[i], opts) {
+				return false
+			}
+		}
+		return true
+	case KindObject:
+		ma, mb := a.(map[string]interface{}), b.(map[string]interface{})
+		if len(ma) != len(mb) {
+			return false
+		}
+		for k, va := range ma {
+			vb, ok := mb[k]
+			if !ok || !Equal(va, vb, opts) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Order returns -1, 0 or 1 as a sorts before, with or after b, or ErrMismatch when the
+// two can't be ordered (null, booleans, lists, objects, or kinds that don't coerce).
+func Order(a, b interface{}, opts Options) (int, error) {
+	ka, kb := KindOf(a), KindOf(b)
+	if opts.Strict && ka != kb {
+		return 0, mismatch(a, b)
+	}
+	for _, k := range []string{ka, kb} {
+		switch k {
+		case KindNull, KindBool, KindList, KindObject:
+			return 0, mismatch(a, b)
+		}
+	}
+
+	if ka == KindDate || kb == KindDate {
+		ta, errA := ParseTime(a)
+		tb, errB := ParseTime(b)
+		if errA != nil || errB != nil {
+			return 0, mismatch(a, b)
+		}
+		return compareTimes(ta, tb), nil
+	}
+	if ka == KindNumber || kb == KindNumber || !opts.Strict {
+		if na, nb, ok := asNumbers(a, b); ok {
+			return compareNumbers(na, nb), nil
+		}
+		_, numA := numericText(a)
+		_, numB := numericText(b)
+		if ka == KindNumber || kb == KindNumber || numA || numB {
+			// A number against a word ("n/a" > "50") has no meaningful order
+			return 0, mismatch(a, b)
+		}
+	}
+	// Two strings
+	if !opts.Strict {
+		ta, errA := parseISO(Text(a))
+		tb, errB := parseISO(Text(b))
+		if errA == nil && errB == nil {
+			return compareTimes(ta, tb), nil
+		}
+	}
+	return strings.Compare(foldText(Text(a), opts), foldText(Text(b), opts)), nil
+}
+
+// asNumbers reads both values as numbers: numbers, and strings that hold a number.
+func asNumbers(a, b interface{}) (float64, float64, bool) {
+	na, okA := number(a)
+	if !okA {
+		na, okA = numericText(a)
+	}
+	nb, okB := number(b)
+	if !okB {
+		nb, okB = numericText(b)
+	}
+	return na, nb, okA && okB
+}
+
+// asBools reads a boolean and a "true" / "false" string as two booleans.
+func asBools(a, b interface{}) (bool, bool, bool) {
+	toBool := func(v interface{}) (bool, bool) {
+		switch val := v.(type) {
+		case bool:
+			return val, true
+		case string:
+			switch strings.ToLower(strings.TrimSpace(val)) {
+			case "true":
+				return true, true
+			case "false":
+				return false, true
+			}
+		}
+		return false, false
+	}
+	_, aBool := a.(bool)
+	_, bBool := b.(bool)
+	if !aBool && !bBool {
+		return false, false, false
+	}
+	ba, okA := toBool(a)
+	bb, okB := toBool(b)
+	return ba, bb, okA && okB
+}
+
+func number(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case int32:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case uint:
+		return float64(val), true
+	case uint32:
+		return float64(val), true
+	case uint64:
+		return float64(val), true
+	}
+	return 0, false
+}
+
+func numericText(v interface{}) (float64, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return f, err == nil
+}
+
+func compareNumbers(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// isoLayouts are the ISO-8601 forms recognised as dates, most specific first.
+var isoLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+func parseISO(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range isoLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as a date", s)
+}
+
+// ParseTime reads a date: a time.Time, or an ISO-8601 string. Dates without a zone are UTC.
+func ParseTime(v interface{}) (time.Time, error) {
+	switch val := v.(type) {
+	case time.Time:
+		return val, nil
+	case string:
+		return parseISO(val)
+	}
+	return time.Time{}, fmt.Errorf("%s is not a date", describe(v))
+}
+
+// Text renders a value as comparison text: strings as-is, numbers without exponent,
+// lists and objects as JSON, null as "".
+func Text(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case map[string]interface{}, []interface{}:
+		if b, err := json.Marshal(val); err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func foldText(s string, opts Options) string {
+	if opts.IgnoreCase {
+		return strings.ToLower(s)
+	}
+	return s
+}
+
+func describe(v interface{}) string {
+	if v == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%s(%v)", KindOf(v), v)
+}
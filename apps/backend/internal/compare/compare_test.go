@@ -0,0 +1,196 @@
+package compare
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCompare(t *testing.T) {
+	loose := Options{}
+	strict := Options{Strict: true}
+	noCase := Options{IgnoreCase: true}
+	date := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		operator string
+		left     interface{}
+		right    interface{}
+		opts     Options
+		want     bool
+		dataErr  bool // the values can't be compared: an error, but not a config error
+	}{
+		// Numbers and numeric strings
+		{"numeric strings order as numbers", ">", "10", "2", loose, true, false},
+		{"number against numeric string", "<", 2.0, "10", loose, true, false},
+		{"number equals numeric string", "==", 5.0, " 5 ", loose, true, false},
+		{"int equals float", "==", 3, 3.0, loose, true, false},
+		{"number against word", ">", "n/a", 50.0, loose, false, true},
+		{"numeric string against word", ">", "n/a", "50", loose, false, true},
+		{"number never equals word", "==", 1.0, "one", loose, false, false},
+
+		// Text
+		{"text orders as text", "<", "apple", "banana", loose, true, false},
+		{"text equality is case-sensitive", "==", "Gold", "gold", loose, false, false},
+		{"ignore case", "==", "Gold", "gold", noCase, true, false},
+		{"ignore case ordering", "<", "apple", "Banana", noCase, true, false},
+		{"case-sensitive ordering", "<", "apple", "Banana", loose, false, false},
+
+		// Dates
+		{"ISO strings order as instants", "<", "2024-01-02T00:00:00+02:00", "2024-01-01T23:00:00Z", loose, true, false},
+		{"date-only strings", ">", "2024-02-01", "2024-01-31", loose, true, false},
+		{"time equals ISO string", "==", date, "2024-03-01T12:00:00Z", loose, true, false},
+		{"time against ISO string", ">", date, "2024-03-01", loose, true, false},
+		{"time against word", ">", date, "soon", loose, false, true},
+		{"before a unix timestamp", "before", "2024-03-01T00:00:00Z", 1709294400.0, loose, true, false},
+		{"after a millisecond timestamp", "after", "2024-03-01T12:00:01Z", 1709294400000.0, loose, true, false},
+		{"strict dates don't read timestamps", "before", "2024-03-01T00:00:00Z", 1709294400.0, strict, false, true},
+		{"not a date", "after", "yesterday", "2024-01-01", loose, false, true},
+
+		// Booleans and null
+		{"bool equals bool string", "==", true, "TRUE", loose, true, false},
+		{"bool isn't a number", "==", true, 1.0, loose, false, false},
+		{"bools aren't ordered", ">", true, false, loose, false, true},
+		{"null equals null", "==", nil, nil, loose, true, false},
+		{"null isn't empty string", "==", nil, "", loose, false, false},
+		{"null isn't ordered", "<", nil, 1.0, loose, false, true},
+
+		// Strict mode
+		{"strict number against numeric string", "==", 5.0, "5", strict, false, false},
+		{"strict ordering across kinds", ">", "10", 2.0, strict, false, true},
+		{"strict numeric strings order as text", "<", "10", "2", strict, true, false},
+		{"strict bool against bool string", "==", true, "true", strict, false, false},
+		{"strict contains needs text", "contains", 123.0, "2", strict, false, true},
+
+		// Lists, objects and membership
+		{"lists equal by content", "==", []interface{}{1.0, "a"}, []interface{}{"1", "a"}, loose, true, false},
+		{"objects equal by content", "==", map[string]interface{}{"a": 1.0}, map[string]interface{}{"a": 1.0}, loose, true, false},
+		{"lists aren't ordered", "<", []interface{}{}, []interface{}{1.0}, loose, false, true},
+		{"list contains element", "contains", []interface{}{"a", 2.0}, "2", loose, true, false},
+		{"object has key", "contains", map[string]interface{}{"Key": 1.0}, "key", noCase, true, false},
+		{"substring", "contains", "hello world", "o w", loose, true, false},
+		{"not_contains", "not_contains", "hello", "x", loose, true, false},
+		{"in comma-separated string", "in", "BE", "FR, BE, DE", loose, true, false},
+		{"in list with coercion", "in", "2", []interface{}{1.0, 2.0}, loose, true, false},
+		{"not_in", "not_in", "IT", "FR, BE", loose, true, false},
+		{"starts_with", "starts_with", "ORD-42", "ORD-", loose, true, false},
+		{"ends_with ignoring case", "ends_with", "photo.JPG", ".jpg", noCase, true, false},
+		{"regex", "matches", "ORD-42", `^ORD-\d+$`, loose, true, false},
+		{"regex ignoring case", "regex", "ord-42", `^ORD-\d+$`, noCase, true, false},
+
+		// Ranges
+		{"between bounds included", "between", "10", []interface{}{10.0, 20.0}, loose, true, false},
+		{"between below", "between", 9.0, []interface{}{10.0, 20.0}, loose, false, false},
+		{"between open max", "range", 1000.0, []interface{}{500.0, nil}, loose, true, false},
+		{"between open min", "between", 5.0, []interface{}{"", 4.0}, loose, false, false},
+
+		// Unary operators
+		{"is_empty blank text", "is_empty", "  ", nil, loose, true, false},
+		{"is_empty empty list", "is_empty", []interface{}{}, nil, loose, true, false},
+		{"is_not_empty zero", "is_not_empty", 0.0, nil, loose, true, false},
+		{"exists", "exists", "", nil, loose, true, false},
+		{"not_exists", "not_exists", nil, nil, loose, true, false},
+
+		// Aliases and spelling
+		{"alias gte", "gte", "3", 3.0, loose, true, false},
+		{"alias ne", "ne", "a", "b", loose, true, false},
+		{"operator name is trimmed and case-folded", " EQUALS ", "x", "x", loose, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Compare(tt.operator, tt.left, tt.right, tt.opts)
+			if tt.dataErr {
+				if err == nil {
+					t.Fatalf("Compare(%q, %v, %v) succeeded, want a data error", tt.operator, tt.left, tt.right)
+				}
+				if IsConfigError(err) {
+					t.Errorf("a data error is reported as a config error: %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("Compare(%q, %v, %v): %v", tt.operator, tt.left, tt.right, err)
+			}
+			if got != tt.want {
+				t.Errorf("Compare(%q, %v, %v) = %v, want %v", tt.operator, tt.left, tt.right, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderMismatch(t *testing.T) {
+	if _, err := Order("n/a", 50.0, Options{}); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Order(word, number) error = %v, want ErrMismatch", err)
+	}
+	if c, err := Order("10", "9", Options{}); err != nil || c != 1 {
+		t.Errorf(`Order("10", "9") = %d, %v, want 1`, c, err)
+	}
+	if c, err := Order("10", "9", Options{Strict: true}); err != nil || c != -1 {
+		t.Errorf(`strict Order("10", "9") = %d, %v, want -1 (text)`, c, err)
+	}
+}
+
+func TestCompareConfigErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		operator string
+		right    interface{}
+		want     error
+	}{
+		{"unknown operator", "approximately", "x", ErrUnknownOperator},
+		{"missing operator", "", "x", ErrUnknownOperator},
+		{"invalid regex", "matches", "([", ErrInvalidPattern},
+		{"range without bounds", "between", []interface{}{nil, ""}, ErrInvalidRange},
+		{"range that isn't a pair", "between", 10.0, ErrInvalidRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Compare(tt.operator, "value", tt.right, Options{})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+			if !IsConfigError(err) {
+				t.Errorf("IsConfigError(%v) = false", err)
+			}
+			if got {
+				t.Error("a config error came with a true result")
+			}
+		})
+	}
+}
+
+func TestWithOverrides(t *testing.T) {
+	base := Options{IgnoreCase: true}
+	if got := base.WithOverrides(map[string]interface{}{}); got != base {
+		t.Errorf("no flags: %+v, want %+v", got, base)
+	}
+	got := base.WithOverrides(map[string]interface{}{"strict": true, "caseSensitive": true})
+	if !got.Strict || got.IgnoreCase {
+		t.Errorf("strict, case-sensitive: %+v", got)
+	}
+	got = Options{}.WithOverrides(map[string]interface{}{"caseSensitive": false, "strict": "yes"})
+	if got.Strict || !got.IgnoreCase {
+		t.Errorf("case-insensitive, strict not a bool: %+v", got)
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, KindNull},
+		{1.5, KindNumber},
+		{int64(2), KindNumber},
+		{"x", KindString},
+		{false, KindBool},
+		{time.Now(), KindDate},
+		{[]interface{}{}, KindList},
+		{map[string]interface{}{}, KindObject},
+		{struct{}{}, KindString},
+	}
+	for _, tt := range tests {
+		if got := KindOf(tt.value); got != tt.want {
+			t.Errorf("KindOf(%#v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Operator is one entry of the operator catalog. Unary operators only look at the left value.
+type Operator struct {
+	Name        string   `json:"name"`
+	Aliases     []string `json:"aliases,omitempty"`
+	Unary       bool     `json:"unary"`
+	Description string   `json:"description"`
+
+	test func(left, right interface{}, opts Options) (bool, error)
+}
+
+// Configuration errors: the comparison can't succeed whatever the data.
+var (
+	ErrUnknownOperator = errors.New("unknown operator")
+	ErrInvalidPattern  = errors.New("invalid regex")
+	ErrInvalidRange    = errors.New("invalid range")
+)
+
+// IsConfigError tells whether err comes from the configuration rather than the data.
+func IsConfigError(err error) bool {
+	return errors.Is(err, ErrUnknownOperator) || errors.Is(err, ErrInvalidPattern) || errors.Is(err, ErrInvalidRange)
+}
+
+var catalog = []Operator{
+	{Name: "==", Aliases: []string{"=", "equals", "eq"}, Description: "Equal, following the coercion rules", test: func(l, r interface{}, o Options) (bool, error) {
+		return Equal(l, r, o), nil
+	}},
+	{Name: "!=", Aliases: []string{"not_equals", "ne"}, Description: "Not equal", test: func(l, r interface{}, o Options) (bool, error) {
+		return !Equal(l, r, o), nil
+	}},
+	{Name: ">", Aliases: []string{"gt"}, Description: "Greater than: numbers, dates or text", test: orderTest(func(c int) bool { return c > 0 })},
+	{Name: ">=", Aliases: []string{"gte"}, Description: "Greater than or equal", test: orderTest(func(c int) bool { return c >= 0 })},
+	{Name: "<", Aliases: []string{"lt"}, Description: "Less than: numbers, dates or text", test: orderTest(func(c int) bool { return c < 0 })},
+	{Name: "<=", Aliases: []string{"lte"}, Description: "Less than or equal", test: orderTest(func(c int) bool { return c <= 0 })},
+	{Name: "between", Aliases: []string{"range"}, Description: "Within [min, max], bounds included; right is a two-item list where either bound may be null", test: betweenTest},
+	{Name: "contains", Description: "Text contains a substring, a list contains an element, or an object has a key", test: containsTest},
+	{Name: "not_contains", Description: "Opposite of contains", test: negate(containsTest)},
+	{Name: "starts_with", Description: "Text starts with the right value", test: textTest(strings.HasPrefix)},
+	{Name: "ends_with", Description: "Text ends with the right value", test: textTest(strings.HasSuffix)},
+	{Name: "matches", Aliases: []string{"regex"}, Description: "Text matches a Go regular expression", test: matchesTest},
+	{Name: "in", Description: "Equal to an element of a list, or of a comma-separated string", test: inTest},
+	{Name: "not_in", Description: "Opposite of in", test: negate(inTest)},
+	{Name: "is_empty", Unary: true, Description: "Null, blank text, or an empty list or object", test: func(l, _ interface{}, _ Options) (bool, error) {
+		return IsEmpty(l), nil
+	}},
+	{Name: "is_not_empty", Unary: true, Description: "Opposite of is_empty", test: func(l, _ interface{}, _ Options) (bool, error) {
+		return !IsEmpty(l), nil
+	}},
+	{Name: "exists", Unary: true, Description: "Not null", test: func(l, _ interface{}, _ Options) (bool, error) {
+		return l != nil, nil
+	}},
+	{Name: "not_exists", Unary: true, Description: "Null, or a missing field", test: func(l, _ interface{}, _ Options) (bool, error) {
+		return l == nil, nil
+	}},
+	{Name: "before", Description: "Date before the right date (ISO-8601, or a unix timestamp unless strict)", test: dateTest(func(c int) bool { return c < 0 })},
+	{Name: "after", Description: "Date after the right date", test: dateTest(func(c int) bool { return c > 0 })},
+}
+
+var operatorIndex = func() map[string]int {
+	index := make(map[string]int)
+	for i, op := range catalog {
+		index[op.Name] = i
+		for _, alias := range op.Aliases {
+			index[alias] = i
+		}
+	}
+	return index
+}()
+
+// Catalog lists the operators, for editors and documentation.
+func Catalog() []Operator {
+	return append([]Operator(nil), catalog...)
+}
+
+// Lookup finds an operator by name or alias, ignoring case and surrounding spaces.
+func Lookup(name string) (Operator, bool) {
+	i, ok := operatorIndex[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return Operator{}, false
+	}
+	return catalog[i], true
+}
+
+// Test applies the operator to left and right.
+func (op Operator) Test(left, right interface{}, opts Options) (bool, error) {
+	return op.test(left, right, opts)
+}
+
+// Compare looks up an operator and applies it. A data error (e.g. ordering a number
+// against a word) comes with a false result; see IsConfigError for the others.
+func Compare(operator string, left, right interface{}, opts Options) (bool, error) {
+	op, ok := Lookup(operator)
+	if !ok {
+		return false, fmt.Errorf("%w %q", ErrUnknownOperator, operator)
+	}
+	return op.Test(left, right, opts)
+}
+
+// CompilePattern compiles a "matches" pattern, honoring IgnoreCase.
+func CompilePattern(pattern string, opts Options) (*regexp.Regexp, error) {
+	if opts.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPattern, err)
+	}
+	return re, nil
+}
+
+// IsEmpty is true for null, blank text, and empty lists or objects.
+func IsEmpty(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(val) == ""
+	case []interface{}:
+		return len(val) == 0
+	case map[string]interface{}:
+		return len(val) == 0
+	}
+	return false
+}
+
+func negate(test func(l, r interface{}, o Options) (bool, error)) func(l, r interface{}, o Options) (bool, error) {
+	return func(l, r interface{}, o Options) (bool, error) {
+		ok, err := test(l, r, o)
+		if err != nil {
+			return false, err
+		}
+		return !ok, nil
+	}
+}
+
+func orderTest(accept func(int) bool) func(l, r interface{}, o Options) (bool, error) {
+	return func(l, r interface{}, o Options) (bool, error) {
+		c, err := Order(l, r, o)
+		if err != nil {
+			return false, err
+		}
+		return accept(c), nil
+	}
+}
+
+// betweenTest checks min <= left <= max, with right as [min, max].
+func betweenTest(l, r interface{}, o Options) (bool, error) {
+	bounds, ok := r.([]interface{})
+	if !ok || len(bounds) != 2 {
+		return false, fmt.Errorf("%w: between needs [min, max], got %s", ErrInvalidRange, describe(r))
+	}
+	lo, hi := bounds[0], bounds[1]
+	if IsEmpty(lo) && IsEmpty(hi) {
+		return false, fmt.Errorf("%w: between needs a min or a max", ErrInvalidRange)
+	}
+	if !IsEmpty(lo) {
+		c, err := Order(l, lo, o)
+		if err != nil || c < 0 {
+			return false, err
+		}
+	}
+	if !IsEmpty(hi) {
+		c, err := Order(l, hi, o)
+		if err != nil || c > 0 {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func containsTest(l, r interface{}, o Options) (bool, error) {
+	switch val := l.(type) {
+	case []interface{}:
+		for _, item := range val {
+			if Equal(item, r, o) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]interface{}:
+		key := Text(r)
+		for k := range val {
+			if foldText(k, o) == foldText(key, o) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case nil:
+		return false, nil
+	}
+	if o.Strict && (KindOf(l) != KindString || KindOf(r) != KindString) {
+		return false, mismatch(l, r)
+	}
+	return strings.Contains(foldText(Text(l), o), foldText(Text(r), o)), nil
+}
+
+func textTest(test func(s, part string) bool) func(l, r interface{}, o Options) (bool, error) {
+	return func(l, r interface{}, o Options) (bool, error) {
+		if l == nil {
+			return false, nil
+		}
+		if o.Strict && (KindOf(l) != KindString || KindOf(r) != KindString) {
+			return false, mismatch(l, r)
+		}
+		return test(foldText(Text(l), o), foldText(Text(r), o)), nil
+	}
+}
+
+func matchesTest(l, r interface{}, o Options) (bool, error) {
+	re, err := CompilePattern(Text(r), o)
+	if err != nil {
+		return false, err
+	}
+	if l == nil {
+		return false, nil
+	}
+	if o.Strict && KindOf(l) != KindString {
+		return false, mismatch(l, r)
+	}
+	return re.MatchString(Text(l)), nil
+}
+
+// inTest looks for left in right: a list, or a comma-separated string.
+func inTest(l, r interface{}, o Options) (bool, error) {
+	list, ok := r.([]interface{})
+	if !ok {
+		for _, item := range strings.Split(Text(r), ",") {
+			list = append(list, strings.TrimSpace(item))
+		}
+	}
+	for _, item := range list {
+		if Equal(l, item, o) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func dateTest(accept func(int) bool) func(l, r interface{}, o Options) (bool, error) {
+	return func(l, r interface{}, o Options) (bool, error) {
+		tl, err := dateOperand(l, o)
+		if err != nil {
+			return false, err
+		}
+		tr, err := dateOperand(r, o)
+		if err != nil {
+			return false, err
+		}
+		return accept(compareTimes(tl, tr)), nil
+	}
+}
+
+// dateOperand reads a date for before / after. Outside strict mode unix timestamps count
+// too: seconds, or milliseconds when the number is large enough.
+func dateOperand(v interface{}, o Options) (time.Time, error) {
+	if t, err := ParseTime(v); err == nil || o.Strict {
+		return t, err
+	}
+	f, ok := number(v)
+	if !ok {
+		f, ok = numericText(v)
+	}
+	if !ok {
+		return time.Time{}, fmt.Errorf("%s is not a date", describe(v))
+	}
+	if math.Abs(f) >= 1e12 {
+		return time.UnixMilli(int64(f)).UTC(), nil
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+}
@@ -0,0 +1,279 @@
+// Package egress decides which destinations flows may reach. Every node that calls out
+// (HTTP requests, OAuth2 token endpoints, an org's SMTP server) goes through a Policy:
+//
+//   - Private, loopback, link-local, carrier-grade NAT and other reserved ranges are
+//     blocked, unless the operator opens some of them with EGRESS_ALLOWED_NETWORKS
+//     (e.g. a self-hosted install calling services on its own network). Cloud metadata
+//     endpoints stay blocked regardless.
+//   - The organization's deny list blocks the hosts, addresses and networks it names.
+//   - A non-empty allow list blocks everything it doesn't name.
+//
+// Host names are checked before they are resolved and again with each address they
+// resolve to. Connections go to the address that was checked, so a DNS answer can't
+// change between the check and the dial, and every redirect is checked like the first
+// request. Blocked attempts are written to audit_logs.
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/audit"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+)
+
+// AllowedNetworksEnv lists comma-separated CIDRs the operator exempts from the
+// reserved ranges, for every organization.
+const AllowedNetworksEnv = "EGRESS_ALLOWED_NETWORKS"
+
+// EventBlocked is the audit event of a blocked attempt.
+const EventBlocked = "egress.blocked"
+
+const maxRedirects = 10
+
+// lookupIPAddr resolves host names for DialContext.
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// Rules is a row of the org_egress_policies table.
+type Rules struct {
+	OrgID     string   `json:"org_id"`
+	Allow     []string `json:"allow"`
+	Deny      []string `json:"deny"`
+	UpdatedBy *string  `json:"updated_by,omitempty"`
+	UpdatedAt string   `json:"updated_at,omitempty"`
+}
+
+// BlockedError is returned for a destination the policy doesn't allow.
+type BlockedError struct {
+	Host   string
+	IP     string // empty when the host was blocked before resolution
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	target := e.Host
+	if e.IP != "" && e.IP != e.Host {
+		target = fmt.Sprintf("%s (%s)", e.Host, e.IP)
+	}
+	return fmt.Sprintf("outbound call to %s blocked: %s", target, e.Reason)
+}
+
+// IsBlocked tells whether err comes from the egress policy.
+func IsBlocked(err error) bool {
+	var blocked *BlockedError
+	return errors.As(err, &blocked)
+}
+
+// Policy is an organization's egress policy, ready to check destinations.
+type Policy struct {
+	orgID  string
+	allow  []rule
+	deny   []rule
+	origin map[string]interface{}
+}
+
+// New builds the policy of an organization from its allow and deny lists.
+func New(orgID string, allow, deny []string) (*Policy, error) {
+	allowRules, err := parseRules(allow)
+	if err != nil {
+		return nil, fmt.Errorf("allow list: %w", err)
+	}
+	denyRules, err := parseRules(deny)
+	if err != nil {
+		return nil, fmt.Errorf("deny list: %w", err)
+	}
+	return &Policy{orgID: orgID, allow: allowRules, deny: denyRules}, nil
+}
+
+// Load reads an organization's policy. Organizations without one get the defaults.
+func Load(orgID string) (*Policy, error) {
+	if orgID == "" {
+		return New("", nil, nil)
+	}
+	var rows []Rules
+	err := database.GetClient().DB.From("org_egress_policies").Select("*").Eq("org_id", orgID).Execute(&rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load egress policy: %w", err)
+	}
+	if len(rows) == 0 {
+		return New(orgID, nil, nil)
+	}
+	return New(orgID, rows[0].Allow, rows[0].Deny)
+}
+
+// WithOrigin returns a copy of the policy whose blocked attempts are logged with
+// details (which node, flow or profile made the call).
+func (p *Policy) WithOrigin(details map[string]interface{}) *Policy {
+	copied := *p
+	copied.origin = details
+	return &copied
+}
+
+// CheckURL checks a URL before anything is resolved: its scheme, and its host by name
+// (or by address, for IP literals).
+func (p *Policy) CheckURL(ctx context.Context, u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return p.block(ctx, u.Redacted(), nil, fmt.Sprintf("scheme %q is not allowed", u.Scheme))
+	}
+	host := u.Hostname()
+	if host == "" {
+		return p.block(ctx, u.Host, nil, "URL has no host")
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if reason := p.check(host, ip); reason != "" {
+			return p.block(ctx, host, ip, reason)
+		}
+		return nil
+	}
+	if reason := p.checkName(host); reason != "" {
+		return p.block(ctx, host, nil, reason)
+	}
+	return nil
+}
+
+// checkName applies what can be decided from a host name alone.
+func (p *Policy) checkName(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if metadataHosts[host] {
+		return "cloud metadata endpoint"
+	}
+	if matchAny(p.deny, host, nil) {
+		return "denied by the organization's egress policy"
+	}
+	if len(p.allow) > 0 && !hasNetworks(p.allow) && !matchAny(p.allow, host, nil) {
+		return "not in the organization's egress allow list"
+	}
+	return ""
+}
+
+// check decides on a host and one of its addresses. It returns why the destination is
+// blocked, or "" when it is allowed.
+func (p *Policy) check(host string, ip net.IP) string {
+	if inNetworks(metadataNetworks, ip) {
+		return "cloud metadata endpoint"
+	}
+	if reason := p.checkName(host); reason != "" {
+		return reason
+	}
+	if inNetworks(reservedNetworks, ip) && !inNetworks(operatorNetworks(), ip) {
+		if ip.IsLoopback() {
+			return "loopback address"
+		}
+		return "private or reserved address"
+	}
+	if matchAny(p.deny, host, ip) {
+		return "denied by the organization's egress policy"
+	}
+	if len(p.allow) > 0 && !matchAny(p.allow, host, ip) {
+		return "not in the organization's egress allow list"
+	}
+	return ""
+}
+
+// operatorNetworks reads EGRESS_ALLOWED_NETWORKS. Invalid entries are ignored.
+func operatorNetworks() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(os.Getenv(AllowedNetworksEnv), ",") {
+		if r, err := parseRule(entry); err == nil && r.network != nil {
+			networks = append(networks, r.network)
+		}
+	}
+	return networks
+}
+
+// DialContext resolves addr, checks every address it resolves to and connects to the
+// first allowed one. A host with any blocked address is blocked altogether.
+func (p *Policy) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		if reason := p.checkName(host); reason != "" {
+			return nil, p.block(ctx, host, nil, reason)
+		}
+		addrs, err := lookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			ips = append(ips, a.IP)
+		}
+	}
+	for _, ip := range ips {
+		if reason := p.check(host, ip); reason != "" {
+			return nil, p.block(ctx, host, ip, reason)
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	var lastErr error
+	for _, ip := range ips {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no address found for %s", host)
+	}
+	return nil, lastErr
+}
+
+// Client returns an HTTP client whose connections and redirects follow the policy.
+// Proxies from the environment are not used: they would connect on the policy's behalf.
+func (p *Policy) Client(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:           p.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return p.CheckURL(req.Context(), req.URL)
+		},
+	}
+}
+
+// block logs a blocked attempt and returns its error.
+func (p *Policy) block(ctx context.Context, host string, ip net.IP, reason string) error {
+	blocked := &BlockedError{Host: host, Reason: reason}
+	if ip != nil {
+		blocked.IP = ip.String()
+	}
+	if p.orgID != "" {
+		details := map[string]interface{}{
+			"host":   blocked.Host,
+			"reason": blocked.Reason,
+		}
+		if blocked.IP != "" {
+			details["ip"] = blocked.IP
+		}
+		for k, v := range p.origin {
+			details[k] = v
+		}
+		if err := audit.LogActivity(ctx, p.orgID, nil, EventBlocked, nil, details, ""); err != nil {
+			log.Printf("Failed to audit blocked egress to %s for org %s: %v", host, p.orgID, err)
+		}
+	}
+	return blocked
+}
@@ -0,0 +1,275 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func mustPolicy(t *testing.T, allow, deny []string) *Policy {
+	t.Helper()
+	p, err := New("", allow, deny)
+	if err != nil {
+		t.Fatalf("New(%v, %v): %v", allow, deny, err)
+	}
+	return p
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name            string
+		allowedNetworks string // EGRESS_ALLOWED_NETWORKS
+		allow, deny     []string
+		host            string
+		ip              string
+		want            string // "" when allowed
+	}{
+		// Defaults
+		{"public address", "", nil, nil, "api.example.com", "93.184.216.34", ""},
+		{"loopback", "", nil, nil, "localhost", "127.0.0.1", "loopback address"},
+		{"IPv6 loopback", "", nil, nil, "localhost", "::1", "loopback address"},
+		{"10/8", "", nil, nil, "db.internal", "10.1.2.3", "private or reserved address"},
+		{"172.16/12", "", nil, nil, "db.internal", "172.31.255.1", "private or reserved address"},
+		{"192.168/16", "", nil, nil, "router", "192.168.1.1", "private or reserved address"},
+		{"carrier-grade NAT", "", nil, nil, "cgn", "100.64.0.1", "private or reserved address"},
+		{"IPv6 unique local", "", nil, nil, "svc", "fd12:3456::1", "private or reserved address"},
+		{"IPv6 link-local", "", nil, nil, "svc", "fe80::1", "private or reserved address"},
+		{"NAT64 of a private address", "", nil, nil, "svc", "64:ff9b::a00:1", "private or reserved address"},
+		{"IPv4-mapped loopback", "", nil, nil, "svc", "::ffff:127.0.0.1", "loopback address"},
+		{"IPv4-mapped private", "", nil, nil, "svc", "::ffff:10.0.0.1", "private or reserved address"},
+		{"IPv4-mapped metadata", "", nil, nil, "svc", "::ffff:169.254.169.254", "cloud metadata endpoint"},
+		{"IPv4-mapped public", "", nil, nil, "svc", "::ffff:93.184.216.34", ""},
+		{"metadata address", "", nil, nil, "whatever", "169.254.169.254", "cloud metadata endpoint"},
+		{"ECS metadata address", "", nil, nil, "whatever", "169.254.170.2", "cloud metadata endpoint"},
+		{"IPv6 metadata address", "", nil, nil, "whatever", "fd00:ec2::254", "cloud metadata endpoint"},
+		{"metadata name", "", nil, nil, "Metadata.Google.Internal.", "93.184.216.34", "cloud metadata endpoint"},
+
+		// EGRESS_ALLOWED_NETWORKS opens reserved ranges, never metadata
+		{"operator network", "10.0.0.0/8", nil, nil, "db.internal", "10.1.2.3", ""},
+		{"outside the operator network", "10.0.0.0/8", nil, nil, "db.internal", "192.168.1.1", "private or reserved address"},
+		{"operator opens link-local", "169.254.0.0/16", nil, nil, "svc", "169.254.1.1", ""},
+		{"metadata despite link-local opened", "169.254.0.0/16", nil, nil, "svc", "169.254.169.254", "cloud metadata endpoint"},
+		{"metadata despite everything opened", "0.0.0.0/0, ::/0", nil, nil, "svc", "169.254.169.254", "cloud metadata endpoint"},
+		{"metadata name despite everything opened", "0.0.0.0/0", nil, nil, "metadata", "10.0.0.1", "cloud metadata endpoint"},
+		{"invalid operator entries are ignored", "nonsense, 10.0.0.0/8", nil, nil, "db", "10.0.0.1", ""},
+		{"org allow list doesn't open reserved ranges", "", []string{"10.0.0.0/8"}, nil, "db", "10.0.0.1", "private or reserved address"},
+
+		// Deny lists
+		{"denied host", "", nil, []string{"evil.com"}, "EVIL.com", "93.184.216.34", "denied by the organization's egress policy"},
+		{"denied subdomain", "", nil, []string{"*.evil.com"}, "a.b.evil.com", "93.184.216.34", "denied by the organization's egress policy"},
+		{"wildcard doesn't deny the apex", "", nil, []string{"*.evil.com"}, "evil.com", "93.184.216.34", ""},
+		{"denied network", "", nil, []string{"93.184.216.0/24"}, "api.example.com", "93.184.216.34", "denied by the organization's egress policy"},
+		{"denied address", "", nil, []string{"93.184.216.34"}, "api.example.com", "93.184.216.34", "denied by the organization's egress policy"},
+
+		// Allow lists with only names
+		{"allowed name", "", []string{"api.example.com"}, nil, "api.example.com", "93.184.216.34", ""},
+		{"name not allowed", "", []string{"api.example.com"}, nil, "other.com", "93.184.216.34", "not in the organization's egress allow list"},
+		{"allowed subdomain", "", []string{"*.example.com"}, nil, "v2.api.example.com", "93.184.216.34", ""},
+		{"allowed name at a private address", "", []string{"api.example.com"}, nil, "api.example.com", "10.0.0.1", "private or reserved address"},
+
+		// Allow lists with only networks
+		{"allowed network", "", []string{"93.184.216.0/24"}, nil, "any.name", "93.184.216.34", ""},
+		{"outside the allowed network", "", []string{"93.184.216.0/24"}, nil, "any.name", "1.1.1.1", "not in the organization's egress allow list"},
+		{"allowed IPv6 network", "", []string{"2606:2800::/32"}, nil, "any.name", "2606:2800:220:1::1", ""},
+
+		// Deny wins over allow
+		{"denied and allowed", "", []string{"*.example.com"}, []string{"admin.example.com"}, "admin.example.com", "93.184.216.34", "denied by the organization's egress policy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(AllowedNetworksEnv, tt.allowedNetworks)
+			p := mustPolicy(t, tt.allow, tt.deny)
+			ip := net.ParseIP(tt.ip)
+			if ip == nil {
+				t.Fatalf("bad test address %q", tt.ip)
+			}
+			if got := p.check(tt.host, ip); got != tt.want {
+				t.Errorf("check(%s, %s) = %q, want %q", tt.host, tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		allow   []string
+		deny    []string
+		url     string
+		blocked bool
+	}{
+		{"public host", nil, nil, "https://api.example.com/v1", false},
+		{"scheme", nil, nil, "file:///etc/passwd", true},
+		{"gopher", nil, nil, "gopher://api.example.com", true},
+		{"loopback literal", nil, nil, "http://127.0.0.1:8080/", true},
+		{"IPv6 loopback literal", nil, nil, "http://[::1]/", true},
+		{"IPv4-mapped literal", nil, nil, "http://[::ffff:127.0.0.1]/", true},
+		{"IPv4-mapped metadata in hex", nil, nil, "http://[::ffff:a9fe:a9fe]/latest/meta-data", true},
+		{"metadata literal", nil, nil, "http://169.254.169.254/latest/meta-data", true},
+		{"metadata name", nil, nil, "http://metadata.google.internal/computeMetadata/v1/", true},
+		{"denied name", nil, []string{"evil.com"}, "https://evil.com/x", true},
+
+		// Names-only allow lists decide before resolution
+		{"name not in a names-only allow list", []string{"api.example.com"}, nil, "https://other.com/", true},
+		{"literal not in a names-only allow list", []string{"api.example.com"}, nil, "https://93.184.216.34/", true},
+		{"name in a names-only allow list", []string{"api.example.com"}, nil, "https://api.example.com/", false},
+
+		// Network allow lists can only decide once the host is resolved
+		{"name with a networks-only allow list", []string{"93.184.216.0/24"}, nil, "https://other.com/", false},
+		{"literal in a networks-only allow list", []string{"93.184.216.0/24"}, nil, "https://93.184.216.34/", false},
+		{"literal outside a networks-only allow list", []string{"93.184.216.0/24"}, nil, "https://1.1.1.1/", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(AllowedNetworksEnv, "")
+			p := mustPolicy(t, tt.allow, tt.deny)
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = p.CheckURL(context.Background(), u)
+			if IsBlocked(err) != tt.blocked {
+				t.Errorf("CheckURL(%s) = %v, want blocked %v", tt.url, err, tt.blocked)
+			}
+			if err != nil && !IsBlocked(err) {
+				t.Errorf("CheckURL(%s) failed without a BlockedError: %v", tt.url, err)
+			}
+		})
+	}
+}
+
+// fakeResolver answers DialContext's lookups for the duration of a test.
+func fakeResolver(t *testing.T, answers map[string][]string) {
+	t.Helper()
+	original := lookupIPAddr
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		var addrs []net.IPAddr
+		for _, a := range answers[host] {
+			addrs = append(addrs, net.IPAddr{IP: net.ParseIP(a)})
+		}
+		if len(addrs) == 0 {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return addrs, nil
+	}
+	t.Cleanup(func() { lookupIPAddr = original })
+}
+
+func TestDialContextChecksEveryAddress(t *testing.T) {
+	t.Setenv(AllowedNetworksEnv, "")
+	fakeResolver(t, map[string][]string{
+		"rebind.example.com":   {"93.184.216.34", "10.0.0.7"},
+		"metadata.example.com": {"169.254.169.254"},
+		"mapped.example.com":   {"::ffff:127.0.0.1"},
+	})
+	p := mustPolicy(t, nil, nil)
+
+	tests := []struct {
+		addr   string
+		wantIP string
+	}{
+		{"rebind.example.com:443", "10.0.0.7"},
+		{"metadata.example.com:80", "169.254.169.254"},
+		{"mapped.example.com:80", "127.0.0.1"},
+		{"127.0.0.1:80", "127.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			conn, err := p.DialContext(context.Background(), "tcp", tt.addr)
+			if conn != nil {
+				conn.Close()
+			}
+			var blocked *BlockedError
+			if !errors.As(err, &blocked) {
+				t.Fatalf("DialContext(%s) = %v, want a BlockedError", tt.addr, err)
+			}
+			if blocked.IP != tt.wantIP {
+				t.Errorf("blocked IP = %q, want %q", blocked.IP, tt.wantIP)
+			}
+		})
+	}
+
+	// Names are checked before they are resolved
+	p = mustPolicy(t, []string{"api.example.com"}, nil)
+	_, err := p.DialContext(context.Background(), "tcp", "unresolvable.test:443")
+	if !IsBlocked(err) {
+		t.Errorf("DialContext to a name outside the allow list = %v, want blocked before resolution", err)
+	}
+}
+
+func TestClientChecksRedirects(t *testing.T) {
+	// Open loopback so the test server itself is reachable
+	t.Setenv(AllowedNetworksEnv, "127.0.0.0/8, ::1/128")
+
+	var hits []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits = append(hits, r.URL.Path)
+		switch r.URL.Path {
+		case "/to-metadata":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+		case "/to-denied":
+			http.Redirect(w, r, "http://evil.com/", http.StatusFound)
+		case "/to-private":
+			http.Redirect(w, r, "http://10.0.0.1/", http.StatusMovedPermanently)
+		case "/to-ok":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	client := mustPolicy(t, nil, []string{"evil.com"}).Client(5 * time.Second)
+
+	for _, path := range []string{"/to-metadata", "/to-denied", "/to-private"} {
+		t.Run(path, func(t *testing.T) {
+			resp, err := client.Get(server.URL + path)
+			if resp != nil {
+				resp.Body.Close()
+			}
+			if !IsBlocked(err) {
+				t.Errorf("GET %s = %v, want the redirect blocked", path, err)
+			}
+		})
+	}
+
+	t.Run("allowed redirect", func(t *testing.T) {
+		hits = nil
+		resp, err := client.Get(server.URL + "/to-ok")
+		if err != nil {
+			t.Fatalf("GET /to-ok: %v", err)
+		}
+		resp.Body.Close()
+		if len(hits) != 2 || hits[1] != "/ok" {
+			t.Errorf("requests = %v, want the redirect followed", hits)
+		}
+	})
+
+	t.Run("redirect limit", func(t *testing.T) {
+		resp, err := client.Get(server.URL + "/loop")
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if err == nil || IsBlocked(err) {
+			t.Errorf("GET /loop = %v, want the redirect limit error", err)
+		}
+	})
+}
+
+func TestNewRejectsInvalidEntries(t *testing.T) {
+	for _, entry := range []string{"", "10.0.0.0/33", "http://x.com", "a b", "*."} {
+		if _, err := New("", []string{entry}, nil); err == nil {
+			t.Errorf("New accepted allow entry %q", entry)
+		}
+	}
+	if err := ValidateEntries([]string{"api.example.com", "*.example.com", "10.0.0.0/8", "[::1]", "1.2.3.4"}); err != nil {
+		t.Errorf("ValidateEntries: %v", err)
+	}
+}
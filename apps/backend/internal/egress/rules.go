@@ -0,0 +1,176 @@
+package egress
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// rule is one entry of an allow or deny list: a host name ("api.example.com"), a
+// wildcard for its subdomains ("*.example.com"), an IP address or a CIDR network.
+type rule struct {
+	host     string
+	wildcard bool
+	network  *net.IPNet
+}
+
+func parseRule(entry string) (rule, error) {
+	entry = strings.ToLower(strings.TrimSpace(entry))
+	entry = strings.TrimSuffix(strings.TrimPrefix(entry, "["), "]")
+	if entry == "" {
+		return rule{}, fmt.Errorf("empty entry")
+	}
+	if strings.Contains(entry, "/") {
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return rule{}, fmt.Errorf("invalid network %q", entry)
+		}
+		return rule{network: network}, nil
+	}
+	if ip := net.ParseIP(entry); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		return rule{network: &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}}, nil
+	}
+	if strings.HasPrefix(entry, "*.") {
+		return rule{host: entry[2:], wildcard: true}, validHost(entry[2:])
+	}
+	return rule{host: entry}, validHost(entry)
+}
+
+func validHost(host string) error {
+	if host == "" || strings.ContainsAny(host, " :/?#@*") {
+		return fmt.Errorf("invalid host %q", host)
+	}
+	return nil
+}
+
+func parseRules(entries []string) ([]rule, error) {
+	rules := make([]rule, 0, len(entries))
+	for _, entry := range entries {
+		r, err := parseRule(entry)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func (r rule) matchHost(host string) bool {
+	if r.network != nil {
+		if ip := net.ParseIP(host); ip != nil {
+			return r.network.Contains(ip)
+		}
+		return false
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if r.wildcard {
+		return strings.HasSuffix(host, "."+r.host)
+	}
+	return host == r.host
+}
+
+func (r rule) matchIP(ip net.IP) bool {
+	return r.network != nil && ip != nil && r.network.Contains(ip)
+}
+
+func matchAny(rules []rule, host string, ip net.IP) bool {
+	for _, r := range rules {
+		if r.matchHost(host) || r.matchIP(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNetworks tells whether some rule can only be decided once the address is known.
+func hasNetworks(rules []rule) bool {
+	for _, r := range rules {
+		if r.network != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateEntries checks an allow or deny list before it is stored.
+func ValidateEntries(entries []string) error {
+	_, err := parseRules(entries)
+	return err
+}
+
+func mustNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// reservedNetworks are blocked unless the operator opens them with EGRESS_ALLOWED_NETWORKS.
+var reservedNetworks = mustNetworks(
+	"0.0.0.0/8",       // "this network"
+	"10.0.0.0/8",      // private
+	"100.64.0.0/10",   // carrier-grade NAT
+	"127.0.0.0/8",     // loopback
+	"169.254.0.0/16",  // link-local
+	"172.16.0.0/12",   // private
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // documentation
+	"192.168.0.0/16",  // private
+	"198.18.0.0/15",   // benchmarking
+	"198.51.100.0/24", // documentation
+	"203.0.113.0/24",  // documentation
+	"224.0.0.0/4",     // multicast
+	"240.0.0.0/4",     // reserved, broadcast
+	"::/128",          // unspecified
+	"::1/128",         // loopback
+	"64:ff9b::/96",    // NAT64, would reach IPv4 ranges above
+	"100::/64",        // discard
+	"2001:db8::/32",   // documentation
+	"fc00::/7",        // unique local
+	"fe80::/10",       // link-local
+	"ff00::/8",        // multicast
+)
+
+// metadataNetworks are cloud instance metadata endpoints. They hand out the platform's
+// credentials, so nothing opens them.
+var metadataNetworks = mustNetworks(
+	"169.254.169.254/32", // AWS, GCP, Azure, OpenStack...
+	"169.254.170.2/32",   // AWS ECS task metadata
+	"100.100.100.200/32", // Alibaba Cloud
+	"fd00:ec2::254/128",  // AWS over IPv6
+)
+
+// metadataHosts are names of metadata endpoints, blocked before they are even resolved.
+var metadataHosts = map[string]bool{
+	"metadata.google.internal": true,
+	"metadata.goog":            true,
+	"metadata":                 true,
+	"instance-data":            true,
+}
+
+func inNetworks(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockedByDefault lists the networks blocked for every organization, for display.
+func BlockedByDefault() []string {
+	var list []string
+	for _, n := range append(append([]*net.IPNet{}, metadataNetworks...), reservedNetworks...) {
+		list = append(list, n.String())
+	}
+	return list
+}
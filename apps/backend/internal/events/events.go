@@ -0,0 +1,165 @@
+// Package events fans run events (node progress, human tasks, run state changes) out to
+// live subscribers such as the SSE endpoints.
+//
+// Events are published by the workflow activities as the engine runs, so a subscriber sees
+// them as they happen. The broker lives in memory: it serves the API process that also runs
+// the Temporal worker (cmd/api), and keeps a bounded history for clients resuming with
+// Last-Event-ID.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	RunStarted    = "run.started"
+	RunCompleted  = "run.completed"
+	RunFailed     = "run.failed"
+	RunCanceled   = "run.canceled"
+	RunTerminated = "run.terminated"
+	RunPaused     = "run.paused"
+	RunResumed    = "run.resumed"
+
+	NodeStarted   = "node.started"
+	NodeCompleted = "node.completed"
+	NodeFailed    = "node.failed"
+	NodePaused    = "node.paused"
+	NodeCanceled  = "node.canceled"
+
+	TaskCreated   = "task.created"
+	TaskCompleted = "task.completed"
+)
+
+// historySize is how many recent events are kept for resuming subscribers.
+const historySize = 2000
+
+// subscriberBuffer is how far a subscriber may fall behind before it is dropped.
+// Dropped subscribers reconnect with Last-Event-ID and catch up from the history.
+const subscriberBuffer = 256
+
+// Event is one change in a run.
+type Event struct {
+	ID         uint64                 `json:"id"`
+	Type       string                 `json:"type"`
+	OrgID      string                 `json:"org_id"`
+	WorkflowID string                 `json:"workflow_id,omitempty"`
+	RunID      string                 `json:"run_id,omitempty"`
+	NodeID     string                 `json:"node_id,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Time       time.Time              `json:"time"`
+}
+
+// IsRunTerminal reports whether the event ends its run.
+func (e Event) IsRunTerminal() bool {
+	switch e.Type {
+	case RunCompleted, RunFailed, RunCanceled, RunTerminated:
+		return true
+	}
+	return false
+}
+
+// Subscription receives the events matching its filter until closed.
+type Subscription struct {
+	C <-chan Event
+
+	ch     chan Event
+	filter func(Event) bool
+	broker *Broker
+	once   sync.Once
+}
+
+// Close stops the subscription and closes C.
+func (s *Subscription) Close() {
+	s.broker.remove(s)
+}
+
+// Broker assigns event IDs, keeps the recent history and delivers events to subscribers.
+type Broker struct {
+	mu      sync.Mutex
+	nextID  uint64
+	history []Event
+	subs    map[*Subscription]struct{}
+}
+
+// NewBroker returns an empty broker. Event IDs start from the current time so that they
+// keep increasing across restarts, which keeps stale Last-Event-IDs harmless.
+func NewBroker() *Broker {
+	return &Broker{
+		nextID: uint64(time.Now().UnixMilli()) * 1000,
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+var defaultBroker = NewBroker()
+
+// Publish sends an event through the default broker.
+func Publish(e Event) { defaultBroker.Publish(e) }
+
+// Subscribe subscribes to the default broker (see Broker.Subscribe).
+func Subscribe(filter func(Event) bool, lastEventID uint64) (*Subscription, []Event) {
+	return defaultBroker.Subscribe(filter, lastEventID)
+}
+
+// Publish records e and delivers it to the matching subscribers. It never blocks:
+// subscribers that fell behind are dropped.
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	e.ID = b.nextID
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.history = append(b.history, e)
+	if len(b.history) > historySize {
+		b.history = b.history[len(b.history)-historySize:]
+	}
+
+	for sub := range b.subs {
+		if !sub.filter(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			b.drop(sub)
+		}
+	}
+}
+
+// Subscribe returns a subscription to the events matching filter, along with the
+// retained events after lastEventID (0 for none) that match it.
+func (b *Broker) Subscribe(filter func(Event) bool, lastEventID uint64) (*Subscription, []Event) {
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, filter: filter, broker: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var backlog []Event
+	if lastEventID > 0 {
+		for _, e := range b.history {
+			if e.ID > lastEventID && filter(e) {
+				backlog = append(backlog, e)
+			}
+		}
+	}
+	b.subs[sub] = struct{}{}
+	return sub, backlog
+}
+
+func (b *Broker) remove(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.drop(sub)
+}
+
+// drop unregisters sub and closes its channel. b.mu must be held.
+func (b *Broker) drop(sub *Subscription) {
+	delete(b.subs, sub)
+	sub.once.Do(func() { close(sub.ch) })
+}
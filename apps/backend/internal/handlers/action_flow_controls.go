@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/audit"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/events"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/middleware"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/workflow"
+	"go.temporal.io/api/serviceerror"
+)
+
+// runControl describes one operator action on a running action flow.
+type runControl struct {
+	name       string   // Used in messages and the audit event ("flow.<name>")
+	fromStatus []string // action_flows statuses the action applies to
+	toStatus   string
+	closesRun  bool // Cancel the run's pending human tasks / automation waits
+}
+
+var (
+	cancelControl    = runControl{name: "canceled", fromStatus: []string{"RUNNING", "PAUSED"}, toStatus: "CANCELED", closesRun: true}
+	terminateControl = runControl{name: "terminated", fromStatus: []string{"RUNNING", "PAUSED"}, toStatus: "TERMINATED", closesRun: true}
+	pauseControl     = runControl{name: "paused", fromStatus: []string{"RUNNING"}, toStatus: "PAUSED"}
+	resumeControl    = runControl{name: "resumed", fromStatus: []string{"PAUSED"}, toStatus: "RUNNING"}
+)
+
+// CancelActionFlow asks the run to stop gracefully: running nodes are canceled and
+// NodalWorkflow closes its record and open waits before finishing.
+// POST /api/action-flows/{id}/cancel
+func (h *ActionFlowHandler) CancelActionFlow(w http.ResponseWriter, r *http.Request) {
+	h.controlRun(w, r, cancelControl)
+}
+
+// TerminateActionFlow stops the run immediately, without running any workflow code.
+// POST /api/action-flows/{id}/terminate
+func (h *ActionFlowHandler) TerminateActionFlow(w http.ResponseWriter, r *http.Request) {
+	h.controlRun(w, r, terminateControl)
+}
+
+// PauseActionFlow holds the run at the next node boundary; nodes already running finish.
+// POST /api/action-flows/{id}/pause
+func (h *ActionFlowHandler) PauseActionFlow(w http.ResponseWriter, r *http.Request) {
+	h.controlRun(w, r, pauseControl)
+}
+
+// ResumeActionFlow releases a paused run.
+// POST /api/action-flows/{id}/resume
+func (h *ActionFlowHandler) ResumeActionFlow(w http.ResponseWriter, r *http.Request) {
+	h.controlRun(w, r, resumeControl)
+}
+
+func (h *ActionFlowHandler) controlRun(w http.ResponseWriter, r *http.Request, action runControl) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "Missing ID", http.StatusBadRequest)
+		return
+	}
+	if h.TemporalClient == nil {
+		http.Error(w, "Temporal client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// 1. Load the run and check the caller may act on it
+	dbClient := database.GetClient()
+	var runs []struct {
+		OrgID              string `json:"org_id"`
+		TemporalWorkflowID string `json:"temporal_workflow_id"`
+		RunID              string `json:"run_id"`
+		Status             string `json:"status"`
+	}
+	err := dbClient.DB.From("action_flows").Select("org_id, temporal_workflow_id, run_id, status").Eq("id", id).Execute(&runs)
+	if err != nil || len(runs) == 0 {
+		http.Error(w, "Action Flow not found", http.StatusNotFound)
+		return
+	}
+	run := runs[0]
+	if !requireOrgMember(w, r, run.OrgID) {
+		return
+	}
+
+	allowed := false
+	for _, s := range action.fromStatus {
+		if run.Status == s {
+			allowed = true
+		}
+	}
+	if !allowed {
+		http.Error(w, fmt.Sprintf("Cannot mark a %s action flow as %s", run.Status, action.name), http.StatusConflict)
+		return
+	}
+
+	// 2. Act on the Temporal run
+	userID, _ := r.Context().Value(middleware.UserIDKey).(string)
+	switch action.name {
+	case cancelControl.name:
+		err = h.TemporalClient.CancelWorkflow(r.Context(), run.TemporalWorkflowID, run.RunID)
+	case terminateControl.name:
+		reason := req.Reason
+		if reason == "" {
+			reason = "Terminated by " + userID
+		}
+		err = h.TemporalClient.TerminateWorkflow(r.Context(), run.TemporalWorkflowID, run.RunID, reason)
+	case pauseControl.name:
+		err = h.TemporalClient.SignalWorkflow(r.Context(), run.TemporalWorkflowID, run.RunID, workflow.PauseSignal, req.Reason)
+	case resumeControl.name:
+		err = h.TemporalClient.SignalWorkflow(r.Context(), run.TemporalWorkflowID, run.RunID, workflow.ResumeSignal, req.Reason)
+	}
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			http.Error(w, "Run is no longer active", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to update run: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 3. Keep the record (and anything waiting on the run) consistent right away.
+	// A canceled run repeats this from its cleanup step, which is harmless.
+	update := map[string]interface{}{"status": action.toStatus}
+	if action.closesRun {
+		update["completed_at"] = time.Now()
+	}
+	var updated []map[string]interface{}
+	if err := dbClient.DB.From("action_flows").Update(update).Eq("id", id).Execute(&updated); err != nil {
+		http.Error(w, "Run updated but its status could not be saved: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if action.closesRun {
+		if err := workflow.CancelPendingWaits(r.Context(), run.RunID); err != nil {
+			log.Printf("Failed to cancel pending waits of run %s: %v", run.RunID, err)
+		}
+	}
+	// A canceled run announces itself once its cleanup ran
+	if action.name != cancelControl.name {
+		events.Publish(events.Event{
+			Type:       workflow.RunEventType(action.toStatus),
+			OrgID:      run.OrgID,
+			WorkflowID: run.TemporalWorkflowID,
+			RunID:      run.RunID,
+			Data:       map[string]interface{}{"status": action.toStatus, "reason": req.Reason},
+		})
+	}
+
+	var userPtr *string
+	if userID != "" {
+		userPtr = &userID
+	}
+	audit.LogActivity(r.Context(), run.OrgID, userPtr, "flow."+action.name, &id, map[string]interface{}{
+		"action_flow_id": id,
+		"workflow_id":    run.TemporalWorkflowID,
+		"previous":       run.Status,
+		"reason":         req.Reason,
+	}, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":     id,
+		"status": action.toStatus,
+	})
+}
+
+// SkipWait ends the wait of a DELAY or WAIT-UNTIL node now; the run continues from it.
+// POST /api/action-flows/{id}/nodes/{nodeId}/skip-wait
+func (h *ActionFlowHandler) SkipWait(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	nodeID := r.PathValue("nodeId")
+	if id == "" || nodeID == "" {
+		http.Error(w, "Missing ID", http.StatusBadRequest)
+		return
+	}
+	if h.TemporalClient == nil {
+		http.Error(w, "Temporal client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	var runs []struct {
+		OrgID              string `json:"org_id"`
+		TemporalWorkflowID string `json:"temporal_workflow_id"`
+		RunID              string `json:"run_id"`
+	}
+	err := database.GetClient().DB.From("action_flows").Select("org_id, temporal_workflow_id, run_id").Eq("id", id).Execute(&runs)
+	if err != nil || len(runs) == 0 {
+		http.Error(w, "Action Flow not found", http.StatusNotFound)
+		return
+	}
+	run := runs[0]
+	if !requireOrgMember(w, r, run.OrgID) {
+		return
+	}
+
+	// Only a node that is on its timer right now can be skipped
+	encoded, err := h.TemporalClient.QueryWorkflow(r.Context(), run.TemporalWorkflowID, run.RunID, workflow.StateQuery)
+	if err != nil {
+		http.Error(w, "Failed to read the state of the run: "+err.Error(), http.StatusConflict)
+		return
+	}
+	var state struct {
+		Waiting map[string]string `json:"waiting"`
+	}
+	if err := encoded.Get(&state); err != nil {
+		http.Error(w, "Failed to decode the state of the run: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	until, waiting := state.Waiting[nodeID]
+	if !waiting {
+		http.Error(w, fmt.Sprintf("Node %s is not waiting", nodeID), http.StatusConflict)
+		return
+	}
+
+	if err := h.TemporalClient.SignalWorkflow(r.Context(), run.TemporalWorkflowID, run.RunID, workflow.SkipWaitSignal(nodeID), nil); err != nil {
+		http.Error(w, "Failed to skip wait: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var userPtr *string
+	if userID, ok := r.Context().Value(middleware.UserIDKey).(string); ok && userID != "" {
+		userPtr = &userID
+	}
+	audit.LogActivity(r.Context(), run.OrgID, userPtr, "flow.wait_skipped", &id, map[string]interface{}{
+		"action_flow_id": id,
+		"node_id":        nodeID,
+		"until":          until,
+	}, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":            id,
+		"node_id":       nodeID,
+		"skipped":       true,
+		"waiting_until": until,
+	})
+}
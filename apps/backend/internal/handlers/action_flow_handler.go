@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/workflow"
 	"go.temporal.io/sdk/client"
 )
 
@@ -214,18 +215,24 @@ func (h *ActionFlowHandler) GetActionFlow(w http.ResponseWriter, r *http.Request
 	client := database.GetClient()
 
 	type ActionFlowResult struct {
-		ID                 string           `json:"id"`
-		OrgID              string           `json:"org_id"` // Added OrgID
-		FlowID             string           `json:"flow_id"`
-		Status             string           `json:"status"`
-		TemporalWorkflowID string           `json:"temporal_workflow_id"`
-		RunID              string           `json:"run_id"`
-		InputData          map[string]any   `json:"input_data"`
-		KeyData            map[string]any   `json:"key_data"` // Added KeyData
-		Output             map[string]any   `json:"output"`
-		StartedAt          string           `json:"started_at"`
-		Priority           string           `json:"priority"`
-		Assignments        []map[string]any `json:"assignments"` // Added
+		ID                 string            `json:"id"`
+		OrgID              string            `json:"org_id"` // Added OrgID
+		FlowID             string            `json:"flow_id"`
+		Status             string            `json:"status"`
+		TemporalWorkflowID string            `json:"temporal_workflow_id"`
+		RunID              string            `json:"run_id"`
+		InputData          map[string]any    `json:"input_data"`
+		KeyData            map[string]any    `json:"key_data"` // Added KeyData
+		Output             map[string]any    `json:"output"`
+		StartedAt          string            `json:"started_at"`
+		Priority           string            `json:"priority"`
+		Assignments        []map[string]any  `json:"assignments"` // Added
+		VersionID          *string           `json:"version_id"`
+		ParentActionFlowID *string           `json:"parent_action_flow_id"`
+		ParentNodeID       *string           `json:"parent_node_id"`
+		RetryOfID          *string           `json:"retry_of_action_flow_id"`
+		RetryFromNode      *string           `json:"retry_from_node"`
+		NodeStatus         map[string]string `json:"node_status"`
 	}
 
 	var results []ActionFlowResult
@@ -248,6 +255,25 @@ func (h *ActionFlowHandler) GetActionFlow(w http.ResponseWriter, r *http.Request
 
 	af := results[0]
 
+	// Per-node statuses: stored once the run ended, asked from the workflow while it runs,
+	// along with when the DELAY / WAIT-UNTIL nodes on a timer wake up
+	nodeStatus := af.NodeStatus
+	var waitingUntil map[string]string
+	if (af.Status == "RUNNING" || af.Status == "PAUSED") && h.TemporalClient != nil {
+		if encoded, err := h.TemporalClient.QueryWorkflow(r.Context(), af.TemporalWorkflowID, af.RunID, workflow.StateQuery); err == nil {
+			var live struct {
+				NodeStatus map[string]string `json:"node_status"`
+				Waiting    map[string]string `json:"waiting"`
+			}
+			if encoded.Get(&live) == nil {
+				if nodeStatus == nil {
+					nodeStatus = live.NodeStatus
+				}
+				waitingUntil = live.Waiting
+			}
+		}
+	}
+
 	// Fetch Flow Name and OrgID
 	var flowName string = "Unknown Flow"
 	var flowOrgID string = ""
@@ -300,63 +326,79 @@ func (h *ActionFlowHandler) GetActionFlow(w http.ResponseWriter, r *http.Request
 	nodeDataMap := make(map[string]map[string]interface{}) // Store node data for future stubs
 	nodeTypeMap := make(map[string]string)                 // Store node type
 
-	if af.FlowID != "" {
+	// Runs pinned to a version are rendered against that snapshot, not the current definition
+	var flowVersion int
+	if af.VersionID != nil {
+		var versions []struct {
+			Version    int                    `json:"version"`
+			Definition map[string]interface{} `json:"definition"`
+		}
+		client.DB.From("flow_versions").Select("version, definition").Eq("id", *af.VersionID).Execute(&versions)
+		if len(versions) > 0 {
+			flowVersion = versions[0].Version
+			flowDefs = append(flowDefs, struct {
+				Definition map[string]interface{} `json:"definition"`
+			}{Definition: versions[0].Definition})
+		}
+	}
+
+	if af.FlowID != "" && len(flowDefs) == 0 {
 		client.DB.From("flows").Select("definition").Eq("id", af.FlowID).Execute(&flowDefs)
+	}
 
-		if len(flowDefs) > 0 && flowDefs[0].Definition != nil {
-			// Calculate Node Depths (Step Numbers)
-			if edgesList, ok := flowDefs[0].Definition["edges"].([]interface{}); ok {
-				adj := make(map[string][]string)
-				inDegree := make(map[string]int)
-
-				// Build Adjacency List & In-Degree
-				for _, e := range edgesList {
-					if eMap, ok := e.(map[string]interface{}); ok {
-						src, _ := eMap["source"].(string)
-						tgt, _ := eMap["target"].(string)
-						if src != "" && tgt != "" {
-							adj[src] = append(adj[src], tgt)
-							inDegree[tgt]++
-						}
+	if len(flowDefs) > 0 && flowDefs[0].Definition != nil {
+		// Calculate Node Depths (Step Numbers)
+		if edgesList, ok := flowDefs[0].Definition["edges"].([]interface{}); ok {
+			adj := make(map[string][]string)
+			inDegree := make(map[string]int)
+
+			// Build Adjacency List & In-Degree
+			for _, e := range edgesList {
+				if eMap, ok := e.(map[string]interface{}); ok {
+					src, _ := eMap["source"].(string)
+					tgt, _ := eMap["target"].(string)
+					if src != "" && tgt != "" {
+						adj[src] = append(adj[src], tgt)
+						inDegree[tgt]++
 					}
 				}
+			}
 
-				// Find Start Nodes (Any node with In-Degree 0)
-				var queue []string
-				visited := make(map[string]bool)
-
-				if nodesList, ok := flowDefs[0].Definition["nodes"].([]interface{}); ok {
-					for _, n := range nodesList {
-						if nMap, ok := n.(map[string]interface{}); ok {
-							nID, _ := nMap["id"].(string)
-							nType, _ := nMap["type"].(string)
-							nData, _ := nMap["data"].(map[string]interface{})
-
-							// Store for later lookups
-							nodeDataMap[nID] = nData
-							nodeTypeMap[nID] = nType
-
-							if inDegree[nID] == 0 {
-								queue = append(queue, nID)
-								visited[nID] = true
-								nodeDepths[nID] = 0
-							}
+			// Find Start Nodes (Any node with In-Degree 0)
+			var queue []string
+			visited := make(map[string]bool)
+
+			if nodesList, ok := flowDefs[0].Definition["nodes"].([]interface{}); ok {
+				for _, n := range nodesList {
+					if nMap, ok := n.(map[string]interface{}); ok {
+						nID, _ := nMap["id"].(string)
+						nType, _ := nMap["type"].(string)
+						nData, _ := nMap["data"].(map[string]interface{})
+
+						// Store for later lookups
+						nodeDataMap[nID] = nData
+						nodeTypeMap[nID] = nType
+
+						if inDegree[nID] == 0 {
+							queue = append(queue, nID)
+							visited[nID] = true
+							nodeDepths[nID] = 0
 						}
 					}
 				}
+			}
 
-				// BFS for Depths
-				for len(queue) > 0 {
-					curr := queue[0]
-					queue = queue[1:]
-					currDepth := nodeDepths[curr]
-
-					for _, neighbor := range adj[curr] {
-						if !visited[neighbor] {
-							visited[neighbor] = true
-							nodeDepths[neighbor] = currDepth + 1
-							queue = append(queue, neighbor)
-						}
+			// BFS for Depths
+			for len(queue) > 0 {
+				curr := queue[0]
+				queue = queue[1:]
+				currDepth := nodeDepths[curr]
+
+				for _, neighbor := range adj[curr] {
+					if !visited[neighbor] {
+						visited[neighbor] = true
+						nodeDepths[neighbor] = currDepth + 1
+						queue = append(queue, neighbor)
 					}
 				}
 			}
@@ -412,7 +454,8 @@ func (h *ActionFlowHandler) GetActionFlow(w http.ResponseWriter, r *http.Request
 		}
 	}
 
-	// D. Add Future Stubs (from Definition, for nodes NOT yet processed)
+	// D. Add Future Stubs (from Definition, for nodes NOT yet processed).
+	// Nodes on branches the run didn't take are shown as skipped instead.
 	// We iterate the definition nodes again to ensure order/completeness
 	if len(flowDefs) > 0 && flowDefs[0].Definition != nil {
 		if nodesList, ok := flowDefs[0].Definition["nodes"].([]interface{}); ok {
@@ -478,15 +521,20 @@ func (h *ActionFlowHandler) GetActionFlow(w http.ResponseWriter, r *http.Request
 								}
 							}
 
+							status, stubID := "PENDING_START", "future-"+nodeID
+							if nodeStatus[nodeID] == "SKIPPED" {
+								status, stubID = "SKIPPED", "skipped-"+nodeID
+							}
+
 							activities = append(activities, Activity{
 								Type:         "human_action",
 								Name:         title,
 								Description:  desc,
 								Information:  info,
 								Instructions: instr,
-								Status:       "PENDING_START",
-								StartedAt:    "", // Future
-								ID:           "future-" + nodeID,
+								Status:       status,
+								StartedAt:    "", // Future (or never, when skipped)
+								ID:           stubID,
 								Schema:       schema,
 								StepNumber:   stepNum,
 							})
@@ -544,9 +592,18 @@ func (h *ActionFlowHandler) GetActionFlow(w http.ResponseWriter, r *http.Request
 		Assignments        []map[string]any `json:"assignments"` // Added
 		InputData          map[string]any   `json:"input_data"`
 		KeyData            map[string]any   `json:"key_data"`
+		VersionID          *string          `json:"version_id"`
+		FlowVersion        int              `json:"flow_version,omitempty"`
+		ParentActionFlowID *string          `json:"parent_action_flow_id,omitempty"`
+		ParentNodeID       *string          `json:"parent_node_id,omitempty"`
+		RetryOfID          *string          `json:"retry_of_action_flow_id,omitempty"`
+		RetryFromNode      *string          `json:"retry_from_node,omitempty"`
 
-		Output     map[string]any `json:"output"`
-		Activities []Activity     `json:"activities"`
+		Output       map[string]any    `json:"output"`
+		NodeStatus   map[string]string `json:"node_status,omitempty"`
+		WaitingUntil map[string]string `json:"waiting_until,omitempty"` // node ID -> wake-up time (RFC 3339)
+		Activities   []Activity        `json:"activities"`
+		Steps        []NodeExecution   `json:"steps"`
 	}
 
 	// Use Dynamic Title or Fallback
@@ -578,9 +635,22 @@ func (h *ActionFlowHandler) GetActionFlow(w http.ResponseWriter, r *http.Request
 		Assignments:        af.Assignments,
 		InputData:          af.InputData,
 		KeyData:            af.KeyData,
-
-		Output:     af.Output,
-		Activities: activities,
+		VersionID:          af.VersionID,
+		FlowVersion:        flowVersion,
+		ParentActionFlowID: af.ParentActionFlowID,
+		ParentNodeID:       af.ParentNodeID,
+		RetryOfID:          af.RetryOfID,
+		RetryFromNode:      af.RetryFromNode,
+
+		Output:       af.Output,
+		NodeStatus:   nodeStatus,
+		WaitingUntil: waitingUntil,
+		Activities:   activities,
+	}
+
+	// Recorded step history (every node type, every attempt)
+	if steps, err := loadNodeExecutions(af.RunID); err == nil {
+		response.Steps = steps
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/audit"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/middleware"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/workflow"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+)
+
+// RetryActionFlow starts a new run that picks up where a failed (or canceled) run stopped.
+// Nodes that completed are not run again: their outputs are reused, so human tasks and
+// external side effects aren't repeated. The retry starts from the failed nodes, or from
+// "from_node" and everything after it. "input" edits the trigger payload and "outputs"
+// replaces the outputs of completed nodes.
+// POST /api/action-flows/{id}/retry
+func (h *ActionFlowHandler) RetryActionFlow(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "Missing ID", http.StatusBadRequest)
+		return
+	}
+	if h.TemporalClient == nil {
+		http.Error(w, "Temporal client not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		FromNode string                            `json:"from_node"`
+		Input    map[string]interface{}            `json:"input"`
+		Outputs  map[string]map[string]interface{} `json:"outputs"`
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// 1. Load the run being retried
+	var runs []struct {
+		OrgID              string  `json:"org_id"`
+		FlowID             *string `json:"flow_id"`
+		VersionID          *string `json:"version_id"`
+		TemporalWorkflowID string  `json:"temporal_workflow_id"`
+		RunID              string  `json:"run_id"`
+	}
+	err := database.GetClient().DB.From("action_flows").
+		Select("org_id, flow_id, version_id, temporal_workflow_id, run_id").
+		Eq("id", id).Execute(&runs)
+	if err != nil || len(runs) == 0 {
+		http.Error(w, "Action Flow not found", http.StatusNotFound)
+		return
+	}
+	run := runs[0]
+	if !requireOrgMember(w, r, run.OrgID) {
+		return
+	}
+	if run.FlowID == nil {
+		http.Error(w, "Test runs cannot be retried", http.StatusBadRequest)
+		return
+	}
+
+	// 2. Only finished runs can be retried; completed ones only from a chosen node
+	desc, err := h.TemporalClient.DescribeWorkflowExecution(r.Context(), run.TemporalWorkflowID, run.RunID)
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			http.Error(w, "Run history is no longer available", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to describe run: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	switch desc.WorkflowExecutionInfo.GetStatus() {
+	case enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING:
+		http.Error(w, "Run is still running", http.StatusConflict)
+		return
+	case enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED:
+		if req.FromNode == "" {
+			http.Error(w, "Run completed; pass from_node to run part of it again", http.StatusConflict)
+			return
+		}
+	}
+
+	// 3. The state the previous run reached (queries replay closed runs too). Runs left on
+	// the legacy task queue have no worker of this release to answer, and runs whose
+	// history this release can't replay fail the query.
+	if queue := desc.WorkflowExecutionInfo.GetTaskQueue(); queue != "" && queue != workflow.TaskQueue {
+		http.Error(w, "Run was started by an earlier release and cannot be retried; start a new run instead", http.StatusConflict)
+		return
+	}
+	encoded, err := h.TemporalClient.QueryWorkflow(r.Context(), run.TemporalWorkflowID, run.RunID, workflow.StateQuery)
+	if err != nil {
+		var queryFailed *serviceerror.QueryFailed
+		if errors.As(err, &queryFailed) {
+			http.Error(w, "The state this run reached cannot be read, so it cannot be retried; start a new run instead", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to read the state of the run: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var state struct {
+		NodeStatus map[string]interface{} `json:"node_status"`
+		Outputs    map[string]interface{} `json:"outputs"`
+	}
+	if err := encoded.Get(&state); err != nil {
+		http.Error(w, "Failed to decode the state of the run: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if state.Outputs == nil {
+		state.Outputs = make(map[string]interface{})
+	}
+
+	// 4. Retry with the definition the run executed
+	flowDef, err := retryDefinition(*run.FlowID, run.VersionID)
+	if err != nil {
+		http.Error(w, "Failed to load flow definition: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	flowDef.OrgID = run.OrgID
+
+	if req.FromNode != "" {
+		if msg := checkRetryFrom(flowDef, req.FromNode, state.NodeStatus); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+	for nodeID, output := range req.Outputs {
+		if status, _ := state.NodeStatus[nodeID].(string); status != "COMPLETED" {
+			http.Error(w, fmt.Sprintf("Cannot replace the output of %s: it did not complete", nodeID), http.StatusBadRequest)
+			return
+		}
+		// Same shape as the engine stores: flat keys plus "output"
+		merged := make(map[string]interface{}, len(output)+1)
+		for k, v := range output {
+			merged[k] = v
+		}
+		merged["output"] = output
+		state.Outputs[nodeID] = merged
+	}
+
+	input := make(map[string]interface{})
+	if trigger, ok := state.Outputs["trigger"].(map[string]interface{}); ok {
+		if body, ok := trigger["body"].(map[string]interface{}); ok {
+			for k, v := range body {
+				input[k] = v
+			}
+		}
+	}
+	for k, v := range req.Input {
+		input[k] = v
+	}
+
+	// 5. Start the retry under the same workflow ID, so status polling follows it
+	retryInput := workflow.RetryInput(*flowDef, run.RunID, req.FromNode, input, state.NodeStatus, state.Outputs)
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        run.TemporalWorkflowID,
+		TaskQueue: workflow.TaskQueue,
+	}
+	we, err := h.TemporalClient.ExecuteWorkflow(r.Context(), workflowOptions, workflow.NodalWorkflow, *flowDef, retryInput)
+	if err != nil {
+		http.Error(w, "Failed to start retry: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var userPtr *string
+	if userID, ok := r.Context().Value(middleware.UserIDKey).(string); ok && userID != "" {
+		userPtr = &userID
+	}
+	audit.LogActivity(r.Context(), run.OrgID, userPtr, "flow.retried", &id, map[string]interface{}{
+		"action_flow_id": id,
+		"workflow_id":    run.TemporalWorkflowID,
+		"retry_run_id":   we.GetRunID(),
+		"from_node":      req.FromNode,
+	}, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":     "Retry started",
+		"retry_of":    id,
+		"from_node":   req.FromNode,
+		"workflow_id": we.GetID(),
+		"run_id":      we.GetRunID(),
+		"status_url":  runStatusURL(we.GetID()),
+	})
+}
+
+// retryDefinition returns the definition a run executed: its pinned flow version, or the
+// flow's published definition for runs that predate versioning.
+func retryDefinition(flowID string, versionID *string) (*workflow.FlowDefinition, error) {
+	if versionID == nil {
+		flow, _, err := loadPublishedFlow(flowID)
+		return flow, err
+	}
+	var versions []FlowVersion
+	err := database.GetClient().DB.From("flow_versions").Select("*").Eq("id", *versionID).Execute(&versions)
+	if err != nil || len(versions) == 0 {
+		return nil, fmt.Errorf("version %s not found", *versionID)
+	}
+	flow, err := toFlowDefinition(versions[0].Definition)
+	if err != nil {
+		return nil, err
+	}
+	flow.ID = flowID
+	flow.VersionID = *versionID
+	return &flow, nil
+}
+
+// checkRetryFrom explains why a run can't be retried from nodeID ("" if it can):
+// the node must exist and its parents must have completed.
+func checkRetryFrom(flow *workflow.FlowDefinition, nodeID string, nodeStatus map[string]interface{}) string {
+	found := false
+	for _, n := range flow.Nodes {
+		if n.ID == nodeID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Sprintf("Node %s is not part of this flow", nodeID)
+	}
+	for _, edge := range flow.Edges {
+		if edge.Target != nodeID {
+			continue
+		}
+		if status, _ := nodeStatus[edge.Source].(string); status != "COMPLETED" {
+			return fmt.Sprintf("Cannot retry from %s: its parent %s did not complete", nodeID, edge.Source)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+)
+
+// NodeExecution is one attempt of a node in a run (the node_executions table).
+type NodeExecution struct {
+	ID          string                 `json:"id"`
+	NodeID      string                 `json:"node_id"`
+	NodeType    string                 `json:"node_type"`
+	Status      string                 `json:"status"`
+	Attempt     int                    `json:"attempt"`
+	LoopIndex   *int                   `json:"loop_index,omitempty"`
+	Input       map[string]interface{} `json:"input"`
+	Output      map[string]interface{} `json:"output"`
+	Error       *string                `json:"error"`
+	StartedAt   string                 `json:"started_at"`
+	CompletedAt *string                `json:"completed_at"`
+	DurationMs  *int64                 `json:"duration_ms"`
+}
+
+// ListSteps returns every node attempt of a run, in the order they started.
+// GET /api/action-flows/{id}/steps
+func (h *ActionFlowHandler) ListSteps(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "Missing ID", http.StatusBadRequest)
+		return
+	}
+
+	var runs []struct {
+		OrgID string `json:"org_id"`
+		RunID string `json:"run_id"`
+	}
+	err := database.GetClient().DB.From("action_flows").Select("org_id, run_id").Eq("id", id).Execute(&runs)
+	if err != nil || len(runs) == 0 {
+		http.Error(w, "Action Flow not found", http.StatusNotFound)
+		return
+	}
+	if !requireOrgMember(w, r, runs[0].OrgID) {
+		return
+	}
+
+	steps, err := loadNodeExecutions(runs[0].RunID)
+	if err != nil {
+		http.Error(w, "Failed to fetch steps: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"action_flow_id": id,
+		"run_id":         runs[0].RunID,
+		"steps":          steps,
+	})
+}
+
+// loadNodeExecutions reads the node attempts of a run, oldest first.
+func loadNodeExecutions(runID string) ([]NodeExecution, error) {
+	steps := []NodeExecution{}
+	err := database.GetClient().DB.From("node_executions").
+		Select("id, node_id, node_type, status, attempt, loop_index, input, output, error, started_at, completed_at, duration_ms").
+		Eq("run_id", runID).
+		Execute(&steps)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort in Go (no Order() on the PostgREST client)
+	sort.SliceStable(steps, func(i, j int) bool {
+		if steps[i].StartedAt != steps[j].StartedAt {
+			return steps[i].StartedAt < steps[j].StartedAt
+		}
+		return steps[i].Attempt < steps[j].Attempt
+	})
+	return steps, nil
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/artifacts"
+)
+
+type ArtifactsHandler struct{}
+
+func NewArtifactsHandler() *ArtifactsHandler {
+	return &ArtifactsHandler{}
+}
+
+// DownloadArtifact serves the content of a run artifact as an attachment.
+// GET /api/orgs/{orgId}/artifacts/{id}
+func (h *ArtifactsHandler) DownloadArtifact(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("orgId")
+	id := r.PathValue("id")
+	if orgID == "" || id == "" {
+		http.Error(w, "Organization ID and artifact ID required", http.StatusBadRequest)
+		return
+	}
+	if !requireOrgMember(w, r, orgID) {
+		return
+	}
+
+	artifact, data, err := artifacts.Get(orgID, id)
+	if errors.Is(err, artifacts.ErrNotFound) {
+		http.Error(w, "Artifact not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load artifact: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", artifact.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": artifact.Filename}))
+	// Artifacts come from third parties: never let the browser render them as a page
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(data)
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/audit"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/httpauth"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/middleware"
+)
+
+type AuthProfilesHandler struct{}
+
+func NewAuthProfilesHandler() *AuthProfilesHandler {
+	return &AuthProfilesHandler{}
+}
+
+type AuthProfileRequest struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Settings httpauth.Settings `json:"settings"`
+	Secret   *string           `json:"secret"` // password, token, API key or client secret; nil keeps the stored one
+}
+
+// ListAuthProfiles returns the organization's HTTP auth profiles (never their credentials).
+// GET /api/orgs/{orgId}/auth-profiles
+func (h *AuthProfilesHandler) ListAuthProfiles(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("orgId")
+	if orgID == "" {
+		http.Error(w, "Organization ID required", http.StatusBadRequest)
+		return
+	}
+	if !requireOrgMember(w, r, orgID) {
+		return
+	}
+
+	var rows []httpauth.Profile
+	err := database.GetClient().DB.From("http_auth_profiles").Select("*").Eq("org_id", orgID).Execute(&rows)
+	if err != nil {
+		http.Error(w, "Failed to list auth profiles: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	list := make([]map[string]interface{}, 0, len(rows))
+	for _, p := range rows {
+		list = append(list, redactAuthProfile(p))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// CreateAuthProfile stores a new profile with its credential encrypted.
+// POST /api/orgs/{orgId}/auth-profiles
+func (h *AuthProfilesHandler) CreateAuthProfile(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("orgId")
+	if orgID == "" {
+		http.Error(w, "Organization ID required", http.StatusBadRequest)
+		return
+	}
+	if !requireOrgAdmin(w, r, orgID) {
+		return
+	}
+
+	var req AuthProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	profile := httpauth.Profile{
+		OrgID:    orgID,
+		Name:     strings.TrimSpace(req.Name),
+		Type:     strings.ToLower(req.Type),
+		Settings: req.Settings,
+	}
+	if err := httpauth.Validate(profile); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Secret == nil || (*req.Secret == "" && profile.Type != httpauth.TypeBasic) {
+		http.Error(w, "secret is required", http.StatusBadRequest)
+		return
+	}
+
+	ciphertext, err := httpauth.SealSecret(orgID, *req.Secret)
+	if err != nil {
+		http.Error(w, "Failed to encrypt credential: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	record := map[string]interface{}{
+		"org_id":            orgID,
+		"name":              profile.Name,
+		"type":              profile.Type,
+		"settings":          profile.Settings,
+		"secret_ciphertext": ciphertext,
+	}
+	if userID, ok := r.Context().Value(middleware.UserIDKey).(string); ok && userID != "" {
+		record["created_by"] = userID
+	}
+
+	var results []httpauth.Profile
+	if err := database.GetClient().DB.From("http_auth_profiles").Insert(record).Execute(&results); err != nil {
+		http.Error(w, "Failed to save auth profile: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(results) == 0 {
+		http.Error(w, "Failed to save auth profile", http.StatusInternalServerError)
+		return
+	}
+
+	logAuthProfileActivity(r, orgID, "auth_profile.created", results[0])
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(redactAuthProfile(results[0]))
+}
+
+// UpdateAuthProfile replaces a profile's settings and, when given, its credential.
+// Cached OAuth2 tokens of the profile are dropped.
+// PUT /api/orgs/{orgId}/auth-profiles/{id}
+func (h *AuthProfilesHandler) UpdateAuthProfile(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("orgId")
+	id := r.PathValue("id")
+	if orgID == "" || id == "" {
+		http.Error(w, "Organization ID and profile ID required", http.StatusBadRequest)
+		return
+	}
+	if !requireOrgAdmin(w, r, orgID) {
+		return
+	}
+
+	var req AuthProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	profile := httpauth.Profile{
+		ID:       id,
+		OrgID:    orgID,
+		Name:     strings.TrimSpace(req.Name),
+		Type:     strings.ToLower(req.Type),
+		Settings: req.Settings,
+	}
+	if err := httpauth.Validate(profile); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	record := map[string]interface{}{
+		"name":       profile.Name,
+		"type":       profile.Type,
+		"settings":   profile.Settings,
+		"updated_at": time.Now(),
+	}
+	if req.Secret != nil {
+		ciphertext, err := httpauth.SealSecret(orgID, *req.Secret)
+		if err != nil {
+			http.Error(w, "Failed to encrypt credential: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		record["secret_ciphertext"] = ciphertext
+	}
+
+	var results []httpauth.Profile
+	err := database.GetClient().DB.From("http_auth_profiles").Update(record).Eq("org_id", orgID).Eq("id", id).Execute(&results)
+	if err != nil {
+		http.Error(w, "Failed to update auth profile: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(results) == 0 {
+		http.Error(w, "Auth profile not found", http.StatusNotFound)
+		return
+	}
+
+	logAuthProfileActivity(r, orgID, "auth_profile.updated", results[0])
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(redactAuthProfile(results[0]))
+}
+
+// DeleteAuthProfile removes a profile. HTTP nodes still using it fail with a config error.
+// DELETE /api/orgs/{orgId}/auth-profiles/{id}
+func (h *AuthProfilesHandler) DeleteAuthProfile(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("orgId")
+	id := r.PathValue("id")
+	if orgID == "" || id == "" {
+		http.Error(w, "Organization ID and profile ID required", http.StatusBadRequest)
+		return
+	}
+	if !requireOrgAdmin(w, r, orgID) {
+		return
+	}
+
+	dbClient := database.GetClient()
+	var existing []httpauth.Profile
+	dbClient.DB.From("http_auth_profiles").Select("id,name,type").Eq("org_id", orgID).Eq("id", id).Execute(&existing)
+	if len(existing) == 0 {
+		http.Error(w, "Auth profile not found", http.StatusNotFound)
+		return
+	}
+
+	var results []map[string]interface{}
+	if err := dbClient.DB.From("http_auth_profiles").Delete().Eq("id", id).Execute(&results); err != nil {
+		http.Error(w, "Failed to delete auth profile: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logAuthProfileActivity(r, orgID, "auth_profile.deleted", existing[0])
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestAuthProfile checks a profile without calling any API: OAuth2 profiles fetch a
+// fresh token from their token endpoint, the other types only decrypt their credential.
+// POST /api/orgs/{orgId}/auth-profiles/{id}/test
+func (h *AuthProfilesHandler) TestAuthProfile(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("orgId")
+	id := r.PathValue("id")
+	if orgID == "" || id == "" {
+		http.Error(w, "Organization ID and profile ID required", http.StatusBadRequest)
+		return
+	}
+	if !requireOrgAdmin(w, r, orgID) {
+		return
+	}
+
+	profile, secret, err := httpauth.Load(orgID, id)
+	if errors.Is(err, httpauth.ErrNotFound) {
+		http.Error(w, "Auth profile not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if profile.Type != httpauth.TypeOAuth2ClientCredentials {
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+	token, err := httpauth.FetchToken(ctx, profile, secret)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "failed",
+			"error":  err.Error(),
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     "ok",
+		"token_type": token.TokenType,
+		"expires_in": token.ExpiresIn,
+		"scope":      token.Scope,
+	})
+}
+
+func logAuthProfileActivity(r *http.Request, orgID, event string, p httpauth.Profile) {
+	var userPtr *string
+	if userID, ok := r.Context().Value(middleware.UserIDKey).(string); ok && userID != "" {
+		userPtr = &userID
+	}
+	audit.LogActivity(r.Context(), orgID, userPtr, event, &p.ID, map[string]interface{}{
+		"name": p.Name,
+		"type": p.Type,
+	}, r.RemoteAddr)
+}
+
+func redactAuthProfile(p httpauth.Profile) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         p.ID,
+		"name":       p.Name,
+		"type":       p.Type,
+		"settings":   p.Settings,
+		"has_secret": p.Ciphertext != "",
+		"created_by": p.CreatedBy,
+		"created_at": p.CreatedAt,
+		"updated_at": p.UpdatedAt,
+	}
+}
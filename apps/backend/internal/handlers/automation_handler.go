@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/webhook"
 	"go.temporal.io/sdk/client"
 )
 
@@ -213,6 +214,7 @@ func (h *AutomationHandler) resumeByActionID(w http.ResponseWriter, r *http.Requ
 // WebhookHandler handles incoming webhooks via unique token
 // POST /api/webhooks/{token}
 // External systems call this URL — no auth needed, the token IS the authentication.
+// When the AUTOMATION node has a "signature" block, the call must also be signed.
 func (h *AutomationHandler) WebhookHandler(w http.ResponseWriter, r *http.Request) {
 	token := r.PathValue("token")
 	if token == "" {
@@ -220,25 +222,20 @@ func (h *AutomationHandler) WebhookHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Parse request body as output data
-	var body map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		// Empty body is fine — not all webhooks send data
-		body = make(map[string]interface{})
-	}
-
 	// Lookup subscription by webhook token
 	dbClient := database.GetClient()
 	type WebhookSub struct {
-		ID         string `json:"id"`
-		WorkflowID string `json:"workflow_id"`
-		RunID      string `json:"run_id"`
-		StepID     string `json:"step_id"`
+		ID         string                 `json:"id"`
+		OrgID      string                 `json:"org_id"`
+		WorkflowID string                 `json:"workflow_id"`
+		RunID      string                 `json:"run_id"`
+		StepID     string                 `json:"step_id"`
+		Signature  map[string]interface{} `json:"signature"`
 	}
 	var subs []WebhookSub
 
 	err := dbClient.DB.From("automation_subscriptions").
-		Select("id, workflow_id, run_id, step_id").
+		Select("id, org_id, workflow_id, run_id, step_id, signature").
 		Eq("webhook_token", token).
 		Eq("status", "active").
 		Execute(&subs)
@@ -247,8 +244,32 @@ func (h *AutomationHandler) WebhookHandler(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Webhook not found or already used", http.StatusNotFound)
 		return
 	}
-
 	sub := subs[0]
+
+	raw, ok := readWebhookBody(w, r)
+	if !ok {
+		return
+	}
+	scope := "automation:" + sub.ID
+	var delivery *webhook.Delivery
+	if len(sub.Signature) > 0 {
+		signature, err := webhook.ParseSignature(sub.Signature)
+		if err != nil {
+			http.Error(w, "Invalid webhook signature settings: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if delivery, ok = verifyWebhook(w, r, sub.OrgID, scope, signature, raw); !ok {
+			return
+		}
+	}
+
+	// Parse request body as output data
+	var body map[string]interface{}
+	if err := json.Unmarshal(raw, &body); err != nil || body == nil {
+		// Empty body is fine — not all webhooks send data
+		body = make(map[string]interface{})
+	}
+
 	actionID := fmt.Sprintf("%s:%s", sub.RunID, sub.StepID)
 	signalName := "AutomationSignal-" + actionID
 
@@ -259,6 +280,9 @@ func (h *AutomationHandler) WebhookHandler(w http.ResponseWriter, r *http.Reques
 
 	err = h.TemporalClient.SignalWorkflow(r.Context(), sub.WorkflowID, sub.RunID, signalName, signalArg)
 	if err != nil {
+		if delivery != nil {
+			webhook.Forget(scope, delivery)
+		}
 		fmt.Printf("ERROR: Webhook signal failed for token %s: %v\n", token, err)
 		http.Error(w, "Failed to resume workflow", http.StatusInternalServerError)
 		return
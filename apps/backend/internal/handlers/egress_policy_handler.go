@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/audit"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/egress"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/middleware"
+)
+
+type EgressPolicyHandler struct{}
+
+func NewEgressPolicyHandler() *EgressPolicyHandler {
+	return &EgressPolicyHandler{}
+}
+
+type UpdateEgressPolicyRequest struct {
+	Allow []string `json:"allow"` // hosts, *.wildcards, IPs or CIDRs; empty allows any public destination
+	Deny  []string `json:"deny"`
+}
+
+// GetEgressPolicy returns the destinations the organization's flows may call.
+// GET /api/orgs/{orgId}/egress-policy
+func (h *EgressPolicyHandler) GetEgressPolicy(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("orgId")
+	if orgID == "" {
+		http.Error(w, "Organization ID required", http.StatusBadRequest)
+		return
+	}
+	if !requireOrgAdmin(w, r, orgID) {
+		return
+	}
+
+	var rows []egress.Rules
+	err := database.GetClient().DB.From("org_egress_policies").Select("*").Eq("org_id", orgID).Execute(&rows)
+	if err != nil {
+		http.Error(w, "Failed to fetch egress policy: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rules := egress.Rules{OrgID: orgID, Allow: []string{}, Deny: []string{}}
+	if len(rows) > 0 {
+		rules = rows[0]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(egressPolicyResponse(rules))
+}
+
+// UpdateEgressPolicy replaces the organization's allow and deny lists.
+// PUT /api/orgs/{orgId}/egress-policy
+func (h *EgressPolicyHandler) UpdateEgressPolicy(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("orgId")
+	if orgID == "" {
+		http.Error(w, "Organization ID required", http.StatusBadRequest)
+		return
+	}
+	if !requireOrgAdmin(w, r, orgID) {
+		return
+	}
+
+	var req UpdateEgressPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	allow, deny := cleanEntries(req.Allow), cleanEntries(req.Deny)
+	if _, err := egress.New(orgID, allow, deny); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var userPtr *string
+	if userID, ok := r.Context().Value(middleware.UserIDKey).(string); ok && userID != "" {
+		userPtr = &userID
+	}
+	record := map[string]interface{}{
+		"org_id":     orgID,
+		"allow":      allow,
+		"deny":       deny,
+		"updated_by": userPtr,
+		"updated_at": time.Now(),
+	}
+
+	dbClient := database.GetClient()
+	var existing []egress.Rules
+	dbClient.DB.From("org_egress_policies").Select("org_id").Eq("org_id", orgID).Execute(&existing)
+
+	var results []egress.Rules
+	var err error
+	if len(existing) > 0 {
+		err = dbClient.DB.From("org_egress_policies").Update(record).Eq("org_id", orgID).Execute(&results)
+	} else {
+		err = dbClient.DB.From("org_egress_policies").Insert(record).Execute(&results)
+	}
+	if err != nil {
+		http.Error(w, "Failed to save egress policy: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	audit.LogActivity(r.Context(), orgID, userPtr, "egress_policy.updated", nil, map[string]interface{}{
+		"allow": allow,
+		"deny":  deny,
+	}, r.RemoteAddr)
+
+	rules := egress.Rules{OrgID: orgID, Allow: allow, Deny: deny}
+	if len(results) > 0 {
+		rules = results[0]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(egressPolicyResponse(rules))
+}
+
+// cleanEntries trims entries and drops blank ones.
+func cleanEntries(entries []string) []string {
+	cleaned := []string{}
+	for _, e := range entries {
+		if e = strings.TrimSpace(e); e != "" {
+			cleaned = append(cleaned, e)
+		}
+	}
+	return cleaned
+}
+
+func egressPolicyResponse(rules egress.Rules) map[string]interface{} {
+	return map[string]interface{}{
+		"allow":              rules.Allow,
+		"deny":               rules.Deny,
+		"updated_by":         rules.UpdatedBy,
+		"updated_at":         rules.UpdatedAt,
+		"blocked_by_default": egress.BlockedByDefault(),
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/events"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/workflow"
+)
+
+// heartbeatInterval keeps idle streams (and the proxies in front of them) open.
+const heartbeatInterval = 15 * time.Second
+
+type EventsHandler struct{}
+
+func NewEventsHandler() *EventsHandler {
+	return &EventsHandler{}
+}
+
+// StreamOrgEvents streams the events of every run of an organization as Server-Sent Events.
+// Reconnecting clients send Last-Event-ID (or ?last_event_id=) to receive what they missed.
+// GET /api/events?org_id=...
+func (h *EventsHandler) StreamOrgEvents(w http.ResponseWriter, r *http.Request) {
+	orgID := r.URL.Query().Get("org_id")
+	if orgID == "" {
+		http.Error(w, "org_id is required", http.StatusBadRequest)
+		return
+	}
+	if !requireOrgMember(w, r, orgID) {
+		return
+	}
+
+	streamEvents(w, r, func(e events.Event) bool { return e.OrgID == orgID }, false, nil)
+}
+
+// StreamEvents streams the node, task and status events of a run as Server-Sent Events.
+// The stream ends after the run's terminal event.
+// GET /api/action-flows/{id}/events
+func (h *ActionFlowHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, "Missing ID", http.StatusBadRequest)
+		return
+	}
+
+	var runs []struct {
+		OrgID              string `json:"org_id"`
+		TemporalWorkflowID string `json:"temporal_workflow_id"`
+		RunID              string `json:"run_id"`
+		Status             string `json:"status"`
+	}
+	err := database.GetClient().DB.From("action_flows").Select("org_id, temporal_workflow_id, run_id, status").Eq("id", id).Execute(&runs)
+	if err != nil || len(runs) == 0 {
+		http.Error(w, "Action Flow not found", http.StatusNotFound)
+		return
+	}
+	run := runs[0]
+	if !requireOrgMember(w, r, run.OrgID) {
+		return
+	}
+
+	filter := func(e events.Event) bool { return e.RunID == run.RunID }
+
+	// A run that already ended has nothing left to stream: replay what the client missed
+	// and report how it ended
+	switch run.Status {
+	case "COMPLETED", "FAILED", "CANCELED", "TERMINATED":
+		streamEvents(w, r, filter, true, &events.Event{
+			Type:       workflow.RunEventType(run.Status),
+			OrgID:      run.OrgID,
+			WorkflowID: run.TemporalWorkflowID,
+			RunID:      run.RunID,
+			Data:       map[string]interface{}{"status": run.Status},
+		})
+		return
+	}
+
+	streamEvents(w, r, filter, true, nil)
+}
+
+// streamEvents writes the events matching filter until the client goes away (or, with
+// untilTerminal, until the run ends), after replaying those missed since Last-Event-ID.
+// ended is the terminal event of a run that already ended: the stream stops after the
+// backlog, with ended in place of a terminal event the backlog no longer holds.
+func streamEvents(w http.ResponseWriter, r *http.Request, filter func(events.Event) bool, untilTerminal bool, ended *events.Event) {
+	sub, backlog := events.Subscribe(filter, lastEventID(r))
+	defer sub.Close()
+
+	if !startStream(w) {
+		return
+	}
+	for _, e := range backlog {
+		writeEvent(w, e)
+		if untilTerminal && e.IsRunTerminal() {
+			return
+		}
+	}
+	if ended != nil {
+		writeEvent(w, *ended)
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// Fell too far behind; the client reconnects and catches up via Last-Event-ID
+				return
+			}
+			writeEvent(w, e)
+			if untilTerminal && e.IsRunTerminal() {
+				return
+			}
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			w.(http.Flusher).Flush()
+		}
+	}
+}
+
+// startStream writes the SSE headers. Streams outlive the server's WriteTimeout,
+// so the deadline is lifted for this response.
+func startStream(w http.ResponseWriter) bool {
+	if _, ok := w.(http.Flusher); !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return false
+	}
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	w.(http.Flusher).Flush()
+	return true
+}
+
+func writeEvent(w http.ResponseWriter, e events.Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if e.ID > 0 {
+		fmt.Fprintf(w, "id: %d\n", e.ID)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+	w.(http.Flusher).Flush()
+}
+
+// lastEventID is the last event a reconnecting client received (0 if none).
+func lastEventID(r *http.Request) uint64 {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("last_event_id")
+	}
+	id, _ := strconv.ParseUint(raw, 10, 64)
+	return id
+}
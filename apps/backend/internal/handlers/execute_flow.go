@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
@@ -64,9 +67,10 @@ func (h *ExecuteFlowHandler) ExecuteFlow(w http.ResponseWriter, r *http.Request)
 		Definition          json.RawMessage `json:"definition"`
 		VariablesSchema     json.RawMessage `json:"variables_schema"`
 		IsActive            bool            `json:"is_active"`
+		CurrentVersionID    *string         `json:"current_version_id"`
 	}
 
-	err := dbClient.DB.From("flows").Select("id, org_id, name, published_definition, definition, variables_schema, is_active").Eq("id", flowID).Execute(&dbResult)
+	err := dbClient.DB.From("flows").Select("id, org_id, name, published_definition, definition, variables_schema, is_active, current_version_id").Eq("id", flowID).Execute(&dbResult)
 	if err != nil || len(dbResult) == 0 {
 		http.Error(w, "Flow not found", http.StatusNotFound)
 		return
@@ -92,6 +96,9 @@ func (h *ExecuteFlowHandler) ExecuteFlow(w http.ResponseWriter, r *http.Request)
 	// Inject Flow ID and Org ID
 	flowDef.ID = flowID
 	flowDef.OrgID = dbResult[0].OrgID
+	if dbResult[0].CurrentVersionID != nil {
+		flowDef.VersionID = *dbResult[0].CurrentVersionID
+	}
 
 	// 3.5 Validate Trigger Schema
 	// Check if the input body matches the required fields defined in the API Trigger
@@ -151,7 +158,21 @@ func (h *ExecuteFlowHandler) ExecuteFlow(w http.ResponseWriter, r *http.Request)
 
 	workflowOptions := client.StartWorkflowOptions{
 		ID:        workflowID,
-		TaskQueue: "nodal-task-queue",
+		TaskQueue: workflow.TaskQueue,
+	}
+
+	// ?mode=sync waits for the run (up to ?timeout=) and returns its result
+	syncMode := r.URL.Query().Get("mode") == "sync"
+	syncTimeout := defaultSyncTimeout
+	if syncMode {
+		if t := r.URL.Query().Get("timeout"); t != "" {
+			parsed, err := parseSyncTimeout(t)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			syncTimeout = parsed
+		}
 	}
 
 	// 5. Execute Workflow
@@ -162,6 +183,11 @@ func (h *ExecuteFlowHandler) ExecuteFlow(w http.ResponseWriter, r *http.Request)
 			var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
 			if errors.As(err, &alreadyStarted) {
 				desc, descErr := h.TemporalClient.DescribeWorkflowExecution(context.Background(), workflowID, "")
+				if descErr == nil && syncMode {
+					run := h.TemporalClient.GetWorkflow(context.Background(), workflowID, desc.WorkflowExecutionInfo.Execution.RunId)
+					h.respondSync(w, r, run, syncTimeout)
+					return
+				}
 				if descErr == nil {
 					w.Header().Set("Content-Type", "application/json")
 					w.WriteHeader(http.StatusOK)
@@ -178,6 +204,11 @@ func (h *ExecuteFlowHandler) ExecuteFlow(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if syncMode {
+		h.respondSync(w, r, we, syncTimeout)
+		return
+	}
+
 	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(map[string]string{
 		"message":     "Flow execution started",
@@ -185,3 +216,115 @@ func (h *ExecuteFlowHandler) ExecuteFlow(w http.ResponseWriter, r *http.Request)
 		"run_id":      we.GetRunID(),
 	})
 }
+
+const (
+	defaultSyncTimeout = 30 * time.Second
+	maxSyncTimeout     = 5 * time.Minute
+)
+
+// parseSyncTimeout accepts Go durations ("30s", "2m") or plain seconds ("30").
+func parseSyncTimeout(raw string) (time.Duration, error) {
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		secs, convErr := strconv.Atoi(raw)
+		if convErr != nil {
+			return 0, fmt.Errorf("invalid timeout %q (use e.g. 30s)", raw)
+		}
+		d = time.Duration(secs) * time.Second
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("timeout must be positive")
+	}
+	if d > maxSyncTimeout {
+		d = maxSyncTimeout
+	}
+	return d, nil
+}
+
+// extendWriteDeadline lifts the server's WriteTimeout for a response that waits up to
+// wait on a run before writing.
+func extendWriteDeadline(w http.ResponseWriter, wait time.Duration) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + 10*time.Second)); err != nil {
+		log.Printf("Failed to extend write deadline: %v", err)
+	}
+}
+
+// respondSync waits for a run to finish and writes its result. A flow with a RESPONSE node
+// decides the status, headers and body; otherwise the final step outputs are returned.
+// If the run is still going when the timeout elapses, the caller gets a 202 with a status URL.
+func (h *ExecuteFlowHandler) respondSync(w http.ResponseWriter, r *http.Request, run client.WorkflowRun, timeout time.Duration) {
+	extendWriteDeadline(w, timeout)
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	var result map[string]interface{}
+	err := run.Get(ctx, &result)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		if ctx.Err() != nil {
+			if r.Context().Err() != nil {
+				return // Caller went away; the run carries on
+			}
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]string{
+				"message":     "Flow is still running",
+				"workflow_id": run.GetID(),
+				"run_id":      run.GetRunID(),
+				"status_url":  runStatusURL(run.GetID()),
+			})
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":       "Flow execution failed: " + err.Error(),
+			"workflow_id": run.GetID(),
+			"run_id":      run.GetRunID(),
+		})
+		return
+	}
+
+	if response, ok := result[workflow.ResponseStateKey].(map[string]interface{}); ok {
+		writeFlowResponse(w, response)
+		return
+	}
+
+	delete(result, "__node_status")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workflow_id": run.GetID(),
+		"run_id":      run.GetRunID(),
+		"status":      "COMPLETED",
+		"outputs":     result,
+	})
+}
+
+// writeFlowResponse writes the output of a RESPONSE node as the HTTP response.
+func writeFlowResponse(w http.ResponseWriter, response map[string]interface{}) {
+	statusCode := http.StatusOK
+	if code, ok := response["status_code"].(float64); ok {
+		statusCode = int(code)
+	}
+
+	if headers, ok := response["headers"].(map[string]interface{}); ok {
+		for k, v := range headers {
+			switch http.CanonicalHeaderKey(k) {
+			case "Content-Length", "Transfer-Encoding", "Connection":
+				continue // Managed by net/http
+			}
+			w.Header().Set(k, fmt.Sprintf("%v", v))
+		}
+	}
+
+	body := response["body"]
+	// String bodies are sent raw when the flow set a non-JSON Content-Type
+	if str, ok := body.(string); ok && !strings.Contains(w.Header().Get("Content-Type"), "json") {
+		w.WriteHeader(statusCode)
+		w.Write([]byte(str))
+		return
+	}
+
+	w.WriteHeader(statusCode)
+	if body != nil {
+		json.NewEncoder(w).Encode(body)
+	}
+}
@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/middleware"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/workflow"
 	temporalClient "go.temporal.io/sdk/client"
 )
 
@@ -169,6 +173,16 @@ func (h *FlowHandler) UpdateFlow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Activating registers the flow's schedule triggers, deactivating removes them
+	if req.IsActive != nil {
+		if err := syncSchedulesForActivation(h.TemporalClient, flowID, *req.IsActive); err != nil {
+			log.Printf("Failed to sync schedules for flow %s: %v", flowID, err)
+			if len(results) > 0 {
+				results[0]["schedule_error"] = err.Error()
+			}
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(results[0])
 }
@@ -266,6 +280,9 @@ func (h *FlowHandler) DeleteFlow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Load the definition first so its schedules can be removed afterwards
+	published, _, _ := loadPublishedFlow(flowID)
+
 	dbClient := database.GetClient()
 	var results []map[string]interface{}
 	err := dbClient.DB.From("flows").Delete().Eq("id", flowID).Execute(&results)
@@ -275,6 +292,14 @@ func (h *FlowHandler) DeleteFlow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if published != nil && h.TemporalClient != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+		defer cancel()
+		if err := workflow.DeleteFlowSchedules(ctx, h.TemporalClient, *published); err != nil {
+			log.Printf("Failed to delete schedules for flow %s: %v", flowID, err)
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Flow deleted successfully"})
 }
@@ -291,9 +316,13 @@ func (h *FlowHandler) PublishFlow(w http.ResponseWriter, r *http.Request) {
 
 	// 1. Get current draft
 	var current []struct {
-		DraftDefinition map[string]interface{} `json:"draft_definition"`
+		OrgID               string                 `json:"org_id"`
+		DraftDefinition     map[string]interface{} `json:"draft_definition"`
+		PublishedDefinition map[string]interface{} `json:"published_definition"`
+		IsActive            bool                   `json:"is_active"`
+		CurrentVersionID    *string                `json:"current_version_id"`
 	}
-	err := dbClient.DB.From("flows").Select("draft_definition").Eq("id", flowID).Execute(&current)
+	err := dbClient.DB.From("flows").Select("org_id, draft_definition, published_definition, is_active, current_version_id").Eq("id", flowID).Execute(&current)
 	if err != nil || len(current) == 0 {
 		http.Error(w, "Flow not found", http.StatusNotFound)
 		return
@@ -304,28 +333,140 @@ func (h *FlowHandler) PublishFlow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 2. Promote to Published
-	// Note: Supabase/PostgREST doesn't support "SET col = other_col" easily in one Update call via client usually (unless using RPC).
-	// So we fetch (done) and update.
+	// 1.5 Validate the definition (and its schedule triggers) before anything is published
+	draftFlow, err := toFlowDefinition(current[0].DraftDefinition)
+	if err != nil {
+		http.Error(w, "Invalid flow definition: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	draftFlow.ID = flowID
+	draftFlow.OrgID = current[0].OrgID
 
-	// 2. Promote to Published
-	var results []map[string]interface{}
-	err = dbClient.DB.From("flows").Update(map[string]interface{}{
-		"published_definition": current[0].DraftDefinition,
-		"definition":           current[0].DraftDefinition, // Keep legacy definition in sync
-		"published_at":         time.Now(),
-		"is_active":            true, // Auto-activate on publish? Maybe not, checking user intent. Usually publish implies ready. Let's strictly follow button state if possible, but usually publish -> active. Let's leave is_active alone strictly unless requested, BUT the user said "Inactive means it cannot be called".
-		// Actually, let's NOT auto-activate. The user has an "Active/Inactive" toggle.
-	}).Eq("id", flowID).Execute(&results)
+	if diags := workflow.ValidateFlow(draftFlow); workflow.HasErrors(diags) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Flow has validation errors",
+			"diagnostics": diags,
+		})
+		return
+	}
+
+	triggers, err := workflow.ScheduleTriggers(draftFlow)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(triggers) > 0 && h.TemporalClient == nil {
+		http.Error(w, "Scheduling service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	// 2. Snapshot the draft as a new immutable version
+	var req struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
 
+	userID, _ := r.Context().Value(middleware.UserIDKey).(string)
+	version, err := createFlowVersion(flowID, current[0].OrgID, current[0].DraftDefinition, req.Note, "", userID)
 	if err != nil {
 		http.Error(w, "Failed to publish flow: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	draftFlow.VersionID = version.ID
+
+	// 3. Register its schedules and webhooks, then make it live
+	var previous *workflow.FlowDefinition
+	if current[0].PublishedDefinition != nil {
+		if prev, err := toFlowDefinition(current[0].PublishedDefinition); err == nil {
+			prev.ID = flowID
+			prev.OrgID = current[0].OrgID
+			if current[0].CurrentVersionID != nil {
+				prev.VersionID = *current[0].CurrentVersionID
+			}
+			previous = &prev
+		}
+	}
+	webhooks, status, err := h.publishFlowVersion(r.Context(), version, draftFlow, previous, current[0].IsActive)
+	if err != nil {
+		http.Error(w, "Failed to publish flow: "+err.Error(), status)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"message":      "Flow published successfully",
-		"published_at": time.Now(),
+		"published_at": version.PublishedAt,
+		"version":      version.Version,
+		"version_id":   version.ID,
+		"schedules":    triggers,
+		"webhooks":     webhooks,
+	})
+}
+
+// ValidateFlow lints a flow definition and returns diagnostics the editor can highlight.
+// It checks the saved draft unless the body carries a "definition" (e.g. unsaved canvas state).
+// POST /api/flows/{id}/validate
+func (h *FlowHandler) ValidateFlow(w http.ResponseWriter, r *http.Request) {
+	flowID := r.PathValue("id")
+	if flowID == "" {
+		http.Error(w, "Flow ID required", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Definition map[string]interface{} `json:"definition"`
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var current []struct {
+		OrgID           string                 `json:"org_id"`
+		DraftDefinition map[string]interface{} `json:"draft_definition"`
+	}
+	err := database.GetClient().DB.From("flows").Select("org_id, draft_definition").Eq("id", flowID).Execute(&current)
+	if err != nil || len(current) == 0 {
+		http.Error(w, "Flow not found", http.StatusNotFound)
+		return
+	}
+	if !requireOrgMember(w, r, current[0].OrgID) {
+		return
+	}
+
+	definition := req.Definition
+	if definition == nil {
+		definition = current[0].DraftDefinition
+	}
+
+	flow, err := toFlowDefinition(definition)
+	if err != nil {
+		http.Error(w, "Invalid flow definition: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	diags := workflow.ValidateFlow(flow)
+	errorCount := 0
+	for _, d := range diags {
+		if d.Severity == workflow.SeverityError {
+			errorCount++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"valid":       errorCount == 0,
+		"errors":      errorCount,
+		"warnings":    len(diags) - errorCount,
+		"diagnostics": diags,
 	})
 }
@@ -0,0 +1,450 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/audit"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/middleware"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/workflow"
+)
+
+// FlowVersion is an immutable snapshot of a published definition (the flow_versions table).
+type FlowVersion struct {
+	ID             string                 `json:"id"`
+	FlowID         string                 `json:"flow_id"`
+	OrgID          string                 `json:"org_id"`
+	Version        int                    `json:"version"`
+	Definition     map[string]interface{} `json:"definition,omitempty"`
+	Note           *string                `json:"note"`
+	RolledBackFrom *string                `json:"rolled_back_from"`
+	PublishedBy    *string                `json:"published_by"`
+	PublishedAt    string                 `json:"published_at"`
+}
+
+// DefinitionDiff summarizes what changed between two versions of a flow.
+type DefinitionDiff struct {
+	FromVersion  int          `json:"from_version"`
+	ToVersion    int          `json:"to_version"`
+	AddedNodes   []string     `json:"added_nodes"`
+	RemovedNodes []string     `json:"removed_nodes"`
+	ChangedNodes []NodeChange `json:"changed_nodes"`
+	AddedEdges   []string     `json:"added_edges"`
+	RemovedEdges []string     `json:"removed_edges"`
+}
+
+// NodeChange lists the config keys of a node that differ between two versions.
+type NodeChange struct {
+	ID     string   `json:"id"`
+	Type   string   `json:"type"`
+	Fields []string `json:"fields"`
+}
+
+// ListVersions lists every published version of a flow, newest first, each with a diff against its predecessor.
+// GET /api/flows/{id}/versions
+func (h *FlowHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
+	flowID := r.PathValue("id")
+	if flowID == "" {
+		http.Error(w, "Flow ID required", http.StatusBadRequest)
+		return
+	}
+
+	dbClient := database.GetClient()
+
+	var flows []struct {
+		OrgID            string  `json:"org_id"`
+		CurrentVersionID *string `json:"current_version_id"`
+	}
+	err := dbClient.DB.From("flows").Select("org_id, current_version_id").Eq("id", flowID).Execute(&flows)
+	if err != nil || len(flows) == 0 {
+		http.Error(w, "Flow not found", http.StatusNotFound)
+		return
+	}
+	if !requireOrgMember(w, r, flows[0].OrgID) {
+		return
+	}
+
+	var versions []FlowVersion
+	err = dbClient.DB.From("flow_versions").Select("*").Eq("flow_id", flowID).Execute(&versions)
+	if err != nil {
+		http.Error(w, "Failed to fetch versions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+
+	list := make([]map[string]interface{}, 0, len(versions))
+	for i, v := range versions {
+		entry := map[string]interface{}{
+			"id":               v.ID,
+			"version":          v.Version,
+			"note":             v.Note,
+			"rolled_back_from": v.RolledBackFrom,
+			"published_by":     v.PublishedBy,
+			"published_at":     v.PublishedAt,
+			"is_current":       flows[0].CurrentVersionID != nil && *flows[0].CurrentVersionID == v.ID,
+			"node_count":       countNodes(v.Definition),
+		}
+		if i > 0 {
+			entry["diff"] = diffVersions(versions[i-1], v)
+		}
+		list = append(list, entry)
+	}
+
+	// Newest first
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetVersion returns one version with its full definition. ?compare=N adds a diff from version N.
+// GET /api/flows/{id}/versions/{version}
+func (h *FlowHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	flowID := r.PathValue("id")
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if flowID == "" || err != nil {
+		http.Error(w, "Flow ID and numeric version required", http.StatusBadRequest)
+		return
+	}
+
+	v, err := fetchFlowVersion(flowID, version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !requireOrgMember(w, r, v.OrgID) {
+		return
+	}
+
+	response := map[string]interface{}{"version": v}
+	if compare := r.URL.Query().Get("compare"); compare != "" {
+		other, err := strconv.Atoi(compare)
+		if err != nil {
+			http.Error(w, "compare must be a version number", http.StatusBadRequest)
+			return
+		}
+		base, err := fetchFlowVersion(flowID, other)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		response["diff"] = diffVersions(*base, *v)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RollbackFlow republishes an older version. The rollback itself becomes a new version,
+// so history is never rewritten. The draft is left untouched.
+// POST /api/flows/{id}/versions/{version}/rollback
+func (h *FlowHandler) RollbackFlow(w http.ResponseWriter, r *http.Request) {
+	flowID := r.PathValue("id")
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if flowID == "" || err != nil {
+		http.Error(w, "Flow ID and numeric version required", http.StatusBadRequest)
+		return
+	}
+
+	target, err := fetchFlowVersion(flowID, version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !requireOrgMember(w, r, target.OrgID) {
+		return
+	}
+
+	// The currently published definition, so schedules of removed triggers are dropped
+	previous, isActive, _ := loadPublishedFlow(flowID)
+
+	// The version is held to today's checks, as PublishFlow holds drafts
+	targetFlow, err := toFlowDefinition(target.Definition)
+	if err != nil {
+		http.Error(w, "Invalid flow definition: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	targetFlow.ID = flowID
+	targetFlow.OrgID = target.OrgID
+
+	if diags := workflow.ValidateFlow(targetFlow); workflow.HasErrors(diags) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       fmt.Sprintf("Version %d has validation errors", target.Version),
+			"diagnostics": diags,
+		})
+		return
+	}
+	if _, err := workflow.ScheduleTriggers(targetFlow); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := workflow.WebhookTriggers(targetFlow); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := r.Context().Value(middleware.UserIDKey).(string)
+	created, err := createFlowVersion(flowID, target.OrgID, target.Definition, fmt.Sprintf("Rollback to version %d", target.Version), target.ID, userID)
+	if err != nil {
+		http.Error(w, "Failed to roll back flow: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	targetFlow.VersionID = created.ID
+	if _, status, err := h.publishFlowVersion(r.Context(), created, targetFlow, previous, isActive); err != nil {
+		http.Error(w, "Failed to roll back flow: "+err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":          fmt.Sprintf("Flow rolled back to version %d", target.Version),
+		"version":          created.Version,
+		"version_id":       created.ID,
+		"rolled_back_from": target.Version,
+	})
+}
+
+func fetchFlowVersion(flowID string, version int) (*FlowVersion, error) {
+	var rows []FlowVersion
+	err := database.GetClient().DB.From("flow_versions").Select("*").
+		Eq("flow_id", flowID).Eq("version", strconv.Itoa(version)).Execute(&rows)
+	if err != nil || len(rows) == 0 {
+		return nil, fmt.Errorf("version %d not found", version)
+	}
+	return &rows[0], nil
+}
+
+// createFlowVersion snapshots def as the next version of the flow. The version isn't live
+// until publishFlowVersion promotes it. rolledBackFrom and publishedBy are optional ("" to omit).
+func createFlowVersion(flowID, orgID string, def map[string]interface{}, note, rolledBackFrom, publishedBy string) (*FlowVersion, error) {
+	dbClient := database.GetClient()
+
+	// 1. Next version number
+	var existing []struct {
+		Version int `json:"version"`
+	}
+	if err := dbClient.DB.From("flow_versions").Select("version").Eq("flow_id", flowID).Execute(&existing); err != nil {
+		return nil, fmt.Errorf("failed to read versions: %w", err)
+	}
+	next := 1
+	for _, v := range existing {
+		if v.Version >= next {
+			next = v.Version + 1
+		}
+	}
+
+	// 2. Insert the immutable snapshot
+	record := map[string]interface{}{
+		"flow_id":      flowID,
+		"org_id":       orgID,
+		"version":      next,
+		"definition":   def,
+		"published_at": time.Now(),
+	}
+	if note != "" {
+		record["note"] = note
+	}
+	if rolledBackFrom != "" {
+		record["rolled_back_from"] = rolledBackFrom
+	}
+	if publishedBy != "" {
+		record["published_by"] = publishedBy
+	}
+
+	var created []FlowVersion
+	if err := dbClient.DB.From("flow_versions").Insert(record).Execute(&created); err != nil {
+		// Unique (flow_id, version) rejects a concurrent publish
+		return nil, fmt.Errorf("failed to create version %d: %w", next, err)
+	}
+	if len(created) == 0 {
+		return nil, fmt.Errorf("failed to create version %d", next)
+	}
+	return &created[0], nil
+}
+
+// publishFlowVersion puts version live. flow is its definition, carrying ID, OrgID and
+// VersionID; previous is the definition published so far (nil on a first publish).
+// Schedules (of an active flow only: activating it registers them) and webhook endpoints
+// are synced before the flow points at the version, so the previous version stays live if
+// anything fails: the syncs are then reverted to previous and the version is dropped.
+// It returns the webhook URLs, or the HTTP status to answer with and the error.
+func (h *FlowHandler) publishFlowVersion(ctx context.Context, version *FlowVersion, flow workflow.FlowDefinition, previous *workflow.FlowDefinition, isActive bool) ([]map[string]string, int, error) {
+	syncCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	// 1. Register schedule triggers (and drop the ones removed since the last publish)
+	if h.TemporalClient != nil && isActive {
+		if err := workflow.SyncFlowSchedules(syncCtx, h.TemporalClient, flow, previous); err != nil {
+			h.revertPublish(version, flow, previous, isActive)
+			return nil, http.StatusBadGateway, fmt.Errorf("schedules could not be registered: %w", err)
+		}
+	}
+
+	// 2. Give webhook triggers their URL (kept from earlier publishes)
+	webhooks, err := syncFlowWebhooks(flow)
+	if err != nil {
+		h.revertPublish(version, flow, previous, isActive)
+		return nil, http.StatusInternalServerError, fmt.Errorf("webhooks could not be registered: %w", err)
+	}
+
+	// 3. Point the flow at it. is_active is left alone: publishing or rolling back
+	// doesn't turn on a flow its owner switched off.
+	var results []map[string]interface{}
+	err = database.GetClient().DB.From("flows").Update(map[string]interface{}{
+		"published_definition": version.Definition,
+		"definition":           version.Definition, // Keep legacy definition in sync
+		"current_version_id":   version.ID,
+		"published_at":         time.Now(),
+	}).Eq("id", version.FlowID).Execute(&results)
+	if err != nil {
+		h.revertPublish(version, flow, previous, isActive)
+		return nil, http.StatusInternalServerError, err
+	}
+
+	eventType := "flow.published"
+	if version.RolledBackFrom != nil {
+		eventType = "flow.rolled_back"
+	}
+	note := ""
+	if version.Note != nil {
+		note = *version.Note
+	}
+	if err := audit.LogActivity(context.Background(), version.OrgID, version.PublishedBy, eventType, &version.FlowID, map[string]interface{}{
+		"version":    version.Version,
+		"version_id": version.ID,
+		"note":       note,
+	}, ""); err != nil {
+		log.Printf("Failed to audit %s for flow %s: %v", eventType, version.FlowID, err)
+	}
+	return webhooks, http.StatusOK, nil
+}
+
+// revertPublish undoes a publish of version that failed before the flow pointed at it:
+// schedules and webhooks go back to previous and the version is deleted. Webhook endpoints
+// the publish removed come back with a new URL.
+func (h *FlowHandler) revertPublish(version *FlowVersion, flow workflow.FlowDefinition, previous *workflow.FlowDefinition, isActive bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	restore := workflow.FlowDefinition{ID: flow.ID, OrgID: flow.OrgID}
+	if previous != nil {
+		restore = *previous
+	}
+	if h.TemporalClient != nil && isActive {
+		if err := workflow.SyncFlowSchedules(ctx, h.TemporalClient, restore, &flow); err != nil {
+			log.Printf("Failed to restore schedules of flow %s: %v", flow.ID, err)
+		}
+	}
+	if _, err := syncFlowWebhooks(restore); err != nil {
+		log.Printf("Failed to restore webhooks of flow %s: %v", flow.ID, err)
+	}
+	if err := database.GetClient().DB.From("flow_versions").Delete().Eq("id", version.ID).Execute(nil); err != nil {
+		log.Printf("Failed to delete unpublished version %s of flow %s: %v", version.ID, flow.ID, err)
+	}
+}
+
+func countNodes(def map[string]interface{}) int {
+	nodes, _ := def["nodes"].([]interface{})
+	return len(nodes)
+}
+
+func diffVersions(from, to FlowVersion) DefinitionDiff {
+	oldFlow, _ := toFlowDefinition(from.Definition)
+	newFlow, _ := toFlowDefinition(to.Definition)
+	diff := diffDefinitions(oldFlow, newFlow)
+	diff.FromVersion = from.Version
+	diff.ToVersion = to.Version
+	return diff
+}
+
+// diffDefinitions compares nodes by ID (and their config keys) and edges by endpoints.
+// Canvas positions aren't part of FlowDefinition, so moving nodes around is not a change.
+func diffDefinitions(oldFlow, newFlow workflow.FlowDefinition) DefinitionDiff {
+	diff := DefinitionDiff{
+		AddedNodes:   []string{},
+		RemovedNodes: []string{},
+		ChangedNodes: []NodeChange{},
+		AddedEdges:   []string{},
+		RemovedEdges: []string{},
+	}
+
+	oldNodes := make(map[string]workflow.Node)
+	for _, n := range oldFlow.Nodes {
+		oldNodes[n.ID] = n
+	}
+	newNodes := make(map[string]bool)
+	for _, n := range newFlow.Nodes {
+		newNodes[n.ID] = true
+		prev, ok := oldNodes[n.ID]
+		if !ok {
+			diff.AddedNodes = append(diff.AddedNodes, n.ID)
+			continue
+		}
+
+		var fields []string
+		if prev.Type != n.Type {
+			fields = append(fields, "type")
+		}
+		keys := make(map[string]bool)
+		for k := range prev.Data {
+			keys[k] = true
+		}
+		for k := range n.Data {
+			keys[k] = true
+		}
+		for k := range keys {
+			if !reflect.DeepEqual(prev.Data[k], n.Data[k]) {
+				fields = append(fields, k)
+			}
+		}
+		if len(fields) > 0 {
+			sort.Strings(fields)
+			diff.ChangedNodes = append(diff.ChangedNodes, NodeChange{ID: n.ID, Type: n.Type, Fields: fields})
+		}
+	}
+	for _, n := range oldFlow.Nodes {
+		if !newNodes[n.ID] {
+			diff.RemovedNodes = append(diff.RemovedNodes, n.ID)
+		}
+	}
+
+	edgeKey := func(e workflow.Edge) string {
+		key := e.Source + " -> " + e.Target
+		if e.SourceHandle != nil && *e.SourceHandle != "" {
+			key += " [" + *e.SourceHandle + "]"
+		}
+		return key
+	}
+	oldEdges := make(map[string]bool)
+	for _, e := range oldFlow.Edges {
+		oldEdges[edgeKey(e)] = true
+	}
+	newEdges := make(map[string]bool)
+	for _, e := range newFlow.Edges {
+		key := edgeKey(e)
+		newEdges[key] = true
+		if !oldEdges[key] {
+			diff.AddedEdges = append(diff.AddedEdges, key)
+		}
+	}
+	for _, e := range oldFlow.Edges {
+		if key := edgeKey(e); !newEdges[key] {
+			diff.RemovedEdges = append(diff.RemovedEdges, key)
+		}
+	}
+
+	return diff
+}
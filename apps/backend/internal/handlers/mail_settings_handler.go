@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/mail"
+)
+
+type MailSettingsHandler struct{}
+
+func NewMailSettingsHandler() *MailSettingsHandler {
+	return &MailSettingsHandler{}
+}
+
+type UpdateMailSettingsRequest struct {
+	Provider    string  `json:"provider"`
+	Host        string  `json:"smtp_host"`
+	Port        int     `json:"smtp_port"`
+	Username    string  `json:"smtp_username"`
+	Password    *string `json:"smtp_password"` // nil keeps the stored password
+	Security    string  `json:"smtp_security"`
+	FromAddress string  `json:"from_address"`
+	FromName    string  `json:"from_name"`
+}
+
+// GetMailSettings returns the organization's mail transport settings (never the password).
+// GET /api/orgs/{orgId}/mail-settings
+func (h *MailSettingsHandler) GetMailSettings(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("orgId")
+	if orgID == "" {
+		http.Error(w, "Organization ID required", http.StatusBadRequest)
+		return
+	}
+	if !requireOrgAdmin(w, r, orgID) {
+		return
+	}
+
+	var rows []mail.Settings
+	err := database.GetClient().DB.From("mail_settings").Select("*").Eq("org_id", orgID).Execute(&rows)
+	if err != nil {
+		http.Error(w, "Failed to fetch mail settings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(rows) == 0 {
+		json.NewEncoder(w).Encode(map[string]interface{}{"configured": false})
+		return
+	}
+	json.NewEncoder(w).Encode(redactMailSettings(rows[0]))
+}
+
+// UpdateMailSettings creates or replaces the organization's mail transport settings.
+// PUT /api/orgs/{orgId}/mail-settings
+func (h *MailSettingsHandler) UpdateMailSettings(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("orgId")
+	if orgID == "" {
+		http.Error(w, "Organization ID required", http.StatusBadRequest)
+		return
+	}
+	if !requireOrgAdmin(w, r, orgID) {
+		return
+	}
+
+	var req UpdateMailSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	settings := mail.Settings{
+		OrgID:       orgID,
+		Provider:    strings.ToLower(req.Provider),
+		Host:        req.Host,
+		Port:        req.Port,
+		Username:    req.Username,
+		Security:    strings.ToLower(req.Security),
+		FromAddress: req.FromAddress,
+		FromName:    req.FromName,
+	}
+	if settings.Provider == "" {
+		settings.Provider = mail.ProviderSMTP
+	}
+
+	dbClient := database.GetClient()
+	var existing []mail.Settings
+	if err := dbClient.DB.From("mail_settings").Select("*").Eq("org_id", orgID).Execute(&existing); err != nil {
+		http.Error(w, "Failed to load mail settings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Passwords are stored encrypted; a plain-text one saved earlier is encrypted now
+	if req.Password != nil {
+		settings.Password = *req.Password
+	} else if len(existing) > 0 {
+		settings.PasswordCiphertext = existing[0].PasswordCiphertext
+		settings.Password = existing[0].LegacyPassword
+	}
+	if settings.Password != "" {
+		ciphertext, err := mail.SealPassword(orgID, settings.Password)
+		if err != nil {
+			http.Error(w, "Failed to encrypt SMTP password: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		settings.PasswordCiphertext = ciphertext
+	}
+
+	// Validate by building the transport
+	if _, err := mail.NewTransport(settings); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if settings.FromAddress == "" {
+		http.Error(w, "from_address is required", http.StatusBadRequest)
+		return
+	}
+
+	record := map[string]interface{}{
+		"org_id":                   orgID,
+		"provider":                 settings.Provider,
+		"smtp_host":                settings.Host,
+		"smtp_port":                settings.Port,
+		"smtp_username":            settings.Username,
+		"smtp_password":            nil,
+		"smtp_password_ciphertext": settings.PasswordCiphertext,
+		"smtp_security":            settings.Security,
+		"from_address":             settings.FromAddress,
+		"from_name":                settings.FromName,
+		"updated_at":               time.Now(),
+	}
+
+	var results []mail.Settings
+	var err error
+	if len(existing) > 0 {
+		err = dbClient.DB.From("mail_settings").Update(record).Eq("org_id", orgID).Execute(&results)
+	} else {
+		err = dbClient.DB.From("mail_settings").Insert(record).Execute(&results)
+	}
+	if err != nil {
+		http.Error(w, "Failed to save mail settings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(redactMailSettings(settings))
+}
+
+// SendTestEmail sends a test message with the organization's current settings.
+// POST /api/orgs/{orgId}/mail-settings/test
+func (h *MailSettingsHandler) SendTestEmail(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("orgId")
+	if orgID == "" {
+		http.Error(w, "Organization ID required", http.StatusBadRequest)
+		return
+	}
+	if !requireOrgAdmin(w, r, orgID) {
+		return
+	}
+
+	var req struct {
+		To string `json:"to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.To == "" {
+		http.Error(w, "'to' is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+
+	result, err := mail.Send(ctx, orgID, &mail.Message{
+		To:       []string{req.To},
+		Subject:  "Test email from Nodal",
+		TextBody: "Your mail settings are working.",
+	})
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "failed",
+			"error":  err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "sent",
+		"result": result,
+	})
+}
+
+func redactMailSettings(s mail.Settings) map[string]interface{} {
+	return map[string]interface{}{
+		"configured":    true,
+		"provider":      s.Provider,
+		"smtp_host":     s.Host,
+		"smtp_port":     s.Port,
+		"smtp_username": s.Username,
+		"smtp_security": s.Security,
+		"has_password":  s.PasswordCiphertext != "" || s.LegacyPassword != "",
+		"from_address":  s.FromAddress,
+		"from_name":     s.FromName,
+	}
+}
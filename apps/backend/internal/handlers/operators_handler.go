@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/compare"
+)
+
+// ListOperatorsHandler returns the comparison operators shared by the Filter, Condition
+// and Switch nodes, for the editor's operator pickers.
+// GET /api/operators
+func ListOperatorsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"operators": compare.Catalog(),
+	})
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/middleware"
+)
+
+// requireOrgAdmin verifies the caller is an admin or owner of orgID.
+// It writes the error response and returns false if not.
+func requireOrgAdmin(w http.ResponseWriter, r *http.Request, orgID string) bool {
+	callerID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	var callerMembership []struct {
+		Role string `json:"role"`
+	}
+	database.GetClient().DB.From("memberships").Select("role").Eq("user_id", callerID).Eq("org_id", orgID).Execute(&callerMembership)
+	if len(callerMembership) == 0 || (callerMembership[0].Role != "admin" && callerMembership[0].Role != "owner") {
+		http.Error(w, "Forbidden: requires admin or owner role", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// requireOrgMember verifies the caller belongs to orgID (any role).
+// It writes the error response and returns false if not.
+func requireOrgMember(w http.ResponseWriter, r *http.Request, orgID string) bool {
+	callerID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	var callerMembership []struct {
+		Role string `json:"role"`
+	}
+	database.GetClient().DB.From("memberships").Select("role").Eq("user_id", callerID).Eq("org_id", orgID).Execute(&callerMembership)
+	if len(callerMembership) == 0 {
+		http.Error(w, "Forbidden: not a member of this organization", http.StatusForbidden)
+		return false
+	}
+	return true
+}
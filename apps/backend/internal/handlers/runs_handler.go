@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/middleware"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/workflow"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+)
+
+type RunsHandler struct {
+	TemporalClient client.Client
+}
+
+func NewRunsHandler(c client.Client) *RunsHandler {
+	return &RunsHandler{
+		TemporalClient: c,
+	}
+}
+
+// runRecord is the action_flows row behind a production run.
+type runRecord struct {
+	ID          string  `json:"id"`
+	OrgID       string  `json:"org_id"`
+	FlowID      *string `json:"flow_id"`
+	RunID       string  `json:"run_id"`
+	VersionID   *string `json:"version_id"`
+	StartedAt   string  `json:"started_at"`
+	CompletedAt *string `json:"completed_at"`
+}
+
+// GetRun returns the status, per-node statuses, outputs and pending waits of a run.
+// ?wait=30s long-polls until the run reaches a terminal state (or the wait elapses).
+// GET /api/runs/{workflow_id}
+func (h *RunsHandler) GetRun(w http.ResponseWriter, r *http.Request) {
+	workflowID := r.PathValue("workflow_id")
+	if workflowID == "" {
+		http.Error(w, "Workflow ID required", http.StatusBadRequest)
+		return
+	}
+
+	record, ok := h.loadRun(w, r, workflowID)
+	if !ok {
+		return
+	}
+
+	// 1. Long-poll: block until the run finishes or the wait elapses
+	if wait := r.URL.Query().Get("wait"); wait != "" {
+		timeout, err := parseSyncTimeout(wait)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		extendWriteDeadline(w, timeout)
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		h.TemporalClient.GetWorkflow(ctx, workflowID, record.RunID).Get(ctx, nil)
+		cancel()
+		if r.Context().Err() != nil {
+			return
+		}
+	}
+
+	// 2. Temporal status
+	desc, err := h.TemporalClient.DescribeWorkflowExecution(r.Context(), workflowID, record.RunID)
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			http.Error(w, "Run not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to describe run: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	info := desc.WorkflowExecutionInfo
+	status := runStatus(info.GetStatus())
+
+	response := map[string]interface{}{
+		"workflow_id":    workflowID,
+		"run_id":         record.RunID,
+		"action_flow_id": record.ID,
+		"flow_id":        record.FlowID,
+		"version_id":     record.VersionID,
+		"status":         status,
+		"terminal":       status != "RUNNING",
+		"started_at":     info.GetStartTime().AsTime(),
+		"ended_at":       nil,
+	}
+	if info.GetCloseTime() != nil {
+		response["ended_at"] = info.GetCloseTime().AsTime()
+	}
+
+	// 3. Node statuses and outputs: the final result once done, the live state query while running
+	var state map[string]interface{}
+	errorsList := []map[string]interface{}{}
+	if status == "RUNNING" {
+		if encoded, err := h.TemporalClient.QueryWorkflow(r.Context(), workflowID, record.RunID, workflow.StateQuery); err == nil {
+			var live struct {
+				NodeStatus map[string]interface{} `json:"node_status"`
+				Outputs    map[string]interface{} `json:"outputs"`
+				Waiting    map[string]string      `json:"waiting"`
+			}
+			if encoded.Get(&live) == nil {
+				response["node_status"] = live.NodeStatus
+				response["waiting_until"] = live.Waiting
+				state = live.Outputs
+			}
+		}
+	} else {
+		err := h.TemporalClient.GetWorkflow(r.Context(), workflowID, record.RunID).Get(r.Context(), &state)
+		if err != nil {
+			errorsList = append(errorsList, map[string]interface{}{"message": err.Error()})
+		}
+		if state != nil {
+			response["node_status"] = state["__node_status"]
+			delete(state, "__node_status")
+			delete(state, workflow.ResponseStateKey)
+		}
+	}
+
+	// Failures absorbed by an on-error policy stay visible as node errors
+	nodeIDs := make([]string, 0, len(state))
+	for id := range state {
+		nodeIDs = append(nodeIDs, id)
+	}
+	sort.Strings(nodeIDs)
+	for _, id := range nodeIDs {
+		if out, ok := state[id].(map[string]interface{}); ok {
+			if failed, _ := out["_failed"].(bool); failed {
+				if details, ok := out["error"].(map[string]interface{}); ok {
+					errorsList = append(errorsList, details)
+				}
+			}
+		}
+	}
+	response["outputs"] = state
+	response["errors"] = errorsList
+
+	// 4. What the run is waiting on
+	if status == "RUNNING" {
+		response["pending_tasks"], response["pending_automations"] = pendingWaits(record.RunID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// loadRun finds the action flow of a run and checks it belongs to the API key's org.
+// Runs of another org are reported as not found.
+func (h *RunsHandler) loadRun(w http.ResponseWriter, r *http.Request, workflowID string) (*runRecord, bool) {
+	var records []runRecord
+	err := database.GetClient().DB.From("action_flows").
+		Select("id, org_id, flow_id, run_id, version_id, started_at, completed_at").
+		Eq("temporal_workflow_id", workflowID).
+		Execute(&records)
+	if err != nil {
+		http.Error(w, "Failed to fetch run: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	orgID, hasOrg := middleware.GetOrgID(r.Context())
+	var owned []runRecord
+	for _, rec := range records {
+		if hasOrg && rec.OrgID == orgID {
+			owned = append(owned, rec)
+		}
+	}
+	if len(owned) == 0 {
+		http.Error(w, "Run not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	// A workflow ID reused through an idempotency key keeps its latest run
+	sort.Slice(owned, func(i, j int) bool { return owned[i].StartedAt > owned[j].StartedAt })
+	return &owned[0], true
+}
+
+// pendingWaits lists the open human tasks and automation subscriptions of a run.
+func pendingWaits(runID string) ([]map[string]interface{}, []map[string]interface{}) {
+	dbClient := database.GetClient()
+
+	tasks := []map[string]interface{}{}
+	dbClient.DB.From("human_tasks").
+		Select("id, node_id, title, status, assignments, created_at").
+		Eq("run_id", runID).Eq("status", "PENDING").
+		Execute(&tasks)
+
+	var subs []struct {
+		StepID    string                 `json:"step_id"`
+		EventName string                 `json:"event_name"`
+		Criteria  map[string]interface{} `json:"criteria"`
+		CreatedAt *string                `json:"created_at"`
+	}
+	dbClient.DB.From("automation_subscriptions").
+		Select("step_id, event_name, criteria, created_at").
+		Eq("run_id", runID).Eq("status", "active").
+		Execute(&subs)
+
+	automations := make([]map[string]interface{}, 0, len(subs))
+	for _, s := range subs {
+		automations = append(automations, map[string]interface{}{
+			"node_id":    s.StepID,
+			"event_name": s.EventName,
+			"criteria":   s.Criteria,
+			"created_at": s.CreatedAt,
+		})
+	}
+	return tasks, automations
+}
+
+// runStatus maps a Temporal execution status to the run statuses of the API.
+func runStatus(s enumspb.WorkflowExecutionStatus) string {
+	switch s {
+	case enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING:
+		return "RUNNING"
+	case enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED:
+		return "COMPLETED"
+	case enumspb.WORKFLOW_EXECUTION_STATUS_FAILED:
+		return "FAILED"
+	case enumspb.WORKFLOW_EXECUTION_STATUS_CANCELED:
+		return "CANCELED"
+	case enumspb.WORKFLOW_EXECUTION_STATUS_TERMINATED:
+		return "TERMINATED"
+	case enumspb.WORKFLOW_EXECUTION_STATUS_TIMED_OUT:
+		return "TIMED_OUT"
+	case enumspb.WORKFLOW_EXECUTION_STATUS_CONTINUED_AS_NEW:
+		return "CONTINUED_AS_NEW"
+	}
+	return strings.TrimPrefix(s.String(), "WORKFLOW_EXECUTION_STATUS_")
+}
+
+// runStatusURL is where API callers poll a run they started.
+func runStatusURL(workflowID string) string {
+	return "/api/runs/" + workflowID
+}
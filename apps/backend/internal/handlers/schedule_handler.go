@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/workflow"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+)
+
+type ScheduleHandler struct {
+	TemporalClient client.Client
+}
+
+func NewScheduleHandler(c client.Client) *ScheduleHandler {
+	return &ScheduleHandler{
+		TemporalClient: c,
+	}
+}
+
+// ListSchedules returns the schedule triggers of a published flow with their state and upcoming fire times.
+// GET /api/flows/{id}/schedules?count=5
+func (h *ScheduleHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	flowID := r.PathValue("id")
+	if flowID == "" {
+		http.Error(w, "Flow ID required", http.StatusBadRequest)
+		return
+	}
+
+	flow, isActive, err := loadPublishedFlow(flowID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !requireOrgMember(w, r, flow.OrgID) {
+		return
+	}
+
+	triggers, err := workflow.ScheduleTriggers(*flow)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	count := 5
+	if c, err := strconv.Atoi(r.URL.Query().Get("count")); err == nil && c > 0 {
+		count = c
+	}
+
+	schedules := make([]map[string]interface{}, 0, len(triggers))
+	for _, t := range triggers {
+		entry := map[string]interface{}{
+			"node_id":     t.NodeID,
+			"schedule_id": workflow.ScheduleID(flowID, t.NodeID),
+			"cron":        t.Cron,
+			"timezone":    t.Timezone,
+			"registered":  false,
+		}
+
+		if h.TemporalClient != nil {
+			desc, err := h.TemporalClient.ScheduleClient().GetHandle(r.Context(), workflow.ScheduleID(flowID, t.NodeID)).Describe(r.Context())
+			if err == nil {
+				next := desc.Info.NextActionTimes
+				if len(next) > count {
+					next = next[:count]
+				}
+				recent := make([]map[string]interface{}, 0, len(desc.Info.RecentActions))
+				for _, a := range desc.Info.RecentActions {
+					run := map[string]interface{}{
+						"scheduled_at": a.ScheduleTime,
+						"started_at":   a.ActualTime,
+					}
+					if a.StartWorkflowResult != nil {
+						run["workflow_id"] = a.StartWorkflowResult.WorkflowID
+						run["run_id"] = a.StartWorkflowResult.FirstExecutionRunID
+					}
+					recent = append(recent, run)
+				}
+
+				entry["registered"] = true
+				entry["next_fire_times"] = next
+				entry["recent_runs"] = recent
+				entry["total_runs"] = desc.Info.NumActions
+				if desc.Schedule.State != nil {
+					entry["paused"] = desc.Schedule.State.Paused
+					entry["note"] = desc.Schedule.State.Note
+				}
+			} else {
+				var notFound *serviceerror.NotFound
+				if !errors.As(err, &notFound) {
+					entry["error"] = err.Error()
+				}
+			}
+		}
+		schedules = append(schedules, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"flow_id":   flowID,
+		"is_active": isActive,
+		"schedules": schedules,
+	})
+}
+
+// PauseSchedule stops a schedule trigger from firing until it is resumed.
+// POST /api/flows/{id}/schedules/{nodeId}/pause
+func (h *ScheduleHandler) PauseSchedule(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, true)
+}
+
+// ResumeSchedule resumes a paused schedule trigger.
+// POST /api/flows/{id}/schedules/{nodeId}/resume
+func (h *ScheduleHandler) ResumeSchedule(w http.ResponseWriter, r *http.Request) {
+	h.setPaused(w, r, false)
+}
+
+func (h *ScheduleHandler) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	flowID := r.PathValue("id")
+	nodeID := r.PathValue("nodeId")
+	if flowID == "" || nodeID == "" {
+		http.Error(w, "Flow ID and node ID required", http.StatusBadRequest)
+		return
+	}
+
+	flow, _, err := loadPublishedFlow(flowID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !requireOrgAdmin(w, r, flow.OrgID) {
+		return
+	}
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	handle := h.TemporalClient.ScheduleClient().GetHandle(r.Context(), workflow.ScheduleID(flowID, nodeID))
+
+	if paused {
+		if req.Note == "" {
+			req.Note = "Paused from the API"
+		}
+		err = handle.Pause(r.Context(), client.SchedulePauseOptions{Note: req.Note})
+	} else {
+		if req.Note == "" {
+			req.Note = "Resumed from the API"
+		}
+		err = handle.Unpause(r.Context(), client.ScheduleUnpauseOptions{Note: req.Note})
+	}
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			http.Error(w, "Schedule not found (is the flow published and active?)", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to update schedule: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"schedule_id": workflow.ScheduleID(flowID, nodeID),
+		"paused":      paused,
+		"note":        req.Note,
+	})
+}
+
+// loadPublishedFlow reads a flow's published definition (falling back to the legacy
+// definition column) with its ID, OrgID and current VersionID filled in.
+func loadPublishedFlow(flowID string) (*workflow.FlowDefinition, bool, error) {
+	var rows []struct {
+		OrgID               string          `json:"org_id"`
+		PublishedDefinition json.RawMessage `json:"published_definition"`
+		Definition          json.RawMessage `json:"definition"`
+		IsActive            bool            `json:"is_active"`
+		CurrentVersionID    *string         `json:"current_version_id"`
+	}
+	err := database.GetClient().DB.From("flows").Select("org_id, published_definition, definition, is_active, current_version_id").Eq("id", flowID).Execute(&rows)
+	if err != nil || len(rows) == 0 {
+		return nil, false, fmt.Errorf("flow not found")
+	}
+
+	raw := rows[0].PublishedDefinition
+	if len(raw) == 0 || string(raw) == "null" {
+		raw = rows[0].Definition
+	}
+
+	var flow workflow.FlowDefinition
+	if len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &flow); err != nil {
+			return nil, false, fmt.Errorf("invalid flow definition: %w", err)
+		}
+	}
+	flow.ID = flowID
+	flow.OrgID = rows[0].OrgID
+	if rows[0].CurrentVersionID != nil {
+		flow.VersionID = *rows[0].CurrentVersionID
+	}
+	return &flow, rows[0].IsActive, nil
+}
+
+// toFlowDefinition converts a definition column (decoded as a generic map) to a FlowDefinition.
+func toFlowDefinition(def map[string]interface{}) (workflow.FlowDefinition, error) {
+	var flow workflow.FlowDefinition
+	raw, err := json.Marshal(def)
+	if err != nil {
+		return flow, err
+	}
+	err = json.Unmarshal(raw, &flow)
+	return flow, err
+}
+
+// syncSchedulesForActivation registers or removes a flow's schedules after its
+// is_active flag changed.
+func syncSchedulesForActivation(c client.Client, flowID string, active bool) error {
+	if c == nil {
+		return nil
+	}
+	flow, _, err := loadPublishedFlow(flowID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if active {
+		return workflow.SyncFlowSchedules(ctx, c, *flow, nil)
+	}
+	return workflow.DeleteFlowSchedules(ctx, c, *flow)
+}
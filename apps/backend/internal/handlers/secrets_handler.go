@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/audit"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/middleware"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/secrets"
+)
+
+type SecretsHandler struct{}
+
+func NewSecretsHandler() *SecretsHandler {
+	return &SecretsHandler{}
+}
+
+type CreateSecretRequest struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+}
+
+type UpdateSecretRequest struct {
+	Value       *string `json:"value"`       // nil keeps the stored value
+	Description *string `json:"description"` // nil keeps the description
+}
+
+// ListSecrets returns the organization's secrets, without their values.
+// GET /api/orgs/{orgId}/secrets
+func (h *SecretsHandler) ListSecrets(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("orgId")
+	if orgID == "" {
+		http.Error(w, "Organization ID required", http.StatusBadRequest)
+		return
+	}
+	if !requireOrgMember(w, r, orgID) {
+		return
+	}
+
+	var rows []secrets.Secret
+	err := database.GetClient().DB.From("org_secrets").Select("id,org_id,name,description,created_by,created_at,updated_at").Eq("org_id", orgID).Execute(&rows)
+	if err != nil {
+		http.Error(w, "Failed to list secrets: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	list := make([]map[string]interface{}, 0, len(rows))
+	for _, s := range rows {
+		list = append(list, redactSecret(s))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// CreateSecret encrypts and stores a new secret. The value is never returned afterwards.
+// POST /api/orgs/{orgId}/secrets
+func (h *SecretsHandler) CreateSecret(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("orgId")
+	if orgID == "" {
+		http.Error(w, "Organization ID required", http.StatusBadRequest)
+		return
+	}
+	if !requireOrgAdmin(w, r, orgID) {
+		return
+	}
+
+	var req CreateSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if err := secrets.ValidateName(req.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Value == "" {
+		http.Error(w, "value is required", http.StatusBadRequest)
+		return
+	}
+
+	dbClient := database.GetClient()
+	var existing []secrets.Secret
+	dbClient.DB.From("org_secrets").Select("id").Eq("org_id", orgID).Eq("name", req.Name).Execute(&existing)
+	if len(existing) > 0 {
+		http.Error(w, "A secret with this name already exists", http.StatusConflict)
+		return
+	}
+
+	ciphertext, err := secrets.Seal(orgID, req.Name, req.Value)
+	if err != nil {
+		http.Error(w, "Failed to encrypt secret: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	userID, _ := r.Context().Value(middleware.UserIDKey).(string)
+	record := map[string]interface{}{
+		"org_id":      orgID,
+		"name":        req.Name,
+		"description": req.Description,
+		"ciphertext":  ciphertext,
+	}
+	if userID != "" {
+		record["created_by"] = userID
+	}
+
+	var results []secrets.Secret
+	if err := dbClient.DB.From("org_secrets").Insert(record).Execute(&results); err != nil {
+		http.Error(w, "Failed to save secret: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(results) == 0 {
+		http.Error(w, "Failed to save secret", http.StatusInternalServerError)
+		return
+	}
+
+	logSecretActivity(r, orgID, "secret.created", results[0])
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(redactSecret(results[0]))
+}
+
+// UpdateSecret replaces a secret's value and/or description.
+// PUT /api/orgs/{orgId}/secrets/{name}
+func (h *SecretsHandler) UpdateSecret(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("orgId")
+	name := r.PathValue("name")
+	if orgID == "" || name == "" {
+		http.Error(w, "Organization ID and secret name required", http.StatusBadRequest)
+		return
+	}
+	if !requireOrgAdmin(w, r, orgID) {
+		return
+	}
+
+	var req UpdateSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	record := map[string]interface{}{
+		"updated_at": time.Now(),
+	}
+	if req.Value != nil {
+		if *req.Value == "" {
+			http.Error(w, "value cannot be empty", http.StatusBadRequest)
+			return
+		}
+		ciphertext, err := secrets.Seal(orgID, name, *req.Value)
+		if err != nil {
+			http.Error(w, "Failed to encrypt secret: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		record["ciphertext"] = ciphertext
+	}
+	if req.Description != nil {
+		record["description"] = *req.Description
+	}
+
+	var results []secrets.Secret
+	err := database.GetClient().DB.From("org_secrets").Update(record).Eq("org_id", orgID).Eq("name", name).Execute(&results)
+	if err != nil {
+		http.Error(w, "Failed to update secret: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(results) == 0 {
+		http.Error(w, "Secret not found", http.StatusNotFound)
+		return
+	}
+
+	logSecretActivity(r, orgID, "secret.updated", results[0])
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(redactSecret(results[0]))
+}
+
+// DeleteSecret removes a secret. Flows still referencing it fail when they read it.
+// DELETE /api/orgs/{orgId}/secrets/{name}
+func (h *SecretsHandler) DeleteSecret(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("orgId")
+	name := r.PathValue("name")
+	if orgID == "" || name == "" {
+		http.Error(w, "Organization ID and secret name required", http.StatusBadRequest)
+		return
+	}
+	if !requireOrgAdmin(w, r, orgID) {
+		return
+	}
+
+	dbClient := database.GetClient()
+	var existing []secrets.Secret
+	dbClient.DB.From("org_secrets").Select("id,name").Eq("org_id", orgID).Eq("name", name).Execute(&existing)
+	if len(existing) == 0 {
+		http.Error(w, "Secret not found", http.StatusNotFound)
+		return
+	}
+
+	var results []map[string]interface{}
+	if err := dbClient.DB.From("org_secrets").Delete().Eq("id", existing[0].ID).Execute(&results); err != nil {
+		http.Error(w, "Failed to delete secret: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logSecretActivity(r, orgID, "secret.deleted", existing[0])
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func logSecretActivity(r *http.Request, orgID, event string, s secrets.Secret) {
+	var userPtr *string
+	if userID, ok := r.Context().Value(middleware.UserIDKey).(string); ok && userID != "" {
+		userPtr = &userID
+	}
+	audit.LogActivity(r.Context(), orgID, userPtr, event, &s.ID, map[string]interface{}{
+		"name": s.Name,
+	}, r.RemoteAddr)
+}
+
+func redactSecret(s secrets.Secret) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          s.ID,
+		"name":        s.Name,
+		"description": s.Description,
+		"reference":   "{{ secrets." + s.Name + " }}",
+		"created_by":  s.CreatedBy,
+		"created_at":  s.CreatedAt,
+		"updated_at":  s.UpdatedAt,
+	}
+}
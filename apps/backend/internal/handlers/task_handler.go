@@ -9,6 +9,7 @@ import (
 
 	"github.com/teavana/enigmatic_s/apps/backend/internal/audit"
 	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/events"
 	"go.temporal.io/sdk/client"
 )
 
@@ -220,6 +221,14 @@ func (h *HumanTaskHandler) CompleteTaskHandler(w http.ResponseWriter, r *http.Re
 	}
 	fmt.Printf("DEBUG: SignalWorkflow SUCCESS for WorkflowID=%s RunID=%s Signal=%s\n", workflowID, task[0].RunID, signalName)
 
+	events.Publish(events.Event{
+		Type:       events.TaskCompleted,
+		OrgID:      actionFlow[0].OrgID,
+		WorkflowID: workflowID,
+		RunID:      task[0].RunID,
+		Data:       map[string]interface{}{"task_id": taskID, "title": task[0].Title},
+	})
+
 	// Log Activity: Task Completed
 	// We extract user info from context if available (TODO: Add Auth Middleware extraction)
 	// For now, assume userID is nil or extracted from elsewhere.
@@ -152,7 +152,7 @@ func (h *TestHandler) TestFlow(w http.ResponseWriter, r *http.Request) {
 
 	options := client.StartWorkflowOptions{
 		ID:        "test-flow-" + generateID(),
-		TaskQueue: "nodal-task-queue",
+		TaskQueue: workflow.TaskQueue,
 	}
 
 	// Execute with Input Data
@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/nodes"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/secrets"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/webhook"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/workflow"
+	"go.temporal.io/sdk/client"
+)
+
+// maxWebhookBody bounds the body of an inbound webhook.
+const maxWebhookBody = 5 << 20
+
+type WebhookTriggerHandler struct {
+	TemporalClient client.Client
+}
+
+func NewWebhookTriggerHandler(c client.Client) *WebhookTriggerHandler {
+	return &WebhookTriggerHandler{
+		TemporalClient: c,
+	}
+}
+
+// TriggerFlow starts a published flow from a call to the URL of one of its
+// webhook-trigger nodes. The run gets the request as { body, headers, query, method }
+// and the caller gets the node's responseCode and responseBody.
+// POST /api/webhooks/flows/{token}
+// External systems call this URL — no auth, the token (and the signature, when the
+// node requires one) authenticates the caller.
+func (h *WebhookTriggerHandler) TriggerFlow(w http.ResponseWriter, r *http.Request) {
+	endpoint, err := webhook.LookupEndpoint(r.PathValue("token"))
+	if errors.Is(err, webhook.ErrNotFound) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	flow, isActive, err := loadPublishedFlow(endpoint.FlowID)
+	if err != nil {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if !isActive {
+		http.Error(w, "Flow is not active", http.StatusConflict)
+		return
+	}
+	triggers, err := workflow.WebhookTriggers(*flow)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	var trigger *workflow.WebhookTrigger
+	for i := range triggers {
+		if triggers[i].NodeID == endpoint.NodeID {
+			trigger = &triggers[i]
+		}
+	}
+	if trigger == nil {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	body, ok := readWebhookBody(w, r)
+	if !ok {
+		return
+	}
+	scope := "trigger:" + endpoint.ID
+	var delivery *webhook.Delivery
+	if trigger.Signature != nil {
+		if delivery, ok = verifyWebhook(w, r, flow.OrgID, scope, trigger.Signature, body); !ok {
+			return
+		}
+	}
+
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        "flow-" + flow.ID + "-webhook-" + fmt.Sprintf("%d", time.Now().UnixNano()),
+		TaskQueue: workflow.TaskQueue,
+	}
+	input := workflow.WebhookInput(trigger.NodeID, webhookRequest(r, body))
+	we, err := h.TemporalClient.ExecuteWorkflow(context.Background(), workflowOptions, workflow.NodalWorkflow, *flow, input)
+	if err != nil {
+		if delivery != nil {
+			webhook.Forget(scope, delivery)
+		}
+		log.Printf("Failed to start flow %s from webhook node %s: %v", flow.ID, trigger.NodeID, err)
+		http.Error(w, "Failed to start workflow", http.StatusInternalServerError)
+		return
+	}
+
+	switch response := trigger.ResponseBody.(type) {
+	case nil:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(trigger.ResponseCode)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message":     "Flow execution started",
+			"workflow_id": we.GetID(),
+			"run_id":      we.GetRunID(),
+		})
+	case string:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(trigger.ResponseCode)
+		w.Write([]byte(response))
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(trigger.ResponseCode)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// ListFlowWebhooks returns the URLs of a published flow's webhook triggers.
+// GET /api/flows/{id}/webhooks
+func (h *WebhookTriggerHandler) ListFlowWebhooks(w http.ResponseWriter, r *http.Request) {
+	flowID := r.PathValue("id")
+	if flowID == "" {
+		http.Error(w, "Flow ID required", http.StatusBadRequest)
+		return
+	}
+
+	flow, isActive, err := loadPublishedFlow(flowID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !requireOrgMember(w, r, flow.OrgID) {
+		return
+	}
+
+	triggers, err := workflow.WebhookTriggers(*flow)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	endpoints, err := webhook.ListEndpoints(flowID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	byNode := make(map[string]webhook.Endpoint, len(endpoints))
+	for _, e := range endpoints {
+		byNode[e.NodeID] = e
+	}
+
+	list := make([]map[string]interface{}, 0, len(triggers))
+	for _, t := range triggers {
+		entry := map[string]interface{}{
+			"node_id":       t.NodeID,
+			"registered":    false,
+			"signed":        t.Signature != nil,
+			"response_code": t.ResponseCode,
+		}
+		if t.Signature != nil {
+			entry["preset"] = t.Signature.Preset
+		}
+		if e, ok := byNode[t.NodeID]; ok {
+			entry["registered"] = true
+			entry["url"] = e.URL()
+			entry["created_at"] = e.CreatedAt
+		}
+		list = append(list, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"flow_id":   flowID,
+		"is_active": isActive,
+		"webhooks":  list,
+	})
+}
+
+// syncFlowWebhooks registers the webhook-trigger nodes of a flow being published and
+// returns their URLs. flow must carry its ID and OrgID.
+func syncFlowWebhooks(flow workflow.FlowDefinition) ([]map[string]string, error) {
+	triggers, err := workflow.WebhookTriggers(flow)
+	if err != nil {
+		return nil, err
+	}
+	nodeIDs := make([]string, 0, len(triggers))
+	for _, t := range triggers {
+		nodeIDs = append(nodeIDs, t.NodeID)
+	}
+	endpoints, err := webhook.SyncEndpoints(flow.OrgID, flow.ID, nodeIDs)
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]map[string]string, 0, len(endpoints))
+	for _, e := range endpoints {
+		urls = append(urls, map[string]string{"node_id": e.NodeID, "url": e.URL()})
+	}
+	return urls, nil
+}
+
+// readWebhookBody reads the raw body of a webhook, which signatures are computed over.
+func readWebhookBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+// verifyWebhook checks the signature of a webhook call and records the delivery, so
+// that it is accepted only once. It answers the caller itself when the call is refused.
+func verifyWebhook(w http.ResponseWriter, r *http.Request, orgID, scope string, signature *webhook.Signature, body []byte) (*webhook.Delivery, bool) {
+	secret, err := webhookSecret(orgID, signature.Secret)
+	if err != nil {
+		log.Printf("Failed to resolve webhook secret for %s: %v", scope, err)
+		http.Error(w, "Webhook signing secret unavailable", http.StatusInternalServerError)
+		return nil, false
+	}
+	delivery, err := signature.Verify(secret, r.Header, body, time.Now())
+	if err != nil {
+		log.Printf("Rejected webhook call to %s from %s: %v", scope, r.RemoteAddr, err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+	if err := webhook.Remember(scope, delivery); err != nil {
+		if errors.Is(err, webhook.ErrReplayed) {
+			http.Error(w, "Webhook delivery already received", http.StatusConflict)
+			return nil, false
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return delivery, true
+}
+
+// webhookSecret resolves a signing secret, usually a {{ secrets.NAME }} reference.
+func webhookSecret(orgID, raw string) (string, error) {
+	if len(nodes.SecretReferences(raw)) == 0 {
+		return raw, nil
+	}
+	values, err := secrets.Load(orgID)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := nodes.NewExpressionEngine().Evaluate(raw, nodes.NodeContext{OrgID: orgID, Secrets: values})
+	if err != nil {
+		return "", err
+	}
+	secret, _ := resolved.(string)
+	if secret == "" {
+		return "", fmt.Errorf("secret resolves to an empty value")
+	}
+	return secret, nil
+}
+
+// webhookRequest describes a webhook call for the run it starts. JSON and form bodies
+// are decoded; anything else is passed as text.
+func webhookRequest(r *http.Request, body []byte) map[string]interface{} {
+	headers := make(map[string]interface{}, len(r.Header))
+	for name, values := range r.Header {
+		switch lower := strings.ToLower(name); lower {
+		case "authorization", "proxy-authorization", "cookie":
+			continue
+		default:
+			headers[lower] = strings.Join(values, ", ")
+		}
+	}
+
+	var payload interface{} = string(body)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case len(body) == 0:
+		payload = map[string]interface{}{}
+	case mediaType == "application/x-www-form-urlencoded":
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			payload = flattenValues(values)
+		}
+	default:
+		var decoded interface{}
+		if err := json.Unmarshal(body, &decoded); err == nil {
+			payload = decoded
+		}
+	}
+
+	return map[string]interface{}{
+		"method":  r.Method,
+		"headers": headers,
+		"query":   flattenValues(r.URL.Query()),
+		"body":    payload,
+	}
+}
+
+// flattenValues keeps single values as strings and repeated ones as lists.
+func flattenValues(values url.Values) map[string]interface{} {
+	out := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if len(v) == 1 {
+			out[k] = v[0]
+			continue
+		}
+		list := make([]interface{}, len(v))
+		for i, s := range v {
+			list[i] = s
+		}
+		out[k] = list
+	}
+	return out
+}
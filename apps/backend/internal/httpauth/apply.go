@@ -0,0 +1,51 @@
+package httpauth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Apply sets the profile's credentials on req. It returns the credential values it
+// used (password, token, key...) so the caller can redact them from what it records.
+func Apply(ctx context.Context, req *http.Request, p *Profile, secret string) ([]string, error) {
+	switch p.Type {
+	case TypeBasic:
+		req.SetBasicAuth(p.Settings.Username, secret)
+		return []string{secret, req.Header.Get("Authorization")}, nil
+
+	case TypeBearer:
+		req.Header.Set("Authorization", "Bearer "+secret)
+		return []string{secret}, nil
+
+	case TypeAPIKey:
+		value := p.Settings.KeyPrefix + secret
+		if p.Settings.KeyIn == KeyInQuery {
+			query := req.URL.Query()
+			query.Set(p.Settings.KeyName, value)
+			req.URL.RawQuery = query.Encode()
+		} else {
+			req.Header.Set(p.Settings.KeyName, value)
+		}
+		return []string{secret}, nil
+
+	case TypeOAuth2ClientCredentials:
+		token, err := cachedToken(ctx, p, secret)
+		if err != nil {
+			return []string{secret}, err
+		}
+		tokenType := token.TokenType
+		if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+			tokenType = "Bearer"
+		}
+		req.Header.Set("Authorization", tokenType+" "+token.AccessToken)
+		return []string{secret, token.AccessToken}, nil
+	}
+	return nil, nil
+}
+
+// Retryable tells whether a request rejected with status should be sent again after
+// Invalidate: only OAuth2 tokens can be renewed (e.g. revoked before they expired).
+func Retryable(p *Profile, status int) bool {
+	return p.Type == TypeOAuth2ClientCredentials && status == http.StatusUnauthorized
+}
@@ -0,0 +1,194 @@
+package httpauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/egress"
+)
+
+// Token is an OAuth2 access token returned by a token endpoint.
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+
+	expiresAt time.Time
+}
+
+// Tokens are renewed this long before they expire, and kept this long when the
+// endpoint doesn't say (expires_in missing).
+const (
+	refreshMargin        = 30 * time.Second
+	defaultTokenLifetime = 5 * time.Minute
+)
+
+// TokenClient returns the client that fetches a profile's tokens. Token endpoints are
+// outbound calls like any other, so the org's egress policy applies to them. Replace it
+// to route token requests differently.
+var TokenClient = func(p *Profile) (*http.Client, error) {
+	policy, err := egress.Load(p.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	policy = policy.WithOrigin(map[string]interface{}{
+		"source":          "oauth2_token",
+		"auth_profile_id": p.ID,
+	})
+	return policy.Client(15 * time.Second), nil
+}
+
+// TokenError is a failed token request. The message is meant for the node's error.
+type TokenError struct {
+	TokenURL    string
+	StatusCode  int    // 0 when the endpoint couldn't be reached
+	Code        string // OAuth2 "error", e.g. invalid_client
+	Description string // OAuth2 "error_description"
+	Err         error
+}
+
+func (e *TokenError) Error() string {
+	msg := fmt.Sprintf("OAuth2 token request to %s failed", e.TokenURL)
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(": HTTP %d", e.StatusCode)
+	}
+	if e.Code != "" {
+		msg += ": " + e.Code
+	}
+	if e.Description != "" {
+		msg += " (" + e.Description + ")"
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *TokenError) Unwrap() error { return e.Err }
+
+// Cached tokens are keyed by profile and last update, so editing a profile drops them.
+type cacheEntry struct {
+	mu    sync.Mutex
+	token *Token
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]*cacheEntry)
+)
+
+func cacheKey(p *Profile) string {
+	return p.ID + "|" + p.UpdatedAt
+}
+
+func entryFor(p *Profile) *cacheEntry {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	key := cacheKey(p)
+	entry, ok := cache[key]
+	if !ok {
+		entry = &cacheEntry{}
+		cache[key] = entry
+	}
+	return entry
+}
+
+// cachedToken returns the profile's token, fetching a new one when there is none or it
+// is about to expire. Concurrent callers for the same profile share one fetch.
+func cachedToken(ctx context.Context, p *Profile, secret string) (*Token, error) {
+	entry := entryFor(p)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.token != nil && time.Now().Add(refreshMargin).Before(entry.token.expiresAt) {
+		return entry.token, nil
+	}
+	token, err := FetchToken(ctx, p, secret)
+	if err != nil {
+		entry.token = nil
+		return nil, err
+	}
+	entry.token = token
+	return token, nil
+}
+
+// Invalidate drops the cached token of a profile, e.g. after the API rejected it.
+func Invalidate(p *Profile) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	delete(cache, cacheKey(p))
+}
+
+// FetchToken requests a new token from the profile's token endpoint, bypassing the cache.
+func FetchToken(ctx context.Context, p *Profile, secret string) (*Token, error) {
+	s := p.Settings
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.Scopes, " "))
+	}
+	if s.Audience != "" {
+		form.Set("audience", s.Audience)
+	}
+	if s.ClientAuth == ClientAuthBody {
+		form.Set("client_id", s.ClientID)
+		form.Set("client_secret", secret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, &TokenError{TokenURL: s.TokenURL, Err: err}
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.ClientAuth != ClientAuthBody {
+		req.SetBasicAuth(url.QueryEscape(s.ClientID), url.QueryEscape(secret))
+	}
+
+	client, err := TokenClient(p)
+	if err != nil {
+		return nil, &TokenError{TokenURL: s.TokenURL, Err: err}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &TokenError{TokenURL: s.TokenURL, Err: err}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, &TokenError{TokenURL: s.TokenURL, StatusCode: resp.StatusCode, Err: err}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		tokenErr := &TokenError{TokenURL: s.TokenURL, StatusCode: resp.StatusCode}
+		var failure struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &failure) == nil {
+			tokenErr.Code, tokenErr.Description = failure.Error, failure.ErrorDescription
+		}
+		return nil, tokenErr
+	}
+
+	var token Token
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, &TokenError{TokenURL: s.TokenURL, StatusCode: resp.StatusCode, Err: fmt.Errorf("response is not JSON")}
+	}
+	if token.AccessToken == "" {
+		return nil, &TokenError{TokenURL: s.TokenURL, StatusCode: resp.StatusCode, Err: fmt.Errorf("response has no access_token")}
+	}
+	lifetime := defaultTokenLifetime
+	if token.ExpiresIn > 0 {
+		lifetime = time.Duration(token.ExpiresIn) * time.Second
+	}
+	token.expiresAt = time.Now().Add(lifetime)
+	return &token, nil
+}
@@ -0,0 +1,297 @@
+package httpauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tokenServer is a stand-in token endpoint. Each request gets a new access token;
+// reply, when set, answers instead.
+type tokenServer struct {
+	*httptest.Server
+	requests  atomic.Int32
+	expiresIn int
+	reply     func(w http.ResponseWriter, r *http.Request)
+	lastForm  atomic.Value // url.Values of the last request
+	lastAuth  atomic.Value // client_id:client_secret from Basic auth
+}
+
+func newTokenServer(t *testing.T) *tokenServer {
+	t.Helper()
+	s := &tokenServer{expiresIn: 3600}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := s.requests.Add(1)
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("token request: %v", err)
+		}
+		s.lastForm.Store(r.PostForm)
+		user, pass, _ := r.BasicAuth()
+		s.lastAuth.Store(user + ":" + pass)
+
+		if s.reply != nil {
+			s.reply(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token-%d", n),
+			"token_type":   "bearer",
+			"expires_in":   s.expiresIn,
+		})
+	}))
+	t.Cleanup(s.Close)
+
+	// Egress refuses loopback addresses: send token requests straight to the stand-in
+	previous := TokenClient
+	TokenClient = func(*Profile) (*http.Client, error) { return s.Client(), nil }
+	t.Cleanup(func() { TokenClient = previous })
+	return s
+}
+
+func oauth2Profile(t *testing.T, tokenURL string) *Profile {
+	return &Profile{
+		ID:        t.Name(), // one cache entry per test
+		OrgID:     "org",
+		Type:      TypeOAuth2ClientCredentials,
+		UpdatedAt: time.Now().Format(time.RFC3339Nano),
+		Settings: Settings{
+			TokenURL: tokenURL,
+			ClientID: "client id",
+			Scopes:   []string{"read", "write"},
+			Audience: "https://api.example.com",
+		},
+	}
+}
+
+func TestFetchToken(t *testing.T) {
+	srv := newTokenServer(t)
+	p := oauth2Profile(t, srv.URL)
+
+	token, err := FetchToken(context.Background(), p, "s3cret&")
+	if err != nil {
+		t.Fatalf("FetchToken: %v", err)
+	}
+	if token.AccessToken != "token-1" || token.TokenType != "bearer" {
+		t.Errorf("token = %+v", token)
+	}
+	if until := time.Until(token.expiresAt); until < 59*time.Minute || until > time.Hour {
+		t.Errorf("token expires in %s, want about an hour", until)
+	}
+
+	form := srv.lastForm.Load().(url.Values)
+	if got := form.Get("grant_type"); got != "client_credentials" {
+		t.Errorf("grant_type = %q", got)
+	}
+	if got := form.Get("scope"); got != "read write" {
+		t.Errorf("scope = %q", got)
+	}
+	if got := form.Get("audience"); got != "https://api.example.com" {
+		t.Errorf("audience = %q", got)
+	}
+	if form.Has("client_secret") {
+		t.Error("client_secret sent in the body with basic client auth")
+	}
+	// RFC 6749 2.3.1: credentials are form-encoded before Basic encoding
+	if got := srv.lastAuth.Load().(string); got != "client+id:s3cret%26" {
+		t.Errorf("basic auth = %q", got)
+	}
+}
+
+func TestFetchTokenClientAuthBody(t *testing.T) {
+	srv := newTokenServer(t)
+	p := oauth2Profile(t, srv.URL)
+	p.Settings.ClientAuth = ClientAuthBody
+
+	if _, err := FetchToken(context.Background(), p, "s3cret"); err != nil {
+		t.Fatalf("FetchToken: %v", err)
+	}
+	form := srv.lastForm.Load().(url.Values)
+	if form.Get("client_id") != "client id" || form.Get("client_secret") != "s3cret" {
+		t.Errorf("form = %v", form)
+	}
+	if got := srv.lastAuth.Load().(string); got != ":" {
+		t.Errorf("basic auth sent with body client auth: %q", got)
+	}
+}
+
+func TestApplyCachesToken(t *testing.T) {
+	srv := newTokenServer(t)
+	p := oauth2Profile(t, srv.URL)
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "https://api.example.com/items", nil)
+		credentials, err := Apply(context.Background(), req, p, "s3cret")
+		if err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+		if got := req.Header.Get("Authorization"); got != "Bearer token-1" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if len(credentials) != 2 || credentials[0] != "s3cret" || credentials[1] != "token-1" {
+			t.Errorf("credentials = %v", credentials)
+		}
+	}
+	if n := srv.requests.Load(); n != 1 {
+		t.Errorf("%d token requests, want 1", n)
+	}
+
+	// Editing the profile drops its cached token
+	p.UpdatedAt = time.Now().Add(time.Second).Format(time.RFC3339Nano)
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/items", nil)
+	if _, err := Apply(context.Background(), req, p, "s3cret"); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer token-2" {
+		t.Errorf("Authorization after edit = %q", got)
+	}
+}
+
+func TestApplyRenewsExpiringToken(t *testing.T) {
+	srv := newTokenServer(t)
+	srv.expiresIn = int(refreshMargin.Seconds()) - 1
+	p := oauth2Profile(t, srv.URL)
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "https://api.example.com/items", nil)
+		if _, err := Apply(context.Background(), req, p, "s3cret"); err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+	}
+	if n := srv.requests.Load(); n != 2 {
+		t.Errorf("%d token requests, want 2: a token inside the refresh margin is renewed", n)
+	}
+}
+
+func TestApplyRefreshesAfterUnauthorized(t *testing.T) {
+	srv := newTokenServer(t)
+	p := oauth2Profile(t, srv.URL)
+
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/items", nil)
+	if _, err := Apply(context.Background(), req, p, "s3cret"); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	// The API revoked the token: the HTTP node invalidates it and sends the request again
+	if !Retryable(p, http.StatusUnauthorized) {
+		t.Fatal("a 401 with an OAuth2 profile should be retryable")
+	}
+	if Retryable(p, http.StatusForbidden) {
+		t.Error("a 403 shouldn't be retryable")
+	}
+	Invalidate(p)
+
+	req = httptest.NewRequest(http.MethodGet, "https://api.example.com/items", nil)
+	if _, err := Apply(context.Background(), req, p, "s3cret"); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer token-2" {
+		t.Errorf("Authorization after refresh = %q", got)
+	}
+
+	bearer := &Profile{Type: TypeBearer}
+	if Retryable(bearer, http.StatusUnauthorized) {
+		t.Error("a static bearer token can't be renewed")
+	}
+}
+
+func TestTokenErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		reply  func(w http.ResponseWriter, r *http.Request)
+		status int
+		code   string
+		want   string
+	}{
+		{
+			name: "oauth2 error",
+			reply: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"invalid_client","error_description":"unknown client"}`))
+			},
+			status: http.StatusUnauthorized,
+			code:   "invalid_client",
+			want:   "HTTP 401: invalid_client (unknown client)",
+		},
+		{
+			name: "server error",
+			reply: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "upstream down", http.StatusBadGateway)
+			},
+			status: http.StatusBadGateway,
+			want:   "HTTP 502",
+		},
+		{
+			name: "not json",
+			reply: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("<html>login</html>"))
+			},
+			status: http.StatusOK,
+			want:   "response is not JSON",
+		},
+		{
+			name: "no access token",
+			reply: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"token_type":"bearer"}`))
+			},
+			status: http.StatusOK,
+			want:   "response has no access_token",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTokenServer(t)
+			srv.reply = tt.reply
+			p := oauth2Profile(t, srv.URL)
+
+			req := httptest.NewRequest(http.MethodGet, "https://api.example.com/items", nil)
+			credentials, err := Apply(context.Background(), req, p, "s3cret")
+			var tokenErr *TokenError
+			if !errors.As(err, &tokenErr) {
+				t.Fatalf("Apply error = %v, want a *TokenError", err)
+			}
+			if tokenErr.StatusCode != tt.status || tokenErr.Code != tt.code {
+				t.Errorf("TokenError = %+v", tokenErr)
+			}
+			if !strings.Contains(err.Error(), srv.URL) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want the token URL and %q", err, tt.want)
+			}
+			if req.Header.Get("Authorization") != "" {
+				t.Error("Authorization set after a failed token request")
+			}
+			if len(credentials) != 1 || credentials[0] != "s3cret" {
+				t.Errorf("credentials = %v, want the client secret for redaction", credentials)
+			}
+
+			// Failures aren't cached: the next request asks again
+			Apply(context.Background(), req, p, "s3cret")
+			if n := srv.requests.Load(); n != 2 {
+				t.Errorf("%d token requests, want 2", n)
+			}
+		})
+	}
+}
+
+func TestTokenEndpointUnreachable(t *testing.T) {
+	srv := newTokenServer(t)
+	p := oauth2Profile(t, srv.URL)
+	srv.Close()
+
+	_, err := FetchToken(context.Background(), p, "s3cret")
+	var tokenErr *TokenError
+	if !errors.As(err, &tokenErr) {
+		t.Fatalf("FetchToken error = %v, want a *TokenError", err)
+	}
+	if tokenErr.StatusCode != 0 || tokenErr.Err == nil {
+		t.Errorf("TokenError = %+v, want no status and the connection error", tokenErr)
+	}
+}
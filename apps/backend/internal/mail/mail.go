@@ -0,0 +1,204 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/egress"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/secrets"
+)
+
+// Message is a fully resolved email ready to hand to a Transport.
+type Message struct {
+	From        Address
+	ReplyTo     string
+	To          []string
+	Cc          []string
+	Bcc         []string
+	Subject     string
+	TextBody    string
+	HTMLBody    string
+	Attachments []Attachment
+}
+
+// Address is a mailbox with an optional display name.
+type Address struct {
+	Name  string
+	Email string
+}
+
+// Attachment is a file attached to a Message.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// DeliveryResult describes what the transport did with a Message.
+type DeliveryResult struct {
+	MessageID string            `json:"message_id"`
+	Provider  string            `json:"provider"`
+	Accepted  []string          `json:"accepted"`
+	Rejected  map[string]string `json:"rejected,omitempty"` // recipient -> reason
+
+	// Submitted is set once the server took the message data. A send that fails after
+	// that may still have reached the accepted recipients; one that fails before didn't.
+	Submitted bool `json:"-"`
+}
+
+// Transport delivers messages through a concrete provider (SMTP, HTTP mail API, ...).
+type Transport interface {
+	Send(ctx context.Context, msg *Message) (*DeliveryResult, error)
+}
+
+// Settings is an organization's mail configuration (the mail_settings table).
+type Settings struct {
+	OrgID       string `json:"org_id"`
+	Provider    string `json:"provider"` // "smtp"
+	Host        string `json:"smtp_host"`
+	Port        int    `json:"smtp_port"`
+	Username    string `json:"smtp_username"`
+	Password    string `json:"-"`             // decrypted by LoadSettings, never stored as is
+	Security    string `json:"smtp_security"` // "starttls" (default), "tls", "none"
+	FromAddress string `json:"from_address"`
+	FromName    string `json:"from_name"`
+
+	// PasswordCiphertext is the password sealed with the secrets master key.
+	PasswordCiphertext string `json:"smtp_password_ciphertext,omitempty"`
+	// LegacyPassword is a password saved in plain text before passwords were encrypted.
+	// It is used until the settings are saved again, which encrypts it.
+	LegacyPassword string `json:"smtp_password,omitempty"`
+
+	// Dial connects to the mail server; nil dials directly. Send sets it to the org's
+	// egress policy for servers the org configured.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error) `json:"-"`
+}
+
+const (
+	ProviderSMTP = "smtp"
+
+	SecuritySTARTTLS = "starttls"
+	SecurityTLS      = "tls"
+	SecurityNone     = "none"
+)
+
+// Providers maps a provider name to its Transport constructor.
+// Add an entry here to support a new delivery backend (e.g. an HTTP mail API).
+var Providers = map[string]func(s Settings) (Transport, error){
+	ProviderSMTP: func(s Settings) (Transport, error) { return NewSMTPTransport(s) },
+}
+
+// NewTransport builds the Transport for the given settings.
+func NewTransport(s Settings) (Transport, error) {
+	provider := strings.ToLower(s.Provider)
+	if provider == "" {
+		provider = ProviderSMTP
+	}
+	factory, ok := Providers[provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown mail provider: %s", s.Provider)
+	}
+	return factory(s)
+}
+
+// LoadSettings returns the mail settings for an organization.
+// If the org has none configured, the server-wide SMTP_* environment settings are used.
+func LoadSettings(orgID string) (*Settings, error) {
+	if orgID != "" {
+		var rows []Settings
+		err := database.GetClient().DB.From("mail_settings").Select("*").Eq("org_id", orgID).Execute(&rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load mail settings: %w", err)
+		}
+		if len(rows) > 0 {
+			settings := rows[0]
+			settings.Password = settings.LegacyPassword
+			if settings.PasswordCiphertext != "" {
+				password, err := secrets.Decrypt(settings.PasswordCiphertext, passwordAdditionalData(orgID))
+				if err != nil {
+					return nil, fmt.Errorf("failed to decrypt SMTP password: %w", err)
+				}
+				settings.Password = password
+			}
+			return &settings, nil
+		}
+	}
+
+	if s := settingsFromEnv(); s != nil {
+		return s, nil
+	}
+	return nil, fmt.Errorf("email is not configured for this organization")
+}
+
+// SealPassword encrypts the SMTP password of orgID for storage.
+func SealPassword(orgID, password string) (string, error) {
+	return secrets.Encrypt(password, passwordAdditionalData(orgID))
+}
+
+func passwordAdditionalData(orgID string) string {
+	return orgID + "/mail_settings.smtp_password"
+}
+
+// settingsFromEnv reads the server-wide fallback transport (SMTP_HOST, SMTP_PORT, ...).
+func settingsFromEnv() *Settings {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return nil
+	}
+	port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
+	return &Settings{
+		Provider:    ProviderSMTP,
+		Host:        host,
+		Port:        port,
+		Username:    os.Getenv("SMTP_USERNAME"),
+		Password:    os.Getenv("SMTP_PASSWORD"),
+		Security:    os.Getenv("SMTP_SECURITY"),
+		FromAddress: os.Getenv("SMTP_FROM_ADDRESS"),
+		FromName:    os.Getenv("SMTP_FROM_NAME"),
+	}
+}
+
+// Send loads the org's transport and delivers msg, filling in the default sender.
+func Send(ctx context.Context, orgID string, msg *Message) (*DeliveryResult, error) {
+	settings, err := LoadSettings(orgID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.From.Email == "" {
+		msg.From.Email = settings.FromAddress
+	}
+	if msg.From.Name == "" {
+		msg.From.Name = settings.FromName
+	}
+	if msg.From.Email == "" {
+		return nil, fmt.Errorf("no sender address configured")
+	}
+
+	if settings.OrgID != "" {
+		// Org-configured servers are outbound calls of the org (the server-wide fallback isn't)
+		policy, err := egress.Load(orgID)
+		if err != nil {
+			return nil, err
+		}
+		settings.Dial = policy.WithOrigin(map[string]interface{}{"source": "smtp"}).DialContext
+	}
+
+	transport, err := NewTransport(*settings)
+	if err != nil {
+		return nil, err
+	}
+
+	// Never hang an activity on a dead mail server
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+	}
+	return transport.Send(ctx, msg)
+}
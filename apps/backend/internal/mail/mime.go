@@ -0,0 +1,187 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NewMessageID generates an RFC 5322 Message-ID using the sender's domain.
+func NewMessageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		domain = from[at+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", uuid.New().String(), domain)
+}
+
+// Build renders msg as a MIME document (headers + body).
+// Bcc recipients are intentionally left out of the headers.
+//
+// Layout:
+//
+//	multipart/mixed            (only when there are attachments)
+//	├── multipart/alternative  (only when both text and HTML are set)
+//	│   ├── text/plain
+//	│   └── text/html
+//	└── attachments...
+func Build(msg *Message, messageID string) ([]byte, error) {
+	// Values come from flow expressions: a line break would start a header of its own
+	for _, value := range append([]string{formatAddress(msg.From), msg.ReplyTo, messageID}, append(msg.To, msg.Cc...)...) {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("header value %q contains a line break", value)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	writeHeader(&buf, "From", formatAddress(msg.From))
+	writeHeader(&buf, "To", strings.Join(msg.To, ", "))
+	if len(msg.Cc) > 0 {
+		writeHeader(&buf, "Cc", strings.Join(msg.Cc, ", "))
+	}
+	if msg.ReplyTo != "" {
+		writeHeader(&buf, "Reply-To", msg.ReplyTo)
+	}
+	writeHeader(&buf, "Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	writeHeader(&buf, "Date", time.Now().Format(time.RFC1123Z))
+	writeHeader(&buf, "Message-ID", messageID)
+	writeHeader(&buf, "MIME-Version", "1.0")
+
+	if len(msg.Attachments) == 0 {
+		if err := writeBody(&buf, msg); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	writeHeader(&buf, "Content-Type", fmt.Sprintf("multipart/mixed; boundary=%q", mixed.Boundary()))
+	buf.WriteString("\r\n")
+
+	// Body part
+	var body bytes.Buffer
+	if err := writeBody(&body, msg); err != nil {
+		return nil, err
+	}
+	headers, content := splitPart(body.Bytes())
+	part, err := mixed.CreatePart(headers)
+	if err != nil {
+		return nil, err
+	}
+	part.Write(content)
+
+	// Attachments
+	for _, att := range msg.Attachments {
+		contentType := att.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", mime.FormatMediaType(contentType, map[string]string{"name": att.Filename}))
+		h.Set("Content-Transfer-Encoding", "base64")
+		h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": att.Filename}))
+		part, err := mixed.CreatePart(h)
+		if err != nil {
+			return nil, err
+		}
+		writeBase64(part, att.Data)
+	}
+
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBody writes the text/HTML content of msg: its Content-Type headers, a blank line, then the content.
+func writeBody(buf *bytes.Buffer, msg *Message) error {
+	switch {
+	case msg.TextBody != "" && msg.HTMLBody != "":
+		alt := multipart.NewWriter(buf)
+		writeHeader(buf, "Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", alt.Boundary()))
+		buf.WriteString("\r\n")
+		for _, p := range []struct{ contentType, content string }{
+			{"text/plain; charset=utf-8", msg.TextBody},
+			{"text/html; charset=utf-8", msg.HTMLBody},
+		} {
+			h := textproto.MIMEHeader{}
+			h.Set("Content-Type", p.contentType)
+			h.Set("Content-Transfer-Encoding", "quoted-printable")
+			part, err := alt.CreatePart(h)
+			if err != nil {
+				return err
+			}
+			if err := writeQuotedPrintable(part, p.content); err != nil {
+				return err
+			}
+		}
+		return alt.Close()
+	case msg.HTMLBody != "":
+		writeHeader(buf, "Content-Type", "text/html; charset=utf-8")
+		writeHeader(buf, "Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		return writeQuotedPrintable(buf, msg.HTMLBody)
+	default:
+		writeHeader(buf, "Content-Type", "text/plain; charset=utf-8")
+		writeHeader(buf, "Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		return writeQuotedPrintable(buf, msg.TextBody)
+	}
+}
+
+// splitPart separates a rendered part into its headers and content.
+func splitPart(raw []byte) (textproto.MIMEHeader, []byte) {
+	h := textproto.MIMEHeader{}
+	idx := bytes.Index(raw, []byte("\r\n\r\n"))
+	if idx < 0 {
+		return h, raw
+	}
+	for _, line := range strings.Split(string(raw[:idx]), "\r\n") {
+		if k, v, ok := strings.Cut(line, ":"); ok {
+			h.Set(strings.TrimSpace(k), strings.TrimSpace(v))
+		}
+	}
+	return h, raw[idx+4:]
+}
+
+func writeHeader(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+	buf.WriteString(": ")
+	buf.WriteString(value)
+	buf.WriteString("\r\n")
+}
+
+func writeQuotedPrintable(w io.Writer, content string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64 writes data base64-encoded in 76 character lines (RFC 2045).
+func writeBase64(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	w.Write([]byte(encoded + "\r\n"))
+}
+
+func formatAddress(a Address) string {
+	if a.Name == "" {
+		return a.Email
+	}
+	return fmt.Sprintf("%s <%s>", mime.QEncoding.Encode("utf-8", a.Name), a.Email)
+}
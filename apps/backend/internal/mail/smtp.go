@@ -0,0 +1,165 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+// SMTPTransport delivers mail through an SMTP relay.
+// Security modes: "starttls" upgrades a plain connection (port 587), "tls" uses implicit
+// TLS (port 465), and "none" sends in clear text (local stand-ins such as MailHog).
+type SMTPTransport struct {
+	host     string
+	port     int
+	username string
+	password string
+	security string
+	dial     func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// NewSMTPTransport validates the SMTP settings and returns a transport.
+func NewSMTPTransport(s Settings) (*SMTPTransport, error) {
+	if s.Host == "" {
+		return nil, fmt.Errorf("SMTP host is required")
+	}
+
+	security := strings.ToLower(s.Security)
+	if security == "" {
+		security = SecuritySTARTTLS
+	}
+	if security != SecuritySTARTTLS && security != SecurityTLS && security != SecurityNone {
+		return nil, fmt.Errorf("unknown SMTP security mode: %s", s.Security)
+	}
+
+	port := s.Port
+	if port == 0 {
+		switch security {
+		case SecurityTLS:
+			port = 465
+		case SecurityNone:
+			port = 25
+		default:
+			port = 587
+		}
+	}
+
+	return &SMTPTransport{
+		host:     s.Host,
+		port:     port,
+		username: s.Username,
+		password: s.Password,
+		security: security,
+		dial:     s.Dial,
+	}, nil
+}
+
+// Send delivers msg to every To/Cc/Bcc recipient the server accepts.
+// Recipients refused by the server are reported in DeliveryResult.Rejected; the send only
+// fails outright if no recipient was accepted.
+func (t *SMTPTransport) Send(ctx context.Context, msg *Message) (*DeliveryResult, error) {
+	recipients := append(append(append([]string{}, msg.To...), msg.Cc...), msg.Bcc...)
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no recipients")
+	}
+
+	messageID := NewMessageID(msg.From.Email)
+	raw, err := Build(msg, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build message: %w", err)
+	}
+
+	// 1. Connect
+	addr := net.JoinHostPort(t.host, strconv.Itoa(t.port))
+	tlsConfig := &tls.Config{ServerName: t.host}
+
+	dial := t.dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SMTP server %s: %w", addr, err)
+	}
+	if t.security == SecurityTLS {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to connect to SMTP server %s: %w", addr, err)
+		}
+		conn = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, t.host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SMTP handshake failed: %w", err)
+	}
+	defer c.Close()
+
+	// 2. STARTTLS
+	if t.security == SecuritySTARTTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return nil, fmt.Errorf("SMTP server %s does not support STARTTLS", addr)
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return nil, fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+
+	// 3. Authenticate
+	if t.username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return nil, fmt.Errorf("SMTP server %s does not support authentication", addr)
+		}
+		if err := c.Auth(smtp.PlainAuth("", t.username, t.password, t.host)); err != nil {
+			return nil, fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	// 4. Envelope
+	if err := c.Mail(msg.From.Email); err != nil {
+		return nil, fmt.Errorf("sender rejected: %w", err)
+	}
+
+	result := &DeliveryResult{
+		MessageID: messageID,
+		Provider:  ProviderSMTP,
+		Accepted:  []string{},
+		Rejected:  map[string]string{},
+	}
+	for _, rcpt := range recipients {
+		if err := c.Rcpt(rcpt); err != nil {
+			result.Rejected[rcpt] = err.Error()
+			continue
+		}
+		result.Accepted = append(result.Accepted, rcpt)
+	}
+	if len(result.Accepted) == 0 {
+		return result, fmt.Errorf("all recipients were rejected")
+	}
+
+	// 5. Data
+	wc, err := c.Data()
+	if err != nil {
+		return result, fmt.Errorf("SMTP DATA failed: %w", err)
+	}
+	result.Submitted = true
+	if _, err := wc.Write(raw); err != nil {
+		wc.Close()
+		return result, fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := wc.Close(); err != nil {
+		return result, fmt.Errorf("message rejected by server: %w", err)
+	}
+
+	c.Quit()
+	return result, nil
+}
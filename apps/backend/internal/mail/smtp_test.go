@@ -0,0 +1,267 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpServer is a stand-in SMTP server. It accepts AUTH PLAIN, refuses the recipients
+// in reject and records what it was sent.
+type smtpServer struct {
+	addr   string
+	reject map[string]bool
+
+	mu   sync.Mutex
+	auth string   // decoded AUTH PLAIN response
+	from string   // MAIL FROM
+	rcpt []string // accepted RCPT TO
+	data string   // message received with DATA
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &smtpServer{addr: ln.Addr().String(), reject: map[string]bool{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP stand-in")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			// No STARTTLS: the stand-in only speaks plain text
+			tp.PrintfLine("250-localhost")
+			tp.PrintfLine("250-AUTH PLAIN")
+			tp.PrintfLine("250 8BITMIME")
+		case "AUTH":
+			_, initial, _ := strings.Cut(arg, " ")
+			decoded, err := base64.StdEncoding.DecodeString(initial)
+			if err != nil {
+				tp.PrintfLine("501 invalid base64")
+				continue
+			}
+			s.mu.Lock()
+			s.auth = string(decoded)
+			s.mu.Unlock()
+			tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			s.mu.Lock()
+			s.from = pathAddress(arg)
+			s.mu.Unlock()
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			rcpt := pathAddress(arg)
+			if s.reject[rcpt] {
+				tp.PrintfLine("550 no such user")
+				continue
+			}
+			s.mu.Lock()
+			s.rcpt = append(s.rcpt, rcpt)
+			s.mu.Unlock()
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = string(data)
+			s.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+// pathAddress reads the address of "FROM:<a@b> BODY=8BITMIME" or "TO:<a@b>".
+func pathAddress(arg string) string {
+	_, rest, _ := strings.Cut(arg, "<")
+	addr, _, _ := strings.Cut(rest, ">")
+	return addr
+}
+
+func (s *smtpServer) transport(t *testing.T, security, username string) *SMTPTransport {
+	t.Helper()
+	host, portStr, _ := net.SplitHostPort(s.addr)
+	port, _ := strconv.Atoi(portStr)
+	transport, err := NewSMTPTransport(Settings{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: "p4ss",
+		Security: security,
+	})
+	if err != nil {
+		t.Fatalf("NewSMTPTransport: %v", err)
+	}
+	return transport
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestSMTPTransportSend(t *testing.T) {
+	srv := newSMTPServer(t)
+	srv.reject["nobody@example.com"] = true
+	transport := srv.transport(t, SecurityNone, "mailer")
+
+	result, err := transport.Send(testContext(t), &Message{
+		From:     Address{Name: "Flows", Email: "flows@example.com"},
+		ReplyTo:  "support@example.com",
+		To:       []string{"ada@example.com", "nobody@example.com"},
+		Bcc:      []string{"audit@example.com"},
+		Subject:  "Order shipped",
+		TextBody: "Your order is on its way.",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if got := strings.Join(result.Accepted, ","); got != "ada@example.com,audit@example.com" {
+		t.Errorf("accepted = %q", got)
+	}
+	if _, ok := result.Rejected["nobody@example.com"]; !ok || len(result.Rejected) != 1 {
+		t.Errorf("rejected = %v", result.Rejected)
+	}
+	if result.Provider != ProviderSMTP || result.MessageID == "" || !result.Submitted {
+		t.Errorf("result = %+v", result)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.auth != "\x00mailer\x00p4ss" {
+		t.Errorf("AUTH PLAIN = %q", srv.auth)
+	}
+	if srv.from != "flows@example.com" {
+		t.Errorf("MAIL FROM = %q", srv.from)
+	}
+
+	header, err := textproto.NewReader(bufio.NewReader(strings.NewReader(srv.data))).ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("message header: %v", err)
+	}
+	if got := header.Get("Subject"); got != "Order shipped" {
+		t.Errorf("Subject = %q", got)
+	}
+	if got := header.Get("Reply-To"); got != "support@example.com" {
+		t.Errorf("Reply-To = %q", got)
+	}
+	if got := header.Get("Message-Id"); got != result.MessageID {
+		t.Errorf("Message-ID = %q, want %q", got, result.MessageID)
+	}
+	if header.Get("Bcc") != "" {
+		t.Error("Bcc recipients leaked into the message header")
+	}
+	if !strings.Contains(srv.data, "Your order is on its way.") {
+		t.Errorf("body missing from message:\n%s", srv.data)
+	}
+}
+
+func TestSMTPTransportAllRecipientsRejected(t *testing.T) {
+	srv := newSMTPServer(t)
+	srv.reject["nobody@example.com"] = true
+	transport := srv.transport(t, SecurityNone, "")
+
+	result, err := transport.Send(testContext(t), &Message{
+		From:     Address{Email: "flows@example.com"},
+		To:       []string{"nobody@example.com"},
+		Subject:  "Hello",
+		TextBody: "Hi",
+	})
+	if err == nil || !strings.Contains(err.Error(), "all recipients were rejected") {
+		t.Fatalf("Send error = %v", err)
+	}
+	if result == nil || len(result.Rejected) != 1 {
+		t.Fatalf("result = %+v, want the rejected recipient", result)
+	}
+	if result.Submitted {
+		t.Error("result marked submitted though no message data was sent")
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.auth != "" {
+		t.Error("authenticated without a username")
+	}
+	if srv.data != "" {
+		t.Error("message sent with no accepted recipient")
+	}
+}
+
+func TestSMTPTransportRequiresSTARTTLS(t *testing.T) {
+	srv := newSMTPServer(t)
+	transport := srv.transport(t, SecuritySTARTTLS, "mailer")
+
+	_, err := transport.Send(testContext(t), &Message{
+		From:     Address{Email: "flows@example.com"},
+		To:       []string{"ada@example.com"},
+		Subject:  "Hello",
+		TextBody: "Hi",
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not support STARTTLS") {
+		t.Fatalf("Send error = %v, want a STARTTLS failure", err)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.auth != "" {
+		t.Error("password sent over a connection that wasn't upgraded")
+	}
+}
+
+func TestSMTPTransportRejectsHeaderInjection(t *testing.T) {
+	srv := newSMTPServer(t)
+	transport := srv.transport(t, SecurityNone, "")
+
+	_, err := transport.Send(testContext(t), &Message{
+		From:     Address{Email: "flows@example.com"},
+		ReplyTo:  "support@example.com\r\nBcc: attacker@example.com",
+		To:       []string{"ada@example.com"},
+		Subject:  "Hello",
+		TextBody: "Hi",
+	})
+	if err == nil || !strings.Contains(err.Error(), "line break") {
+		t.Fatalf("Send error = %v, want the message refused", err)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.data != "" {
+		t.Error("message with an injected header was sent")
+	}
+}
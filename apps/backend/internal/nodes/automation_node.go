@@ -23,6 +23,9 @@ type Subscription struct {
 	Criteria     map[string]interface{} `json:"criteria"`
 	WebhookToken string                 `json:"webhook_token"`
 	Status       string                 `json:"status"`
+	// Signature is the node's "signature" block, kept unresolved: its secret stays a
+	// {{ secrets.NAME }} reference until a call to the webhook is verified.
+	Signature map[string]interface{} `json:"signature,omitempty"`
 }
 
 func (e *AutomationNodeExecutor) Execute(ctx context.Context, input NodeContext) (*NodeResult, error) {
@@ -105,6 +108,7 @@ func (e *AutomationNodeExecutor) Execute(ctx context.Context, input NodeContext)
 		WebhookToken: webhookToken,
 		Status:       "active",
 	}
+	sub.Signature, _ = input.Config["signature"].(map[string]interface{})
 
 	var results []Subscription
 	err := client.DB.From("automation_subscriptions").Insert(sub).Execute(&results)
@@ -0,0 +1,109 @@
+package nodes
+
+import (
+	"context"
+	"testing"
+)
+
+// Filter, Condition, condition groups and Switch compare through the compare package, so
+// the same data and operator give the same answer in all four.
+func TestComparisonNodesAgree(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		operator string
+		right    interface{}
+		want     bool
+	}{
+		{"numeric strings", "10", ">", "2", true},
+		{"number against numeric string", 5.0, "==", "5", true},
+		{"ISO dates", "2024-03-01", "after", "2024-02-29T23:59:59Z", true},
+		{"word against number", "n/a", ">", "50", false},
+		{"membership", "BE", "in", "FR, BE", true},
+		{"unknown operator", "a", "approximately", "a", false},
+		{"missing operator", "a", "", "a", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := NodeContext{InputData: map[string]interface{}{
+				"value": tt.value,
+				"items": []interface{}{map[string]interface{}{"value": tt.value}},
+			}}
+			clause := map[string]interface{}{"left": "{{ input.value }}", "operator": tt.operator, "right": tt.right}
+
+			// Condition
+			input.Config = map[string]interface{}{"condition": clause, "caseSensitive": true}
+			result, _ := (&ConditionNode{}).Execute(context.Background(), input)
+			if result.Status != StatusSuccess || result.Output["result"] != tt.want {
+				t.Errorf("Condition: %s %+v, want result %v", result.Status, result.Output, tt.want)
+			}
+
+			// Condition group
+			input.Config = map[string]interface{}{
+				"group":         map[string]interface{}{"logic": "and", "conditions": []interface{}{clause}},
+				"caseSensitive": true,
+			}
+			result, _ = (&ConditionNode{}).Execute(context.Background(), input)
+			if result.Status != StatusSuccess || result.Output["result"] != tt.want {
+				t.Errorf("Condition group: %s %+v, want result %v", result.Status, result.Output, tt.want)
+			}
+
+			// Filter
+			input.Config = map[string]interface{}{"settings": map[string]interface{}{
+				"arrayVariable": "{{ input.items }}",
+				"conditions": []interface{}{
+					map[string]interface{}{"field": "value", "operator": tt.operator, "value": tt.right, "caseSensitive": true},
+				},
+			}}
+			result, err := (&FilterNode{}).Execute(context.Background(), input)
+			if err != nil || result.Status != StatusSuccess {
+				t.Fatalf("Filter: %v %+v", err, result)
+			}
+			if kept := result.Output["count"]; (kept == 1) != tt.want {
+				t.Errorf("Filter kept %d items, want match %v: %+v", kept, tt.want, result.Output)
+			}
+
+			// Switch. A blank switch operator means equals, so it's only checked for the others
+			if tt.operator == "" {
+				return
+			}
+			input.Config = map[string]interface{}{
+				"variable":      "{{ input.value }}",
+				"caseSensitive": true,
+				"cases": []interface{}{
+					map[string]interface{}{"id": "hit", "label": "Hit", "operator": tt.operator, "value": tt.right, "values": tt.right},
+				},
+			}
+			result, _ = (&SwitchNode{}).Execute(context.Background(), input)
+			want := SwitchDefaultCase
+			if tt.want {
+				want = "hit"
+			}
+			if result.Status != StatusSuccess || result.Output["selected_case"] != want {
+				t.Errorf("Switch: %s %+v, want case %s", result.Status, result.Output, want)
+			}
+		})
+	}
+}
+
+func TestSwitchUnknownOperatorSkipsCase(t *testing.T) {
+	input := NodeContext{
+		InputData: map[string]interface{}{"tier": "gold"},
+		Config: map[string]interface{}{
+			"variable": "{{ input.tier }}",
+			"mode":     SwitchModeAll,
+			"cases": []interface{}{
+				map[string]interface{}{"id": "typo", "label": "Typo", "operator": "equalz", "value": "gold"},
+				map[string]interface{}{"id": "vip", "label": "VIP", "value": "GOLD"},
+			},
+		},
+	}
+	result, _ := (&SwitchNode{}).Execute(context.Background(), input)
+	if result.Status != StatusSuccess || result.Output["selected_case"] != "vip" {
+		t.Fatalf("result = %s %+v, want the vip case", result.Status, result.Output)
+	}
+	caseErrors, _ := result.Output["case_errors"].(map[string]interface{})
+	if _, ok := caseErrors["typo"]; !ok {
+		t.Errorf("case_errors = %v, want the typo case reported", result.Output["case_errors"])
+	}
+}
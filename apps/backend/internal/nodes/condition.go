@@ -2,8 +2,11 @@ package nodes
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/compare"
 )
 
 // ConditionNode evaluates a boolean condition.
@@ -20,6 +23,40 @@ func (n *ConditionNode) Execute(ctx context.Context, input NodeContext) (*NodeRe
 	// 1. Initialize Expression Engine
 	expressionEngine := NewExpressionEngine()
 
+	// Free-form boolean expression, e.g. "{{ steps.api.data.total > 100 && input.vip }}"
+	if expr, ok := input.Config["expression"].(string); ok && strings.TrimSpace(expr) != "" {
+		val, err := expressionEngine.Evaluate(expr, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate condition expression: %w", err)
+		}
+		return &NodeResult{
+			Status: StatusSuccess,
+			Output: map[string]interface{}{
+				"result":     isTruthy(val),
+				"expression": expr,
+				"evaluated":  val,
+			},
+		}, nil
+	}
+
+	// "strict" and "caseSensitive" tune comparisons (see the compare package)
+	opts := compare.Options{}.WithOverrides(input.Config)
+
+	// Nested AND / OR / NOT groups (see condition_group.go)
+	if group, ok := input.Config["group"].(map[string]interface{}); ok {
+		result, trace, err := evaluateConditionGroup(expressionEngine, input, group, opts)
+		if err != nil {
+			return configError(fmt.Sprintf("Invalid condition group: %v", err)), nil
+		}
+		return &NodeResult{
+			Status: StatusSuccess,
+			Output: map[string]interface{}{
+				"result": result,
+				"trace":  trace,
+			},
+		}, nil
+	}
+
 	// Extract "condition" object from config
 	// Format: { "condition": { "left": "...", "operator": "==", "right": "..." } }
 	condMap, ok := input.Config["condition"].(map[string]interface{})
@@ -48,90 +85,28 @@ func (n *ConditionNode) Execute(ctx context.Context, input NodeContext) (*NodeRe
 		valRight = rawRight
 	}
 
-	// 3. Compare
-	result := compareValuesGeneric(valLeft, valRight, operator)
-	fmt.Printf("DEBUG: Condition Result: %v (Left: %v, Right: %v)\n", result, valLeft, valRight)
-
-	return &NodeResult{
-		Status: StatusSuccess,
-		Output: map[string]interface{}{
-			"result":          result,
-			"evaluated_left":  valLeft,
-			"evaluated_right": valRight,
-			"operator":        operator,
-		},
-		Error: "",
-	}, nil
-}
-
-func compareValuesGeneric(a, b interface{}, op string) bool {
-	// 1. Try Numeric Comparison
-	fA, okA := toFloat(a)
-	fB, okB := toFloat(b)
-
-	if okA && okB {
-		switch op {
-		case "==", "=":
-			return fA == fB
-		case "!=":
-			return fA != fB
-		case ">":
-			return fA > fB
-		case "<":
-			return fA < fB
-		case ">=":
-			return fA >= fB
-		case "<=":
-			return fA <= fB
-		}
-	}
-
-	// 2. String Comparison
-	sA := fmt.Sprintf("%v", a)
-	sB := fmt.Sprintf("%v", b)
-
-	switch op {
-	case "==", "=":
-		return sA == sB
-	case "!=":
-		return sA != sB
-	case "contains":
-		return strings.Contains(sA, sB)
-		// Add Regex match if needed
+	// 3. Compare. A missing or unknown operator makes the condition false, as it always
+	// has; the flow validator reports it
+	result, err := compare.Compare(operator, valLeft, valRight, opts.WithOverrides(condMap))
+	if compare.IsConfigError(err) && !errors.Is(err, compare.ErrUnknownOperator) {
+		return configError(fmt.Sprintf("Invalid condition: %v", err)), nil
 	}
+	fmt.Printf("DEBUG: Condition Result: %v (Left: %v, Right: %v)\n", result, input.Redact(valLeft), input.Redact(valRight))
 
-	return false
-}
-
-func toFloat(v interface{}) (float64, bool) {
-	if v == nil {
-		return 0, false
+	output := map[string]interface{}{
+		"result":          result,
+		"evaluated_left":  valLeft,
+		"evaluated_right": valRight,
+		"operator":        operator,
 	}
-	switch val := v.(type) {
-	case int:
-		return float64(val), true
-	case int64:
-		return float64(val), true
-	case float64:
-		return val, true
-	case bool:
-		if val {
-			return 1.0, true
-		}
-		return 0.0, true
-	case string:
-		var f float64
-		if _, err := fmt.Sscanf(val, "%f", &f); err == nil {
-			return f, true
-		}
-		// Handle "true"/"false" strings
-		lower := strings.ToLower(val)
-		if lower == "true" {
-			return 1.0, true
-		}
-		if lower == "false" {
-			return 0.0, true
-		}
+	if err != nil {
+		// The values couldn't be compared (e.g. "abc" > 10) or the operator is unknown: the condition is false
+		output["error"] = err.Error()
 	}
-	return 0, false
+
+	return &NodeResult{
+		Status: StatusSuccess,
+		Output: output,
+		Error:  "",
+	}, nil
 }
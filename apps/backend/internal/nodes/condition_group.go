@@ -0,0 +1,191 @@
+package nodes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/compare"
+)
+
+// Logic of a condition group. Groups nest clauses and other groups:
+//
+//	{
+//	  "logic": "and",
+//	  "conditions": [
+//	    { "left": "{{ steps.order.data.total }}", "operator": ">=", "right": "100" },
+//	    { "logic": "or", "conditions": [
+//	      { "left": "{{ steps.order.data.country }}", "operator": "in", "right": "FR, BE" },
+//	      { "left": "{{ steps.order.data.coupon }}", "operator": "exists" }
+//	    ]}
+//	  ]
+//	}
+//
+// Operators come from the compare catalog. Groups and clauses may set "strict" and
+// "caseSensitive", which apply to everything below them.
+const (
+	LogicAnd = "and" // every condition holds (default)
+	LogicOr  = "or"  // at least one condition holds
+	LogicNot = "not" // none of the conditions hold
+)
+
+// evaluateConditionGroup evaluates a group and returns its result with a trace that
+// mirrors the group: every clause carries its resolved values and its own result.
+// Clauses are all evaluated (no short-circuit) so the trace is complete. Only invalid
+// configuration (unknown logic, bad regex) is an error; a clause with an unknown operator
+// is false.
+func evaluateConditionGroup(engine *ExpressionEngine, input NodeContext, group map[string]interface{}, opts compare.Options) (bool, map[string]interface{}, error) {
+	opts = opts.WithOverrides(group)
+	logic := LogicAnd
+	if l, _ := group["logic"].(string); l != "" {
+		logic = strings.ToLower(l)
+	}
+	if logic != LogicAnd && logic != LogicOr && logic != LogicNot {
+		return false, nil, fmt.Errorf("unknown group logic %q (use and, or or not)", logic)
+	}
+
+	conditions, _ := group["conditions"].([]interface{})
+	traces := make([]interface{}, 0, len(conditions))
+	passed := 0
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var result bool
+		var trace map[string]interface{}
+		var err error
+		if _, nested := cond["conditions"]; nested {
+			result, trace, err = evaluateConditionGroup(engine, input, cond, opts)
+		} else {
+			result, trace, err = evaluateConditionClause(engine, input, cond, opts)
+		}
+		if err != nil {
+			return false, nil, err
+		}
+		if result {
+			passed++
+		}
+		traces = append(traces, trace)
+	}
+
+	var result bool
+	switch logic {
+	case LogicAnd:
+		result = passed == len(traces)
+	case LogicOr:
+		result = passed > 0
+	case LogicNot:
+		result = passed == 0
+	}
+	return result, map[string]interface{}{
+		"logic":      logic,
+		"result":     result,
+		"conditions": traces,
+	}, nil
+}
+
+// evaluateConditionClause evaluates one "left operator right" clause. A left side that
+// doesn't resolve (e.g. a missing field) counts as null, so "exists" can test for it.
+func evaluateConditionClause(engine *ExpressionEngine, input NodeContext, clause map[string]interface{}, opts compare.Options) (bool, map[string]interface{}, error) {
+	rawOp, _ := clause["operator"].(string)
+	operator, ok := compare.Lookup(rawOp)
+	if !ok {
+		// The clause is false, as a Condition with that operator is; the flow validator
+		// reports it
+		return false, map[string]interface{}{
+			"left":     clause["left"],
+			"operator": rawOp,
+			"error":    fmt.Sprintf("%v %q", compare.ErrUnknownOperator, rawOp),
+			"result":   false,
+		}, nil
+	}
+	opts = opts.WithOverrides(clause)
+
+	trace := map[string]interface{}{
+		"left":     clause["left"],
+		"operator": operator.Name,
+	}
+
+	left := clause["left"]
+	if s, ok := left.(string); ok {
+		val, err := engine.Evaluate(s, input)
+		if err != nil {
+			trace["left_error"] = err.Error()
+			val = nil
+		}
+		left = val
+	}
+	trace["left_value"] = left
+
+	var right interface{}
+	if !operator.Unary {
+		trace["right"] = clause["right"]
+		right = clause["right"]
+		if s, ok := right.(string); ok {
+			val, err := engine.Evaluate(s, input)
+			if err != nil {
+				trace["error"] = fmt.Sprintf("failed to resolve right side: %v", err)
+				trace["result"] = false
+				return false, trace, nil
+			}
+			right = val
+		}
+		trace["right_value"] = right
+	}
+
+	result, err := operator.Test(left, right, opts)
+	if err != nil {
+		if compare.IsConfigError(err) {
+			return false, nil, err
+		}
+		// e.g. a value that isn't a date: the clause is false, the trace says why
+		trace["error"] = err.Error()
+		result = false
+	}
+	trace["result"] = result
+	return result, trace, nil
+}
+
+// CheckConditionGroup reports configuration problems in a condition group, for the flow
+// validator: unknown logic or operators, invalid static regexes and empty groups.
+func CheckConditionGroup(group map[string]interface{}) []string {
+	var problems []string
+	var walk func(g map[string]interface{})
+	walk = func(g map[string]interface{}) {
+		if l, _ := g["logic"].(string); l != "" {
+			switch strings.ToLower(l) {
+			case LogicAnd, LogicOr, LogicNot:
+			default:
+				problems = append(problems, fmt.Sprintf("unknown group logic %q", l))
+			}
+		}
+		conditions, _ := g["conditions"].([]interface{})
+		if len(conditions) == 0 {
+			problems = append(problems, "a condition group is empty")
+		}
+		for _, c := range conditions {
+			cond, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if _, nested := cond["conditions"]; nested {
+				walk(cond)
+				continue
+			}
+			rawOp, _ := cond["operator"].(string)
+			op, ok := compare.Lookup(rawOp)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("unknown operator %q", rawOp))
+				continue
+			}
+			if pattern, isString := cond["right"].(string); op.Name == "matches" && isString && !strings.Contains(pattern, "{{") {
+				if _, err := regexp.Compile(pattern); err != nil {
+					problems = append(problems, fmt.Sprintf("invalid regex %q: %v", pattern, err))
+				}
+			}
+		}
+	}
+	walk(group)
+	return problems
+}
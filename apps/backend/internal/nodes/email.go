@@ -2,17 +2,324 @@ package nodes
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	netmail "net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/artifacts"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/egress"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/mail"
 )
 
+// EmailNode sends an email through the organization's configured mail transport.
+// Config:
+//
+//	to, cc, bcc   - comma separated string or list of addresses (expressions allowed)
+//	subject       - subject template
+//	html / text   - HTML and plain-text body templates ("body" is accepted as legacy alias)
+//	fromName, replyTo
+//	attachments   - [{ "filename", "content", "contentType", "encoding": "base64"|"text" }]
 type EmailNode struct{}
 
 func (n *EmailNode) Execute(ctx context.Context, input NodeContext) (*NodeResult, error) {
-	// Stub implementation for Email
+	engine := NewExpressionEngine()
+
+	// 1. Recipients
+	to, err := resolveRecipients(engine, input.Config["to"], input)
+	if err != nil {
+		return emailFailure("invalid 'to': %v", err), nil
+	}
+	cc, err := resolveRecipients(engine, input.Config["cc"], input)
+	if err != nil {
+		return emailFailure("invalid 'cc': %v", err), nil
+	}
+	bcc, err := resolveRecipients(engine, input.Config["bcc"], input)
+	if err != nil {
+		return emailFailure("invalid 'bcc': %v", err), nil
+	}
+	if len(to)+len(cc)+len(bcc) == 0 {
+		return emailFailure("at least one recipient is required"), nil
+	}
+
+	// 2. Subject & Body templates
+	subject, err := evaluateString(engine, input.Config["subject"], input)
+	if err != nil {
+		return emailFailure("failed to evaluate subject: %v", err), nil
+	}
+	htmlBody, err := evaluateString(engine, input.Config["html"], input)
+	if err != nil {
+		return emailFailure("failed to evaluate html body: %v", err), nil
+	}
+	textBody, err := evaluateString(engine, input.Config["text"], input)
+	if err != nil {
+		return emailFailure("failed to evaluate text body: %v", err), nil
+	}
+	if htmlBody == "" && textBody == "" {
+		legacy, err := evaluateString(engine, input.Config["body"], input)
+		if err != nil {
+			return emailFailure("failed to evaluate body: %v", err), nil
+		}
+		if looksLikeHTML(legacy) {
+			htmlBody = legacy
+		} else {
+			textBody = legacy
+		}
+	}
+	// Always send a plain-text alternative for clients that don't render HTML
+	if htmlBody != "" && textBody == "" {
+		textBody = htmlToText(htmlBody)
+	}
+
+	// 3. Attachments (taken from prior step outputs)
+	attachments, err := resolveAttachments(engine, input.Config["attachments"], input)
+	if err != nil {
+		return emailFailure("invalid attachment: %v", err), nil
+	}
+
+	fromName, _ := evaluateString(engine, input.Config["fromName"], input)
+	replyTo, _ := evaluateString(engine, input.Config["replyTo"], input)
+	if strings.TrimSpace(replyTo) != "" {
+		addr, err := netmail.ParseAddress(strings.TrimSpace(replyTo))
+		if err != nil {
+			return emailFailure("invalid replyTo: %q is not a valid email address", replyTo), nil
+		}
+		replyTo = addr.String()
+	}
+
+	msg := &mail.Message{
+		From:        mail.Address{Name: fromName},
+		ReplyTo:     replyTo,
+		To:          to,
+		Cc:          cc,
+		Bcc:         bcc,
+		Subject:     subject,
+		TextBody:    textBody,
+		HTMLBody:    htmlBody,
+		Attachments: attachments,
+	}
+
+	// 4. Deliver
+	result, err := mail.Send(ctx, input.OrgID, msg)
+	if err != nil {
+		output := map[string]interface{}{"error": err.Error()}
+		if result != nil {
+			output["rejected"] = result.Rejected
+		}
+		failure := &NodeResult{
+			Status: StatusFailed,
+			Output: output,
+			Error:  fmt.Sprintf("failed to send email: %v", err),
+			// Once the server took the message, some recipients may already have it
+			SideEffects: result != nil && result.Submitted,
+		}
+		if egress.IsBlocked(err) {
+			failure.ErrorType = ErrorTypeEgressBlocked
+		}
+		return failure, nil
+	}
+
 	return &NodeResult{
 		Status: StatusSuccess,
 		Output: map[string]interface{}{
-			"message": "Email sent (Stub)",
-			"to":      input.Config["to"],
+			"message":     fmt.Sprintf("Email sent to %d recipient(s)", len(result.Accepted)),
+			"message_id":  result.MessageID,
+			"provider":    result.Provider,
+			"accepted":    result.Accepted,
+			"rejected":    result.Rejected,
+			"to":          to,
+			"cc":          cc,
+			"subject":     subject,
+			"attachments": len(attachments),
 		},
 	}, nil
 }
+
+func emailFailure(format string, args ...interface{}) *NodeResult {
+	return &NodeResult{
+		Status: StatusFailed,
+		Error:  fmt.Sprintf(format, args...),
+	}
+}
+
+// evaluateString resolves a template config value to a string ("" if missing).
+func evaluateString(engine *ExpressionEngine, raw interface{}, input NodeContext) (string, error) {
+	str, ok := raw.(string)
+	if !ok || str == "" {
+		return "", nil
+	}
+	val, err := engine.Evaluate(str, input)
+	if err != nil {
+		return "", err
+	}
+	if val == nil {
+		return "", nil
+	}
+	return fmt.Sprintf("%v", val), nil
+}
+
+// resolveRecipients accepts "a@x.com, b@y.com", ["a@x.com", "{{ steps.lookup.email }}"]
+// or an expression that resolves to a list, and returns validated addresses.
+func resolveRecipients(engine *ExpressionEngine, raw interface{}, input NodeContext) ([]string, error) {
+	var candidates []interface{}
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		val, err := engine.Evaluate(v, input)
+		if err != nil {
+			return nil, err
+		}
+		if list, ok := val.([]interface{}); ok {
+			candidates = list
+		} else {
+			candidates = []interface{}{val}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				val, err := engine.Evaluate(s, input)
+				if err != nil {
+					return nil, err
+				}
+				if list, ok := val.([]interface{}); ok {
+					candidates = append(candidates, list...)
+					continue
+				}
+				candidates = append(candidates, val)
+			} else {
+				candidates = append(candidates, item)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported recipient format %T", raw)
+	}
+
+	var addresses []string
+	for _, c := range candidates {
+		if c == nil {
+			continue
+		}
+		for _, part := range strings.FieldsFunc(fmt.Sprintf("%v", c), func(r rune) bool { return r == ',' || r == ';' }) {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			addr, err := netmail.ParseAddress(part)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a valid email address", part)
+			}
+			addresses = append(addresses, addr.Address)
+		}
+	}
+	return addresses, nil
+}
+
+// resolveAttachments builds attachments from config entries whose "content" usually
+// references a prior step, e.g. "{{ steps.report.data }}".
+// Maps and lists are attached as JSON; "encoding": "base64" decodes binary content, and
+// an artifact reference (e.g. the binary response of an HTTP node) attaches the file.
+func resolveAttachments(engine *ExpressionEngine, raw interface{}, input NodeContext) ([]mail.Attachment, error) {
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	var attachments []mail.Attachment
+	for i, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		filename, err := evaluateString(engine, m["filename"], input)
+		if err != nil {
+			return nil, err
+		}
+		if filename == "" {
+			filename = fmt.Sprintf("attachment-%d", i+1)
+		}
+		contentType, _ := m["contentType"].(string)
+		encoding, _ := m["encoding"].(string)
+
+		content := m["content"]
+		if s, ok := content.(string); ok {
+			content, err = engine.Evaluate(s, input)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", filename, err)
+			}
+		}
+
+		var data []byte
+		if id, ok := artifacts.ReferenceID(content); ok {
+			artifact, fileData, err := artifacts.Get(input.OrgID, id)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", filename, err)
+			}
+			if m["filename"] == nil || m["filename"] == "" {
+				filename = artifact.Filename
+			}
+			if contentType == "" {
+				contentType = artifact.ContentType
+			}
+			attachments = append(attachments, mail.Attachment{
+				Filename:    filename,
+				ContentType: contentType,
+				Data:        fileData,
+			})
+			continue
+		}
+		switch v := content.(type) {
+		case nil:
+			return nil, fmt.Errorf("%s: content is empty", filename)
+		case string:
+			if strings.EqualFold(encoding, "base64") {
+				data, err = base64.StdEncoding.DecodeString(v)
+				if err != nil {
+					return nil, fmt.Errorf("%s: invalid base64 content", filename)
+				}
+			} else {
+				data = []byte(v)
+				if contentType == "" {
+					contentType = "text/plain; charset=utf-8"
+				}
+			}
+		default:
+			data, err = json.MarshalIndent(v, "", "  ")
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", filename, err)
+			}
+			if contentType == "" {
+				contentType = "application/json"
+			}
+		}
+
+		attachments = append(attachments, mail.Attachment{
+			Filename:    filename,
+			ContentType: contentType,
+			Data:        data,
+		})
+	}
+	return attachments, nil
+}
+
+var (
+	htmlTagPattern   = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlBreakPattern = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</li>|</h[1-6]>`)
+	blankLinePattern = regexp.MustCompile(`\n{3,}`)
+)
+
+func looksLikeHTML(s string) bool {
+	return htmlTagPattern.MatchString(s)
+}
+
+// htmlToText produces a readable plain-text fallback from an HTML body.
+func htmlToText(html string) string {
+	text := htmlBreakPattern.ReplaceAllString(html, "\n")
+	text = htmlTagPattern.ReplaceAllString(text, "")
+	replacer := strings.NewReplacer("&nbsp;", " ", "&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&#39;", "'")
+	text = replacer.Replace(text)
+	text = blankLinePattern.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
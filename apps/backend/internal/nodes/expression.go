@@ -1,12 +1,18 @@
 package nodes
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
-// ExpressionEngine resolves expressions like {{ steps.foo.data.bar }}
+// ExpressionEngine resolves expressions like {{ steps.foo.data.bar }}.
+// The content of a {{ }} block may be a full expression with operators, ternaries,
+// "??" fallbacks, function calls and filters, e.g. {{ steps.api.data.total * 1.2 }}
+// or {{ input.name | upper }}. See expression_parser.go for the grammar and
+// expression_functions.go for the function library.
 type ExpressionEngine struct{}
 
 // NewExpressionEngine creates a new instance
@@ -14,6 +20,10 @@ func NewExpressionEngine() *ExpressionEngine {
 	return &ExpressionEngine{}
 }
 
+// Regex to find {{ ... }}
+// We use a non-greedy match (.*?)
+var expressionPattern = regexp.MustCompile(`\{\{\s*(.*?)\s*\}\}`)
+
 // Evaluate resolves string expressions against the context.
 // It supports basic string interpolation: "Hello {{ steps.name.input.value }}"
 func (e *ExpressionEngine) Evaluate(expression string, ctx NodeContext) (interface{}, error) {
@@ -22,9 +32,7 @@ func (e *ExpressionEngine) Evaluate(expression string, ctx NodeContext) (interfa
 		return expression, nil
 	}
 
-	// Regex to find {{ ... }}
-	// We use a non-greedy match (.*?)
-	re := regexp.MustCompile(`\{\{\s*(.*?)\s*\}\}`)
+	re := expressionPattern
 
 	// Check if the ENTIRE string is just one expression (e.g. "{{ steps.foo }}")
 	// If so, we return the raw interface{} value to preserve types (maps, slices)
@@ -33,7 +41,7 @@ func (e *ExpressionEngine) Evaluate(expression string, ctx NodeContext) (interfa
 	if loc != nil && loc[0] == 0 && loc[1] == len(trimmed) {
 		match := re.FindStringSubmatch(trimmed)
 		content := match[1]
-		return e.resolvePath(content, ctx)
+		return e.evaluateExpression(content, ctx)
 	}
 
 	// Otherwise, it's string interpolation (e.g. "Hello {{ steps.name }}")
@@ -43,12 +51,12 @@ func (e *ExpressionEngine) Evaluate(expression string, ctx NodeContext) (interfa
 		// match is like "{{ steps.foo.data }}"
 		// content is like "steps.foo.data"
 		content := re.FindStringSubmatch(match)[1]
-		val, evalErr := e.resolvePath(content, ctx)
+		val, evalErr := e.evaluateExpression(content, ctx)
 		if evalErr != nil {
 			err = evalErr // Capture error
 			return match  // Return original on error
 		}
-		return fmt.Sprintf("%v", val)
+		return stringify(val)
 	})
 
 	if err != nil {
@@ -58,6 +66,20 @@ func (e *ExpressionEngine) Evaluate(expression string, ctx NodeContext) (interfa
 	return result, nil
 }
 
+// evaluateExpression parses and runs the content of one {{ }} block.
+// Content that doesn't parse (e.g. keys with spaces: "steps.form.First Name") falls
+// back to plain dotted path resolution, which is what older flows rely on.
+func (e *ExpressionEngine) evaluateExpression(content string, ctx NodeContext) (interface{}, error) {
+	node, parseErr := parseExpression(content)
+	if parseErr != nil {
+		if val, err := e.resolvePath(content, ctx); err == nil {
+			return val, nil
+		}
+		return nil, fmt.Errorf("invalid expression %q: %v", content, parseErr)
+	}
+	return node.eval(&evalContext{engine: e, node: ctx})
+}
+
 // resolvePath traverses the NodeContext based on dot notation
 // Path examples:
 // - steps.Trigger.input.foo
@@ -68,10 +90,20 @@ func (e *ExpressionEngine) resolvePath(path string, ctx NodeContext) (interface{
 		return nil, fmt.Errorf("empty path")
 	}
 
-	root := parts[0]
+	// 1. Determine Root Object
+	current, err := e.resolveRoot(parts[0], ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Traverse
+	return e.Traverse(current, parts[1:])
+}
+
+// resolveRoot returns the context object behind a root identifier.
+func (e *ExpressionEngine) resolveRoot(root string, ctx NodeContext) (interface{}, error) {
 	var current interface{}
 
-	// 1. Determine Root Object
 	switch root {
 	case "steps":
 		// steps.NodeName.field...
@@ -119,12 +151,28 @@ func (e *ExpressionEngine) resolvePath(path string, ctx NodeContext) (interface{
 		} else {
 			return nil, fmt.Errorf("item context not found (are you inside a loop?)")
 		}
+	case "index":
+		// Loop iteration index (0-based)
+		if val, ok := ctx.InputData["index"]; ok {
+			current = val
+		} else {
+			return nil, fmt.Errorf("index context not found (are you inside a loop?)")
+		}
+	case "secrets":
+		// secrets.NAME, decrypted by the node activity
+		if ctx.Secrets == nil {
+			return nil, fmt.Errorf("secrets are only available while a step runs")
+		}
+		values := make(map[string]interface{}, len(ctx.Secrets))
+		for name, value := range ctx.Secrets {
+			values[name] = value
+		}
+		current = values
 	default:
-		return nil, fmt.Errorf("unknown root object: %s (expected steps, input, config, or item)", root)
+		return nil, fmt.Errorf("unknown root object: %s (expected steps, input, config, item, index, or secrets)", root)
 	}
 
-	// 2. Traverse
-	return e.Traverse(current, parts[1:])
+	return current, nil
 }
 
 // Traverse navigates an object using a list of path parts (keys or array indices)
@@ -206,3 +254,101 @@ func (e *ExpressionEngine) EvaluateMap(config map[string]interface{}, ctx NodeCo
 	}
 	return result, nil
 }
+
+// ResolveConfig evaluates the expressions anywhere in a node's config, the way the node
+// sees them. Values that fail to evaluate are kept as written.
+func (e *ExpressionEngine) ResolveConfig(ctx NodeContext) map[string]interface{} {
+	resolved := make(map[string]interface{}, len(ctx.Config))
+	for k, v := range ctx.Config {
+		if val, err := evaluateDeep(e, v, ctx); err == nil {
+			resolved[k] = val
+		} else {
+			resolved[k] = v
+		}
+	}
+	return resolved
+}
+
+// stringify renders a value for string interpolation: nil becomes "", whole numbers
+// drop the exponent/decimal part, and maps/lists are written as JSON.
+func stringify(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32)
+	case map[string]interface{}, []interface{}:
+		if b, err := json.Marshal(val); err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+var stepReferencePattern = regexp.MustCompile(`\bsteps(?:\.([A-Za-z0-9_-]+)|\[\s*["']([^"']+)["']\s*\])`)
+
+var secretReferencePattern = regexp.MustCompile(`\bsecrets(?:\.([A-Za-z0-9_]+)|\[\s*["']([^"']+)["']\s*\])`)
+
+// ExpressionBlock is one {{ }} block found in a config string.
+type ExpressionBlock struct {
+	Content  string   // The expression inside the braces
+	Steps    []string // Step IDs it reads through steps.<id> / steps["<id>"]
+	Secrets  []string // Secret names it reads through secrets.<name>
+	ParseErr error    // Set when the content isn't a valid expression
+}
+
+// ExpressionBlocks lists the {{ }} blocks of s without evaluating them.
+// It is used to lint flow definitions before they are published.
+func ExpressionBlocks(s string) []ExpressionBlock {
+	if !strings.Contains(s, "{{") {
+		return nil
+	}
+	var blocks []ExpressionBlock
+	for _, match := range expressionPattern.FindAllStringSubmatch(s, -1) {
+		block := ExpressionBlock{Content: match[1]}
+		if _, err := parseExpression(match[1]); err != nil {
+			block.ParseErr = err
+		}
+		for _, ref := range stepReferencePattern.FindAllStringSubmatch(match[1], -1) {
+			id := ref[1]
+			if id == "" {
+				id = ref[2]
+			}
+			block.Steps = append(block.Steps, id)
+		}
+		for _, ref := range secretReferencePattern.FindAllStringSubmatch(match[1], -1) {
+			name := ref[1]
+			if name == "" {
+				name = ref[2]
+			}
+			block.Secrets = append(block.Secrets, name)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// SecretReferences lists the secret names read by the expressions anywhere in v
+// (typically a node config), so the activity only decrypts secrets for nodes that use them.
+func SecretReferences(v interface{}) []string {
+	var names []string
+	switch val := v.(type) {
+	case string:
+		for _, block := range ExpressionBlocks(val) {
+			names = append(names, block.Secrets...)
+		}
+	case map[string]interface{}:
+		for _, item := range val {
+			names = append(names, SecretReferences(item)...)
+		}
+	case []interface{}:
+		for _, item := range val {
+			names = append(names, SecretReferences(item)...)
+		}
+	}
+	return names
+}
@@ -0,0 +1,555 @@
+package nodes
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type expressionFunc func(args []interface{}) (interface{}, error)
+
+// expressionFunctions is the standard library available in expressions, either called
+// directly ("upper(input.name)") or as a filter ("input.name | upper").
+var expressionFunctions = map[string]expressionFunc{
+	// Strings
+	"upper":    stringFunc(strings.ToUpper),
+	"lower":    stringFunc(strings.ToLower),
+	"trim":     stringFunc(strings.TrimSpace),
+	"split":    fnSplit,
+	"join":     fnJoin,
+	"replace":  fnReplace,
+	"contains": fnContains,
+	"string":   fnString,
+
+	// Collections
+	"len":    fnLen,
+	"length": fnLen,
+	"keys":   fnKeys,
+	"first":  fnFirst,
+	"last":   fnLast,
+
+	// Numbers
+	"number": fnNumber,
+	"round":  fnRound,
+	"floor":  mathFunc(math.Floor),
+	"ceil":   mathFunc(math.Ceil),
+	"abs":    mathFunc(math.Abs),
+	"min":    fnMinMax(-1),
+	"max":    fnMinMax(1),
+
+	// JSON
+	"json":       fnJSON,
+	"parse_json": fnParseJSON,
+
+	// Dates (values are exchanged as RFC 3339 strings)
+	"now":         fnNow,
+	"date_parse":  fnDateParse,
+	"date_format": fnDateFormat,
+	"date_add":    fnDateAdd,
+	"date_diff":   fnDateDiff,
+
+	// Misc
+	"uuid":    fnUUID,
+	"default": fnDefault,
+}
+
+func checkArgs(args []interface{}, min, max int) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		if min == max {
+			return fmt.Errorf("expects %d argument(s), got %d", min, len(args))
+		}
+		if max < 0 {
+			return fmt.Errorf("expects at least %d argument(s), got %d", min, len(args))
+		}
+		return fmt.Errorf("expects %d to %d arguments, got %d", min, max, len(args))
+	}
+	return nil
+}
+
+func numberArg(v interface{}) (float64, error) {
+	f, ok := toNumber(v)
+	if !ok {
+		return 0, fmt.Errorf("%s is not a number", describe(v))
+	}
+	return f, nil
+}
+
+func stringFunc(fn func(string) string) expressionFunc {
+	return func(args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 1, 1); err != nil {
+			return nil, err
+		}
+		return fn(stringify(args[0])), nil
+	}
+}
+
+func mathFunc(fn func(float64) float64) expressionFunc {
+	return func(args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 1, 1); err != nil {
+			return nil, err
+		}
+		f, err := numberArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return fn(f), nil
+	}
+}
+
+func fnSplit(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 2); err != nil {
+		return nil, err
+	}
+	sep := ","
+	if len(args) == 2 {
+		sep = stringify(args[1])
+	}
+	s := stringify(args[0])
+	if s == "" {
+		return []interface{}{}, nil
+	}
+	parts := strings.Split(s, sep)
+	out := make([]interface{}, len(parts))
+	for i, p := range parts {
+		out[i] = p
+	}
+	return out, nil
+}
+
+func fnJoin(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 2); err != nil {
+		return nil, err
+	}
+	list, ok := asList(args[0])
+	if !ok {
+		return nil, fmt.Errorf("%s is not a list", describe(args[0]))
+	}
+	sep := ","
+	if len(args) == 2 {
+		sep = stringify(args[1])
+	}
+	parts := make([]string, len(list))
+	for i, item := range list {
+		parts[i] = stringify(item)
+	}
+	return strings.Join(parts, sep), nil
+}
+
+func fnReplace(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 3, 3); err != nil {
+		return nil, err
+	}
+	return strings.ReplaceAll(stringify(args[0]), stringify(args[1]), stringify(args[2])), nil
+}
+
+// contains works on strings (substring), lists (element) and maps (key).
+func fnContains(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	if list, ok := asList(args[0]); ok {
+		for _, item := range list {
+			if valuesEqual(item, args[1]) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	if m, ok := args[0].(map[string]interface{}); ok {
+		_, exists := m[stringify(args[1])]
+		return exists, nil
+	}
+	return strings.Contains(stringify(args[0]), stringify(args[1])), nil
+}
+
+func fnString(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	return stringify(args[0]), nil
+}
+
+func fnLen(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	switch v := args[0].(type) {
+	case nil:
+		return 0, nil
+	case string:
+		return len([]rune(v)), nil
+	}
+	rv := reflect.ValueOf(args[0])
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len(), nil
+	}
+	return nil, fmt.Errorf("%s has no length", describe(args[0]))
+}
+
+func fnKeys(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	m, ok := args[0].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is not an object", describe(args[0]))
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]interface{}, len(keys))
+	for i, k := range keys {
+		out[i] = k
+	}
+	return out, nil
+}
+
+func fnFirst(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	list, ok := asList(args[0])
+	if !ok {
+		return nil, fmt.Errorf("%s is not a list", describe(args[0]))
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return list[0], nil
+}
+
+func fnLast(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	list, ok := asList(args[0])
+	if !ok {
+		return nil, fmt.Errorf("%s is not a list", describe(args[0]))
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return list[len(list)-1], nil
+}
+
+func fnNumber(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	if b, ok := args[0].(bool); ok {
+		if b {
+			return 1.0, nil
+		}
+		return 0.0, nil
+	}
+	return numberArg(args[0])
+}
+
+// round(value, digits = 0)
+func fnRound(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 2); err != nil {
+		return nil, err
+	}
+	f, err := numberArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	digits := 0.0
+	if len(args) == 2 {
+		if digits, err = numberArg(args[1]); err != nil {
+			return nil, err
+		}
+	}
+	pow := math.Pow(10, math.Trunc(digits))
+	return math.Round(f*pow) / pow, nil
+}
+
+// fnMinMax accepts either several numbers or a single list.
+func fnMinMax(sign float64) expressionFunc {
+	return func(args []interface{}) (interface{}, error) {
+		if err := checkArgs(args, 1, -1); err != nil {
+			return nil, err
+		}
+		values := args
+		if len(args) == 1 {
+			if list, ok := asList(args[0]); ok {
+				values = list
+			}
+		}
+		if len(values) == 0 {
+			return nil, nil
+		}
+		best, err := numberArg(values[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range values[1:] {
+			f, err := numberArg(v)
+			if err != nil {
+				return nil, err
+			}
+			if (f-best)*sign > 0 {
+				best = f
+			}
+		}
+		return best, nil
+	}
+}
+
+func fnJSON(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func fnParseJSON(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal([]byte(stringify(args[0])), &out); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	return out, nil
+}
+
+func fnUUID(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+	return uuid.New().String(), nil
+}
+
+// default(value, fallback) returns fallback when value is missing, null or "".
+func fnDefault(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+	if args[0] == nil || args[0] == "" {
+		return args[1], nil
+	}
+	return args[0], nil
+}
+
+// --- Dates ---
+
+var dateInputLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// toTime accepts RFC 3339 / ISO-8601 strings, common date layouts and unix timestamps
+// (seconds, or milliseconds when the number is large enough).
+func toTime(v interface{}) (time.Time, error) {
+	switch val := v.(type) {
+	case time.Time:
+		return val, nil
+	case string:
+		s := strings.TrimSpace(val)
+		for _, layout := range dateInputLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, nil
+			}
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return unixTime(f), nil
+		}
+		return time.Time{}, fmt.Errorf("cannot parse %q as a date", val)
+	}
+	if f, ok := toNumber(v); ok {
+		return unixTime(f), nil
+	}
+	return time.Time{}, fmt.Errorf("%s is not a date", describe(v))
+}
+
+func unixTime(f float64) time.Time {
+	if math.Abs(f) >= 1e12 {
+		return time.UnixMilli(int64(f)).UTC()
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+// dateLayoutTokens maps the familiar YYYY-MM-DD style tokens to Go's reference layout.
+// Longer tokens come first so "MMMM" wins over "MM".
+var dateLayoutTokens = []struct{ token, layout string }{
+	{"YYYY", "2006"}, {"YY", "06"},
+	{"MMMM", "January"}, {"MMM", "Jan"}, {"MM", "01"},
+	{"dddd", "Monday"}, {"ddd", "Mon"}, {"DD", "02"},
+	{"HH", "15"}, {"hh", "03"}, {"mm", "04"}, {"ss", "05"}, {"SSS", "000"},
+	{"A", "PM"}, {"ZZ", "-0700"}, {"Z", "Z07:00"},
+}
+
+var namedDateLayouts = map[string]string{
+	"iso":      time.RFC3339,
+	"rfc3339":  time.RFC3339,
+	"date":     "2006-01-02",
+	"time":     "15:04:05",
+	"datetime": "2006-01-02 15:04:05",
+	"rfc1123":  time.RFC1123,
+}
+
+// toGoLayout converts "YYYY-MM-DD HH:mm" (or a named layout) to a Go time layout.
+// Layouts that already use Go's reference date are returned unchanged.
+func toGoLayout(layout string) string {
+	if named, ok := namedDateLayouts[strings.ToLower(layout)]; ok {
+		return named
+	}
+	if strings.Contains(layout, "2006") {
+		return layout
+	}
+	var sb strings.Builder
+	for i := 0; i < len(layout); {
+		matched := false
+		for _, t := range dateLayoutTokens {
+			if strings.HasPrefix(layout[i:], t.token) {
+				sb.WriteString(t.layout)
+				i += len(t.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			sb.WriteByte(layout[i])
+			i++
+		}
+	}
+	return sb.String()
+}
+
+func fnNow(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+	return formatTime(time.Now().UTC()), nil
+}
+
+// date_parse(value, layout?) normalizes a date to RFC 3339.
+func fnDateParse(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 1, 2); err != nil {
+		return nil, err
+	}
+	if len(args) == 1 {
+		t, err := toTime(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return formatTime(t), nil
+	}
+	t, err := time.Parse(toGoLayout(stringify(args[1])), stringify(args[0]))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %q with layout %q", stringify(args[0]), stringify(args[1]))
+	}
+	return formatTime(t), nil
+}
+
+// date_format(value, layout, timezone?)
+func fnDateFormat(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 3); err != nil {
+		return nil, err
+	}
+	t, err := toTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 3 {
+		loc, err := time.LoadLocation(stringify(args[2]))
+		if err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", stringify(args[2]))
+		}
+		t = t.In(loc)
+	}
+	return t.Format(toGoLayout(stringify(args[1]))), nil
+}
+
+// date_add(value, amount, unit) where unit is seconds, minutes, hours, days, weeks, months or years.
+func fnDateAdd(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 3, 3); err != nil {
+		return nil, err
+	}
+	t, err := toTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+	amount, err := numberArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	switch unit := strings.TrimSuffix(strings.ToLower(stringify(args[2])), "s"); unit {
+	case "month":
+		t = t.AddDate(0, int(amount), 0)
+	case "year":
+		t = t.AddDate(int(amount), 0, 0)
+	default:
+		d, err := unitDuration(unit)
+		if err != nil {
+			return nil, err
+		}
+		t = t.Add(time.Duration(amount * float64(d)))
+	}
+	return formatTime(t), nil
+}
+
+// date_diff(a, b, unit = "seconds") returns a - b in the given unit.
+func fnDateDiff(args []interface{}) (interface{}, error) {
+	if err := checkArgs(args, 2, 3); err != nil {
+		return nil, err
+	}
+	a, err := toTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+	b, err := toTime(args[1])
+	if err != nil {
+		return nil, err
+	}
+	unit := "second"
+	if len(args) == 3 {
+		unit = strings.TrimSuffix(strings.ToLower(stringify(args[2])), "s")
+	}
+	d, err := unitDuration(unit)
+	if err != nil {
+		return nil, err
+	}
+	return float64(a.Sub(b)) / float64(d), nil
+}
+
+func unitDuration(unit string) (time.Duration, error) {
+	switch unit {
+	case "second", "sec":
+		return time.Second, nil
+	case "minute", "min":
+		return time.Minute, nil
+	case "hour":
+		return time.Hour, nil
+	case "day":
+		return 24 * time.Hour, nil
+	case "week":
+		return 7 * 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("unknown time unit %q", unit)
+}
@@ -0,0 +1,808 @@
+package nodes
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expression grammar (lowest to highest precedence):
+//
+//	pipe        = ternary { "|" IDENT [ "(" args ")" ] }
+//	ternary     = nullish [ "?" ternary ":" ternary ]
+//	nullish     = or { "??" or }
+//	or          = and { ("||" | "or") and }
+//	and         = equality { ("&&" | "and") equality }
+//	equality    = comparison { ("==" | "!=") comparison }
+//	comparison  = additive { ("<" | "<=" | ">" | ">=") additive }
+//	additive    = multiplicative { ("+" | "-") multiplicative }
+//	multiplicative = unary { ("*" | "/" | "%") unary }
+//	unary       = ("!" | "not" | "-") unary | postfix
+//	postfix     = primary { "." NAME | "[" pipe "]" }
+//	primary     = NUMBER | STRING | true | false | null | "[" args "]" | "(" pipe ")"
+//	            | IDENT "(" args ")" | IDENT
+//
+// Bare identifiers are the context roots (steps, variables, input, config, item, index).
+// "x | f(a)" is sugar for "f(x, a)". Member names after a dot may contain dashes
+// (steps.api.headers.Content-Type), so write subtraction with spaces: "a.b - 1".
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokName // member name following a "."
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+// twoCharOps must be checked before single characters
+var twoCharOps = []string{"??", "||", "&&", "==", "!=", "<=", ">="}
+
+const singleCharOps = ".[](),?:|!<>+-*/%"
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			i++
+			continue
+		}
+
+		afterDot := len(tokens) > 0 && tokens[len(tokens)-1].kind == tokOp && tokens[len(tokens)-1].text == "."
+
+		// Member name: anything identifier-like, digits and inner dashes allowed
+		if afterDot && isNameChar(rune(c)) {
+			start := i
+			for i < len(src) {
+				if isNameChar(rune(src[i])) || src[i] >= 0x80 {
+					i++
+				} else if src[i] == '-' && i+1 < len(src) && isNameChar(rune(src[i+1])) {
+					i++
+				} else {
+					break
+				}
+			}
+			tokens = append(tokens, token{kind: tokName, text: src[start:i], pos: start})
+			continue
+		}
+
+		switch {
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.' || src[i] == 'e' || src[i] == 'E' ||
+				((src[i] == '+' || src[i] == '-') && (src[i-1] == 'e' || src[i-1] == 'E'))) {
+				i++
+			}
+			n, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", src[start:i], start)
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], num: n, pos: start})
+
+		case c == '"' || c == '\'':
+			start := i
+			quote := c
+			i++
+			var sb strings.Builder
+			closed := false
+			for i < len(src) {
+				ch := src[i]
+				if ch == '\\' && i+1 < len(src) {
+					i++
+					switch src[i] {
+					case 'n':
+						sb.WriteByte('\n')
+					case 't':
+						sb.WriteByte('\t')
+					case 'r':
+						sb.WriteByte('\r')
+					default:
+						sb.WriteByte(src[i])
+					}
+					i++
+					continue
+				}
+				if ch == quote {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteByte(ch)
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string starting at position %d", start)
+			}
+			tokens = append(tokens, token{kind: tokString, text: sb.String(), pos: start})
+
+		case c == '_' || c == '$' || unicode.IsLetter(rune(c)) || c >= 0x80:
+			start := i
+			for i < len(src) && (isNameChar(rune(src[i])) || src[i] >= 0x80) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[start:i], pos: start})
+
+		default:
+			matched := false
+			for _, op := range twoCharOps {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+					i += 2
+					matched = true
+					break
+				}
+			}
+			if matched {
+				continue
+			}
+			if strings.IndexByte(singleCharOps, c) >= 0 {
+				tokens = append(tokens, token{kind: tokOp, text: string(c), pos: i})
+				i++
+				continue
+			}
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+		}
+	}
+	tokens = append(tokens, token{kind: tokEOF, pos: len(src)})
+	return tokens, nil
+}
+
+func isNameChar(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// --- AST ---
+
+type exprNode interface {
+	eval(ctx *evalContext) (interface{}, error)
+}
+
+type literalNode struct{ value interface{} }
+type rootNode struct{ name string }
+type memberNode struct {
+	object exprNode
+	name   string
+}
+type indexNode struct{ object, index exprNode }
+type callNode struct {
+	name string
+	args []exprNode
+}
+type listNode struct{ items []exprNode }
+type unaryNode struct {
+	op      string
+	operand exprNode
+}
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+type ternaryNode struct{ cond, then, otherwise exprNode }
+
+// --- Parser ---
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parseExpression compiles the content of a {{ }} block.
+func parseExpression(src string) (exprNode, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	node, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+	return node, nil
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is one of the given operators / keywords.
+func (p *parser) accept(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokOp && tok.kind != tokIdent {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expect(op string) error {
+	tok := p.next()
+	if tok.kind != tokOp || tok.text != op {
+		if tok.kind == tokEOF {
+			return fmt.Errorf("expected %q but expression ended", op)
+		}
+		return fmt.Errorf("expected %q at position %d, got %q", op, tok.pos, tok.text)
+	}
+	return nil
+}
+
+func (p *parser) parsePipe() (exprNode, error) {
+	left, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("|"); !ok {
+			return left, nil
+		}
+		tok := p.next()
+		if tok.kind != tokIdent {
+			return nil, fmt.Errorf("expected filter name after '|' at position %d", tok.pos)
+		}
+		args := []exprNode{left}
+		if _, ok := p.accept("("); ok {
+			rest, err := p.parseArgs(")")
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, rest...)
+		}
+		left = &callNode{name: tok.text, args: args}
+	}
+}
+
+func (p *parser) parseTernary() (exprNode, error) {
+	cond, err := p.parseNullish()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("?"); !ok {
+		return cond, nil
+	}
+	then, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	return &ternaryNode{cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+// parseBinary parses a left-associative chain of operators at one precedence level.
+func (p *parser) parseBinary(next func() (exprNode, error), normalize map[string]string, ops ...string) (exprNode, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+		if alias, ok := normalize[op]; ok {
+			op = alias
+		}
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+var keywordOps = map[string]string{"or": "||", "and": "&&"}
+
+func (p *parser) parseNullish() (exprNode, error) {
+	return p.parseBinary(p.parseOr, nil, "??")
+}
+
+func (p *parser) parseOr() (exprNode, error) {
+	return p.parseBinary(p.parseAnd, keywordOps, "||", "or")
+}
+
+func (p *parser) parseAnd() (exprNode, error) {
+	return p.parseBinary(p.parseEquality, keywordOps, "&&", "and")
+}
+
+func (p *parser) parseEquality() (exprNode, error) {
+	return p.parseBinary(p.parseComparison, nil, "==", "!=")
+}
+
+func (p *parser) parseComparison() (exprNode, error) {
+	return p.parseBinary(p.parseAdditive, nil, "<", "<=", ">", ">=")
+}
+
+func (p *parser) parseAdditive() (exprNode, error) {
+	return p.parseBinary(p.parseMultiplicative, nil, "+", "-")
+}
+
+func (p *parser) parseMultiplicative() (exprNode, error) {
+	return p.parseBinary(p.parseUnary, nil, "*", "/", "%")
+}
+
+func (p *parser) parseUnary() (exprNode, error) {
+	if op, ok := p.accept("!", "not", "-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "not" {
+			op = "!"
+		}
+		return &unaryNode{op: op, operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (exprNode, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("."); ok {
+			tok := p.next()
+			if tok.kind != tokName {
+				return nil, fmt.Errorf("expected property name after '.' at position %d", tok.pos)
+			}
+			node = &memberNode{object: node, name: tok.text}
+			continue
+		}
+		if _, ok := p.accept("["); ok {
+			index, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			node = &indexNode{object: node, index: index}
+			continue
+		}
+		return node, nil
+	}
+}
+
+func (p *parser) parsePrimary() (exprNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		return &literalNode{value: tok.num}, nil
+	case tokString:
+		return &literalNode{value: tok.text}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null", "nil":
+			return &literalNode{value: nil}, nil
+		}
+		if _, ok := p.accept("("); ok {
+			args, err := p.parseArgs(")")
+			if err != nil {
+				return nil, err
+			}
+			return &callNode{name: tok.text, args: args}, nil
+		}
+		return &rootNode{name: tok.text}, nil
+	case tokOp:
+		switch tok.text {
+		case "(":
+			node, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return node, nil
+		case "[":
+			items, err := p.parseArgs("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{items: items}, nil
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+}
+
+// parseArgs parses a comma separated list up to the closing token (already past the opener).
+func (p *parser) parseArgs(closing string) ([]exprNode, error) {
+	var args []exprNode
+	if _, ok := p.accept(closing); ok {
+		return args, nil
+	}
+	for {
+		arg, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if _, ok := p.accept(","); ok {
+			continue
+		}
+		if err := p.expect(closing); err != nil {
+			return nil, err
+		}
+		return args, nil
+	}
+}
+
+// --- Evaluation ---
+
+type evalContext struct {
+	engine *ExpressionEngine
+	node   NodeContext
+}
+
+func (n *literalNode) eval(ctx *evalContext) (interface{}, error) { return n.value, nil }
+
+func (n *rootNode) eval(ctx *evalContext) (interface{}, error) {
+	return ctx.engine.resolveRoot(n.name, ctx.node)
+}
+
+func (n *memberNode) eval(ctx *evalContext) (interface{}, error) {
+	obj, err := n.object.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Numeric member on a list is an index (items.0)
+	if list, ok := obj.([]interface{}); ok {
+		if idx, err := strconv.Atoi(n.name); err == nil {
+			return indexList(list, idx, n.name)
+		}
+	}
+	return ctx.engine.Traverse(obj, []string{n.name})
+}
+
+func (n *indexNode) eval(ctx *evalContext) (interface{}, error) {
+	obj, err := n.object.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := n.index.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if list, ok := asList(obj); ok {
+		f, ok := toNumber(key)
+		if !ok || f != math.Trunc(f) {
+			return nil, fmt.Errorf("list index must be an integer, got %v", key)
+		}
+		return indexList(list, int(f), fmt.Sprint(key))
+	}
+	if s, ok := key.(string); ok {
+		return ctx.engine.Traverse(obj, []string{s})
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("cannot index nil")
+	}
+	return nil, fmt.Errorf("cannot index %T with %v", obj, key)
+}
+
+// indexList supports negative indices counting from the end (list[-1] is the last element).
+func indexList(list []interface{}, idx int, label string) (interface{}, error) {
+	if idx < 0 {
+		idx += len(list)
+	}
+	if idx < 0 || idx >= len(list) {
+		return nil, fmt.Errorf("array index %s out of bounds (length %d)", label, len(list))
+	}
+	return list[idx], nil
+}
+
+func (n *listNode) eval(ctx *evalContext) (interface{}, error) {
+	out := make([]interface{}, 0, len(n.items))
+	for _, item := range n.items {
+		v, err := item.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func (n *callNode) eval(ctx *evalContext) (interface{}, error) {
+	fn, ok := expressionFunctions[n.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function: %s", n.name)
+	}
+	// default() must tolerate a missing left side
+	if n.name == "default" && len(n.args) > 0 {
+		args := make([]interface{}, len(n.args))
+		first, err := n.args[0].eval(ctx)
+		if err == nil {
+			args[0] = first
+		}
+		for i := 1; i < len(n.args); i++ {
+			if args[i], err = n.args[i].eval(ctx); err != nil {
+				return nil, err
+			}
+		}
+		return fn(args)
+	}
+
+	args := make([]interface{}, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	result, err := fn(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s(): %w", n.name, err)
+	}
+	return result, nil
+}
+
+func (n *unaryNode) eval(ctx *evalContext) (interface{}, error) {
+	v, err := n.operand.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		return !isTruthy(v), nil
+	}
+	f, ok := toNumber(v)
+	if !ok {
+		return nil, fmt.Errorf("cannot negate %v", v)
+	}
+	return -f, nil
+}
+
+func (n *ternaryNode) eval(ctx *evalContext) (interface{}, error) {
+	cond, err := n.cond.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if isTruthy(cond) {
+		return n.then.eval(ctx)
+	}
+	return n.otherwise.eval(ctx)
+}
+
+func (n *binaryNode) eval(ctx *evalContext) (interface{}, error) {
+	// Short-circuiting operators
+	switch n.op {
+	case "??":
+		left, err := n.left.eval(ctx)
+		if err == nil && left != nil {
+			return left, nil
+		}
+		return n.right.eval(ctx)
+	case "&&", "||":
+		left, err := n.left.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if n.op == "&&" && !isTruthy(left) {
+			return false, nil
+		}
+		if n.op == "||" && isTruthy(left) {
+			return true, nil
+		}
+		right, err := n.right.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return isTruthy(right), nil
+	}
+
+	left, err := n.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return valuesEqual(left, right), nil
+	case "!=":
+		return !valuesEqual(left, right), nil
+	case "<", "<=", ">", ">=":
+		cmp, err := compareOrdered(left, right)
+		if err != nil {
+			return nil, err
+		}
+		switch n.op {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		default:
+			return cmp >= 0, nil
+		}
+	case "+":
+		// String concatenation wins over addition
+		_, ls := left.(string)
+		_, rs := right.(string)
+		if ls || rs {
+			return stringify(left) + stringify(right), nil
+		}
+		if ll, ok := left.([]interface{}); ok {
+			if rl, ok := right.([]interface{}); ok {
+				return append(append([]interface{}{}, ll...), rl...), nil
+			}
+		}
+	}
+
+	a, okA := toNumber(left)
+	b, okB := toNumber(right)
+	if !okA || !okB {
+		return nil, fmt.Errorf("operator %s needs numbers, got %s and %s", n.op, describe(left), describe(right))
+	}
+	switch n.op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		if b == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return a / b, nil
+	case "%":
+		if b == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(a, b), nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", n.op)
+}
+
+// --- Value helpers ---
+
+// toNumber converts numeric values and numeric strings. Booleans are not numbers here.
+func toNumber(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case int32:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case uint:
+		return float64(val), true
+	case uint64:
+		return float64(val), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// isTruthy follows the usual scripting rules: nil, false, 0, "" and empty collections are false.
+func isTruthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case string:
+		return val != ""
+	case []interface{}:
+		return len(val) > 0
+	case map[string]interface{}:
+		return len(val) > 0
+	}
+	if f, ok := toNumber(v); ok {
+		return f != 0
+	}
+	return true
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	as, aStr := a.(string)
+	bs, bStr := b.(string)
+	if aStr && bStr {
+		return as == bs
+	}
+	if fa, ok := toNumber(a); ok {
+		if fb, ok := toNumber(b); ok {
+			return fa == fb
+		}
+	}
+	if aStr || bStr {
+		return stringify(a) == stringify(b)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compareOrdered returns -1, 0 or 1. Numbers compare numerically, strings lexically
+// (which also orders ISO-8601 dates correctly).
+func compareOrdered(a, b interface{}) (int, error) {
+	as, aStr := a.(string)
+	bs, bStr := b.(string)
+	if fa, okA := toNumber(a); okA {
+		if fb, okB := toNumber(b); okB && !(aStr && bStr) {
+			switch {
+			case fa < fb:
+				return -1, nil
+			case fa > fb:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	if aStr && bStr {
+		return strings.Compare(as, bs), nil
+	}
+	return 0, fmt.Errorf("cannot compare %s with %s", describe(a), describe(b))
+}
+
+func asList(v interface{}) ([]interface{}, bool) {
+	switch val := v.(type) {
+	case []interface{}:
+		return val, true
+	case []string:
+		out := make([]interface{}, len(val))
+		for i, s := range val {
+			out[i] = s
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+func describe(v interface{}) string {
+	if v == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%T(%v)", v, v)
+}
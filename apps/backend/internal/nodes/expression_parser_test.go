@@ -0,0 +1,261 @@
+package nodes
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func expressionTestContext() NodeContext {
+	return NodeContext{
+		InputData: map[string]interface{}{
+			"name":    "Ada",
+			"n":       10.0,
+			"price":   "19.99",
+			"n-1":     "dashed",
+			"flag":    false,
+			"empty":   "",
+			"tags":    []interface{}{"a", "b", "c"},
+			"nothing": nil,
+			"steps": map[string]interface{}{
+				"api": map[string]interface{}{
+					"headers": map[string]interface{}{"Content-Type": "application/json"},
+					"data":    map[string]interface{}{"total": 40.0, "items": []interface{}{1.0, 2.0}},
+				},
+				"form":     map[string]interface{}{"First Name": "Grace"},
+				"fetch-id": map[string]interface{}{"status": 200.0},
+			},
+		},
+	}
+}
+
+func TestExpressionEvaluate(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want interface{}
+	}{
+		// Precedence and associativity
+		{"multiplication before addition", "{{ 1 + 2 * 3 }}", 7.0},
+		{"parentheses", "{{ (1 + 2) * 3 }}", 9.0},
+		{"subtraction is left-associative", "{{ 10 - 4 - 3 }}", 3.0},
+		{"division is left-associative", "{{ 24 / 4 / 2 }}", 3.0},
+		{"modulo", "{{ 7 % 4 + 1 }}", 4.0},
+		{"unary minus binds tighter", "{{ -2 * 3 }}", -6.0},
+		{"comparison before and", "{{ 2 * 3 > 5 && 1 == 1 }}", true},
+		{"and before or", "{{ true || false && false }}", true},
+		{"not binds tighter than or", "{{ not true or true }}", true},
+		{"keyword and", "{{ input.n > 5 and input.n < 20 }}", true},
+		{"equality after comparison", "{{ 1 < 2 == true }}", true},
+		{"pipe is lowest", "{{ 1 + 2 | string }}", "3"},
+		{"string concatenation", `{{ "n=" + input.n }}`, "n=10"},
+		{"numeric strings compare as numbers", `{{ input.price > 9 }}`, true},
+		{"list concatenation", "{{ [1] + [2] }}", []interface{}{1.0, 2.0}},
+
+		// ?? and the ternary operator
+		{"?? on a missing key", `{{ input.missing ?? "x" }}`, "x"},
+		{"?? on a missing root", `{{ item ?? "none" }}`, "none"},
+		{"?? on null", `{{ input.nothing ?? 1 }}`, 1.0},
+		{"?? keeps false", `{{ input.flag ?? "x" }}`, false},
+		{"?? keeps empty string", `{{ input.empty ?? "x" }}`, ""},
+		{"?? chains", `{{ input.a ?? input.b ?? 3 }}`, 3.0},
+		{"ternary then", `{{ input.n > 5 ? "big" : "small" }}`, "big"},
+		{"ternary otherwise", `{{ input.flag ? 1 : 2 }}`, 2.0},
+		{"ternary is right-associative", `{{ input.n > 50 ? "a" : input.n > 5 ? "b" : "c" }}`, "b"},
+		{"?? binds tighter than ternary", `{{ input.missing ?? 1 ? "y" : "n" }}`, "y"},
+		{"ternary skips the other branch", `{{ true ? 1 : input.missing.deep }}`, 1.0},
+
+		// Filters and functions
+		{"filter", "{{ input.name | upper }}", "ADA"},
+		{"chained filters", "{{ input.name | upper | lower }}", "ada"},
+		{"filter with arguments", `{{ input.tags | join("-") }}`, "a-b-c"},
+		{"filter after arithmetic", "{{ input.n / 3 | round(2) }}", 3.33},
+		{"default filter on a missing key", `{{ input.missing | default("none") }}`, "none"},
+		{"function call", `{{ len(input.tags) }}`, 3},
+		{"nested calls", `{{ upper(replace(input.name, "a", "x")) }}`, "ADX"},
+		{"filter inside an index", `{{ input.tags[len(input.tags) - 1] }}`, "c"},
+
+		// Member access and dashed keys
+		{"dashed member name", "{{ steps.api.headers.Content-Type }}", "application/json"},
+		{"dashed step ID", "{{ steps.fetch-id.status }}", 200.0},
+		{"dash after a dot is part of the name", "{{ input.n-1 }}", "dashed"},
+		{"subtraction needs spaces", "{{ input.n - 1 }}", 9.0},
+		{"bracket key", `{{ steps["fetch-id"]["status"] }}`, 200.0},
+		{"numeric member on a list", "{{ input.tags.1 }}", "b"},
+		{"negative index", "{{ input.tags[-1] }}", "c"},
+		{"index then member", "{{ steps.api.data.items[0] + steps.api.data.total }}", 41.0},
+
+		// Whole-block results keep their type; interpolation stringifies
+		{"whole block keeps maps", "{{ steps.fetch-id }}", map[string]interface{}{"status": 200.0}},
+		{"interpolation", "Total: {{ steps.api.data.total * 1.5 }} for {{ input.name }}", "Total: 60 for Ada"},
+		{"interpolation of a list", "tags={{ input.tags }}", `tags=["a","b","c"]`},
+		{"interpolation of null", "[{{ input.nothing }}]", "[]"},
+		{"no expression", "plain text", "plain text"},
+
+		// Plain path fallback for keys the grammar can't express
+		{"key with a space", "{{ steps.form.First Name }}", "Grace"},
+	}
+
+	engine := NewExpressionEngine()
+	ctx := expressionTestContext()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.Evaluate(tt.expr, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate(%q): %v", tt.expr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate(%q) = %#v, want %#v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr string
+	}{
+		{"missing operand", "{{ 1 + }}", "invalid expression"},
+		{"unterminated string", `{{ "abc }}`, "unterminated string"},
+		{"unbalanced parenthesis", "{{ (1 + 2 }}", `expected ")"`},
+		{"ternary without else", "{{ true ? 1 }}", `expected ":"`},
+		{"filter without a name", "{{ input.name | 1 }}", "expected filter name"},
+		{"unknown function", "{{ input.name | shout }}", "unknown function: shout"},
+		{"unknown root", "{{ flow.name }}", "unknown root object"},
+		{"missing key", "{{ input.missing }}", "property 'missing' not found"},
+		{"division by zero", "{{ input.n / 0 }}", "division by zero"},
+		{"arithmetic on text", "{{ input.name * 2 }}", "needs numbers"},
+		{"ordering text against a number", "{{ input.name > 1 }}", "cannot compare"},
+		{"index out of range", "{{ input.tags[5] }}", "out of bounds"},
+		{"function error", "{{ round(input.name) }}", "round():"},
+		{"fallback path that doesn't resolve", "{{ steps.form.Last Name }}", "invalid expression"},
+		{"error inside interpolation", "Hi {{ input.missing }}", "property 'missing' not found"},
+	}
+
+	engine := NewExpressionEngine()
+	ctx := expressionTestContext()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.Evaluate(tt.expr, ctx)
+			if err == nil {
+				t.Fatalf("Evaluate(%q) = %#v, want an error", tt.expr, got)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Evaluate(%q) error = %q, want it to mention %q", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExpressionSecrets(t *testing.T) {
+	engine := NewExpressionEngine()
+	ctx := expressionTestContext()
+
+	if _, err := engine.Evaluate("{{ secrets.API_KEY }}", ctx); err == nil {
+		t.Error("secrets resolved outside of a step")
+	}
+
+	ctx.Secrets = map[string]string{"API_KEY": "s3cr3t"}
+	got, err := engine.Evaluate(`Bearer {{ secrets.API_KEY }}`, ctx)
+	if err != nil || got != "Bearer s3cr3t" {
+		t.Errorf("Evaluate = %#v, %v, want the secret interpolated", got, err)
+	}
+}
+
+func TestExpressionBlocks(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wantContent []string
+		wantSteps   []string
+		wantSecrets []string
+		wantErr     []bool
+	}{
+		{"no blocks", "plain text", nil, nil, nil, nil},
+		{
+			"step references",
+			`{{ steps.api.data.total }} and {{ steps["fetch-id"].status ?? steps.cache-1.status }}`,
+			[]string{"steps.api.data.total", `steps["fetch-id"].status ?? steps.cache-1.status`},
+			[]string{"api", "fetch-id", "cache-1"},
+			nil,
+			[]bool{false, false},
+		},
+		{
+			"secret references",
+			`Bearer {{ secrets.API_KEY }}:{{ secrets['other'] }}`,
+			[]string{"secrets.API_KEY", "secrets['other']"},
+			nil,
+			[]string{"API_KEY", "other"},
+			[]bool{false, false},
+		},
+		{
+			"parse error",
+			"{{ steps.api.data + }}",
+			[]string{"steps.api.data +"},
+			[]string{"api"},
+			nil,
+			[]bool{true},
+		},
+		{
+			"mysteps isn't a reference",
+			"{{ input.mysteps.x }}",
+			[]string{"input.mysteps.x"},
+			nil,
+			nil,
+			[]bool{false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks := ExpressionBlocks(tt.input)
+			if len(blocks) != len(tt.wantContent) {
+				t.Fatalf("ExpressionBlocks(%q) = %d blocks, want %d", tt.input, len(blocks), len(tt.wantContent))
+			}
+			var steps, secretNames []string
+			for i, block := range blocks {
+				if block.Content != tt.wantContent[i] {
+					t.Errorf("block %d content = %q, want %q", i, block.Content, tt.wantContent[i])
+				}
+				if (block.ParseErr != nil) != tt.wantErr[i] {
+					t.Errorf("block %d parse error = %v, want error %v", i, block.ParseErr, tt.wantErr[i])
+				}
+				steps = append(steps, block.Steps...)
+				secretNames = append(secretNames, block.Secrets...)
+			}
+			if !reflect.DeepEqual(steps, tt.wantSteps) {
+				t.Errorf("steps = %v, want %v", steps, tt.wantSteps)
+			}
+			if !reflect.DeepEqual(secretNames, tt.wantSecrets) {
+				t.Errorf("secrets = %v, want %v", secretNames, tt.wantSecrets)
+			}
+		})
+	}
+}
+
+func TestSecretReferences(t *testing.T) {
+	config := map[string]interface{}{
+		"url": "https://api.example.com/{{ input.id }}",
+		"headers": map[string]interface{}{
+			"Authorization": "Bearer {{ secrets.API_TOKEN }}",
+		},
+		"body": []interface{}{
+			"{{ secrets.SIGNING_KEY }}",
+			map[string]interface{}{"nested": `{{ secrets["db-password"] ?? "" }}`},
+			42.0,
+		},
+		"note": "secrets.NOT_AN_EXPRESSION",
+	}
+	got := SecretReferences(config)
+	sort.Strings(got)
+	want := []string{"API_TOKEN", "SIGNING_KEY", "db-password"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SecretReferences = %v, want %v", got, want)
+	}
+
+	if got := SecretReferences("{{ input.secrets }}"); len(got) != 0 {
+		t.Errorf("SecretReferences(input.secrets) = %v, want none", got)
+	}
+}
@@ -4,8 +4,13 @@ import (
 	"context"
 	"fmt"
 	"strings"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/compare"
 )
 
+// FilterNode keeps the items of an array that match its conditions. Conditions use the
+// compare operators; "strict" and "caseSensitive" may be set in the settings or on a
+// single condition.
 type FilterNode struct{}
 
 func (n *FilterNode) Execute(ctx context.Context, input NodeContext) (*NodeResult, error) {
@@ -59,26 +64,34 @@ func (n *FilterNode) Execute(ctx context.Context, input NodeContext) (*NodeResul
 	// We optimize by resolving dynamic values once against the global context
 	type resolvedCondition struct {
 		field     string
-		operator  string
+		operator  compare.Operator
+		known     bool // unknown (or missing) operators match nothing; the validator reports them
 		targetVal interface{}
+		opts      compare.Options
 	}
 	var activeConditions []resolvedCondition
+	opts := compare.Options{}.WithOverrides(settings)
 
 	for _, cond := range conditions {
 		f, _ := cond["field"].(string)
-		op, _ := cond["operator"].(string)
-		vStr, _ := cond["value"].(string)
+		rawOp, _ := cond["operator"].(string)
+		op, known := compare.Lookup(rawOp)
 
 		// Resolve the comparison value (e.g. "100" or "{{ steps.prev.limit }}")
-		rVal, err := engine.Evaluate(vStr, input)
-		if err != nil {
-			return nil, fmt.Errorf("failed to resolve condition value: %w", err)
+		rVal := cond["value"]
+		if vStr, ok := rVal.(string); ok || rVal == nil {
+			rVal, err = engine.Evaluate(vStr, input)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve condition value: %w", err)
+			}
 		}
 
 		activeConditions = append(activeConditions, resolvedCondition{
 			field:     f,
 			operator:  op,
+			known:     known,
 			targetVal: rVal,
+			opts:      opts.WithOverrides(cond),
 		})
 	}
 
@@ -110,8 +123,14 @@ func (n *FilterNode) Execute(ctx context.Context, input NodeContext) (*NodeResul
 				}
 			}
 
-			// Compare
-			pass := compareValues(itemVal, cond.operator, cond.targetVal)
+			// Compare. Values that can't be compared (e.g. "n/a" > 100) don't pass
+			pass := false
+			if cond.known {
+				pass, err = cond.operator.Test(itemVal, cond.targetVal, cond.opts)
+				if compare.IsConfigError(err) {
+					return configError(fmt.Sprintf("Invalid filter condition: %v", err)), nil
+				}
+			}
 
 			if matchType == "ANY" {
 				if pass {
@@ -139,30 +158,3 @@ func (n *FilterNode) Execute(ctx context.Context, input NodeContext) (*NodeResul
 		},
 	}, nil
 }
-
-// compareValues compares two values based on the operator
-func compareValues(a interface{}, op string, b interface{}) bool {
-	// Simple stringification for comparison for MVP
-	// This handles numbers as strings if they come from JSON as float64
-	strA := fmt.Sprintf("%v", a)
-	strB := fmt.Sprintf("%v", b)
-
-	switch op {
-	case "==":
-		return strA == strB
-	case "!=":
-		return strA != strB
-	case ">":
-		// This is naive string comparison. For numbers, we should try type assertion.
-		// For MVP, let's keep it simple or try to parse floats if they look like numbers?
-		// Let's stick to string comparison for now unless strict numeric required.
-		// Actually, for "Greater", string comparison is dangerous ("10" < "2").
-		return strA > strB
-	case "<":
-		return strA < strB
-	case "contains":
-		return strings.Contains(strA, strB)
-	default:
-		return false
-	}
-}
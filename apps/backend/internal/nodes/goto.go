@@ -25,10 +25,7 @@ func (n *GotoNode) Execute(ctx context.Context, input NodeContext) (*NodeResult,
 
 	if !ok || targetID == "" {
 		fmt.Printf("DEBUG: GotoNode Missing TargetID\n")
-		return &NodeResult{
-			Status: StatusFailed,
-			Error:  "Missing 'targetId' configuration for Goto node",
-		}, nil
+		return configError("Missing 'targetId' configuration for Goto node"), nil
 	}
 
 	fmt.Printf("DEBUG: GotoNode Jumping to: %s\n", targetID)
@@ -1,18 +1,54 @@
 package nodes
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"math"
 	"net/http"
+	neturl "net/url"
+	"strings"
 	"time"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/egress"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/httpauth"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/secrets"
 )
 
+// HttpNode calls an HTTP endpoint. With "authProfileId" set, the org's auth profile
+// (see the httpauth package) supplies the credentials at request time.
+//
+//	{
+//	  "method": "POST",
+//	  "url": "https://api.example.com/shipments",
+//	  "query": { "status": "open", "tag": ["a", "b"] },
+//	  "headers": { "X-Trace": "{{ trigger.id }}" },
+//	  "bodyType": "json" | "form" | "multipart" | "raw",
+//	  "body": { ... },
+//	  "timeoutSeconds": 10,
+//	  "maxResponseBytes": 10485760,
+//	  "responseType": "auto" | "json" | "text" | "binary",
+//	  "rateLimit": { "maxRetries": 3, "maxWaitSeconds": 30 },
+//	  "pagination": { "type": "link" | "cursor" | "page", ... }
+//	}
+//
+// Binary responses are stored as run artifacts and "data" holds their reference. With
+// pagination (see httpPagination), "data" is the items of every page in one list.
 type HttpNode struct{}
 
 func (n *HttpNode) Execute(ctx context.Context, input NodeContext) (*NodeResult, error) {
+	var credentials []string
+	result, err := n.send(ctx, input, &credentials)
+	// Responses can echo what was sent (e.g. debugging endpoints): keep profile credentials out
+	if result != nil && len(credentials) > 0 {
+		redactor := secrets.NewValueRedactor(credentials)
+		result.Output = redactor.Map(result.Output)
+		result.Error = redactor.String(result.Error)
+	}
+	return result, err
+}
+
+func (n *HttpNode) send(ctx context.Context, input NodeContext, credentials *[]string) (*NodeResult, error) {
 	// 1. Resolve Config using Expression Engine
 	engine := NewExpressionEngine()
 	resolvedConfig, err := engine.EvaluateMap(input.Config, input)
@@ -26,93 +62,286 @@ func (n *HttpNode) Execute(ctx context.Context, input NodeContext) (*NodeResult,
 		method = "GET"
 	}
 	if url == "" {
-		return nil, fmt.Errorf("URL is required")
-	}
-
-	// 2. Prepare Body
-	var bodyReader io.Reader
-	if resolvedConfig["body"] != nil {
-		bodyStr, ok := resolvedConfig["body"].(string)
-		if ok {
-			bodyReader = bytes.NewBufferString(bodyStr)
-		} else {
-			// Try to marshal if it's an object
-			jsonBody, err := json.Marshal(resolvedConfig["body"])
-			if err == nil {
-				bodyReader = bytes.NewBuffer(jsonBody)
-			}
-		}
+		return &NodeResult{
+			Status:    StatusFailed,
+			Error:     "URL is required",
+			ErrorType: ErrorTypeConfig,
+		}, nil
 	}
 
-	// 3. Create Request
-	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+	call := &httpCall{
+		input:       input,
+		method:      method,
+		headers:     make(map[string]string),
+		credentials: credentials,
+	}
+	if failed := call.configure(resolvedConfig); failed != nil {
+		return failed, nil
+	}
+	pagination, err := parsePagination(resolvedConfig["pagination"])
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return configError(err.Error()), nil
+	}
+
+	// 2. Query parameters
+	if rawQuery, ok := input.Config["query"].(map[string]interface{}); ok {
+		resolved, err := evaluateDeep(engine, rawQuery, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate query expressions: %w", err)
+		}
+		query, _ := resolved.(map[string]interface{})
+		if url, err = withQuery(url, query); err != nil {
+			return configError(err.Error()), nil
+		}
 	}
 
-	// 4. Add Headers
-	if headers, ok := resolvedConfig["headers"].(map[string]interface{}); ok {
-		for k, v := range headers {
+	// 3. Prepare Body
+	var contentType string
+	if rawBody := input.Config["body"]; rawBody != nil {
+		body, err := evaluateDeep(engine, rawBody, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate body expressions: %w", err)
+		}
+		bodyType, _ := resolvedConfig["bodyType"].(string)
+		call.body, contentType, err = buildRequestBody(input.OrgID, body, bodyType)
+		if err != nil {
+			return configError(fmt.Sprintf("Invalid body: %v", err)), nil
+		}
+	}
+
+	// 4. Resolve Headers (values may reference secrets, e.g. "Bearer {{ secrets.API_TOKEN }}")
+	if rawHeaders, ok := resolvedConfig["headers"].(map[string]interface{}); ok {
+		resolved, err := evaluateDeep(engine, rawHeaders, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate header expressions: %w", err)
+		}
+		resolvedHeaders, _ := resolved.(map[string]interface{})
+		for k, v := range resolvedHeaders {
 			if val, ok := v.(string); ok {
-				req.Header.Set(k, val)
+				call.headers[k] = val
+			}
+		}
+	}
+	if contentType != "" {
+		hasContentType := false
+		for k := range call.headers {
+			if http.CanonicalHeaderKey(k) == "Content-Type" {
+				hasContentType = true
+				if strings.HasPrefix(contentType, "multipart/") {
+					// The boundary is generated: a configured multipart type can't carry it
+					delete(call.headers, k)
+					hasContentType = false
+				}
 			}
 		}
+		if !hasContentType {
+			call.headers["Content-Type"] = contentType
+		}
 	}
 
-	// 5. Execute Request
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+	// 5. Load the auth profile
+	if profileID := authProfileID(input.Config); profileID != "" {
+		call.profile, call.secret, err = httpauth.Load(input.OrgID, profileID)
+		if errors.Is(err, httpauth.ErrNotFound) {
+			return configError(fmt.Sprintf("Auth profile %s does not exist", profileID)), nil
+		}
+		if err != nil {
+			return &NodeResult{Status: StatusFailed, Error: err.Error(), ErrorType: ErrorTypeAuth}, nil
+		}
 	}
-	resp, err := client.Do(req)
+
+	// 6. Check the destination against the org's egress policy. The client re-checks
+	// every address it connects to and every redirect
+	policy, err := egress.Load(input.OrgID)
 	if err != nil {
-		return &NodeResult{
-			Status: "FAILED",
-			Output: map[string]interface{}{
-				"error": err.Error(),
-			},
-			Error: err.Error(),
-		}, nil
+		return &NodeResult{Status: StatusFailed, Error: err.Error()}, nil
+	}
+	policy = policy.WithOrigin(map[string]interface{}{
+		"source":      "http_node",
+		"flow_id":     input.FlowID,
+		"workflow_id": input.WorkflowID,
+		"node_id":     input.StepID,
+	})
+	target, err := neturl.Parse(url)
+	if err != nil {
+		return configError(fmt.Sprintf("Invalid URL: %v", err)), nil
+	}
+	if err := policy.CheckURL(ctx, target); err != nil {
+		return egressFailure(err), nil
+	}
+	call.client = policy.Client(call.timeout)
+
+	// 7. Execute Request(s)
+	if pagination != nil {
+		return call.paginate(ctx, url, pagination), nil
+	}
+	page, failed := call.fetch(ctx, url)
+	if failed != nil {
+		return failed, nil
+	}
+	return call.result(page), nil
+}
+
+// configure reads the timeout, size, response type and rate limit settings.
+func (c *httpCall) configure(config map[string]interface{}) *NodeResult {
+	c.timeout = defaultHTTPTimeout
+	if s, ok := toNumber(config["timeoutSeconds"]); ok && s > 0 {
+		c.timeout = clampHTTPDuration(time.Duration(s*float64(time.Second)), maxHTTPTimeout)
+	}
+
+	c.maxBytes = defaultMaxResponseBytes
+	if n, ok := toNumber(config["maxResponseBytes"]); ok && n > 0 {
+		c.maxBytes = int64(math.Min(n, maxResponseBytesLimit))
+	}
+	c.remaining = c.maxBytes
+
+	c.responseType = ResponseTypeAuto
+	if t, _ := config["responseType"].(string); t != "" {
+		switch t = strings.ToLower(t); t {
+		case ResponseTypeAuto, ResponseTypeJSON, ResponseTypeText, ResponseTypeBinary:
+			c.responseType = t
+		default:
+			return configError(fmt.Sprintf("Unknown response type %q (use auto, json, text or binary)", t))
+		}
+	}
+
+	c.maxRetries = defaultRateLimitRetries
+	c.maxWait = defaultRateLimitMaxWait
+	if rateLimit, ok := config["rateLimit"].(map[string]interface{}); ok {
+		if n, ok := toNumber(rateLimit["maxRetries"]); ok && n >= 0 {
+			c.maxRetries = int(math.Min(n, maxRateLimitRetries))
+		}
+		if s, ok := toNumber(rateLimit["maxWaitSeconds"]); ok && s >= 0 {
+			c.maxWait = clampHTTPDuration(time.Duration(s*float64(time.Second)), maxHTTPTimeout)
+		}
+	}
+	return nil
+}
+
+// egressFailure is the result of a call the egress policy refused.
+func egressFailure(err error) *NodeResult {
+	return &NodeResult{
+		Status: StatusFailed,
+		Output: map[string]interface{}{
+			"error": err.Error(),
+		},
+		Error:     err.Error(),
+		ErrorType: ErrorTypeEgressBlocked,
 	}
-	defer resp.Body.Close()
+}
 
-	// 6. Read Response
-	respBody, err := io.ReadAll(resp.Body)
+// result turns a response into the node result. Server errors (5xx) fail so Temporal
+// retries via the activity retry policy, except after a POST or PATCH, which the server
+// may have applied; other statuses are for the flow to handle.
+func (c *httpCall) result(page *httpPage) *NodeResult {
+	responseData, err := c.decode(page)
 	if err != nil {
 		return &NodeResult{
-			Status: "FAILED",
+			Status: StatusFailed,
 			Output: map[string]interface{}{
-				"error": fmt.Sprintf("failed to read body: %v", err),
+				"status":  page.status,
+				"headers": page.header,
+				"error":   err.Error(),
 			},
-			Error: fmt.Sprintf("failed to read body: %v", err),
-		}, nil
-	}
-
-	// 7. Parse Response (try JSON, fallback to string)
-	var responseData interface{}
-	if err := json.Unmarshal(respBody, &responseData); err != nil {
-		responseData = string(respBody)
+			Error: err.Error(),
+		}
 	}
 
-	// 8. Fail on server errors (5xx) so Temporal retries via activity retry policy
-	if resp.StatusCode >= 500 {
+	if page.status >= 500 {
 		return &NodeResult{
 			Status: StatusFailed,
 			Output: map[string]interface{}{
-				"status":  resp.StatusCode,
-				"headers": resp.Header,
+				"status":  page.status,
+				"headers": page.header,
 				"data":    responseData,
-				"error":   fmt.Sprintf("server error: HTTP %d", resp.StatusCode),
+				"error":   fmt.Sprintf("server error: HTTP %d", page.status),
 			},
-			Error: fmt.Sprintf("server error: HTTP %d", resp.StatusCode),
-		}, nil
+			Error:       fmt.Sprintf("server error: HTTP %d", page.status),
+			ErrorType:   ErrorTypeHTTPServerError,
+			SideEffects: !idempotentMethod(c.method),
+		}
 	}
 
 	return &NodeResult{
-		Status: "SUCCESS",
+		Status: StatusSuccess,
 		Output: map[string]interface{}{
-			"status":  resp.StatusCode,
-			"headers": resp.Header,
+			"status":  page.status,
+			"headers": page.header,
 			"data":    responseData,
 		},
-	}, nil
+	}
+}
+
+// idempotentMethod tells whether repeating a request has no more effect than sending
+// it once (RFC 9110, section 9.2.2).
+func idempotentMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func clampHTTPDuration(d, max time.Duration) time.Duration {
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// authProfileID reads the auth profile of an HTTP node config.
+func authProfileID(config map[string]interface{}) string {
+	for _, key := range []string{"authProfileId", "auth_profile_id"} {
+		if id, ok := config[key].(string); ok && id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// CheckHTTPConfig reports configuration problems of an HTTP node, for the flow
+// validator: unknown body or response types, invalid pagination, and settings that
+// let the call outlast the timeout of the node's policy.
+func CheckHTTPConfig(config map[string]interface{}) []string {
+	var problems []string
+	if t, _ := config["bodyType"].(string); t != "" && !strings.Contains(t, "{{") {
+		switch strings.ToLower(t) {
+		case BodyTypeJSON, BodyTypeForm, BodyTypeMultipart, BodyTypeRaw:
+		default:
+			problems = append(problems, fmt.Sprintf("unknown body type %q (use json, form, multipart or raw)", t))
+		}
+	}
+	if t, _ := config["responseType"].(string); t != "" && !strings.Contains(t, "{{") {
+		switch strings.ToLower(t) {
+		case ResponseTypeAuto, ResponseTypeJSON, ResponseTypeText, ResponseTypeBinary:
+		default:
+			problems = append(problems, fmt.Sprintf("unknown response type %q (use auto, json, text or binary)", t))
+		}
+	}
+	if _, err := parsePagination(config["pagination"]); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if policy, ok := config["policy"].(map[string]interface{}); ok {
+		if s, ok := policy["timeoutSeconds"].(float64); ok && s > 0 {
+			limit := time.Duration(s * float64(time.Second))
+			if budget := HTTPTimeBudget(config); budget > limit {
+				problems = append(problems, fmt.Sprintf("timeoutSeconds, rateLimit and maxPages let the call run for up to %s, longer than the policy timeout of %s", budget, limit))
+			}
+		}
+	}
+	return problems
+}
+
+// HTTPTimeBudget is the longest an HTTP node can run with its settings: every page may
+// use up its timeout on each attempt and wait out each 429 in between. Settings given
+// as expressions count as their defaults.
+func HTTPTimeBudget(config map[string]interface{}) time.Duration {
+	c := &httpCall{}
+	c.configure(config)
+	pages := 1
+	if p, err := parsePagination(config["pagination"]); err == nil && p != nil {
+		pages = p.MaxPages
+	}
+	perPage := time.Duration(c.maxRetries+1)*c.timeout + time.Duration(c.maxRetries)*c.maxWait
+	return time.Duration(pages) * perPage
 }
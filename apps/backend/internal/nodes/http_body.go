@@ -0,0 +1,223 @@
+package nodes
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/artifacts"
+)
+
+// HTTP node body types
+const (
+	BodyTypeJSON      = "json"      // objects are sent as JSON (default)
+	BodyTypeForm      = "form"      // application/x-www-form-urlencoded
+	BodyTypeMultipart = "multipart" // multipart/form-data, with file fields
+	BodyTypeRaw       = "raw"       // a string sent as is (default for string bodies)
+)
+
+// buildRequestBody encodes a resolved "body" as bodyType. It returns the bytes and the
+// Content-Type they call for ("" leaves the header to the node's headers).
+func buildRequestBody(orgID string, body interface{}, bodyType string) ([]byte, string, error) {
+	if body == nil {
+		return nil, "", nil
+	}
+	if bodyType == "" {
+		bodyType = BodyTypeJSON
+		if _, isString := body.(string); isString {
+			bodyType = BodyTypeRaw
+		}
+	}
+
+	switch strings.ToLower(bodyType) {
+	case BodyTypeRaw:
+		if s, ok := body.(string); ok {
+			return []byte(s), "", nil
+		}
+		data, err := json.Marshal(body)
+		return data, "", err
+	case BodyTypeJSON:
+		if s, ok := body.(string); ok {
+			return []byte(s), "application/json", nil
+		}
+		data, err := json.Marshal(body)
+		return data, "application/json", err
+	case BodyTypeForm:
+		if s, ok := body.(string); ok {
+			return []byte(s), "application/x-www-form-urlencoded", nil
+		}
+		fields, ok := body.(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("a form body must be an object, got %s", describe(body))
+		}
+		values := url.Values{}
+		for name, v := range fields {
+			for _, s := range fieldValues(v) {
+				values.Add(name, s)
+			}
+		}
+		return []byte(values.Encode()), "application/x-www-form-urlencoded", nil
+	case BodyTypeMultipart:
+		fields, ok := body.(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("a multipart body must be an object, got %s", describe(body))
+		}
+		return buildMultipart(orgID, fields)
+	}
+	return nil, "", fmt.Errorf("unknown body type %q (use json, form, multipart or raw)", bodyType)
+}
+
+// buildMultipart writes fields as multipart/form-data. A field is a file when its value
+// is an artifact reference (e.g. "{{ steps.download.data }}") or an object with
+// "content" (plus optional "filename", "contentType" and "encoding": "base64").
+func buildMultipart(orgID string, fields map[string]interface{}) ([]byte, string, error) {
+	// Sorted so that the same config always produces the same body
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, name := range names {
+		v := fields[name]
+		if file, ok := v.(map[string]interface{}); ok && isFileField(file) {
+			filename, contentType, data, err := loadFileField(orgID, file)
+			if err != nil {
+				return nil, "", fmt.Errorf("field %q: %w", name, err)
+			}
+			header := make(textproto.MIMEHeader)
+			header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(name), escapeQuotes(filename)))
+			header.Set("Content-Type", contentType)
+			part, err := w.CreatePart(header)
+			if err != nil {
+				return nil, "", err
+			}
+			if _, err := part.Write(data); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+		for _, s := range fieldValues(v) {
+			if err := w.WriteField(name, s); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+func isFileField(m map[string]interface{}) bool {
+	if _, ok := artifacts.ReferenceID(m); ok {
+		return true
+	}
+	_, hasContent := m["content"]
+	return hasContent
+}
+
+// loadFileField reads the content of a multipart file field.
+func loadFileField(orgID string, m map[string]interface{}) (string, string, []byte, error) {
+	filename, _ := m["filename"].(string)
+	contentType, _ := m["contentType"].(string)
+	if contentType == "" {
+		contentType, _ = m["content_type"].(string)
+	}
+
+	var data []byte
+	if id, ok := artifacts.ReferenceID(m); ok {
+		artifact, content, err := artifacts.Get(orgID, id)
+		if err != nil {
+			return "", "", nil, err
+		}
+		data = content
+		if filename == "" {
+			filename = artifact.Filename
+		}
+		if contentType == "" {
+			contentType = artifact.ContentType
+		}
+	} else {
+		switch content := m["content"].(type) {
+		case string:
+			data = []byte(content)
+			if encoding, _ := m["encoding"].(string); strings.EqualFold(encoding, "base64") {
+				decoded, err := base64.StdEncoding.DecodeString(content)
+				if err != nil {
+					return "", "", nil, fmt.Errorf("invalid base64 content")
+				}
+				data = decoded
+			}
+		case nil:
+			return "", "", nil, fmt.Errorf("content is empty")
+		default:
+			encoded, err := json.Marshal(content)
+			if err != nil {
+				return "", "", nil, err
+			}
+			data = encoded
+			if contentType == "" {
+				contentType = "application/json"
+			}
+		}
+	}
+
+	if filename == "" {
+		filename = "file"
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return filename, contentType, data, nil
+}
+
+// fieldValues renders a form or query value: lists repeat the field, null omits it.
+func fieldValues(v interface{}) []string {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		var out []string
+		for _, item := range val {
+			out = append(out, fieldValues(item)...)
+		}
+		return out
+	}
+	return []string{stringify(v)}
+}
+
+// withQuery adds the "query" map of an HTTP node to rawURL, next to the parameters the
+// URL already has. Parameters named in query replace those of the URL.
+func withQuery(rawURL string, query map[string]interface{}) (string, error) {
+	if len(query) == 0 {
+		return rawURL, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	values := u.Query()
+	for name, v := range query {
+		values.Del(name)
+		for _, s := range fieldValues(v) {
+			values.Add(name, s)
+		}
+	}
+	u.RawQuery = values.Encode()
+	return u.String(), nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -0,0 +1,235 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Pagination types of the HTTP node
+const (
+	PaginationLink   = "link"   // follow the rel="next" URL of the Link header
+	PaginationCursor = "cursor" // send the cursor found in each page as a query parameter
+	PaginationPage   = "page"   // count pages in a query parameter until one comes back short
+)
+
+const (
+	defaultMaxPages = 10
+	maxPagesLimit   = 1000
+)
+
+// httpPagination is the "pagination" block of an HTTP node:
+//
+//	"pagination": {
+//	  "type": "link" | "cursor" | "page",
+//	  "itemsPath": "data.items",        // where the items are in a page (default: the page is a list)
+//	  "maxPages": 10,
+//	  "cursorPath": "meta.next_cursor", // cursor: where the next cursor is in a page
+//	  "cursorParam": "cursor",          // cursor: query parameter it is sent as
+//	  "pageParam": "page",              // page: query parameter of the page number
+//	  "startPage": 1,
+//	  "pageSizeParam": "per_page",      // page: optional page size parameter...
+//	  "pageSize": 100                   // ...and value; a page with fewer items is the last
+//	}
+type httpPagination struct {
+	Type          string
+	ItemsPath     string
+	MaxPages      int
+	CursorPath    string
+	CursorParam   string
+	PageParam     string
+	StartPage     int
+	PageSizeParam string
+	PageSize      int
+}
+
+// parsePagination reads the pagination block; nil when the node isn't paginated.
+func parsePagination(raw interface{}) (*httpPagination, error) {
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	str := func(key, def string) string {
+		if s, ok := m[key].(string); ok && strings.TrimSpace(s) != "" {
+			return strings.TrimSpace(s)
+		}
+		return def
+	}
+	num := func(key string, def int) int {
+		if f, ok := toNumber(m[key]); ok {
+			return int(f)
+		}
+		return def
+	}
+
+	p := &httpPagination{
+		Type:          strings.ToLower(str("type", "")),
+		ItemsPath:     str("itemsPath", ""),
+		MaxPages:      num("maxPages", defaultMaxPages),
+		CursorPath:    str("cursorPath", ""),
+		CursorParam:   str("cursorParam", "cursor"),
+		PageParam:     str("pageParam", "page"),
+		StartPage:     num("startPage", 1),
+		PageSizeParam: str("pageSizeParam", ""),
+		PageSize:      num("pageSize", 0),
+	}
+	switch p.Type {
+	case "", "none":
+		return nil, nil
+	case PaginationLink, PaginationPage:
+	case PaginationCursor:
+		if p.CursorPath == "" {
+			return nil, fmt.Errorf("cursor pagination needs a cursorPath")
+		}
+	default:
+		return nil, fmt.Errorf("unknown pagination type %q (use link, cursor or page)", p.Type)
+	}
+	if p.MaxPages < 1 {
+		p.MaxPages = 1
+	}
+	if p.MaxPages > maxPagesLimit {
+		p.MaxPages = maxPagesLimit
+	}
+	return p, nil
+}
+
+// paginate fetches pages until the API has no more or maxPages is reached, and returns
+// the items of every page as one list. Any page that isn't a 2xx ends the call with
+// that page's result, as an unpaginated request would.
+func (c *httpCall) paginate(ctx context.Context, firstURL string, p *httpPagination) *NodeResult {
+	engine := NewExpressionEngine()
+	items := []interface{}{}
+	pageNumber := p.StartPage
+	next := firstURL
+	if p.Type == PaginationPage {
+		params := map[string]interface{}{p.PageParam: pageNumber}
+		if p.PageSizeParam != "" && p.PageSize > 0 {
+			params[p.PageSizeParam] = p.PageSize
+		}
+		var err error
+		if next, err = withQuery(firstURL, params); err != nil {
+			return configError(err.Error())
+		}
+	}
+
+	var page *httpPage
+	pages := 0
+	more := false
+	seenCursors := make(map[string]bool)
+	for {
+		var failed *NodeResult
+		page, failed = c.fetch(ctx, next)
+		if failed != nil {
+			return failed
+		}
+		if page.status < 200 || page.status >= 300 {
+			result := c.result(page)
+			result.Output["pages"] = pages
+			return result
+		}
+		pages++
+
+		var data interface{}
+		if err := json.Unmarshal(page.body, &data); err != nil {
+			return &NodeResult{Status: StatusFailed, Error: fmt.Sprintf("page %d is not JSON: %v", pages, err)}
+		}
+		pageItems, err := pageItemsAt(engine, data, p.ItemsPath)
+		if err != nil {
+			return &NodeResult{Status: StatusFailed, Error: fmt.Sprintf("page %d: %v", pages, err)}
+		}
+		items = append(items, pageItems...)
+
+		next = ""
+		switch p.Type {
+		case PaginationLink:
+			next = nextLink(page.header.Values("Link"), page.url)
+		case PaginationCursor:
+			cursor, _ := engine.Traverse(data, strings.Split(p.CursorPath, "."))
+			if s := stringify(cursor); cursor != nil && cursor != false && s != "" && !seenCursors[s] {
+				seenCursors[s] = true
+				next, err = withQuery(firstURL, map[string]interface{}{p.CursorParam: s})
+			}
+		case PaginationPage:
+			if len(pageItems) > 0 && (p.PageSize <= 0 || len(pageItems) >= p.PageSize) {
+				pageNumber++
+				next, err = withQuery(page.url, map[string]interface{}{p.PageParam: pageNumber})
+			}
+		}
+		if err != nil {
+			return configError(err.Error())
+		}
+		if next == "" {
+			break
+		}
+		if pages >= p.MaxPages {
+			more = true
+			break
+		}
+	}
+
+	return &NodeResult{
+		Status: StatusSuccess,
+		Output: map[string]interface{}{
+			"status":    page.status,
+			"headers":   page.header,
+			"data":      items,
+			"pages":     pages,
+			"truncated": more,
+		},
+	}
+}
+
+// pageItemsAt returns the list of items at itemsPath in a page.
+func pageItemsAt(engine *ExpressionEngine, data interface{}, itemsPath string) ([]interface{}, error) {
+	at := data
+	if itemsPath != "" {
+		var err error
+		if at, err = engine.Traverse(data, strings.Split(itemsPath, ".")); err != nil {
+			return nil, fmt.Errorf("itemsPath %q: %v", itemsPath, err)
+		}
+	}
+	switch list := at.(type) {
+	case []interface{}:
+		return list, nil
+	case nil:
+		return nil, nil
+	}
+	if itemsPath == "" {
+		return nil, fmt.Errorf("the page is %s, not a list; set itemsPath to where its items are", describe(at))
+	}
+	return nil, fmt.Errorf("itemsPath %q is %s, not a list", itemsPath, describe(at))
+}
+
+var linkPattern = regexp.MustCompile(`<([^>]*)>\s*((?:;\s*[^;,]+)*)`)
+
+var relPattern = regexp.MustCompile(`(?i);\s*rel\s*=\s*"?([^";]+)"?`)
+
+// nextLink finds the rel="next" URL of Link headers (RFC 8288), resolved against base.
+func nextLink(headers []string, base string) string {
+	for _, header := range headers {
+		for _, m := range linkPattern.FindAllStringSubmatch(header, -1) {
+			rel := relPattern.FindStringSubmatch(m[2])
+			if rel == nil {
+				continue
+			}
+			for _, r := range strings.Fields(rel[1]) {
+				if !strings.EqualFold(r, "next") {
+					continue
+				}
+				baseURL, err := url.Parse(base)
+				if err != nil {
+					return m[1]
+				}
+				ref, err := url.Parse(m[1])
+				if err != nil {
+					return ""
+				}
+				return baseURL.ResolveReference(ref).String()
+			}
+		}
+	}
+	return ""
+}
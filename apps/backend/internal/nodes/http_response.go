@@ -0,0 +1,324 @@
+package nodes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/artifacts"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/egress"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/httpauth"
+)
+
+// HTTP node response types
+const (
+	ResponseTypeAuto   = "auto"   // JSON when it parses, text when it is text, an artifact otherwise (default)
+	ResponseTypeJSON   = "json"   // must be JSON
+	ResponseTypeText   = "text"   // always a string
+	ResponseTypeBinary = "binary" // always stored as an artifact
+)
+
+// Bounds of the HTTP node settings
+const (
+	defaultHTTPTimeout      = 10 * time.Second
+	maxHTTPTimeout          = 5 * time.Minute
+	defaultMaxResponseBytes = 10 << 20
+	maxResponseBytesLimit   = 100 << 20
+	defaultRateLimitRetries = 3
+	defaultRateLimitMaxWait = 30 * time.Second
+	maxRateLimitRetries     = 10
+)
+
+// httpCall holds what every request of one HTTP node execution shares: the same
+// method, headers, body and credentials, and one response budget across pages.
+type httpCall struct {
+	input       NodeContext
+	client      *http.Client
+	timeout     time.Duration
+	method      string
+	headers     map[string]string
+	body        []byte
+	profile     *httpauth.Profile
+	secret      string
+	credentials *[]string
+
+	responseType string
+	maxBytes     int64
+	remaining    int64
+	maxRetries   int
+	maxWait      time.Duration
+}
+
+// httpPage is one response, read in full.
+type httpPage struct {
+	url    string
+	status int
+	header http.Header
+	body   []byte
+}
+
+// fetch requests rawURL and reads the response. A 429 is retried after the time given
+// by Retry-After (or an exponential backoff without one) as long as the wait stays within
+// maxWait and the activity's deadline; after that Temporal's retry policy takes over.
+func (c *httpCall) fetch(ctx context.Context, rawURL string) (*httpPage, *NodeResult) {
+	for waits := 0; ; waits++ {
+		resp, failed := c.roundTrip(ctx, rawURL)
+		if failed != nil {
+			return nil, failed
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			resp.Body.Close()
+			wait, hinted := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+			if !hinted {
+				wait = time.Duration(math.Pow(2, float64(waits))) * time.Second
+				if wait > c.maxWait {
+					wait = c.maxWait
+				}
+			}
+			deadline, hasDeadline := ctx.Deadline()
+			if waits >= c.maxRetries || wait > c.maxWait || (hasDeadline && time.Until(deadline) < wait) {
+				message := fmt.Sprintf("rate limited: HTTP 429, retry after %s", wait.Round(time.Second))
+				return nil, &NodeResult{
+					Status: StatusFailed,
+					Output: map[string]interface{}{
+						"status":              resp.StatusCode,
+						"headers":             resp.Header,
+						"retry_after_seconds": math.Ceil(wait.Seconds()),
+						"error":               message,
+					},
+					Error:     message,
+					ErrorType: ErrorTypeHTTPRateLimited,
+				}
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, httpRequestFailure(ctx.Err())
+			case <-timer.C:
+			}
+			continue
+		}
+
+		body, failed := c.read(resp)
+		resp.Body.Close()
+		if failed != nil {
+			return nil, failed
+		}
+		return &httpPage{url: rawURL, status: resp.StatusCode, header: resp.Header, body: body}, nil
+	}
+}
+
+// roundTrip sends one request. An OAuth2 token the API rejects is renewed once.
+func (c *httpCall) roundTrip(ctx context.Context, rawURL string) (*http.Response, *NodeResult) {
+	for attempt := 0; ; attempt++ {
+		var bodyReader io.Reader
+		if c.body != nil {
+			bodyReader = bytes.NewReader(c.body)
+		}
+		req, err := http.NewRequestWithContext(ctx, c.method, rawURL, bodyReader)
+		if err != nil {
+			return nil, configError(fmt.Sprintf("failed to create request: %v", err))
+		}
+		for k, v := range c.headers {
+			req.Header.Set(k, v)
+		}
+		if c.profile != nil {
+			used, err := httpauth.Apply(ctx, req, c.profile, c.secret)
+			*c.credentials = append(*c.credentials, used...)
+			if egress.IsBlocked(err) {
+				return nil, egressFailure(fmt.Errorf("Auth profile %q: %w", c.profile.Name, err))
+			}
+			if err != nil {
+				return nil, &NodeResult{
+					Status: StatusFailed,
+					Output: map[string]interface{}{
+						"error": err.Error(),
+					},
+					Error:     fmt.Sprintf("Auth profile %q: %v", c.profile.Name, err),
+					ErrorType: ErrorTypeAuth,
+				}
+			}
+		}
+
+		resp, err := c.client.Do(req)
+		if egress.IsBlocked(err) {
+			return nil, egressFailure(err)
+		}
+		if err != nil {
+			return nil, httpRequestFailure(err)
+		}
+		if attempt == 0 && c.profile != nil && httpauth.Retryable(c.profile, resp.StatusCode) {
+			resp.Body.Close()
+			httpauth.Invalidate(c.profile)
+			continue
+		}
+		return resp, nil
+	}
+}
+
+// read reads a response body within what is left of maxResponseBytes.
+func (c *httpCall) read(resp *http.Response) ([]byte, *NodeResult) {
+	tooLarge := func() *NodeResult {
+		message := fmt.Sprintf("response exceeds maxResponseBytes (%d bytes)", c.maxBytes)
+		return &NodeResult{
+			Status: StatusFailed,
+			Output: map[string]interface{}{
+				"status":  resp.StatusCode,
+				"headers": resp.Header,
+				"error":   message,
+			},
+			Error:     message,
+			ErrorType: ErrorTypeHTTPTooLarge,
+		}
+	}
+	if resp.ContentLength > c.remaining {
+		return nil, tooLarge()
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.remaining+1))
+	if err != nil {
+		return nil, &NodeResult{
+			Status: StatusFailed,
+			Output: map[string]interface{}{
+				"error": fmt.Sprintf("failed to read body: %v", err),
+			},
+			Error:     fmt.Sprintf("failed to read body: %v", err),
+			ErrorType: ErrorTypeHTTPRequest,
+		}
+	}
+	if int64(len(body)) > c.remaining {
+		return nil, tooLarge()
+	}
+	c.remaining -= int64(len(body))
+	return body, nil
+}
+
+// decode turns a response body into step data according to the response type. Binary
+// content is stored as an artifact and replaced by its reference.
+func (c *httpCall) decode(page *httpPage) (interface{}, error) {
+	if page.status >= 400 {
+		// Error bodies are kept readable whatever the response type
+		var data interface{}
+		if err := json.Unmarshal(page.body, &data); err == nil {
+			return data, nil
+		}
+		return string(page.body), nil
+	}
+	switch c.responseType {
+	case ResponseTypeJSON:
+		var data interface{}
+		if err := json.Unmarshal(page.body, &data); err != nil {
+			return nil, fmt.Errorf("response is not JSON: %v", err)
+		}
+		return data, nil
+	case ResponseTypeText:
+		return string(page.body), nil
+	case ResponseTypeBinary:
+		return c.storeArtifact(page)
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(page.body, &data); err == nil {
+		return data, nil
+	}
+	if len(page.body) > 0 && isBinaryContent(page.header.Get("Content-Type"), page.body) {
+		return c.storeArtifact(page)
+	}
+	return string(page.body), nil
+}
+
+func (c *httpCall) storeArtifact(page *httpPage) (interface{}, error) {
+	contentType := page.header.Get("Content-Type")
+	artifact, err := artifacts.Save(artifacts.Artifact{
+		OrgID:       c.input.OrgID,
+		WorkflowID:  c.input.WorkflowID,
+		RunID:       c.input.RunID,
+		NodeID:      c.input.StepID,
+		Filename:    responseFilename(page, contentType),
+		ContentType: contentType,
+	}, page.body)
+	if err != nil {
+		return nil, err
+	}
+	return artifact.Reference(), nil
+}
+
+// isBinaryContent tells whether a body that isn't JSON should be kept as a file.
+func isBinaryContent(contentType string, body []byte) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "" {
+		return !utf8.Valid(body)
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "json"),
+		strings.HasSuffix(mediaType, "xml"),
+		strings.Contains(mediaType, "javascript"),
+		mediaType == "application/x-www-form-urlencoded":
+		return false
+	}
+	return true
+}
+
+// responseFilename names an artifact after Content-Disposition, else the URL path.
+func responseFilename(page *httpPage, contentType string) string {
+	if _, params, err := mime.ParseMediaType(page.header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return path.Base(params["filename"])
+	}
+	if u, err := url.Parse(page.url); err == nil {
+		if name := path.Base(u.Path); name != "." && name != "/" {
+			return name
+		}
+	}
+	name := "response"
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	return name
+}
+
+// retryAfter reads a Retry-After header: a number of seconds or an HTTP date.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil {
+		if secs < 0 {
+			secs = 0
+		}
+		return time.Duration(secs * float64(time.Second)), true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func httpRequestFailure(err error) *NodeResult {
+	return &NodeResult{
+		Status: StatusFailed,
+		Output: map[string]interface{}{
+			"error": err.Error(),
+		},
+		Error:     err.Error(),
+		ErrorType: ErrorTypeHTTPRequest,
+	}
+}
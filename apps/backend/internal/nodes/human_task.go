@@ -7,6 +7,7 @@ import (
 
 	"github.com/teavana/enigmatic_s/apps/backend/internal/audit"
 	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/events"
 )
 
 type HumanTaskNode struct{}
@@ -162,18 +163,17 @@ func (n *HumanTaskNode) Execute(ctx context.Context, input NodeContext) (*NodeRe
 
 	// Ensure FlowID is present to avoid Foreign Key violations
 	if taskRecord.FlowID == "" {
-		return &NodeResult{
-			Status: StatusFailed,
-			Error:  "flow_id is missing",
-		}, nil
+		return configError("flow_id is missing"), nil
 	}
 
 	var results []map[string]interface{}
 	err = client.DB.From("human_tasks").Insert(taskRecord).Execute(&results)
 	if err != nil {
+		// The insert may have gone through: a retry could assign the task twice
 		return &NodeResult{
-			Status: StatusFailed,
-			Error:  fmt.Sprintf("failed to create human task: %v", err),
+			Status:      StatusFailed,
+			Error:       fmt.Sprintf("failed to create human task: %v", err),
+			SideEffects: true,
 		}, nil
 	}
 
@@ -185,6 +185,16 @@ func (n *HumanTaskNode) Execute(ctx context.Context, input NodeContext) (*NodeRe
 		"task_title": title,
 		"assignee":   assignee,
 	}, "")
+	if input.OrgID != "" {
+		events.Publish(events.Event{
+			Type:       events.TaskCreated,
+			OrgID:      input.OrgID,
+			WorkflowID: input.WorkflowID,
+			RunID:      input.RunID,
+			NodeID:     input.StepID,
+			Data:       map[string]interface{}{"task_id": taskID, "title": title},
+		})
+	}
 
 	// 4. Suspend Workflow
 	return &NodeResult{
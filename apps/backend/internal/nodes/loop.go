@@ -2,30 +2,122 @@ package nodes
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 )
 
+const (
+	// LoopModeSequential runs one iteration at a time, in item order.
+	LoopModeSequential = "sequential"
+	// LoopModeParallel runs up to "concurrency" iterations at the same time.
+	LoopModeParallel = "parallel"
+
+	defaultLoopConcurrency = 5
+	maxLoopConcurrency     = 20
+	defaultLoopMaxItems    = 1000
+)
+
+// LoopNode resolves the list a LOOP iterates over.
+// The iteration itself (running the "item" branch once per element) is driven by
+// the workflow engine, which reads the normalized "items", "mode" and "concurrency" from this output.
 type LoopNode struct{}
 
 func (n *LoopNode) Execute(ctx context.Context, input NodeContext) (*NodeResult, error) {
-	// Stub implementation
-	// In a real loop, this would handle iterator logic, but for now we just pass through.
-	// The graph traversal logic handles the actual looping structure usually,
-	// or this node returns a special status/output to drive the iterator.
+	rawItems, ok := input.Config["items"]
+	if !ok || rawItems == nil || rawItems == "" {
+		return configError("Missing 'items' configuration for Loop"), nil
+	}
+
+	// 1. Resolve items through the Expression Engine (e.g. "{{ steps.api.data.rows }}")
+	resolved := rawItems
+	if expr, ok := rawItems.(string); ok {
+		engine := NewExpressionEngine()
+		val, err := engine.Evaluate(expr, input)
+		if err != nil {
+			return &NodeResult{
+				Status: StatusFailed,
+				Error:  fmt.Sprintf("failed to resolve loop items: %v", err),
+			}, nil
+		}
+		resolved = val
+	}
 
-	items, ok := input.Config["items"]
-	if !ok {
+	items, err := normalizeLoopItems(resolved)
+	if err != nil {
 		return &NodeResult{
 			Status: StatusFailed,
-			Error:  "Missing 'items' configuration for Loop",
+			Error:  err.Error(),
 		}, nil
 	}
 
+	// 2. Safety limit (a typo in the items expression should not fan out into thousands of activities)
+	maxItems := defaultLoopMaxItems
+	if m, ok := input.Config["maxItems"].(float64); ok && m > 0 {
+		maxItems = int(m)
+	}
+	if len(items) > maxItems {
+		return &NodeResult{
+			Status: StatusFailed,
+			Error:  fmt.Sprintf("loop has %d items, which exceeds the limit of %d", len(items), maxItems),
+		}, nil
+	}
+
+	// 3. Execution mode
+	mode := LoopModeSequential
+	if m, ok := input.Config["mode"].(string); ok && strings.EqualFold(m, LoopModeParallel) {
+		mode = LoopModeParallel
+	}
+
+	concurrency := 1
+	if mode == LoopModeParallel {
+		concurrency = defaultLoopConcurrency
+		if c, ok := input.Config["concurrency"].(float64); ok && c > 0 {
+			concurrency = int(c)
+		}
+		if concurrency > maxLoopConcurrency {
+			concurrency = maxLoopConcurrency
+		}
+	}
+
 	return &NodeResult{
 		Status: StatusSuccess,
 		Output: map[string]interface{}{
-			"message": fmt.Sprintf("Looping over %v", items),
-			"items":   items,
+			"message":     fmt.Sprintf("Looping over %d items", len(items)),
+			"items":       items,
+			"count":       len(items),
+			"mode":        mode,
+			"concurrency": concurrency,
 		},
 	}, nil
 }
+
+// normalizeLoopItems converts the resolved "items" value into a slice.
+// JSON array strings are decoded, a single object is wrapped, and nil becomes an empty list.
+func normalizeLoopItems(v interface{}) ([]interface{}, error) {
+	switch val := v.(type) {
+	case []interface{}:
+		return val, nil
+	case []map[string]interface{}:
+		items := make([]interface{}, len(val))
+		for i, m := range val {
+			items[i] = m
+		}
+		return items, nil
+	case map[string]interface{}:
+		return []interface{}{val}, nil
+	case nil:
+		return []interface{}{}, nil
+	case string:
+		trimmed := strings.TrimSpace(val)
+		if strings.HasPrefix(trimmed, "[") {
+			var items []interface{}
+			if err := json.Unmarshal([]byte(trimmed), &items); err == nil {
+				return items, nil
+			}
+		}
+		return nil, fmt.Errorf("loop items must be an array (got string %q)", val)
+	default:
+		return nil, fmt.Errorf("loop items must be an array (got %T)", v)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"strings"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/secrets"
 )
 
 // NodeContext contains all the data available to the node during execution.
@@ -15,13 +17,32 @@ type NodeContext struct {
 	StepID     string
 	InputData  map[string]interface{}
 	Config     map[string]interface{}
+
+	// Secrets holds the org's decrypted secrets for {{ secrets.NAME }}. It is only set
+	// inside the node activity and never serialized, so values stay out of workflow history.
+	Secrets map[string]string `json:"-"`
+}
+
+// Redact replaces the values of the context's secrets in v, for logs and outputs.
+func (c NodeContext) Redact(v interface{}) interface{} {
+	if len(c.Secrets) == 0 {
+		return v
+	}
+	return secrets.NewRedactor(c.Secrets).Value(v)
 }
 
 // NodeResult is the output of a node execution.
 type NodeResult struct {
-	Status string                 // "SUCCESS", "FAILED", "PAUSED"
-	Output map[string]interface{} // The data produced by this node
-	Error  string                 // Serialized error message
+	Status    string                 // "SUCCESS", "FAILED", "PAUSED"
+	Output    map[string]interface{} // The data produced by this node
+	Error     string                 // Serialized error message
+	ErrorType string                 // Error class for retry policies (defaults to ErrorTypeNodeFailed)
+	// SideEffects marks a failure after which the node may still have acted (mail sent,
+	// record created, POST handled). It isn't retried unless the node's policy sets
+	// retry.maxAttempts.
+	SideEffects bool
+
+	ExecutionID string // node_executions row of this attempt (set by the workflow activity)
 }
 
 const (
@@ -30,6 +51,33 @@ const (
 	StatusPaused  = "PAUSED"
 )
 
+// Error classes reported by failed nodes.
+// A node's retry policy can list any of them under "nonRetryableErrors".
+const (
+	ErrorTypeNodeFailed      = "NodeFailed"           // Generic executor failure
+	ErrorTypeConfig          = "ConfigError"          // Unknown node type / invalid config, never retried
+	ErrorTypeHTTPRequest     = "HTTPRequestError"     // Connection failed, DNS, timeout...
+	ErrorTypeHTTPServerError = "HTTPServerError"      // Upstream answered 5xx
+	ErrorTypeHTTPRateLimited = "HTTPRateLimited"      // Upstream still answered 429 after the node's own waits
+	ErrorTypeHTTPTooLarge    = "HTTPResponseTooLarge" // Response exceeded maxResponseBytes, never retried
+	ErrorTypeAuth            = "AuthError"            // Auth profile couldn't be loaded or its token fetched
+	ErrorTypeEgressBlocked   = "EgressBlocked"        // Destination refused by the org's egress policy, never retried
+)
+
+// IsPermanent tells whether a failure of this class would fail the same way on retry.
+func IsPermanent(errorType string) bool {
+	switch errorType {
+	case ErrorTypeConfig, ErrorTypeHTTPTooLarge, ErrorTypeEgressBlocked:
+		return true
+	}
+	return false
+}
+
+// configError is the result of a node whose configuration can never succeed.
+func configError(message string) *NodeResult {
+	return &NodeResult{Status: StatusFailed, Error: message, ErrorType: ErrorTypeConfig}
+}
+
 // NodeExecutor is the interface that all node types must implement.
 type NodeExecutor interface {
 	Execute(ctx context.Context, input NodeContext) (*NodeResult, error)
@@ -38,24 +86,30 @@ type NodeExecutor interface {
 // Registry is the global map of all available node types.
 // It maps the node type string (e.g., "SET") to its corresponding NodeExecutor implementation.
 var Registry = map[string]NodeExecutor{
-	"DEBUG":       &DebugNode{},
-	"SET":         &SetNode{},
-	"VARIABLE":    &SetNode{}, // Alias for SetNode
-	"CONDITION":   &ConditionNode{},
-	"MAP":         &MapNode{},
-	"HTTP":        &HttpNode{},
-	"PARSE":       &ParseNode{},
-	"APPROVAL":    &ApprovalNode{},
-	"LOOP":        &LoopNode{},
-	"SWITCH":      &SwitchNode{},
-	"FILTER":      &FilterNode{},
-	"EMAIL":       &EmailNode{},
-	"TRIGGER":     &TriggerNode{},
-	"API-TRIGGER": &TriggerNode{}, // Support for API Trigger node type
-	"ACTION":      &HttpNode{},    // Alias for generic Action nodes (defaults to HTTP)
-	"HUMAN-TASK":  &HumanTaskNode{},
-	"GOTO":        &GotoNode{},
-	"AUTOMATION":  &AutomationNodeExecutor{},
+	"DEBUG":            &DebugNode{},
+	"SET":              &SetNode{},
+	"VARIABLE":         &SetNode{}, // Alias for SetNode
+	"CONDITION":        &ConditionNode{},
+	"MAP":              &MapNode{},
+	"HTTP":             &HttpNode{},
+	"PARSE":            &ParseNode{},
+	"APPROVAL":         &ApprovalNode{},
+	"LOOP":             &LoopNode{},
+	"SWITCH":           &SwitchNode{},
+	"FILTER":           &FilterNode{},
+	"EMAIL":            &EmailNode{},
+	"TRIGGER":          &TriggerNode{},
+	"API-TRIGGER":      &TriggerNode{}, // Support for API Trigger node type
+	"SCHEDULE-TRIGGER": &TriggerNode{},
+	"WEBHOOK-TRIGGER":  &TriggerNode{},
+	"ACTION":           &HttpNode{}, // Alias for generic Action nodes (defaults to HTTP)
+	"HUMAN-TASK":       &HumanTaskNode{},
+	"GOTO":             &GotoNode{},
+	"AUTOMATION":       &AutomationNodeExecutor{},
+	"SUBFLOW":          &SubflowNode{},
+	"RESPONSE":         &ResponseNode{},
+	"DELAY":            &DelayNode{},
+	"WAIT-UNTIL":       &WaitUntilNode{},
 }
 
 // GetExecutor returns the executor for a given node type.
@@ -0,0 +1,104 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ResponseKey marks the output of a RESPONSE node. The engine keeps the first response
+// reached in a run, and synchronous executions return it to the HTTP caller.
+const ResponseKey = "_response"
+
+// ResponseNode decides what a synchronous execution returns to its caller.
+//
+//	{ "type": "response", "statusCode": 201,
+//	  "headers": { "Location": "/orders/{{ steps.create.data.id }}" },
+//	  "body": { "id": "{{ steps.create.data.id }}", "status": "created" } }
+//
+// Strings anywhere in the body are evaluated as expressions; a body that is a single
+// expression (e.g. "{{ steps.api.data }}") keeps the value's type.
+type ResponseNode struct{}
+
+func (n *ResponseNode) Execute(ctx context.Context, input NodeContext) (*NodeResult, error) {
+	engine := NewExpressionEngine()
+
+	// 1. Status code (a number or an expression)
+	statusCode := http.StatusOK
+	switch raw := input.Config["statusCode"].(type) {
+	case float64:
+		statusCode = int(raw)
+	case string:
+		if raw != "" {
+			val, err := engine.Evaluate(raw, input)
+			if err != nil {
+				return &NodeResult{Status: StatusFailed, Error: fmt.Sprintf("failed to resolve status code: %v", err)}, nil
+			}
+			f, ok := toNumber(val)
+			if !ok {
+				return configError(fmt.Sprintf("Status code %v is not a number", val)), nil
+			}
+			statusCode = int(f)
+		}
+	}
+	if statusCode < 100 || statusCode > 599 {
+		return configError(fmt.Sprintf("Invalid HTTP status code %d", statusCode)), nil
+	}
+
+	// 2. Headers
+	headers := make(map[string]interface{})
+	if rawHeaders, ok := input.Config["headers"].(map[string]interface{}); ok {
+		for k, v := range rawHeaders {
+			val, err := evaluateString(engine, v, input)
+			if err != nil {
+				return &NodeResult{Status: StatusFailed, Error: fmt.Sprintf("failed to resolve header %s: %v", k, err)}, nil
+			}
+			headers[k] = val
+		}
+	}
+
+	// 3. Body
+	body, err := evaluateDeep(engine, input.Config["body"], input)
+	if err != nil {
+		return &NodeResult{Status: StatusFailed, Error: fmt.Sprintf("failed to resolve response body: %v", err)}, nil
+	}
+
+	return &NodeResult{
+		Status: StatusSuccess,
+		Output: map[string]interface{}{
+			"status_code": statusCode,
+			"headers":     headers,
+			"body":        body,
+			ResponseKey:   true,
+		},
+	}, nil
+}
+
+// evaluateDeep evaluates every string inside maps and lists.
+func evaluateDeep(engine *ExpressionEngine, v interface{}, input NodeContext) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		return engine.Evaluate(val, input)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			resolved, err := evaluateDeep(engine, item, input)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = resolved
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			resolved, err := evaluateDeep(engine, item, input)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,129 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+)
+
+const (
+	// SubflowModeWait blocks until the child flow completes and exposes its final state.
+	SubflowModeWait = "wait"
+	// SubflowModeAsync starts the child flow and continues immediately (fire-and-forget).
+	SubflowModeAsync = "async"
+
+	// SubflowCallKey is the output key through which SubflowNode hands the resolved call
+	// to the workflow engine, which starts the child workflow.
+	SubflowCallKey = "_subflow_call"
+)
+
+// SubflowNode prepares a call to another published flow of the same organization.
+// It resolves the target flow and the input mapping; starting the child workflow
+// (and waiting for it) is driven by the workflow engine, like LOOP iterations are.
+//
+//	{ "type": "subflow", "flowId": "...", "mode": "wait",
+//	  "input": { "customer": "{{ steps.trigger.body.customer }}" } }
+type SubflowNode struct{}
+
+func (n *SubflowNode) Execute(ctx context.Context, input NodeContext) (*NodeResult, error) {
+	// 0. Check for mock data (test mode) — skip starting the child flow
+	if mockDataMap, ok := input.InputData["__mock_data"].(map[string]interface{}); ok {
+		if nodeMock, ok := mockDataMap[input.StepID].(map[string]interface{}); ok {
+			if response, ok := nodeMock["response"].(map[string]interface{}); ok {
+				fmt.Printf("[TEST MODE] Sub-flow '%s' auto-completed with mock data\n", input.StepID)
+				return &NodeResult{Status: StatusSuccess, Output: response}, nil
+			}
+		}
+	}
+
+	flowID, _ := input.Config["flowId"].(string)
+	if flowID == "" {
+		flowID, _ = input.Config["flow_id"].(string)
+	}
+	if flowID == "" {
+		return configError("Missing 'flowId' configuration for Sub-flow"), nil
+	}
+
+	mode := SubflowModeWait
+	if m, ok := input.Config["mode"].(string); ok && strings.EqualFold(m, SubflowModeAsync) {
+		mode = SubflowModeAsync
+	}
+
+	// 1. Map inputs through the expression engine
+	childInput := make(map[string]interface{})
+	if mapping, ok := input.Config["input"].(map[string]interface{}); ok {
+		resolved, err := NewExpressionEngine().EvaluateMap(mapping, input)
+		if err != nil {
+			return &NodeResult{
+				Status: StatusFailed,
+				Error:  fmt.Sprintf("failed to resolve sub-flow input: %v", err),
+			}, nil
+		}
+		childInput = resolved
+	}
+
+	// 2. Load the target flow
+	client := database.GetClient()
+	var flows []struct {
+		Name                string          `json:"name"`
+		OrgID               string          `json:"org_id"`
+		PublishedDefinition json.RawMessage `json:"published_definition"`
+		IsActive            bool            `json:"is_active"`
+		CurrentVersionID    *string         `json:"current_version_id"`
+	}
+	err := client.DB.From("flows").Select("name, org_id, published_definition, is_active, current_version_id").Eq("id", flowID).Execute(&flows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sub-flow %s: %w", flowID, err)
+	}
+	if len(flows) == 0 {
+		return configError(fmt.Sprintf("Sub-flow %s not found", flowID)), nil
+	}
+	target := flows[0]
+
+	// 3. Only flows of the caller's organization can be called.
+	// Test runs don't carry an OrgID, so fall back to the org of the calling flow.
+	callerOrgID := input.OrgID
+	if callerOrgID == "" && input.FlowID != "" {
+		var callers []struct {
+			OrgID string `json:"org_id"`
+		}
+		client.DB.From("flows").Select("org_id").Eq("id", input.FlowID).Execute(&callers)
+		if len(callers) > 0 {
+			callerOrgID = callers[0].OrgID
+		}
+	}
+	if callerOrgID == "" || callerOrgID != target.OrgID {
+		return configError(fmt.Sprintf("Sub-flow %s does not belong to this organization", flowID)), nil
+	}
+
+	if len(target.PublishedDefinition) == 0 || string(target.PublishedDefinition) == "null" {
+		return configError(fmt.Sprintf("Sub-flow %q has not been published", target.Name)), nil
+	}
+	if !target.IsActive {
+		return configError(fmt.Sprintf("Sub-flow %q is not active", target.Name)), nil
+	}
+
+	var definition map[string]interface{}
+	if err := json.Unmarshal(target.PublishedDefinition, &definition); err != nil {
+		return configError(fmt.Sprintf("Sub-flow %q has an invalid definition", target.Name)), nil
+	}
+
+	call := map[string]interface{}{
+		"flow_id":    flowID,
+		"flow_name":  target.Name,
+		"mode":       mode,
+		"input":      childInput,
+		"definition": definition,
+	}
+	if target.CurrentVersionID != nil {
+		call["version_id"] = *target.CurrentVersionID
+	}
+
+	return &NodeResult{
+		Status: StatusSuccess,
+		Output: map[string]interface{}{SubflowCallKey: call},
+	}, nil
+}
@@ -2,10 +2,46 @@ package nodes
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/compare"
+)
+
+// Switch modes
+const (
+	SwitchModeFirst = "first" // route to the first matching case (default)
+	SwitchModeAll   = "all"   // route to every matching case
+)
+
+// SwitchDefaultCase is the handle that fires when no case matched.
+const SwitchDefaultCase = "default"
+
+// Common case operators. Any operator of the compare catalog works; case values
+// ("value", "values", "min", "max") may be expressions.
+const (
+	SwitchOpEquals   = "equals"   // equal, numbers compared as numbers (default)
+	SwitchOpContains = "contains" // substring, or element of a list
+	SwitchOpRegex    = "regex"    // Go regular expression on the value as a string
+	SwitchOpRange    = "range"    // min <= value <= max, either bound optional
+	SwitchOpIn       = "in"       // equals any of "values" (a list, or a comma-separated string)
 )
 
+// SwitchNode routes on the value of "variable":
+//
+//	{
+//	  "variable": "{{ steps.order.data.total }}",
+//	  "mode": "first" | "all",
+//	  "cases": [
+//	    { "id": "vip",   "label": "VIP",   "value": "gold" },
+//	    { "id": "big",   "label": "Big",   "operator": "range", "min": 1000 },
+//	    { "id": "eu",    "label": "EU",    "operator": "in", "values": ["FR", "BE", "DE"] },
+//	    { "id": "order", "label": "Order", "operator": "regex", "value": "^ORD-\\d+$" }
+//	  ]
+//	}
+//
+// Cases without a value compare against their label. Text compares case-insensitively
+// unless "caseSensitive" is set on the node or the case; "strict" turns off coercion.
 type SwitchNode struct{}
 
 func (n *SwitchNode) Execute(ctx context.Context, input NodeContext) (*NodeResult, error) {
@@ -17,7 +53,8 @@ func (n *SwitchNode) Execute(ctx context.Context, input NodeContext) (*NodeResul
 		return &NodeResult{
 			Status: StatusSuccess,
 			Output: map[string]interface{}{
-				"selected_case": "default",
+				"selected_case": SwitchDefaultCase,
+				"matched_cases": []interface{}{},
 				"value":         nil,
 			},
 		}, nil
@@ -29,11 +66,20 @@ func (n *SwitchNode) Execute(ctx context.Context, input NodeContext) (*NodeResul
 		evaluated = variable // Fallback to raw string
 	}
 
-	evaluatedStr := fmt.Sprintf("%v", evaluated)
-	fmt.Printf("DEBUG: Switch evaluated variable '%s' → '%s'\n", variable, evaluatedStr)
+	mode, _ := input.Config["mode"].(string)
+	if mode == "" {
+		mode = SwitchModeFirst
+	}
+	if mode != SwitchModeFirst && mode != SwitchModeAll {
+		return configError(fmt.Sprintf("Unknown switch mode %q (use first or all)", mode)), nil
+	}
+
+	opts := compare.Options{IgnoreCase: true}.WithOverrides(input.Config)
 
 	// Check cases for a match
-	selectedCase := "default"
+	selectedCase := SwitchDefaultCase
+	matched := []interface{}{}
+	caseErrors := map[string]interface{}{}
 	casesRaw, _ := input.Config["cases"].([]interface{})
 
 	for _, c := range casesRaw {
@@ -42,31 +88,100 @@ func (n *SwitchNode) Execute(ctx context.Context, input NodeContext) (*NodeResul
 			continue
 		}
 		caseID, _ := caseMap["id"].(string)
-		caseLabel, _ := caseMap["label"].(string)
-		caseValue := caseLabel // Use label as the comparison value
 
-		// Allow explicit value override if present
-		if v, ok := caseMap["value"].(string); ok && v != "" {
-			caseValue = v
+		ok, err := matchSwitchCase(expressionEngine, input, evaluated, caseMap, opts)
+		if errors.Is(err, compare.ErrUnknownOperator) {
+			// The case never matches, as a Condition with that operator is false; the
+			// flow validator reports it
+			caseErrors[caseID] = err.Error()
+			continue
+		}
+		if err != nil {
+			label, _ := caseMap["label"].(string)
+			return configError(fmt.Sprintf("Switch case %q: %v", label, err)), nil
+		}
+		if !ok {
+			continue
 		}
 
-		// Compare: case-insensitive string match
-		if strings.EqualFold(evaluatedStr, caseValue) {
+		if len(matched) == 0 {
 			selectedCase = caseID
-			fmt.Printf("DEBUG: Switch matched case '%s' (id: %s)\n", caseLabel, caseID)
+		}
+		matched = append(matched, caseID)
+		if mode == SwitchModeFirst {
 			break
 		}
 	}
 
-	if selectedCase == "default" {
-		fmt.Printf("DEBUG: Switch fell through to default\n")
-	}
+	fmt.Printf("DEBUG: Switch evaluated '%s' → %v, matched %v\n", variable, input.Redact(evaluated), matched)
 
+	output := map[string]interface{}{
+		"selected_case": selectedCase,
+		"matched_cases": matched,
+		"value":         evaluated,
+	}
+	if len(caseErrors) > 0 {
+		output["case_errors"] = caseErrors
+	}
 	return &NodeResult{
 		Status: StatusSuccess,
-		Output: map[string]interface{}{
-			"selected_case": selectedCase,
-			"value":         evaluated,
-		},
+		Output: output,
 	}, nil
 }
+
+// matchSwitchCase tests value against one case.
+func matchSwitchCase(engine *ExpressionEngine, input NodeContext, value interface{}, caseMap map[string]interface{}, opts compare.Options) (bool, error) {
+	resolve := func(key string) (interface{}, error) {
+		raw := caseMap[key]
+		if s, ok := raw.(string); ok {
+			return engine.Evaluate(s, input)
+		}
+		return raw, nil
+	}
+
+	rawOp, _ := caseMap["operator"].(string)
+	if rawOp == "" {
+		rawOp = SwitchOpEquals
+	}
+	operator, ok := compare.Lookup(rawOp)
+	if !ok {
+		return false, fmt.Errorf("%w %q", compare.ErrUnknownOperator, rawOp)
+	}
+
+	caseValue, err := resolve("value")
+	if err != nil {
+		return false, err
+	}
+	if caseValue == nil || caseValue == "" {
+		caseValue = caseMap["label"] // Use label as the comparison value
+	}
+
+	right := caseValue
+	switch operator.Name {
+	case "between":
+		min, err := resolve("min")
+		if err != nil {
+			return false, err
+		}
+		max, err := resolve("max")
+		if err != nil {
+			return false, err
+		}
+		right = []interface{}{min, max}
+	case "in", "not_in":
+		values, err := resolve("values")
+		if err != nil {
+			return false, err
+		}
+		if values != nil && values != "" {
+			right = values
+		}
+	}
+
+	matched, err := operator.Test(value, right, opts.WithOverrides(caseMap))
+	if compare.IsConfigError(err) {
+		return false, err
+	}
+	// Otherwise the value can't be compared with this case (e.g. text against a range)
+	return matched, nil
+}
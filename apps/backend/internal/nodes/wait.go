@@ -0,0 +1,191 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimerKey is the output key through which DELAY and WAIT-UNTIL nodes hand the resolved
+// wait to the workflow engine, which sleeps on a durable timer (it survives worker restarts).
+// It holds either "duration_ms" (DELAY) or "until" (WAIT-UNTIL, RFC 3339).
+const TimerKey = "_timer"
+
+// maxWait bounds how far ahead a DELAY or WAIT-UNTIL node may wait.
+const maxWait = 366 * 24 * time.Hour
+
+// DelayNode waits for a fixed or computed duration.
+//
+//	{ "type": "delay", "duration": 2, "unit": "days" }
+//	{ "type": "delay", "duration": "{{ steps.policy.data.grace_period }}" }
+//
+// String durations use Go syntax plus days ("36h", "90m", "2d", "1d12h");
+// plain numbers are counted in "unit" (seconds, minutes, hours, days or weeks; default seconds).
+type DelayNode struct{}
+
+func (n *DelayNode) Execute(ctx context.Context, input NodeContext) (*NodeResult, error) {
+	if result := mockWaitResult(input); result != nil {
+		return result, nil
+	}
+
+	raw := input.Config["duration"]
+	if raw == nil || raw == "" {
+		return configError("Missing 'duration' configuration for Delay"), nil
+	}
+	if s, ok := raw.(string); ok {
+		val, err := NewExpressionEngine().Evaluate(s, input)
+		if err != nil {
+			return &NodeResult{Status: StatusFailed, Error: fmt.Sprintf("failed to resolve delay duration: %v", err)}, nil
+		}
+		raw = val
+	}
+	unit, _ := input.Config["unit"].(string)
+
+	d, err := parseWaitDuration(raw, unit)
+	if err != nil {
+		return configError(err.Error()), nil
+	}
+
+	return &NodeResult{
+		Status: StatusSuccess,
+		Output: map[string]interface{}{
+			TimerKey: map[string]interface{}{"duration_ms": d.Milliseconds()},
+		},
+	}, nil
+}
+
+// WaitUntilNode waits until a computed point in time. Times without a UTC offset are read
+// in "timezone" (an IANA name such as "Europe/Paris"; default UTC). Times already past
+// don't wait at all.
+//
+//	{ "type": "wait-until", "until": "{{ steps.order.data.delivery_date }}", "timezone": "America/New_York" }
+type WaitUntilNode struct{}
+
+func (n *WaitUntilNode) Execute(ctx context.Context, input NodeContext) (*NodeResult, error) {
+	if result := mockWaitResult(input); result != nil {
+		return result, nil
+	}
+
+	engine := NewExpressionEngine()
+	raw := input.Config["until"]
+	if raw == nil || raw == "" {
+		return configError("Missing 'until' configuration for Wait Until"), nil
+	}
+	if s, ok := raw.(string); ok {
+		val, err := engine.Evaluate(s, input)
+		if err != nil {
+			return &NodeResult{Status: StatusFailed, Error: fmt.Sprintf("failed to resolve wait time: %v", err)}, nil
+		}
+		raw = val
+	}
+
+	loc := time.UTC
+	if tz, _ := input.Config["timezone"].(string); tz != "" {
+		resolved, err := evaluateString(engine, tz, input)
+		if err != nil {
+			return &NodeResult{Status: StatusFailed, Error: fmt.Sprintf("failed to resolve timezone: %v", err)}, nil
+		}
+		loc, err = time.LoadLocation(resolved)
+		if err != nil {
+			return configError(fmt.Sprintf("Unknown timezone %q", resolved)), nil
+		}
+	}
+
+	until, err := parseWaitUntil(raw, loc)
+	if err != nil {
+		return configError(err.Error()), nil
+	}
+	if time.Until(until) > maxWait {
+		return configError(fmt.Sprintf("wait time %s is more than %d days ahead", until.Format(time.RFC3339), int(maxWait.Hours()/24))), nil
+	}
+
+	return &NodeResult{
+		Status: StatusSuccess,
+		Output: map[string]interface{}{
+			TimerKey: map[string]interface{}{"until": until.UTC().Format(time.RFC3339Nano)},
+		},
+	}, nil
+}
+
+// mockWaitResult returns the mock response of a wait node in test mode, if any.
+func mockWaitResult(input NodeContext) *NodeResult {
+	if mockDataMap, ok := input.InputData["__mock_data"].(map[string]interface{}); ok {
+		if nodeMock, ok := mockDataMap[input.StepID].(map[string]interface{}); ok {
+			if response, ok := nodeMock["response"].(map[string]interface{}); ok {
+				return &NodeResult{Status: StatusSuccess, Output: response}
+			}
+		}
+	}
+	return nil
+}
+
+var waitUnits = map[string]time.Duration{
+	"":        time.Second,
+	"ms":      time.Millisecond,
+	"seconds": time.Second, "second": time.Second, "s": time.Second,
+	"minutes": time.Minute, "minute": time.Minute, "m": time.Minute,
+	"hours": time.Hour, "hour": time.Hour, "h": time.Hour,
+	"days": 24 * time.Hour, "day": 24 * time.Hour, "d": 24 * time.Hour,
+	"weeks": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "w": 7 * 24 * time.Hour,
+}
+
+// daysPrefix matches the day part Go durations lack ("2d", "1.5d12h").
+var daysPrefix = regexp.MustCompile(`^(\d+(?:\.\d+)?)d`)
+
+// parseWaitDuration reads a DELAY duration: a number of units, or a duration string.
+func parseWaitDuration(v interface{}, unit string) (time.Duration, error) {
+	scale, ok := waitUnits[strings.ToLower(strings.TrimSpace(unit))]
+	if !ok {
+		return 0, fmt.Errorf("unknown delay unit %q", unit)
+	}
+
+	var d time.Duration
+	switch val := v.(type) {
+	case float64:
+		d = time.Duration(val * float64(scale))
+	case string:
+		s := strings.TrimSpace(val)
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			d = time.Duration(f * float64(scale))
+			break
+		}
+		if m := daysPrefix.FindStringSubmatch(s); m != nil {
+			days, _ := strconv.ParseFloat(m[1], 64)
+			d = time.Duration(days * float64(24*time.Hour))
+			s = s[len(m[0]):]
+		}
+		if s != "" {
+			rest, err := time.ParseDuration(s)
+			if err != nil {
+				return 0, fmt.Errorf("invalid delay duration %q (use e.g. 90m, 36h or 2d)", val)
+			}
+			d += rest
+		}
+	default:
+		return 0, fmt.Errorf("delay duration must be a number or a duration string, got %s", describe(v))
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf("delay duration cannot be negative")
+	}
+	if d > maxWait {
+		return 0, fmt.Errorf("delay duration is more than %d days", int(maxWait.Hours()/24))
+	}
+	return d, nil
+}
+
+// parseWaitUntil reads a WAIT-UNTIL time. Layouts without an offset are read in loc.
+func parseWaitUntil(v interface{}, loc *time.Location) (time.Time, error) {
+	if s, ok := v.(string); ok {
+		s = strings.TrimSpace(s)
+		for _, layout := range dateInputLayouts {
+			if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return toTime(v)
+}
@@ -0,0 +1,82 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MasterKeyEnv holds the server master key: 32 bytes, base64-encoded
+// (e.g. the output of `openssl rand -base64 32`).
+const MasterKeyEnv = "SECRETS_MASTER_KEY"
+
+// Ciphertexts are "v1:" followed by base64(nonce || AES-256-GCM sealed value).
+const ciphertextPrefix = "v1:"
+
+// ErrNoMasterKey means the server can't encrypt or decrypt secrets.
+var ErrNoMasterKey = errors.New("secrets are not configured on this server (" + MasterKeyEnv + " is not set)")
+
+func masterKey() ([]byte, error) {
+	raw := strings.TrimSpace(os.Getenv(MasterKeyEnv))
+	if raw == "" {
+		return nil, ErrNoMasterKey
+	}
+	key, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s must be 32 bytes, base64-encoded", MasterKeyEnv)
+	}
+	return key, nil
+}
+
+func newGCM() (cipher.AEAD, error) {
+	key, err := masterKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt seals a secret value with the master key. additionalData binds the ciphertext
+// to its owner (org and name), so it can't be swapped onto another secret.
+func Encrypt(plaintext, additionalData string) (string, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), []byte(additionalData))
+	return ciphertextPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value sealed by Encrypt with the same additionalData.
+func Decrypt(ciphertext, additionalData string) (string, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(ciphertext, ciphertextPrefix) {
+		return "", fmt.Errorf("unsupported ciphertext format")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ciphertext, ciphertextPrefix))
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("malformed ciphertext")
+	}
+	nonce, body := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, body, []byte(additionalData))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret (wrong master key, or the value was tampered with)")
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,97 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testMasterKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=" // "0123456789abcdef0123456789abcdef"
+
+func TestEncryptRoundTrip(t *testing.T) {
+	t.Setenv(MasterKeyEnv, testMasterKey)
+
+	for _, value := range []string{"sk_live_123", "", `quote " backslash \ ünïcode`} {
+		sealed, err := Seal("org-1", "API_KEY", value)
+		if err != nil {
+			t.Fatalf("Seal(%q): %v", value, err)
+		}
+		if !strings.HasPrefix(sealed, "v1:") || (value != "" && strings.Contains(sealed, value)) {
+			t.Errorf("ciphertext = %q", sealed)
+		}
+		got, err := Decrypt(sealed, "org-1/API_KEY")
+		if err != nil || got != value {
+			t.Errorf("Decrypt = %q, %v, want %q", got, err, value)
+		}
+	}
+
+	// A fresh nonce every time: the same value never seals to the same ciphertext
+	a, _ := Encrypt("same", "ad")
+	b, _ := Encrypt("same", "ad")
+	if a == b {
+		t.Error("two encryptions of the same value are identical")
+	}
+}
+
+func TestDecryptRejects(t *testing.T) {
+	t.Setenv(MasterKeyEnv, testMasterKey)
+	sealed, err := Seal("org-1", "API_KEY", "sk_live_123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, "v1:"))
+	raw[len(raw)-1] ^= 1
+	tampered := "v1:" + base64.StdEncoding.EncodeToString(raw)
+
+	tests := []struct {
+		name           string
+		ciphertext, ad string
+		want           string
+	}{
+		{"another secret's name", sealed, "org-1/OTHER_KEY", "failed to decrypt"},
+		{"another org", sealed, "org-2/API_KEY", "failed to decrypt"},
+		{"tampered", tampered, "org-1/API_KEY", "failed to decrypt"},
+		{"no version prefix", strings.TrimPrefix(sealed, "v1:"), "org-1/API_KEY", "unsupported ciphertext format"},
+		{"unknown version", "v2:" + strings.TrimPrefix(sealed, "v1:"), "org-1/API_KEY", "unsupported ciphertext format"},
+		{"not base64", "v1:not base64!", "org-1/API_KEY", "malformed ciphertext"},
+		{"shorter than a nonce", "v1:" + base64.StdEncoding.EncodeToString([]byte("short")), "org-1/API_KEY", "malformed ciphertext"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decrypt(tt.ciphertext, tt.ad)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Decrypt = %q, %v, want an error containing %q", got, err, tt.want)
+			}
+		})
+	}
+
+	// Another master key can't open it either
+	t.Setenv(MasterKeyEnv, base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210")))
+	if _, err := Decrypt(sealed, "org-1/API_KEY"); err == nil || !strings.Contains(err.Error(), "failed to decrypt") {
+		t.Errorf("Decrypt with another master key = %v", err)
+	}
+}
+
+func TestMasterKey(t *testing.T) {
+	t.Setenv(MasterKeyEnv, "")
+	if _, err := Encrypt("value", "ad"); !errors.Is(err, ErrNoMasterKey) {
+		t.Errorf("Encrypt without a master key = %v, want ErrNoMasterKey", err)
+	}
+	if _, err := Decrypt("v1:AAAA", "ad"); !errors.Is(err, ErrNoMasterKey) {
+		t.Errorf("Decrypt without a master key = %v, want ErrNoMasterKey", err)
+	}
+
+	for _, key := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte("too short"))} {
+		t.Setenv(MasterKeyEnv, key)
+		if _, err := Encrypt("value", "ad"); err == nil || errors.Is(err, ErrNoMasterKey) || !strings.Contains(err.Error(), "32 bytes") {
+			t.Errorf("Encrypt with master key %q = %v, want an invalid key error", key, err)
+		}
+	}
+
+	// Surrounding whitespace (e.g. a trailing newline in an env file) is ignored
+	t.Setenv(MasterKeyEnv, " "+testMasterKey+"\n")
+	if _, err := Encrypt("value", "ad"); err != nil {
+		t.Errorf("Encrypt with a padded master key: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package secrets
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Placeholder replaces secret values in redacted output.
+const Placeholder = "[REDACTED]"
+
+// minRedactLength keeps very short values (e.g. "1", "yes") from wiping out unrelated text.
+const minRedactLength = 4
+
+// Redactor replaces known secret values wherever they appear in text.
+type Redactor struct {
+	replacer *strings.Replacer
+}
+
+// NewRedactor builds a Redactor for the given secrets (typically the result of Load).
+func NewRedactor(values map[string]string) *Redactor {
+	list := make([]string, 0, len(values))
+	for _, v := range values {
+		list = append(list, v)
+	}
+	return NewValueRedactor(list)
+}
+
+// NewValueRedactor builds a Redactor for sensitive values that aren't named secrets,
+// such as credentials applied by an auth profile.
+func NewValueRedactor(values []string) *Redactor {
+	var olds []string
+	for _, v := range values {
+		if len(v) >= minRedactLength {
+			olds = append(olds, v)
+		}
+	}
+	if len(olds) == 0 {
+		return &Redactor{}
+	}
+	// Longest first, so a secret that contains another is replaced whole
+	sort.Slice(olds, func(i, j int) bool { return len(olds[i]) > len(olds[j]) })
+	pairs := make([]string, 0, len(olds)*2)
+	for _, old := range olds {
+		pairs = append(pairs, old, Placeholder)
+	}
+	return &Redactor{replacer: strings.NewReplacer(pairs...)}
+}
+
+// String redacts s.
+func (r *Redactor) String(s string) string {
+	if r == nil || r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// Value redacts every string inside v (maps and lists included, keys too) and returns
+// the redacted copy. Other values are returned as-is.
+func (r *Redactor) Value(v interface{}) interface{} {
+	if r == nil || r.replacer == nil {
+		return v
+	}
+	switch val := v.(type) {
+	case string:
+		return r.String(val)
+	case map[string]interface{}:
+		return r.Map(val)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = r.Value(item)
+		}
+		return out
+	case map[string][]string:
+		// e.g. HTTP response headers
+		out := make(map[string][]string, len(val))
+		for k, items := range val {
+			redacted := make([]string, len(items))
+			for i, item := range items {
+				redacted[i] = r.String(item)
+			}
+			out[k] = redacted
+		}
+		return out
+	case []string:
+		out := make([]string, len(val))
+		for i, item := range val {
+			out[i] = r.String(item)
+		}
+		return out
+	case nil, bool, float64, int, int64:
+		return v
+	}
+	// Anything else (http.Header, structs...) goes through JSON; it's left untouched
+	// unless it holds a secret
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	if redacted := r.String(string(raw)); redacted != string(raw) {
+		var out interface{}
+		if json.Unmarshal([]byte(redacted), &out) == nil {
+			return out
+		}
+		return Placeholder
+	}
+	return v
+}
+
+// Map redacts a node output.
+func (r *Redactor) Map(m map[string]interface{}) map[string]interface{} {
+	if r == nil || r.replacer == nil || m == nil {
+		return m
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[r.String(k)] = r.Value(v)
+	}
+	return out
+}
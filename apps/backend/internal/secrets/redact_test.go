@@ -0,0 +1,77 @@
+package secrets
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestRedactorString(t *testing.T) {
+	r := NewRedactor(map[string]string{"TOKEN": "abcd1234", "LONG": "abcd1234-extended", "PIN": "123"})
+
+	tests := []struct{ in, want string }{
+		{"Bearer abcd1234", "Bearer [REDACTED]"},
+		// The longer secret is replaced whole, not as the shorter one plus a tail
+		{"key=abcd1234-extended", "key=[REDACTED]"},
+		// Values under minRedactLength are left alone
+		{"pin 123, order 1234", "pin 123, order 1234"},
+		{"no secrets here", "no secrets here"},
+	}
+	for _, tt := range tests {
+		if got := r.String(tt.in); got != tt.want {
+			t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	// Without secrets (or without a redactor) everything passes through
+	for _, r := range []*Redactor{nil, NewRedactor(nil), NewValueRedactor([]string{"", "ab"})} {
+		if got := r.String("abcd1234"); got != "abcd1234" {
+			t.Errorf("empty redactor changed the text: %q", got)
+		}
+		m := map[string]interface{}{"k": "abcd1234"}
+		if got := r.Map(m); !reflect.DeepEqual(got, m) {
+			t.Errorf("empty redactor changed a map: %v", got)
+		}
+	}
+}
+
+func TestRedactorValue(t *testing.T) {
+	r := NewValueRedactor([]string{"s3cr3t"})
+
+	output := map[string]interface{}{
+		"body": map[string]interface{}{
+			"token":     "s3cr3t",
+			"s3cr3t":    "used as a key",
+			"items":     []interface{}{"a s3cr3t b", 1.0, true, nil},
+			"untouched": 42.0,
+		},
+		"headers": map[string][]string{"Authorization": {"Bearer s3cr3t"}},
+		"lines":   []string{"s3cr3t", "other"},
+	}
+	want := map[string]interface{}{
+		"body": map[string]interface{}{
+			"token":      "[REDACTED]",
+			"[REDACTED]": "used as a key",
+			"items":      []interface{}{"a [REDACTED] b", 1.0, true, nil},
+			"untouched":  42.0,
+		},
+		"headers": map[string][]string{"Authorization": {"Bearer [REDACTED]"}},
+		"lines":   []string{"[REDACTED]", "other"},
+	}
+	if got := r.Map(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("Map = %v, want %v", got, want)
+	}
+	if output["body"].(map[string]interface{})["token"] != "s3cr3t" {
+		t.Error("Map changed its input")
+	}
+
+	// Other types go through JSON, and are only replaced when they hold a secret
+	header := http.Header{"X-Api-Key": {"s3cr3t"}}
+	if got := r.Value(header); !reflect.DeepEqual(got, map[string]interface{}{"X-Api-Key": []interface{}{"[REDACTED]"}}) {
+		t.Errorf("Value(http.Header) = %#v", got)
+	}
+	type row struct{ Name string }
+	if got := r.Value(row{Name: "plain"}); got != (row{Name: "plain"}) {
+		t.Errorf("Value(struct without secrets) = %#v, want it as-is", got)
+	}
+}
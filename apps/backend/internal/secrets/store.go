@@ -0,0 +1,57 @@
+// Package secrets stores organization secrets (API tokens, passwords) encrypted at rest
+// and resolves them for node executions. Flow definitions only hold references such as
+// {{ secrets.STRIPE_KEY }}; values are decrypted inside activities and redacted from
+// whatever the run produces.
+package secrets
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+)
+
+// Secret is a row of the org_secrets table. The value is only ever held encrypted.
+type Secret struct {
+	ID          string  `json:"id,omitempty"`
+	OrgID       string  `json:"org_id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Ciphertext  string  `json:"ciphertext"`
+	CreatedBy   *string `json:"created_by,omitempty"`
+	CreatedAt   string  `json:"created_at,omitempty"`
+	UpdatedAt   string  `json:"updated_at,omitempty"`
+}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// ValidateName checks that a secret can be referenced as {{ secrets.NAME }}.
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("secret names use letters, digits and underscores, start with a letter or underscore, and are at most 64 characters")
+	}
+	return nil
+}
+
+// Seal encrypts a value for the secret name of orgID.
+func Seal(orgID, name, value string) (string, error) {
+	return Encrypt(value, orgID+"/"+name)
+}
+
+// Load decrypts every secret of an organization, by name.
+func Load(orgID string) (map[string]string, error) {
+	var rows []Secret
+	err := database.GetClient().DB.From("org_secrets").Select("name,ciphertext").Eq("org_id", orgID).Execute(&rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load secrets: %w", err)
+	}
+	values := make(map[string]string, len(rows))
+	for _, row := range rows {
+		value, err := Decrypt(row.Ciphertext, orgID+"/"+row.Name)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: %w", row.Name, err)
+		}
+		values[row.Name] = value
+	}
+	return values, nil
+}
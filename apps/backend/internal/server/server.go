@@ -116,13 +116,39 @@ func (s *Server) RegisterRoutes() http.Handler {
 	actionFlowHandler := handlers.NewActionFlowHandler(s.temporalClient)
 	mux.Handle("GET /api/action-flows", middleware.Auth(http.HandlerFunc(actionFlowHandler.ListActionFlows)))
 	mux.Handle("GET /api/action-flows/{id}", middleware.Auth(http.HandlerFunc(actionFlowHandler.GetActionFlow)))
+	mux.Handle("GET /api/action-flows/{id}/steps", middleware.Auth(http.HandlerFunc(actionFlowHandler.ListSteps)))
+	mux.Handle("GET /api/action-flows/{id}/events", middleware.Auth(http.HandlerFunc(actionFlowHandler.StreamEvents)))
 	mux.Handle("PATCH /api/action-flows/{id}", middleware.Auth(http.HandlerFunc(actionFlowHandler.UpdateActionFlow))) // Added
 	mux.Handle("DELETE /api/action-flows/{id}", middleware.Auth(http.HandlerFunc(actionFlowHandler.DeleteActionFlow)))
+	mux.Handle("POST /api/action-flows/{id}/cancel", middleware.Auth(http.HandlerFunc(actionFlowHandler.CancelActionFlow)))
+	mux.Handle("POST /api/action-flows/{id}/terminate", middleware.Auth(http.HandlerFunc(actionFlowHandler.TerminateActionFlow)))
+	mux.Handle("POST /api/action-flows/{id}/pause", middleware.Auth(http.HandlerFunc(actionFlowHandler.PauseActionFlow)))
+	mux.Handle("POST /api/action-flows/{id}/resume", middleware.Auth(http.HandlerFunc(actionFlowHandler.ResumeActionFlow)))
+	mux.Handle("POST /api/action-flows/{id}/retry", middleware.Auth(http.HandlerFunc(actionFlowHandler.RetryActionFlow)))
+	mux.Handle("POST /api/action-flows/{id}/nodes/{nodeId}/skip-wait", middleware.Auth(http.HandlerFunc(actionFlowHandler.SkipWait)))
+
+	// Live run events (Server-Sent Events)
+	eventsHandler := handlers.NewEventsHandler()
+	mux.Handle("GET /api/events", middleware.Auth(http.HandlerFunc(eventsHandler.StreamOrgEvents)))
 
 	// Execution Routes (requires per-org API key via X-API-Key header)
 	if s.temporalClient != nil {
 		executeHandler := handlers.NewExecuteFlowHandler(s.temporalClient)
 		mux.Handle("POST /api/flows/{id}/execute", middleware.ApiKeyAuth(http.HandlerFunc(executeHandler.ExecuteFlow)))
+
+		runsHandler := handlers.NewRunsHandler(s.temporalClient)
+		mux.Handle("GET /api/runs/{workflow_id}", middleware.ApiKeyAuth(http.HandlerFunc(runsHandler.GetRun)))
+
+		// Schedule triggers (registered on publish)
+		scheduleHandler := handlers.NewScheduleHandler(s.temporalClient)
+		mux.Handle("GET /api/flows/{id}/schedules", middleware.Auth(http.HandlerFunc(scheduleHandler.ListSchedules)))
+		mux.Handle("POST /api/flows/{id}/schedules/{nodeId}/pause", middleware.Auth(http.HandlerFunc(scheduleHandler.PauseSchedule)))
+		mux.Handle("POST /api/flows/{id}/schedules/{nodeId}/resume", middleware.Auth(http.HandlerFunc(scheduleHandler.ResumeSchedule)))
+
+		// Webhook triggers (URLs assigned on publish; the public endpoint authenticates by token and signature)
+		webhookTriggerHandler := handlers.NewWebhookTriggerHandler(s.temporalClient)
+		mux.Handle("GET /api/flows/{id}/webhooks", middleware.Auth(http.HandlerFunc(webhookTriggerHandler.ListFlowWebhooks)))
+		mux.Handle("POST /api/webhooks/flows/{token}", http.HandlerFunc(webhookTriggerHandler.TriggerFlow))
 	}
 
 	// Public routes (no auth)
@@ -131,7 +157,7 @@ func (s *Server) RegisterRoutes() http.Handler {
 	// Automation Routes
 	if s.temporalClient != nil {
 		automationHandler := handlers.NewAutomationHandler(s.temporalClient)
-		// Public: webhook endpoint (token IS authentication)
+		// Public: webhook endpoint (token IS authentication, plus the node's signature if it sets one)
 		mux.Handle("POST /api/webhooks/{token}", http.HandlerFunc(automationHandler.WebhookHandler))
 		// Internal: require auth for signal/resume (these use internal IDs)
 		mux.Handle("POST /api/automation/resume", middleware.Auth(http.HandlerFunc(automationHandler.ResumeAutomationHandler)))
@@ -159,6 +185,13 @@ func (s *Server) RegisterRoutes() http.Handler {
 
 	// Publish Route
 	mux.Handle("POST /api/flows/{id}/publish", middleware.Auth(http.HandlerFunc(flowHandler.PublishFlow)))
+	mux.Handle("POST /api/flows/{id}/validate", middleware.Auth(http.HandlerFunc(flowHandler.ValidateFlow)))
+	mux.Handle("GET /api/operators", middleware.Auth(http.HandlerFunc(handlers.ListOperatorsHandler)))
+
+	// Version History Routes
+	mux.Handle("GET /api/flows/{id}/versions", middleware.Auth(http.HandlerFunc(flowHandler.ListVersions)))
+	mux.Handle("GET /api/flows/{id}/versions/{version}", middleware.Auth(http.HandlerFunc(flowHandler.GetVersion)))
+	mux.Handle("POST /api/flows/{id}/versions/{version}/rollback", middleware.Auth(http.HandlerFunc(flowHandler.RollbackFlow)))
 
 	// Organization Routes
 	orgHandler := handlers.NewOrganizationHandler()
@@ -185,6 +218,36 @@ func (s *Server) RegisterRoutes() http.Handler {
 	mux.Handle("GET /api/orgs/{orgId}/api-keys", middleware.Auth(http.HandlerFunc(apiKeyHandler.ListApiKeys)))
 	mux.Handle("DELETE /api/orgs/{orgId}/api-keys/{id}", middleware.Auth(http.HandlerFunc(apiKeyHandler.DeleteApiKey)))
 
+	// Mail Settings Routes (org admins configure the EMAIL node transport)
+	mailSettingsHandler := handlers.NewMailSettingsHandler()
+	mux.Handle("GET /api/orgs/{orgId}/mail-settings", middleware.Auth(http.HandlerFunc(mailSettingsHandler.GetMailSettings)))
+	mux.Handle("PUT /api/orgs/{orgId}/mail-settings", middleware.Auth(http.HandlerFunc(mailSettingsHandler.UpdateMailSettings)))
+	mux.Handle("POST /api/orgs/{orgId}/mail-settings/test", middleware.Auth(http.HandlerFunc(mailSettingsHandler.SendTestEmail)))
+
+	// Secrets Routes (org admins manage encrypted values referenced as {{ secrets.NAME }})
+	secretsHandler := handlers.NewSecretsHandler()
+	mux.Handle("GET /api/orgs/{orgId}/secrets", middleware.Auth(http.HandlerFunc(secretsHandler.ListSecrets)))
+	mux.Handle("POST /api/orgs/{orgId}/secrets", middleware.Auth(http.HandlerFunc(secretsHandler.CreateSecret)))
+	mux.Handle("PUT /api/orgs/{orgId}/secrets/{name}", middleware.Auth(http.HandlerFunc(secretsHandler.UpdateSecret)))
+	mux.Handle("DELETE /api/orgs/{orgId}/secrets/{name}", middleware.Auth(http.HandlerFunc(secretsHandler.DeleteSecret)))
+
+	// HTTP Auth Profile Routes (credentials the HTTP node applies by profile id)
+	authProfilesHandler := handlers.NewAuthProfilesHandler()
+	mux.Handle("GET /api/orgs/{orgId}/auth-profiles", middleware.Auth(http.HandlerFunc(authProfilesHandler.ListAuthProfiles)))
+	mux.Handle("POST /api/orgs/{orgId}/auth-profiles", middleware.Auth(http.HandlerFunc(authProfilesHandler.CreateAuthProfile)))
+	mux.Handle("PUT /api/orgs/{orgId}/auth-profiles/{id}", middleware.Auth(http.HandlerFunc(authProfilesHandler.UpdateAuthProfile)))
+	mux.Handle("DELETE /api/orgs/{orgId}/auth-profiles/{id}", middleware.Auth(http.HandlerFunc(authProfilesHandler.DeleteAuthProfile)))
+	mux.Handle("POST /api/orgs/{orgId}/auth-profiles/{id}/test", middleware.Auth(http.HandlerFunc(authProfilesHandler.TestAuthProfile)))
+
+	// Egress Policy Routes (org admins restrict which destinations flows may call)
+	egressPolicyHandler := handlers.NewEgressPolicyHandler()
+	mux.Handle("GET /api/orgs/{orgId}/egress-policy", middleware.Auth(http.HandlerFunc(egressPolicyHandler.GetEgressPolicy)))
+	mux.Handle("PUT /api/orgs/{orgId}/egress-policy", middleware.Auth(http.HandlerFunc(egressPolicyHandler.UpdateEgressPolicy)))
+
+	// Artifact Routes (files produced by runs, e.g. binary HTTP responses)
+	artifactsHandler := handlers.NewArtifactsHandler()
+	mux.Handle("GET /api/orgs/{orgId}/artifacts/{id}", middleware.Auth(http.HandlerFunc(artifactsHandler.DownloadArtifact)))
+
 	// Activity Feed Routes
 	activityHandler := handlers.NewActivityHandler()
 	mux.Handle("GET /api/activity-feed", middleware.Auth(http.HandlerFunc(activityHandler.GetActivityFeed)))
@@ -2,11 +2,13 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 
 	"github.com/nedpals/supabase-go"
 	"github.com/sirupsen/logrus"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/secrets"
 )
 
 // ToolContext carries org-scoping information through tool execution
@@ -16,6 +18,8 @@ type ToolContext struct {
 	IsSuperAdmin bool // true if orgSlug == "enigmatic-i2v2i"
 	Client       *supabase.Client
 	Logger       *logrus.Logger
+
+	redactor *secrets.Redactor // the org's secret values, set by ExecuteTool
 }
 
 var uuidRegex = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
@@ -204,7 +208,26 @@ func ExecuteTool(ctx ToolContext, toolCall ToolCall) (string, error) {
 		"is_super": ctx.IsSuperAdmin,
 	}).Info("Executing tool call")
 
-	switch toolCall.Function.Name {
+	// Run outputs and audit details can echo secret values: the model never sees them.
+	// Without the values to redact, no result goes out (no master key means no secrets)
+	values, err := secrets.Load(ctx.OrgID)
+	if err != nil && !errors.Is(err, secrets.ErrNoMasterKey) {
+		ctx.Logger.WithError(err).Warn("Could not load secrets to redact tool result")
+		return "", errors.New("tool result withheld: secrets could not be loaded to redact it")
+	}
+	ctx.redactor = secrets.NewRedactor(values)
+
+	result, err := dispatchTool(ctx, toolCall.Function.Name, args)
+	if err != nil {
+		return "", errors.New(ctx.redactor.String(err.Error()))
+	}
+	// Error messages and other hand-built results are plain strings
+	return ctx.redactor.String(result), nil
+}
+
+// dispatchTool runs the executor for a tool name.
+func dispatchTool(ctx ToolContext, name string, args map[string]interface{}) (string, error) {
+	switch name {
 	case "list_flows":
 		return executeListFlows(ctx, args)
 	case "get_flow_details":
@@ -222,7 +245,7 @@ func ExecuteTool(ctx ToolContext, toolCall ToolCall) (string, error) {
 	case "list_comments":
 		return executeListComments(ctx, args)
 	default:
-		return fmt.Sprintf(`{"error": "unknown tool: %s"}`, toolCall.Function.Name), nil
+		return fmt.Sprintf(`{"error": "unknown tool: %s"}`, name), nil
 	}
 }
 
@@ -262,7 +285,7 @@ func executeListFlows(ctx ToolContext, args map[string]interface{}) (string, err
 		return jsonError(err), nil
 	}
 
-	return marshalResult(results)
+	return marshalResult(ctx, results)
 }
 
 func executeGetFlowDetails(ctx ToolContext, args map[string]interface{}) (string, error) {
@@ -296,7 +319,7 @@ func executeGetFlowDetails(ctx ToolContext, args map[string]interface{}) (string
 		delete(flow, "definition")
 	}
 
-	return marshalResult(flow)
+	return marshalResult(ctx, flow)
 }
 
 func executeListActionFlows(ctx ToolContext, args map[string]interface{}) (string, error) {
@@ -325,7 +348,7 @@ func executeListActionFlows(ctx ToolContext, args map[string]interface{}) (strin
 		return jsonError(err), nil
 	}
 
-	return marshalResult(results)
+	return marshalResult(ctx, results)
 }
 
 func executeGetActionFlowDetails(ctx ToolContext, args map[string]interface{}) (string, error) {
@@ -367,7 +390,7 @@ func executeGetActionFlowDetails(ctx ToolContext, args map[string]interface{}) (
 		result["actions"] = actions
 	}
 
-	return marshalResult(result)
+	return marshalResult(ctx, result)
 }
 
 func executeListHumanTasks(ctx ToolContext, args map[string]interface{}) (string, error) {
@@ -405,7 +428,7 @@ func executeListHumanTasks(ctx ToolContext, args map[string]interface{}) (string
 		if err := filterQuery.Execute(&results); err != nil {
 			return jsonError(err), nil
 		}
-		return marshalResult(results)
+		return marshalResult(ctx, results)
 	}
 
 	// Superadmin: no org filter
@@ -419,14 +442,14 @@ func executeListHumanTasks(ctx ToolContext, args map[string]interface{}) (string
 		if err := filterQuery.Execute(&results); err != nil {
 			return jsonError(err), nil
 		}
-		return marshalResult(results)
+		return marshalResult(ctx, results)
 	}
 
 	var results []map[string]interface{}
 	if err := selectQuery.Execute(&results); err != nil {
 		return jsonError(err), nil
 	}
-	return marshalResult(results)
+	return marshalResult(ctx, results)
 }
 
 func executeQueryAuditLogs(ctx ToolContext, args map[string]interface{}) (string, error) {
@@ -446,7 +469,7 @@ func executeQueryAuditLogs(ctx ToolContext, args map[string]interface{}) (string
 		if err := filterQuery.Execute(&results); err != nil {
 			return jsonError(err), nil
 		}
-		return marshalResult(results)
+		return marshalResult(ctx, results)
 	}
 
 	// Superadmin
@@ -456,14 +479,14 @@ func executeQueryAuditLogs(ctx ToolContext, args map[string]interface{}) (string
 		if err := filterQuery.Execute(&results); err != nil {
 			return jsonError(err), nil
 		}
-		return marshalResult(results)
+		return marshalResult(ctx, results)
 	}
 
 	var results []map[string]interface{}
 	if err := selectQuery.Execute(&results); err != nil {
 		return jsonError(err), nil
 	}
-	return marshalResult(results)
+	return marshalResult(ctx, results)
 }
 
 func executeListTeams(ctx ToolContext, args map[string]interface{}) (string, error) {
@@ -479,7 +502,7 @@ func executeListTeams(ctx ToolContext, args map[string]interface{}) (string, err
 		if err := filterQuery.Execute(&results); err != nil {
 			return jsonError(err), nil
 		}
-		return marshalResult(results)
+		return marshalResult(ctx, results)
 	}
 
 	var results []map[string]interface{}
@@ -487,7 +510,7 @@ func executeListTeams(ctx ToolContext, args map[string]interface{}) (string, err
 		return jsonError(err), nil
 	}
 
-	return marshalResult(results)
+	return marshalResult(ctx, results)
 }
 
 func executeListComments(ctx ToolContext, args map[string]interface{}) (string, error) {
@@ -521,7 +544,7 @@ func executeListComments(ctx ToolContext, args map[string]interface{}) (string,
 		return jsonError(err), nil
 	}
 
-	return marshalResult(results)
+	return marshalResult(ctx, results)
 }
 
 // ---- Helpers ----
@@ -561,11 +584,21 @@ func jsonError(err error) string {
 	return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 }
 
-func marshalResult(data interface{}) (string, error) {
+// marshalResult encodes a tool result with the org's secrets redacted. Redaction works
+// on the decoded values: once encoded, a secret holding ", \ or < no longer matches.
+func marshalResult(ctx ToolContext, data interface{}) (string, error) {
 	b, err := json.Marshal(data)
 	if err != nil {
 		return jsonError(err), nil
 	}
+	var decoded interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return jsonError(err), nil
+	}
+	b, err = json.Marshal(ctx.redactor.Value(decoded))
+	if err != nil {
+		return jsonError(err), nil
+	}
 	return truncateResult(string(b)), nil
 }
 
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/secrets"
+)
+
+// A secret that JSON escapes: once encoded, it no longer appears as-is
+const testSecret = `pa"ss<wd>\1`
+
+func TestMarshalResultRedactsSecrets(t *testing.T) {
+	ctx := ToolContext{}
+	ctx.redactor = secrets.NewRedactor(map[string]string{"DB_PASSWORD": testSecret})
+
+	data := map[string]interface{}{
+		"runs": []map[string]interface{}{
+			{"id": "run-1", "output": map[string]interface{}{"password": testSecret, "count": 3}},
+		},
+		"login " + testSecret: "used as a key",
+	}
+	got, err := marshalResult(ctx, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "pa\\\"ss") || strings.Contains(got, "\\u003cwd") {
+		t.Fatalf("result holds the secret: %s", got)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+		t.Fatalf("result isn't JSON: %v", err)
+	}
+	want := map[string]interface{}{
+		"runs": []interface{}{
+			map[string]interface{}{"id": "run-1", "output": map[string]interface{}{"password": "[REDACTED]", "count": 3.0}},
+		},
+		"login [REDACTED]": "used as a key",
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("result = %v, want %v", decoded, want)
+	}
+
+	// Without secrets the result is the plain encoding
+	got, _ = marshalResult(ToolContext{}, map[string]interface{}{"password": testSecret})
+	if b, _ := json.Marshal(map[string]interface{}{"password": testSecret}); got != string(b) {
+		t.Errorf("result without secrets = %s", got)
+	}
+}
+
+// secretsTable stands in for the org_secrets table behind PostgREST.
+type secretsTable struct {
+	mu   sync.Mutex
+	rows []secrets.Secret
+	down bool
+}
+
+func (s *secretsTable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.URL.Path != "/rest/v1/org_secrets" || s.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"message":"unavailable"}`))
+		return
+	}
+	orgID := strings.TrimPrefix(r.URL.Query().Get("org_id"), "eq.")
+	rows := []secrets.Secret{}
+	for _, row := range s.rows {
+		if row.OrgID == orgID {
+			rows = append(rows, row)
+		}
+	}
+	json.NewEncoder(w).Encode(rows)
+}
+
+func TestExecuteToolRedactsSecrets(t *testing.T) {
+	t.Setenv(secrets.MasterKeyEnv, "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	sealed, err := secrets.Seal("org-1", "DB_PASSWORD", testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := &secretsTable{rows: []secrets.Secret{{OrgID: "org-1", Name: "DB_PASSWORD", Ciphertext: sealed}}}
+	server := httptest.NewServer(table)
+	defer server.Close()
+	database.Init(server.URL, "test-key")
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	// Hand-built results (here, the unknown tool error) echo the tool name as-is
+	call := ToolCall{Function: FunctionCall{Name: "tool " + testSecret, Arguments: "{}"}}
+
+	result, err := ExecuteTool(ToolContext{OrgID: "org-1", Logger: logger}, call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(result, testSecret) || !strings.Contains(result, "tool [REDACTED]") {
+		t.Errorf("result = %s, want the secret redacted", result)
+	}
+
+	// Another org's secrets are not this org's: nothing to redact
+	result, err = ExecuteTool(ToolContext{OrgID: "org-2", Logger: logger}, call)
+	if err != nil || !strings.Contains(result, testSecret) {
+		t.Errorf("result for another org = %s, %v", result, err)
+	}
+
+	// Without the values to redact, no result goes out
+	table.mu.Lock()
+	table.down = true
+	table.mu.Unlock()
+	result, err = ExecuteTool(ToolContext{OrgID: "org-1", Logger: logger}, call)
+	if err == nil || result != "" || !strings.Contains(err.Error(), "withheld") {
+		t.Errorf("result with secrets unavailable = %q, %v, want it withheld", result, err)
+	}
+	table.mu.Lock()
+	table.down = false
+	table.mu.Unlock()
+
+	// Nor when a stored secret can't be decrypted
+	t.Setenv(secrets.MasterKeyEnv, "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=")
+	if result, err = ExecuteTool(ToolContext{OrgID: "org-1", Logger: logger}, call); err == nil || result != "" {
+		t.Errorf("result with undecryptable secrets = %q, %v, want it withheld", result, err)
+	}
+}
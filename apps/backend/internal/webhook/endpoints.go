@@ -0,0 +1,114 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+)
+
+// ErrNotFound means no webhook-trigger answers to a token.
+var ErrNotFound = errors.New("webhook not found")
+
+// Endpoint is a row of the flow_webhooks table: the public URL of one webhook-trigger
+// node. Its token is created on the first publish and kept by later ones.
+type Endpoint struct {
+	ID        string `json:"id,omitempty"`
+	OrgID     string `json:"org_id"`
+	FlowID    string `json:"flow_id"`
+	NodeID    string `json:"node_id"`
+	Token     string `json:"token"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// URL is where senders post to the endpoint.
+func (e Endpoint) URL() string {
+	return TriggerURL(e.Token)
+}
+
+// TriggerURL builds the public URL of a webhook-trigger token from PUBLIC_URL.
+func TriggerURL(token string) string {
+	return strings.TrimRight(os.Getenv("PUBLIC_URL"), "/") + "/api/webhooks/flows/" + token
+}
+
+// SyncEndpoints gives every webhook-trigger node of a flow an endpoint, and removes the
+// endpoints of nodes that are gone. Existing tokens are kept, so URLs survive republishing.
+func SyncEndpoints(orgID, flowID string, nodeIDs []string) ([]Endpoint, error) {
+	existing, err := ListEndpoints(flowID)
+	if err != nil {
+		return nil, err
+	}
+	byNode := make(map[string]Endpoint, len(existing))
+	for _, e := range existing {
+		byNode[e.NodeID] = e
+	}
+
+	db := database.GetClient().DB
+	keep := make(map[string]bool, len(nodeIDs))
+	endpoints := make([]Endpoint, 0, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		keep[nodeID] = true
+		if e, ok := byNode[nodeID]; ok {
+			endpoints = append(endpoints, e)
+			continue
+		}
+		token, err := newToken()
+		if err != nil {
+			return nil, err
+		}
+		var created []Endpoint
+		row := Endpoint{OrgID: orgID, FlowID: flowID, NodeID: nodeID, Token: token}
+		if err := db.From("flow_webhooks").Insert(row).Execute(&created); err != nil {
+			return nil, fmt.Errorf("failed to create webhook for node %s: %w", nodeID, err)
+		}
+		if len(created) > 0 {
+			row = created[0]
+		}
+		endpoints = append(endpoints, row)
+	}
+
+	for _, e := range existing {
+		if !keep[e.NodeID] {
+			if err := db.From("flow_webhooks").Delete().Eq("id", e.ID).Execute(nil); err != nil {
+				return nil, fmt.Errorf("failed to remove webhook of node %s: %w", e.NodeID, err)
+			}
+		}
+	}
+	return endpoints, nil
+}
+
+// ListEndpoints returns the webhook endpoints of a flow.
+func ListEndpoints(flowID string) ([]Endpoint, error) {
+	var rows []Endpoint
+	err := database.GetClient().DB.From("flow_webhooks").Select("*").Eq("flow_id", flowID).Execute(&rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load webhooks: %w", err)
+	}
+	return rows, nil
+}
+
+// LookupEndpoint finds the endpoint of a token.
+func LookupEndpoint(token string) (*Endpoint, error) {
+	var rows []Endpoint
+	err := database.GetClient().DB.From("flow_webhooks").Select("*").Eq("token", token).Execute(&rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load webhook: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, ErrNotFound
+	}
+	return &rows[0], nil
+}
+
+// newToken returns 192 random bits, URL-safe.
+func newToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
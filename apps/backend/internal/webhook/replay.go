@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	postgrest "github.com/nedpals/postgrest-go/pkg"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+)
+
+// ErrReplayed means the delivery was already accepted once.
+var ErrReplayed = errors.New("webhook delivery was already received")
+
+// uniqueViolation is the Postgres error code of a duplicate key.
+const uniqueViolation = "23505"
+
+// Remember records a verified delivery for scope (a webhook URL). The webhook_deliveries
+// primary key makes the check atomic: of two identical requests, only one gets through.
+// Deliveries that expired in scope are dropped on the way; those without an expiry are
+// kept, since nothing else would stop their replay.
+func Remember(scope string, d *Delivery) error {
+	row := map[string]interface{}{
+		"scope":       scope,
+		"delivery_id": d.key(),
+		"expires_at":  nil,
+	}
+	if !d.Expires.IsZero() {
+		row["expires_at"] = d.Expires.UTC().Format(time.RFC3339)
+	}
+
+	db := database.GetClient().DB
+	db.From("webhook_deliveries").Delete().Eq("scope", scope).Lt("expires_at", time.Now().UTC().Format(time.RFC3339)).Execute(nil)
+
+	err := db.From("webhook_deliveries").Insert(row).Execute(nil)
+	var requestErr *postgrest.RequestError
+	if errors.As(err, &requestErr) && requestErr.Code == uniqueViolation {
+		return ErrReplayed
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// Forget drops a delivery remembered for scope, so that the sender can retry a request
+// that was verified but couldn't be processed.
+func Forget(scope string, d *Delivery) {
+	database.GetClient().DB.From("webhook_deliveries").Delete().Eq("scope", scope).Eq("delivery_id", d.key()).Execute(nil)
+}
+
+// key is what is stored of a delivery: a hash of its signature, whatever the algorithm.
+func (d *Delivery) key() string {
+	sum := sha256.Sum256([]byte(d.ID))
+	return hex.EncodeToString(sum[:])
+}
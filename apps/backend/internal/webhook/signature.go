@@ -0,0 +1,252 @@
+// Package webhook authenticates inbound webhooks. Both flow triggers (webhook-trigger
+// nodes) and the resume URLs of AUTOMATION nodes can require an HMAC signature:
+//
+//	"signature": {
+//	  "preset": "github" | "stripe" | "hmac",
+//	  "secret": "{{ secrets.GITHUB_WEBHOOK_SECRET }}",
+//	  "header": "X-Signature",          // hmac: header carrying the signature
+//	  "algorithm": "sha256",            // hmac: sha1, sha256 or sha512
+//	  "encoding": "hex",                // hmac: hex or base64
+//	  "prefix": "sha256=",              // hmac: text before the signature, if any
+//	  "timestampHeader": "X-Timestamp", // hmac: signs "<timestamp>.<body>" instead of the body
+//	  "toleranceSeconds": 300           // how far a signed timestamp may be from now
+//	}
+//
+// The github preset checks X-Hub-Signature-256; the stripe preset checks the t= timestamp
+// and v1= signatures of Stripe-Signature. Every accepted delivery is remembered by its
+// signature, so the same request can't be played twice. Delivery IDs sent in other
+// headers (X-GitHub-Delivery...) aren't signed and play no part in this. Schemes with a
+// signed timestamp only need to remember a delivery until it falls out of the tolerance;
+// the others remember it for good.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature presets
+const (
+	PresetGitHub = "github" // X-Hub-Signature-256: sha256=<hex>
+	PresetStripe = "stripe" // Stripe-Signature: t=<unix>,v1=<hex>
+	PresetHMAC   = "hmac"   // any header, algorithm and encoding (default)
+)
+
+const (
+	defaultTolerance = 5 * time.Minute
+	maxTolerance     = 24 * time.Hour
+)
+
+// ErrInvalidSignature is wrapped by every verification failure.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Signature is how the sender of a webhook signs its requests.
+type Signature struct {
+	Preset          string        `json:"preset"`
+	Secret          string        `json:"secret"`
+	Header          string        `json:"header"`
+	Algorithm       string        `json:"algorithm"`
+	Encoding        string        `json:"encoding"`
+	Prefix          string        `json:"prefix"`
+	TimestampHeader string        `json:"timestampHeader"`
+	Tolerance       time.Duration `json:"-"`
+}
+
+// Delivery is a verified request, identified for replay protection.
+type Delivery struct {
+	ID      string    // the verified signature, hex-encoded
+	Expires time.Time // when a replay would fail verification anyway; zero without a signed timestamp
+}
+
+// ParseSignature reads a "signature" block, filling in the defaults of its preset.
+// It returns nil when raw is empty: the webhook isn't signed.
+func ParseSignature(raw interface{}) (*Signature, error) {
+	m, ok := raw.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		if raw != nil && !ok {
+			return nil, fmt.Errorf("signature must be an object")
+		}
+		return nil, nil
+	}
+	str := func(key string) string {
+		s, _ := m[key].(string)
+		return strings.TrimSpace(s)
+	}
+
+	s := &Signature{
+		Preset:          strings.ToLower(str("preset")),
+		Secret:          str("secret"),
+		Header:          str("header"),
+		Algorithm:       strings.ToLower(str("algorithm")),
+		Encoding:        strings.ToLower(str("encoding")),
+		Prefix:          str("prefix"),
+		TimestampHeader: str("timestampHeader"),
+	}
+	if s.Secret == "" {
+		return nil, fmt.Errorf("signature needs a secret")
+	}
+
+	switch s.Preset {
+	case PresetGitHub:
+		s.Header, s.Algorithm, s.Encoding, s.Prefix = "X-Hub-Signature-256", "sha256", "hex", "sha256="
+		s.TimestampHeader = ""
+	case PresetStripe:
+		s.Header, s.Algorithm, s.Encoding, s.Prefix = "Stripe-Signature", "sha256", "hex", ""
+		s.TimestampHeader = ""
+	case "", PresetHMAC:
+		s.Preset = PresetHMAC
+		if s.Header == "" {
+			return nil, fmt.Errorf("signature needs the header carrying it")
+		}
+		if s.Algorithm == "" {
+			s.Algorithm = "sha256"
+		}
+		if s.Encoding == "" {
+			s.Encoding = "hex"
+		}
+	default:
+		return nil, fmt.Errorf("unknown signature preset %q (use github, stripe or hmac)", s.Preset)
+	}
+
+	if newHash(s.Algorithm) == nil {
+		return nil, fmt.Errorf("unknown signature algorithm %q (use sha1, sha256 or sha512)", s.Algorithm)
+	}
+	if s.Encoding != "hex" && s.Encoding != "base64" {
+		return nil, fmt.Errorf("unknown signature encoding %q (use hex or base64)", s.Encoding)
+	}
+
+	s.Tolerance = defaultTolerance
+	if v, ok := m["toleranceSeconds"]; ok && v != nil {
+		secs, ok := v.(float64)
+		if !ok || secs <= 0 {
+			return nil, fmt.Errorf("toleranceSeconds must be a positive number")
+		}
+		s.Tolerance = time.Duration(secs * float64(time.Second))
+		if s.Tolerance > maxTolerance {
+			s.Tolerance = maxTolerance
+		}
+	}
+	return s, nil
+}
+
+// Timestamped tells whether the scheme signs a timestamp, which bounds replays in time.
+func (s *Signature) Timestamped() bool {
+	return s.Preset == PresetStripe || s.TimestampHeader != ""
+}
+
+// Verify checks the signature of a request whose body is body, with the resolved
+// secret. The Delivery it returns still has to be remembered (see Remember) before the
+// request is acted on.
+func (s *Signature) Verify(secret string, header http.Header, body []byte, now time.Time) (*Delivery, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("%w: the signing secret is empty", ErrInvalidSignature)
+	}
+	raw := strings.TrimSpace(header.Get(s.Header))
+	if raw == "" {
+		return nil, fmt.Errorf("%w: missing %s header", ErrInvalidSignature, s.Header)
+	}
+
+	var candidates []string
+	var timestamp string
+	if s.Preset == PresetStripe {
+		for _, part := range strings.Split(raw, ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			switch key {
+			case "t":
+				timestamp = value
+			case "v1":
+				candidates = append(candidates, value)
+			}
+		}
+		if timestamp == "" || len(candidates) == 0 {
+			return nil, fmt.Errorf("%w: malformed %s header", ErrInvalidSignature, s.Header)
+		}
+	} else {
+		if s.Prefix != "" {
+			if !strings.HasPrefix(raw, s.Prefix) {
+				return nil, fmt.Errorf("%w: %s doesn't start with %q", ErrInvalidSignature, s.Header, s.Prefix)
+			}
+			raw = raw[len(s.Prefix):]
+		}
+		candidates = []string{raw}
+		if s.TimestampHeader != "" {
+			timestamp = strings.TrimSpace(header.Get(s.TimestampHeader))
+			if timestamp == "" {
+				return nil, fmt.Errorf("%w: missing %s header", ErrInvalidSignature, s.TimestampHeader)
+			}
+		}
+	}
+
+	payload := body
+	delivery := &Delivery{}
+	if timestamp != "" {
+		signedAt, err := parseTimestamp(timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+		if skew := now.Sub(signedAt); skew > s.Tolerance || skew < -s.Tolerance {
+			return nil, fmt.Errorf("%w: timestamp is outside the %s tolerance", ErrInvalidSignature, s.Tolerance)
+		}
+		payload = append([]byte(timestamp+"."), body...)
+		delivery.Expires = signedAt.Add(s.Tolerance)
+	}
+
+	mac := hmac.New(newHash(s.Algorithm), []byte(secret))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+	for _, candidate := range candidates {
+		if given, err := s.decode(candidate); err == nil && hmac.Equal(given, expected) {
+			// Keyed on the MAC itself: the encoding of a signature can vary, its value can't
+			delivery.ID = hex.EncodeToString(expected)
+			return delivery, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: signature doesn't match", ErrInvalidSignature)
+}
+
+func (s *Signature) decode(signature string) ([]byte, error) {
+	if s.Encoding == "base64" {
+		if decoded, err := base64.StdEncoding.DecodeString(signature); err == nil {
+			return decoded, nil
+		}
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(signature, "="))
+	}
+	return hex.DecodeString(strings.ToLower(signature))
+}
+
+func newHash(algorithm string) func() hash.Hash {
+	switch algorithm {
+	case "sha1":
+		return sha1.New
+	case "sha256":
+		return sha256.New
+	case "sha512":
+		return sha512.New
+	}
+	return nil
+}
+
+// parseTimestamp reads Unix seconds (or milliseconds) or an RFC 3339 time.
+func parseTimestamp(value string) (time.Time, error) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if n > 1e12 {
+			return time.UnixMilli(n), nil
+		}
+		return time.Unix(n, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
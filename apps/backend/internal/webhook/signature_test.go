@@ -0,0 +1,354 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+)
+
+// Known answers: GitHub's documented example, RFC 4231 / RFC 2202 test case 2, and
+// HMACs of "<timestamp>.<body>" computed with openssl.
+const (
+	githubSecret    = "It's a Secret to Everybody"
+	githubBody      = "Hello, World!"
+	githubSignature = "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+
+	rfcKey       = "Jefe"
+	rfcBody      = "what do ya want for nothing?"
+	rfcSHA1      = "effcdf6ae5eb2fa2d27416d5f184df9c259a7c79"
+	rfcSHA256    = "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	rfcSHA256B64 = "W9zBRr9gdU5qBCQmCJV1x1oAPwidJzmDnexYuWTsOEM="
+	rfcSHA512    = "164b7a7bfcf819e2e395fbe73b56e0a387bd64222e831fd610270cd7ea2505549758bf75c05a994a6d034f65f8f0e6fdcaeab1a34d4a6b4b636e070a38bce737"
+
+	signedAt        = 1700000000
+	stripeSecret    = "whsec_test"
+	stripeBody      = `{"id":"evt_1"}`
+	stripeSignature = "c89214b5b5da833daed6f0b8c5bb6bd58cea9022bd80ccc78230f3942d632925" // sha256, whsec_test
+	timestampedSHA1 = "49a5be18f0587f594eed883d368a297dd3cf472f"                         // sha1, Jefe
+)
+
+func mustSignature(t *testing.T, raw map[string]interface{}) *Signature {
+	t.Helper()
+	s, err := ParseSignature(raw)
+	if err != nil {
+		t.Fatalf("ParseSignature(%v): %v", raw, err)
+	}
+	return s
+}
+
+func TestVerify(t *testing.T) {
+	signed := time.Unix(signedAt, 0)
+	hmacConfig := func(extra map[string]interface{}) map[string]interface{} {
+		m := map[string]interface{}{"preset": "hmac", "secret": rfcKey, "header": "X-Signature"}
+		for k, v := range extra {
+			m[k] = v
+		}
+		return m
+	}
+
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		secret  string
+		headers map[string]string
+		body    string
+		now     time.Time
+		wantErr string // "" when the request verifies
+	}{
+		// github
+		{"github", map[string]interface{}{"preset": "github", "secret": "x"}, githubSecret,
+			map[string]string{"X-Hub-Signature-256": githubSignature}, githubBody, signed, ""},
+		{"github ignores a configured header", map[string]interface{}{"preset": "github", "secret": "x", "header": "X-Other"}, githubSecret,
+			map[string]string{"X-Hub-Signature-256": githubSignature}, githubBody, signed, ""},
+		{"github without the prefix", map[string]interface{}{"preset": "github", "secret": "x"}, githubSecret,
+			map[string]string{"X-Hub-Signature-256": strings.TrimPrefix(githubSignature, "sha256=")}, githubBody, signed, `doesn't start with "sha256="`},
+		{"github with another body", map[string]interface{}{"preset": "github", "secret": "x"}, githubSecret,
+			map[string]string{"X-Hub-Signature-256": githubSignature}, githubBody + " ", signed, "doesn't match"},
+		{"github with another secret", map[string]interface{}{"preset": "github", "secret": "x"}, "another secret",
+			map[string]string{"X-Hub-Signature-256": githubSignature}, githubBody, signed, "doesn't match"},
+		{"github without its header", map[string]interface{}{"preset": "github", "secret": "x"}, githubSecret,
+			map[string]string{"X-Hub-Signature": githubSignature}, githubBody, signed, "missing X-Hub-Signature-256 header"},
+
+		// stripe
+		{"stripe", map[string]interface{}{"preset": "stripe", "secret": "x"}, stripeSecret,
+			map[string]string{"Stripe-Signature": "t=1700000000,v1=" + stripeSignature}, stripeBody, signed.Add(time.Minute), ""},
+		{"stripe with several signatures", map[string]interface{}{"preset": "stripe", "secret": "x"}, stripeSecret,
+			map[string]string{"Stripe-Signature": "t=1700000000, v1=" + rfcSHA256 + ", v1=" + stripeSignature + ", v0=abc"}, stripeBody, signed, ""},
+		{"stripe only checks v1", map[string]interface{}{"preset": "stripe", "secret": "x"}, stripeSecret,
+			map[string]string{"Stripe-Signature": "t=1700000000,v0=" + stripeSignature}, stripeBody, signed, "malformed Stripe-Signature header"},
+		{"stripe without a timestamp", map[string]interface{}{"preset": "stripe", "secret": "x"}, stripeSecret,
+			map[string]string{"Stripe-Signature": "v1=" + stripeSignature}, stripeBody, signed, "malformed Stripe-Signature header"},
+		{"stripe with another timestamp", map[string]interface{}{"preset": "stripe", "secret": "x"}, stripeSecret,
+			map[string]string{"Stripe-Signature": "t=1700000001,v1=" + stripeSignature}, stripeBody, signed, "doesn't match"},
+
+		// Timestamp tolerance
+		{"stripe too old", map[string]interface{}{"preset": "stripe", "secret": "x"}, stripeSecret,
+			map[string]string{"Stripe-Signature": "t=1700000000,v1=" + stripeSignature}, stripeBody, signed.Add(5*time.Minute + time.Second), "outside the 5m0s tolerance"},
+		{"stripe at the tolerance", map[string]interface{}{"preset": "stripe", "secret": "x"}, stripeSecret,
+			map[string]string{"Stripe-Signature": "t=1700000000,v1=" + stripeSignature}, stripeBody, signed.Add(5 * time.Minute), ""},
+		{"stripe from the future", map[string]interface{}{"preset": "stripe", "secret": "x"}, stripeSecret,
+			map[string]string{"Stripe-Signature": "t=1700000000,v1=" + stripeSignature}, stripeBody, signed.Add(-6 * time.Minute), "outside the 5m0s tolerance"},
+		{"stripe with a longer tolerance", map[string]interface{}{"preset": "stripe", "secret": "x", "toleranceSeconds": 3600.0}, stripeSecret,
+			map[string]string{"Stripe-Signature": "t=1700000000,v1=" + stripeSignature}, stripeBody, signed.Add(30 * time.Minute), ""},
+		{"hmac timestamp header", hmacConfig(map[string]interface{}{"algorithm": "sha1", "timestampHeader": "X-Timestamp"}), rfcKey,
+			map[string]string{"X-Signature": timestampedSHA1, "X-Timestamp": "1700000000"}, stripeBody, signed, ""},
+		{"hmac timestamp too old", hmacConfig(map[string]interface{}{"algorithm": "sha1", "timestampHeader": "X-Timestamp", "toleranceSeconds": 10.0}), rfcKey,
+			map[string]string{"X-Signature": timestampedSHA1, "X-Timestamp": "1700000000"}, stripeBody, signed.Add(11 * time.Second), "outside the 10s tolerance"},
+		{"hmac timestamp missing", hmacConfig(map[string]interface{}{"algorithm": "sha1", "timestampHeader": "X-Timestamp"}), rfcKey,
+			map[string]string{"X-Signature": timestampedSHA1}, stripeBody, signed, "missing X-Timestamp header"},
+		{"hmac timestamp unreadable", hmacConfig(map[string]interface{}{"algorithm": "sha1", "timestampHeader": "X-Timestamp"}), rfcKey,
+			map[string]string{"X-Signature": timestampedSHA1, "X-Timestamp": "yesterday"}, stripeBody, signed, "invalid timestamp"},
+
+		// hmac algorithms, encodings and prefixes
+		{"hmac sha256 by default", hmacConfig(nil), rfcKey,
+			map[string]string{"X-Signature": rfcSHA256}, rfcBody, signed, ""},
+		{"hmac sha1", hmacConfig(map[string]interface{}{"algorithm": "SHA1"}), rfcKey,
+			map[string]string{"X-Signature": rfcSHA1}, rfcBody, signed, ""},
+		{"hmac sha512", hmacConfig(map[string]interface{}{"algorithm": "sha512"}), rfcKey,
+			map[string]string{"X-Signature": rfcSHA512}, rfcBody, signed, ""},
+		{"hmac with the wrong algorithm", hmacConfig(map[string]interface{}{"algorithm": "sha512"}), rfcKey,
+			map[string]string{"X-Signature": rfcSHA256}, rfcBody, signed, "doesn't match"},
+		{"hex is case-insensitive", hmacConfig(nil), rfcKey,
+			map[string]string{"X-Signature": strings.ToUpper(rfcSHA256)}, rfcBody, signed, ""},
+		{"base64", hmacConfig(map[string]interface{}{"encoding": "base64"}), rfcKey,
+			map[string]string{"X-Signature": rfcSHA256B64}, rfcBody, signed, ""},
+		{"unpadded URL-safe base64", hmacConfig(map[string]interface{}{"encoding": "base64"}), rfcKey,
+			map[string]string{"X-Signature": strings.TrimRight(rfcSHA256B64, "=")}, rfcBody, signed, ""},
+		{"hex where base64 is expected", hmacConfig(map[string]interface{}{"encoding": "base64"}), rfcKey,
+			map[string]string{"X-Signature": rfcSHA256}, rfcBody, signed, "doesn't match"},
+		{"base64 where hex is expected", hmacConfig(nil), rfcKey,
+			map[string]string{"X-Signature": rfcSHA256B64}, rfcBody, signed, "doesn't match"},
+		{"prefix", hmacConfig(map[string]interface{}{"prefix": "v1="}), rfcKey,
+			map[string]string{"X-Signature": "v1=" + rfcSHA256}, rfcBody, signed, ""},
+		{"prefix missing", hmacConfig(map[string]interface{}{"prefix": "v1="}), rfcKey,
+			map[string]string{"X-Signature": rfcSHA256}, rfcBody, signed, `doesn't start with "v1="`},
+		{"unexpected prefix", hmacConfig(nil), rfcKey,
+			map[string]string{"X-Signature": "sha256=" + rfcSHA256}, rfcBody, signed, "doesn't match"},
+		{"surrounding spaces", hmacConfig(nil), rfcKey,
+			map[string]string{"X-Signature": "  " + rfcSHA256 + " "}, rfcBody, signed, ""},
+		{"empty secret", hmacConfig(nil), "",
+			map[string]string{"X-Signature": rfcSHA256}, rfcBody, signed, "the signing secret is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mustSignature(t, tt.config)
+			header := http.Header{}
+			for k, v := range tt.headers {
+				header.Set(k, v)
+			}
+			delivery, err := s.Verify(tt.secret, header, []byte(tt.body), tt.now)
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrInvalidSignature) {
+					t.Fatalf("Verify = %v, want ErrInvalidSignature", err)
+				}
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Verify error = %q, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if delivery.ID == "" {
+				t.Error("verified delivery has no ID")
+			}
+			if s.Timestamped() != !delivery.Expires.IsZero() {
+				t.Errorf("Expires = %v for a scheme with Timestamped() = %v", delivery.Expires, s.Timestamped())
+			}
+		})
+	}
+}
+
+func TestDeliveryIdentity(t *testing.T) {
+	signed := time.Unix(signedAt, 0)
+	verify := func(config map[string]interface{}, signature string) *Delivery {
+		t.Helper()
+		header := http.Header{}
+		header.Set("X-Signature", signature)
+		d, err := mustSignature(t, config).Verify(rfcKey, header, []byte(rfcBody), signed)
+		if err != nil {
+			t.Fatalf("Verify(%s): %v", signature, err)
+		}
+		return d
+	}
+
+	hexDelivery := verify(map[string]interface{}{"secret": "x", "header": "X-Signature"}, rfcSHA256)
+	if hexDelivery.ID != rfcSHA256 {
+		t.Errorf("ID = %s, want the hex MAC", hexDelivery.ID)
+	}
+	// The same MAC written differently is the same delivery
+	upper := verify(map[string]interface{}{"secret": "x", "header": "X-Signature"}, strings.ToUpper(rfcSHA256))
+	b64 := verify(map[string]interface{}{"secret": "x", "header": "X-Signature", "encoding": "base64"}, rfcSHA256B64)
+	if upper.ID != hexDelivery.ID || b64.ID != hexDelivery.ID {
+		t.Errorf("IDs = %s, %s, %s, want one ID for one MAC", hexDelivery.ID, upper.ID, b64.ID)
+	}
+
+	// Deliveries with a signed timestamp expire once a replay would be too old anyway
+	s := mustSignature(t, map[string]interface{}{"preset": "stripe", "secret": "x", "toleranceSeconds": 60.0})
+	header := http.Header{}
+	header.Set("Stripe-Signature", "t=1700000000,v1="+stripeSignature)
+	d, err := s.Verify(stripeSecret, header, []byte(stripeBody), signed)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if want := signed.Add(time.Minute); !d.Expires.Equal(want) {
+		t.Errorf("Expires = %v, want %v", d.Expires, want)
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	if s, err := ParseSignature(nil); s != nil || err != nil {
+		t.Errorf("ParseSignature(nil) = %v, %v, want an unsigned webhook", s, err)
+	}
+	if s, err := ParseSignature(map[string]interface{}{}); s != nil || err != nil {
+		t.Errorf("ParseSignature({}) = %v, %v, want an unsigned webhook", s, err)
+	}
+
+	invalid := []struct {
+		name string
+		raw  interface{}
+	}{
+		{"not an object", "github"},
+		{"no secret", map[string]interface{}{"preset": "github"}},
+		{"hmac without a header", map[string]interface{}{"secret": "x"}},
+		{"unknown preset", map[string]interface{}{"preset": "gitlab", "secret": "x"}},
+		{"unknown algorithm", map[string]interface{}{"secret": "x", "header": "X-Sig", "algorithm": "md5"}},
+		{"unknown encoding", map[string]interface{}{"secret": "x", "header": "X-Sig", "encoding": "base32"}},
+		{"negative tolerance", map[string]interface{}{"preset": "stripe", "secret": "x", "toleranceSeconds": -1.0}},
+		{"tolerance as text", map[string]interface{}{"preset": "stripe", "secret": "x", "toleranceSeconds": "300"}},
+	}
+	for _, tt := range invalid {
+		if s, err := ParseSignature(tt.raw); err == nil {
+			t.Errorf("%s: ParseSignature = %+v, want an error", tt.name, s)
+		}
+	}
+
+	s := mustSignature(t, map[string]interface{}{"preset": "GitHub", "secret": "x", "timestampHeader": "X-Timestamp"})
+	if s.Header != "X-Hub-Signature-256" || s.Prefix != "sha256=" || s.Timestamped() {
+		t.Errorf("github preset = %+v", s)
+	}
+	s = mustSignature(t, map[string]interface{}{"secret": "x", "header": "X-Sig"})
+	if s.Preset != PresetHMAC || s.Algorithm != "sha256" || s.Encoding != "hex" || s.Tolerance != defaultTolerance {
+		t.Errorf("hmac defaults = %+v", s)
+	}
+	s = mustSignature(t, map[string]interface{}{"preset": "stripe", "secret": "x", "toleranceSeconds": 1e9})
+	if s.Tolerance != maxTolerance || !s.Timestamped() {
+		t.Errorf("stripe tolerance = %v, want it capped at %v", s.Tolerance, maxTolerance)
+	}
+}
+
+// deliveryStore stands in for the webhook_deliveries table behind PostgREST.
+type deliveryStore struct {
+	mu   sync.Mutex
+	rows map[[2]string]*string // scope, delivery_id -> expires_at
+}
+
+func (s *deliveryStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.URL.Path != "/rest/v1/webhook_deliveries" {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		var row struct {
+			Scope      string  `json:"scope"`
+			DeliveryID string  `json:"delivery_id"`
+			ExpiresAt  *string `json:"expires_at"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&row); err != nil {
+			http.Error(w, `{"message":"bad body"}`, http.StatusBadRequest)
+			return
+		}
+		key := [2]string{row.Scope, row.DeliveryID}
+		if _, exists := s.rows[key]; exists {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"code":"23505","message":"duplicate key value violates unique constraint"}`))
+			return
+		}
+		s.rows[key] = row.ExpiresAt
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		// Filters are "<op>.<value>", values with reserved characters in double quotes
+		filter := func(column, op string) (string, bool) {
+			v := r.URL.Query().Get(column)
+			if v == "" {
+				return "", false
+			}
+			return strings.Trim(strings.TrimPrefix(v, op+"."), `"`), true
+		}
+		for key, expires := range s.rows {
+			if v, ok := filter("scope", "eq"); ok && v != key[0] {
+				continue
+			}
+			if v, ok := filter("delivery_id", "eq"); ok && v != key[1] {
+				continue
+			}
+			if v, ok := filter("expires_at", "lt"); ok && (expires == nil || *expires >= v) {
+				continue
+			}
+			delete(s.rows, key)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestRememberRejectsReplays(t *testing.T) {
+	store := &deliveryStore{rows: map[[2]string]*string{}}
+	server := httptest.NewServer(store)
+	defer server.Close()
+	database.Init(server.URL, "test-key")
+
+	d := &Delivery{ID: rfcSHA256}
+	if err := Remember("flow-1", d); err != nil {
+		t.Fatalf("first delivery: %v", err)
+	}
+	if err := Remember("flow-1", &Delivery{ID: rfcSHA256}); !errors.Is(err, ErrReplayed) {
+		t.Errorf("replayed delivery = %v, want ErrReplayed", err)
+	}
+	if err := Remember("flow-2", d); err != nil {
+		t.Errorf("same delivery on another webhook: %v", err)
+	}
+	if err := Remember("flow-1", &Delivery{ID: rfcSHA512}); err != nil {
+		t.Errorf("another delivery: %v", err)
+	}
+
+	// A delivery that couldn't be processed can be sent again
+	Forget("flow-1", d)
+	if err := Remember("flow-1", d); err != nil {
+		t.Errorf("delivery after Forget: %v", err)
+	}
+
+	// Expired deliveries are dropped; they would fail verification anyway
+	expired := &Delivery{ID: rfcSHA1, Expires: time.Now().Add(-time.Minute)}
+	if err := Remember("flow-3", expired); err != nil {
+		t.Fatalf("expired delivery: %v", err)
+	}
+	if err := Remember("flow-3", expired); err != nil {
+		t.Errorf("expired delivery wasn't dropped: %v", err)
+	}
+	live := &Delivery{ID: stripeSignature, Expires: time.Now().Add(time.Minute)}
+	if err := Remember("flow-3", live); err != nil {
+		t.Fatalf("live delivery: %v", err)
+	}
+	if err := Remember("flow-3", live); !errors.Is(err, ErrReplayed) {
+		t.Errorf("replayed live delivery = %v, want ErrReplayed", err)
+	}
+
+	// Stored keys are hashes, not the signatures themselves
+	for key := range store.rows {
+		if key[1] == rfcSHA256 || key[1] == rfcSHA512 {
+			t.Errorf("stored delivery_id %s is the raw signature", key[1])
+		}
+	}
+}
@@ -2,38 +2,86 @@ package workflow
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/teavana/enigmatic_s/apps/backend/internal/nodes"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/secrets"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
 )
 
 // NodeExecutionActivity executes a single node by looking up its executor in the registry.
+// Failures are returned as typed ApplicationErrors so the node's retry policy applies;
+// the failed result's output travels along as error details. Failures that may have had
+// side effects are only retried when the node's policy asks for it explicitly.
 func NodeExecutionActivity(ctx context.Context, input nodes.NodeContext) (*nodes.NodeResult, error) {
+	// Every attempt is kept in node_executions and announced to live subscribers
+	attempt := startNodeAttempt(input, activity.GetInfo(ctx).Attempt)
+
 	// 1. Get Executor
-	executor, err := nodes.GetExecutor(input.Config["type"].(string), input.Config)
+	nodeType, _ := input.Config["type"].(string)
+	executor, err := nodes.GetExecutor(nodeType, input.Config)
 	if err != nil {
-		return &nodes.NodeResult{
-			Status: nodes.StatusFailed,
-			Error:  fmt.Sprintf("node type not specified or unknown: %v", err),
-		}, nil
+		attempt.finish(ExecutionFailed, nil, err.Error())
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("node type not specified or unknown: %v", err), nodes.ErrorTypeConfig, err)
+	}
+
+	// 2. Resolve secrets. They only exist for the duration of this call: everything
+	// leaving it (output, errors, recorded attempts) is redacted
+	if len(nodes.SecretReferences(input.Config)) > 0 {
+		values, err := secrets.Load(input.OrgID)
+		if err != nil {
+			attempt.finish(ExecutionFailed, nil, err.Error())
+			if errors.Is(err, secrets.ErrNoMasterKey) {
+				return nil, temporal.NewNonRetryableApplicationError(err.Error(), nodes.ErrorTypeConfig, err)
+			}
+			return nil, temporal.NewApplicationError(err.Error(), nodes.ErrorTypeNodeFailed)
+		}
+		input.Secrets = values
 	}
+	redactor := secrets.NewRedactor(input.Secrets)
 
-	// 2. Execute
+	// 3. Execute
 	result, err := executor.Execute(ctx, input)
 	if err != nil {
-		return &nodes.NodeResult{
-			Status: nodes.StatusFailed,
-			Error:  err.Error(),
-		}, nil
+		message := redactor.String(err.Error())
+		attempt.finish(ExecutionFailed, nil, message)
+		return nil, temporal.NewApplicationError(message, nodes.ErrorTypeNodeFailed)
+	}
+	if result != nil {
+		result.Output = redactor.Map(result.Output)
+		result.Error = redactor.String(result.Error)
 	}
 
-	// 3. Return result
+	// 4. Return result
 	if result == nil {
-		return &nodes.NodeResult{
-			Status: nodes.StatusFailed,
-			Error:  "node execution returned null result",
-		}, nil
+		attempt.finish(ExecutionFailed, nil, "node execution returned null result")
+		return nil, temporal.NewApplicationError("node execution returned null result", nodes.ErrorTypeNodeFailed)
+	}
+	if result.Status == nodes.StatusFailed {
+		errType := result.ErrorType
+		if errType == "" {
+			errType = nodes.ErrorTypeNodeFailed
+		}
+		message := result.Error
+		if message == "" {
+			message = "node failed"
+		}
+		attempt.finish(ExecutionFailed, result.Output, message)
+		return nil, temporal.NewApplicationErrorWithOptions(message, errType, temporal.ApplicationErrorOptions{
+			NonRetryable: nodes.IsPermanent(errType) || (result.SideEffects && parseNodePolicy(input.Config).MaxAttempts == 0),
+			Details:      []interface{}{result.Output},
+		})
+	}
+
+	status := ExecutionCompleted
+	if _, isTimer := result.Output[nodes.TimerKey]; result.Status == nodes.StatusPaused || isTimer {
+		status = ExecutionWaiting
 	}
+	attempt.finish(status, result.Output, "")
+	result.ExecutionID = attempt.id
 
 	return result, nil
 }
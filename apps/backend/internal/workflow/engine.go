@@ -0,0 +1,548 @@
+package workflow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/nodes"
+	"go.temporal.io/sdk/workflow"
+)
+
+// GOTO loop protection: limit maximum jump iterations
+const maxGotoHops = 50
+
+// flowEngine holds the graph and run-wide settings shared by every scope of a NodalWorkflow run.
+type flowEngine struct {
+	flow          FlowDefinition
+	nodesLookup   map[string]Node
+	incomingEdges map[string][]Edge
+	outgoingEdges map[string][]Edge
+
+	// Extracted mock data for test mode (keyed by node ID)
+	mockData map[string]interface{}
+
+	gotoCounter int
+
+	// subflowDepth is how many sub-flow calls deep this run is (0 for top-level runs)
+	subflowDepth int
+	subflowCount int
+
+	// response is the output of the first RESPONSE node reached (returned to sync callers)
+	response map[string]interface{}
+
+	// paused holds new nodes before they start (toggled by PauseSignal / ResumeSignal)
+	paused bool
+
+	// waiting holds the wake-up time of DELAY / WAIT-UNTIL nodes currently on a timer
+	waiting map[string]time.Time
+}
+
+// executionScope is the mutable state of one pass over the graph.
+// The main run uses a single root scope; every LOOP iteration gets its own child scope
+// so that parallel iterations don't overwrite each other's step outputs.
+type executionScope struct {
+	// We use a map to track the status of each node: "PENDING", "RUNNING", "COMPLETED", "FAILED", "SKIPPED", "CANCELED"
+	nodeStatus     map[string]string
+	executionState map[string]map[string]interface{}
+	variables      map[string]interface{}
+
+	// loopVars holds the "item" / "index" bindings when running inside a LOOP body
+	loopVars map[string]interface{}
+	// members restricts which nodes may run in this scope (nil = whole graph)
+	members map[string]bool
+	// running cancels the nodes currently running, for branches that lose a first-wins join
+	running map[string]workflow.CancelFunc
+	// jumpedTo holds the GOTO targets about to run, whatever their join says
+	jumpedTo map[string]bool
+
+	// We use a WaitGroup to wait for all branches to finish
+	wg workflow.WaitGroup
+
+	// executionError handles failing the whole scope if one node fails
+	executionError error
+}
+
+func newFlowEngine(flow FlowDefinition, mockData map[string]interface{}) *flowEngine {
+	e := &flowEngine{
+		flow:          flow,
+		nodesLookup:   make(map[string]Node),
+		incomingEdges: make(map[string][]Edge),
+		outgoingEdges: make(map[string][]Edge),
+		mockData:      mockData,
+		waiting:       make(map[string]time.Time),
+	}
+	for _, n := range flow.Nodes {
+		e.nodesLookup[n.ID] = n
+	}
+	for _, edge := range flow.Edges {
+		e.outgoingEdges[edge.Source] = append(e.outgoingEdges[edge.Source], edge)
+		e.incomingEdges[edge.Target] = append(e.incomingEdges[edge.Target], edge)
+	}
+	return e
+}
+
+// isTriggerType reports whether a node type is an entry point of the flow.
+func isTriggerType(nodeType string) bool {
+	return nodeType == "api-trigger" || nodeType == "manual-trigger" || nodeType == "webhook" || nodeType == "trigger" ||
+		nodeType == ScheduleTriggerType || nodeType == WebhookTriggerType
+}
+
+// runNode schedules tryExecuteNode for nodeID on a new workflow goroutine.
+func (e *flowEngine) runNode(ctx workflow.Context, scope *executionScope, nodeID string) {
+	scope.wg.Add(1)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		e.tryExecuteNode(ctx, scope, nodeID)
+	})
+}
+
+// resetDownstreamNodes resets a node and everything reachable from it back to PENDING.
+// Used for Loop/Goto to signal that nodes can run again.
+func (e *flowEngine) resetDownstreamNodes(scope *executionScope, nodeID string, visited map[string]bool) {
+	if visited[nodeID] {
+		return
+	}
+	visited[nodeID] = true
+	if scope.members != nil && !scope.members[nodeID] {
+		return
+	}
+	scope.nodeStatus[nodeID] = "PENDING"
+	// We do NOT clear executionState, to preserve history?
+	// Actually, for a clean rerun, we might want to?
+	// But in a parallel graph, maybe not.
+	// For now, let's just reset status.
+
+	// Follow all outgoing edges
+	for _, edge := range e.outgoingEdges[nodeID] {
+		e.resetDownstreamNodes(scope, edge.Target, visited)
+	}
+}
+
+// tryExecuteNode runs a node once all its parents are complete, then fans out to its children.
+// This function is RECURSIVE (via workflow.Go)
+func (e *flowEngine) tryExecuteNode(ctx workflow.Context, scope *executionScope, nodeID string) {
+	defer scope.wg.Done()
+
+	logger := workflow.GetLogger(ctx)
+	nodeStatus := scope.nodeStatus
+	executionState := scope.executionState
+
+	node, exists := e.nodesLookup[nodeID]
+	nodeType := ""
+	if exists {
+		nodeType = node.Type
+	}
+	logger.Info("tryExecuteNode called", "ID", nodeID, "Type", nodeType, "Status", nodeStatus[nodeID])
+
+	// Safety check
+	if scope.executionError != nil {
+		logger.Info("Skipping node (executionError set)", "ID", nodeID)
+		return
+	}
+
+	// Nodes outside this scope (e.g. after a LOOP body) are not ours to run
+	if scope.members != nil && !scope.members[nodeID] {
+		logger.Info("Skipping node (outside scope)", "ID", nodeID)
+		return
+	}
+
+	// A. Check Status
+	// In Temporal's single-threaded event loop, this is safe without locks
+	if nodeStatus[nodeID] == "COMPLETED" || nodeStatus[nodeID] == "RUNNING" || nodeStatus[nodeID] == "SKIPPED" {
+		logger.Info("Skipping node (already done/running)", "ID", nodeID, "Status", nodeStatus[nodeID])
+		return
+	}
+
+	// B. Dependency Check (join mode, see join.go)
+	// Every parent that finishes, or is skipped, triggers this node again.
+	if exists {
+		switch e.joinDecision(scope, node) {
+		case joinWait:
+			logger.Info("Dependency not met, deferring", "ID", nodeID, "Join", parseJoinMode(node.Data))
+			return
+		case joinSkip:
+			logger.Info("Node can no longer be reached, skipping", "ID", nodeID)
+			e.skipNode(ctx, scope, nodeID)
+			return
+		}
+	}
+
+	// Paused runs hold new nodes here until resumed; nodes already running finish normally
+	if e.paused {
+		logger.Info("Run paused, holding node", "ID", nodeID)
+		if err := workflow.Await(ctx, func() bool { return !e.paused }); err != nil {
+			scope.executionError = err
+			return
+		}
+		// Another parent may have started this node while we were held
+		if scope.executionError != nil || nodeStatus[nodeID] == "COMPLETED" || nodeStatus[nodeID] == "RUNNING" || nodeStatus[nodeID] == "SKIPPED" {
+			return
+		}
+	}
+
+	// C. Execute Node
+	nodeStatus[nodeID] = "RUNNING"
+	delete(scope.jumpedTo, nodeID)
+	if !exists {
+		logger.Error("Node not found", "ID", nodeID)
+		return
+	}
+	if parseJoinMode(node.Data) == JoinFirstWins {
+		e.cancelRacers(ctx, scope, nodeID)
+	}
+
+	// The node's own work can be canceled when its branch loses a first-wins race;
+	// the nodes it triggers run on the scope's context.
+	scopeCtx := ctx
+	ctx, cancelNode := workflow.WithCancel(ctx)
+	scope.running[nodeID] = cancelNode
+	defer func() {
+		delete(scope.running, nodeID)
+		cancelNode()
+	}()
+
+	// Special case for Trigger: It's technically already "Done" as it triggered the flow
+	// But if it has logic, we run it. Usually Triggers just pass data.
+	// For simplicity, we treat Trigger as an immediate success if it was the entry point.
+
+	var result nodes.NodeResult
+
+	// Retry / timeout / on-error policy from node.Data["policy"]
+	policy := parseNodePolicy(node.Data)
+	var handledErr error // failure absorbed by the on-error policy
+	routeToError := false
+
+	// node_executions row of the attempt, while its outcome is still up to the engine
+	// (waits, LOOP bodies and sub-flows finish after the activity returned)
+	openExecutionID := ""
+
+	// onFailure applies the on-error policy. It returns false when the failure stops the
+	// scope (the default "fail"); otherwise the error becomes the node's output.
+	onFailure := func(err error) bool {
+		if nodeStatus[nodeID] == "SKIPPED" {
+			// Canceled by cancelRacers: not a failure of the run
+			logger.Info("Node canceled, its branch lost a first-wins race", "ID", nodeID)
+			if openExecutionID != "" {
+				e.recordNodeOutcome(scopeCtx, NodeExecutionParams{ID: openExecutionID, NodeID: nodeID, Status: ExecutionCanceled})
+			}
+			return false
+		}
+		if ctx.Err() == workflow.ErrCanceled {
+			// The run was canceled while the node ran or waited: not a failure of the node
+			logger.Info("Node canceled with the run", "ID", nodeID)
+			if openExecutionID != "" {
+				recordCtx, _ := workflow.NewDisconnectedContext(ctx)
+				e.recordNodeOutcome(recordCtx, NodeExecutionParams{ID: openExecutionID, NodeID: nodeID, Status: ExecutionCanceled})
+				openExecutionID = ""
+			}
+			nodeStatus[nodeID] = "CANCELED"
+			return false
+		}
+		if openExecutionID != "" {
+			e.recordNodeOutcome(ctx, NodeExecutionParams{ID: openExecutionID, NodeID: nodeID, Status: ExecutionFailed, Error: err.Error()})
+			openExecutionID = ""
+		}
+		if policy.OnError == OnErrorFail {
+			scope.executionError = err
+			nodeStatus[nodeID] = "FAILED"
+			return false
+		}
+		logger.Warn("Node failed, continuing per on-error policy", "ID", nodeID, "OnError", policy.OnError, "Error", err)
+		handledErr = err
+		routeToError = policy.OnError == OnErrorRoute
+		result = nodes.NodeResult{Status: nodes.StatusSuccess, Output: errorOutput(nodeID, err)}
+		return true
+	}
+
+	// Skip execution for triggers, just mark success as we did init above
+	if isTriggerType(node.Type) {
+		result = nodes.NodeResult{Status: nodes.StatusSuccess, Output: executionState[nodeID]}
+		e.recordNodeOutcome(ctx, NodeExecutionParams{NodeID: nodeID, NodeType: node.Type, Status: ExecutionCompleted, Input: node.Data, Output: result.Output})
+	} else {
+		// Prepare Context
+		nodeInputData := map[string]interface{}{
+			"steps":     executionState,
+			"variables": scope.variables,
+		}
+		for k, v := range scope.loopVars {
+			nodeInputData[k] = v
+		}
+		if e.mockData != nil {
+			nodeInputData["__mock_data"] = e.mockData
+		}
+
+		nodeCtx := nodes.NodeContext{
+			FlowID:     e.flow.ID,
+			OrgID:      e.flow.OrgID, // Populate OrgID
+			WorkflowID: workflow.GetInfo(ctx).WorkflowExecution.ID,
+			RunID:      workflow.GetInfo(ctx).WorkflowExecution.RunID,
+			StepID:     node.ID,
+			InputData:  nodeInputData,
+			Config:     node.Data,
+		}
+
+		logger.Info("Executing Node", "ID", node.ID, "Type", node.Type)
+		activityCtx := workflow.WithActivityOptions(ctx, policy.ActivityOptions(workflow.GetActivityOptions(ctx)))
+		err := workflow.ExecuteActivity(activityCtx, NodeExecutionActivity, nodeCtx).Get(ctx, &result)
+		if err != nil {
+			logger.Error("Node execution failed", "ID", node.ID, "Error", err)
+			if !onFailure(err) {
+				return
+			}
+		}
+		_, isSubflow := result.Output[nodes.SubflowCallKey]
+		_, isTimer := result.Output[nodes.TimerKey]
+		if result.Status == nodes.StatusPaused || node.Type == "loop" || isSubflow || isTimer {
+			openExecutionID = result.ExecutionID
+		}
+	}
+
+	// D. Handle Result (Pause/Resume)
+	if result.Status == nodes.StatusPaused {
+		logger.Info("Node requested suspension", "ID", node.ID)
+		signalName := "Resume-" + node.ID
+		if tid, ok := result.Output["task_id"].(string); ok {
+			signalName = "HumanTask-" + tid
+		}
+		if actionID, ok := result.Output["action_id"].(string); ok {
+			signalName = "AutomationSignal-" + actionID
+		}
+
+		// Configurable timeout: default 7 days, override via node config "timeout" (in minutes)
+		timeoutDuration := 7 * 24 * time.Hour
+		if t, ok := node.Data["timeout"].(float64); ok && t > 0 {
+			timeoutDuration = time.Duration(t) * time.Minute
+		}
+
+		var signalData interface{}
+		timedOut := false
+		selector := workflow.NewSelector(ctx)
+		selector.AddReceive(workflow.GetSignalChannel(ctx, signalName), func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, &signalData)
+		})
+		selector.AddFuture(workflow.NewTimer(ctx, timeoutDuration), func(f workflow.Future) {
+			timedOut = true
+		})
+		selector.Select(ctx)
+
+		// Canceling the run also fires the timer: that's no timeout
+		if ctx.Err() == workflow.ErrCanceled {
+			onFailure(workflow.ErrCanceled)
+			return
+		}
+		if timedOut {
+			logger.Error("Node timed out waiting for signal", "ID", node.ID, "Timeout", timeoutDuration)
+			if !onFailure(fmt.Errorf("node %s timed out waiting for signal after %v", node.ID, timeoutDuration)) {
+				return
+			}
+		} else if signalMap, ok := signalData.(map[string]interface{}); ok {
+			// Update result
+			if result.Output == nil {
+				result.Output = make(map[string]interface{})
+			}
+
+			// Flatten "output" key if present
+			// The signal sends { "task_id": "...", "output": { "key": "val" } }
+			// We want result.Output["key"] = "val"
+			if outputMap, ok := signalMap["output"].(map[string]interface{}); ok {
+				for k, v := range outputMap {
+					result.Output[k] = v
+				}
+			}
+
+			// Also allow top-level keys if needed (like task_id)
+			for k, v := range signalMap {
+				if k != "output" {
+					result.Output[k] = v
+				}
+			}
+		}
+		result.Status = nodes.StatusSuccess
+	}
+
+	if result.Status == nodes.StatusFailed {
+		if !onFailure(fmt.Errorf("node %s failed: %s", node.ID, result.Error)) {
+			return
+		}
+	}
+
+	// LOOP: run the "item" branch once per element before the node counts as completed
+	if node.Type == "loop" && handledErr == nil {
+		loopOutput, err := e.runLoop(ctx, scope, node, result.Output)
+		if err != nil {
+			logger.Error("Loop failed", "ID", node.ID, "Error", err)
+			if !onFailure(err) {
+				return
+			}
+		} else {
+			result.Output = loopOutput
+		}
+	}
+
+	// SUBFLOW: run the flow the node resolved as a child workflow
+	if call, ok := result.Output[nodes.SubflowCallKey].(map[string]interface{}); ok && handledErr == nil {
+		subOutput, err := e.runSubflow(ctx, node, call)
+		if err != nil {
+			logger.Error("Sub-flow failed", "ID", node.ID, "Error", err)
+			if !onFailure(err) {
+				return
+			}
+		} else {
+			result.Output = subOutput
+		}
+	}
+
+	// DELAY / WAIT-UNTIL: sleep on a durable timer (skippable through the API)
+	if timer, ok := result.Output[nodes.TimerKey].(map[string]interface{}); ok && handledErr == nil {
+		timerOutput, err := e.runTimer(ctx, node, timer)
+		if err != nil {
+			logger.Error("Wait failed", "ID", node.ID, "Error", err)
+			if !onFailure(err) {
+				return
+			}
+		} else {
+			result.Output = timerOutput
+		}
+	}
+
+	// A branch that lost a first-wins race while the node was finishing stops here
+	if nodeStatus[nodeID] == "SKIPPED" {
+		onFailure(workflow.ErrCanceled)
+		return
+	}
+
+	if openExecutionID != "" {
+		e.recordNodeOutcome(ctx, NodeExecutionParams{ID: openExecutionID, NodeID: nodeID, Status: ExecutionCompleted, Output: result.Output})
+	}
+
+	// Save State
+	// Store with both flat access and nested "output" key so expressions
+	// like {{ steps.nodeId.fieldName }} AND {{ steps.nodeId.output.fieldName }} both work.
+	merged := make(map[string]interface{})
+	if result.Output != nil {
+		for k, v := range result.Output {
+			merged[k] = v
+		}
+		merged["output"] = result.Output
+	}
+	executionState[node.ID] = merged
+	nodeStatus[nodeID] = "COMPLETED"
+	if isResponse, _ := result.Output[nodes.ResponseKey].(bool); isResponse && e.response == nil {
+		e.response = result.Output
+	}
+	if (node.Type == "set" || node.Type == "variable") && handledErr == nil {
+		for k, v := range result.Output {
+			if k != "_debug_message" {
+				scope.variables[k] = v
+			}
+		}
+	}
+
+	// Handle GOTO
+	if gotoTarget, ok := result.Output["_goto_target"].(string); ok && gotoTarget != "" {
+		// In test mode, treat reaching a Retry/Revisit node as a successful terminal state.
+		// This prevents infinite loops during testing since there is no real event to
+		// satisfy the jump-back target.
+		if e.mockData != nil {
+			logger.Info("GOTO reached in test mode — stopping as success", "From", nodeID, "To", gotoTarget)
+			// Surface a marker so the test results UI can show a clear message
+			// instead of a generic success.
+			executionState[nodeID]["_test_stopped_at_goto"] = true
+			executionState[nodeID]["_goto_target"] = gotoTarget
+			return
+		}
+
+		e.gotoCounter++
+		if e.gotoCounter > maxGotoHops {
+			scope.executionError = fmt.Errorf("maximum GOTO iterations (%d) exceeded — possible infinite loop detected", maxGotoHops)
+			logger.Error("GOTO loop limit reached", "From", nodeID, "To", gotoTarget, "Count", e.gotoCounter)
+			return
+		}
+		logger.Info("GOTO signal received", "From", nodeID, "To", gotoTarget, "Hop", e.gotoCounter)
+		// 1. Reset status of target and its children so they can run again
+		e.resetDownstreamNodes(scope, gotoTarget, make(map[string]bool))
+		if scope.jumpedTo == nil {
+			scope.jumpedTo = make(map[string]bool)
+		}
+		scope.jumpedTo[gotoTarget] = true
+
+		// 2. Trigger the target immediately
+		e.runNode(scopeCtx, scope, gotoTarget)
+
+		// 3. Stop normal propagation (Do not trigger children of this node)
+		return
+	}
+
+	// E. Trigger Children (Parallel Split)
+	childrenEdges := e.outgoingEdges[nodeID]
+	logger.Info("Triggering children", "ParentID", nodeID, "ParentType", node.Type, "ChildEdges", len(childrenEdges))
+	for _, edge := range childrenEdges {
+		if followsEdge(node, edge, result.Output, routeToError) {
+			logger.Info("Triggering child node", "Parent", nodeID, "Child", edge.Target)
+			// Launch child in new routine
+			e.runNode(scopeCtx, scope, edge.Target)
+		} else if node.Type != "loop" {
+			// The child may be skipped now, or be a join that no longer waits on this branch
+			logger.Info("Skipping child (branching)", "Parent", nodeID, "Child", edge.Target)
+			e.runNode(scopeCtx, scope, edge.Target)
+		}
+	}
+}
+
+// followsEdge reports whether a completed node continues along edge. Conditions,
+// switches, loops and the on-error "route" policy only follow some of their edges.
+func followsEdge(node Node, edge Edge, output map[string]interface{}, routeToError bool) bool {
+	// Branching Logic (Condition / Switch)
+	// If node is a conditional, we only trigger specific children
+	shouldTrigger := true
+	isErrorEdge := edge.SourceHandle != nil && *edge.SourceHandle == ErrorHandle
+
+	if routeToError || isErrorEdge {
+		// The "error" handle only fires when the node failed with onError: "route"
+		shouldTrigger = routeToError && isErrorEdge
+	} else if node.Type == "condition" || node.Type == "if-else" {
+		resVal, _ := output["result"].(bool)
+		targetHandle := "false"
+		if resVal {
+			targetHandle = "true"
+		}
+
+		// Only follow edge if handle matches
+		if edge.SourceHandle != nil && *edge.SourceHandle != targetHandle {
+			shouldTrigger = false
+		}
+	} else if node.Type == "switch" {
+		// Unlabelled edges always fire
+		if edge.SourceHandle != nil {
+			shouldTrigger = switchRoutesTo(output, *edge.SourceHandle)
+		}
+	} else if node.Type == "loop" {
+		// The body already ran inside runLoop; only the "done" branch continues
+		if isLoopBodyEdge(edge) {
+			shouldTrigger = false
+		}
+	}
+	return shouldTrigger
+}
+
+// switchRoutesTo reports whether a switch output routes to handle: every matched case
+// fires, and "default" only fires when no case matched.
+func switchRoutesTo(output map[string]interface{}, handle string) bool {
+	matched, ok := output["matched_cases"].([]interface{})
+	if !ok {
+		// Outputs recorded before multi-match only name the selected case
+		selected, _ := output["selected_case"].(string)
+		if selected == "" {
+			selected = nodes.SwitchDefaultCase
+		}
+		return handle == selected
+	}
+	if handle == nodes.SwitchDefaultCase {
+		return len(matched) == 0
+	}
+	for _, id := range matched {
+		if id == handle {
+			return true
+		}
+	}
+	return false
+}
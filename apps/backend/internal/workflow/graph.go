@@ -6,10 +6,11 @@ import (
 
 // FlowDefinition maps the React Flow JSON structure
 type FlowDefinition struct {
-	ID    string `json:"id"`
-	OrgID string `json:"org_id"` // Added OrgID
-	Nodes []Node `json:"nodes"`
-	Edges []Edge `json:"edges"`
+	ID        string `json:"id"`
+	OrgID     string `json:"org_id"`               // Added OrgID
+	VersionID string `json:"version_id,omitempty"` // flow_versions row being executed (empty for drafts/tests)
+	Nodes     []Node `json:"nodes"`
+	Edges     []Edge `json:"edges"`
 }
 
 type Node struct {
@@ -26,7 +27,9 @@ type Edge struct {
 	TargetHandle *string `json:"targetHandle,omitempty"`
 }
 
-// TopologicalSort sorts nodes by dependency order (Kahn's algorithm)
+// TopologicalSort sorts nodes by dependency order (Kahn's algorithm).
+// On a cycle it returns the nodes it could order along with the error; the missing
+// ones are on (or downstream of) the cycle.
 func TopologicalSort(flow FlowDefinition) ([]Node, error) {
 	// 1. Build Adjacency List and In-Degree Map
 	adj := make(map[string][]string)
@@ -78,10 +81,9 @@ func TopologicalSort(flow FlowDefinition) ([]Node, error) {
 		}
 	}
 
-	// 4. Cycle Detection
 	// 4. Cycle Detection
 	if len(sorted) != len(flow.Nodes) {
-		return nil, fmt.Errorf("cycle detected or disjoint graph: sorted %d vs total %d nodes", len(sorted), len(flow.Nodes))
+		return sorted, fmt.Errorf("cycle detected: sorted %d vs total %d nodes", len(sorted), len(flow.Nodes))
 	}
 
 	return sorted, nil
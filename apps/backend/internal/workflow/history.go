@@ -0,0 +1,233 @@
+package workflow
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/events"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/nodes"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Statuses of a node_executions row.
+const (
+	ExecutionRunning   = "RUNNING"
+	ExecutionCompleted = "COMPLETED"
+	ExecutionFailed    = "FAILED"
+	ExecutionWaiting   = "WAITING"  // Paused on a human task, automation or resume signal
+	ExecutionCanceled  = "CANCELED" // Its branch lost a first-wins join, or the run was canceled
+)
+
+// NodeExecutionParams describes one attempt of a node. With ID set, the existing
+// attempt is updated to its final Status, Output and Error.
+type NodeExecutionParams struct {
+	ID         string                 `json:"id"`
+	OrgID      string                 `json:"org_id"`
+	FlowID     string                 `json:"flow_id"`
+	WorkflowID string                 `json:"workflow_id"`
+	RunID      string                 `json:"run_id"`
+	NodeID     string                 `json:"node_id"`
+	NodeType   string                 `json:"node_type"`
+	Status     string                 `json:"status"`
+	Input      map[string]interface{} `json:"input"`
+	Output     map[string]interface{} `json:"output"`
+	Error      string                 `json:"error"`
+}
+
+// nodeAttempt is one attempt of a node, run by NodeExecutionActivity. It is kept in
+// node_executions and announced to live subscribers as it starts and finishes.
+type nodeAttempt struct {
+	input     nodes.NodeContext
+	id        string // node_executions row ("" when not recorded)
+	startedAt time.Time
+}
+
+// startNodeAttempt records the start of an attempt. Test runs (no OrgID) aren't recorded;
+// neither are attempts the DB rejects, since history must never fail a run.
+func startNodeAttempt(input nodes.NodeContext, attempt int32) *nodeAttempt {
+	a := &nodeAttempt{input: input, startedAt: time.Now()}
+	if input.OrgID == "" {
+		return a
+	}
+
+	nodeType, _ := input.Config["type"].(string)
+	record := map[string]interface{}{
+		"org_id":      input.OrgID,
+		"workflow_id": input.WorkflowID,
+		"run_id":      input.RunID,
+		"node_id":     input.StepID,
+		"node_type":   nodeType,
+		"status":      ExecutionRunning,
+		"attempt":     attempt,
+		"input":       nodes.NewExpressionEngine().ResolveConfig(input),
+		"started_at":  a.startedAt,
+	}
+	if input.FlowID != "" {
+		record["flow_id"] = input.FlowID
+	}
+	// LOOP body nodes run once per item
+	if index, ok := input.InputData["index"].(float64); ok {
+		record["loop_index"] = int(index)
+	}
+
+	var rows []struct {
+		ID string `json:"id"`
+	}
+	if err := database.GetClient().DB.From("node_executions").Insert(record).Execute(&rows); err != nil || len(rows) == 0 {
+		log.Printf("Failed to record execution of node %s (run %s): %v", input.StepID, input.RunID, err)
+	} else {
+		a.id = rows[0].ID
+	}
+
+	publishNodeEvent(events.NodeStarted, input.OrgID, input.WorkflowID, input.RunID, input.StepID, map[string]interface{}{
+		"node_type": nodeType,
+		"attempt":   attempt,
+	})
+	return a
+}
+
+// finish stores the outcome of the attempt.
+func (a *nodeAttempt) finish(status string, output map[string]interface{}, errMsg string) {
+	if a.input.OrgID == "" {
+		return
+	}
+	finishNodeExecution(a.id, a.startedAt, status, output, errMsg)
+	data := map[string]interface{}{
+		"status":      status,
+		"error":       errMsg,
+		"duration_ms": time.Since(a.startedAt).Milliseconds(),
+	}
+	if status == ExecutionWaiting {
+		// What the node waits on: a task, an automation or a timer
+		data["output"] = output
+	}
+	publishNodeEvent(nodeEventType(status), a.input.OrgID, a.input.WorkflowID, a.input.RunID, a.input.StepID, data)
+}
+
+// finishNodeExecution stores the outcome of an attempt started at startedAt.
+func finishNodeExecution(id string, startedAt time.Time, status string, output map[string]interface{}, errMsg string) {
+	if id == "" {
+		return
+	}
+	now := time.Now()
+	update := map[string]interface{}{
+		"status":       status,
+		"output":       output,
+		"completed_at": now,
+		"duration_ms":  now.Sub(startedAt).Milliseconds(),
+	}
+	if errMsg != "" {
+		update["error"] = errMsg
+	}
+	if status == ExecutionWaiting {
+		// Still open: the engine completes it once the wait is over
+		delete(update, "completed_at")
+		delete(update, "duration_ms")
+	}
+
+	var rows []map[string]interface{}
+	if err := database.GetClient().DB.From("node_executions").Update(update).Eq("id", id).Execute(&rows); err != nil {
+		log.Printf("Failed to update node execution %s: %v", id, err)
+	}
+}
+
+// nodeEventType maps a node_executions status to the live event announcing it.
+func nodeEventType(status string) string {
+	switch status {
+	case ExecutionCompleted:
+		return events.NodeCompleted
+	case ExecutionFailed:
+		return events.NodeFailed
+	case ExecutionWaiting:
+		return events.NodePaused
+	case ExecutionCanceled:
+		return events.NodeCanceled
+	}
+	return events.NodeStarted
+}
+
+func publishNodeEvent(eventType, orgID, workflowID, runID, nodeID string, data map[string]interface{}) {
+	events.Publish(events.Event{
+		Type:       eventType,
+		OrgID:      orgID,
+		WorkflowID: workflowID,
+		RunID:      runID,
+		NodeID:     nodeID,
+		Data:       data,
+	})
+}
+
+// RecordNodeExecutionActivity records node outcomes decided by the workflow rather than
+// by an executor: triggers, and nodes that finish after a wait, a LOOP or a SUBFLOW.
+func RecordNodeExecutionActivity(ctx context.Context, params NodeExecutionParams) error {
+	client := database.GetClient()
+
+	if params.ID != "" {
+		var rows []struct {
+			StartedAt time.Time `json:"started_at"`
+		}
+		if err := client.DB.From("node_executions").Select("started_at").Eq("id", params.ID).Execute(&rows); err != nil {
+			return err
+		}
+		startedAt := time.Now()
+		if len(rows) > 0 {
+			startedAt = rows[0].StartedAt
+		}
+		finishNodeExecution(params.ID, startedAt, params.Status, params.Output, params.Error)
+		publishNodeEvent(nodeEventType(params.Status), params.OrgID, params.WorkflowID, params.RunID, params.NodeID, map[string]interface{}{
+			"status":      params.Status,
+			"error":       params.Error,
+			"duration_ms": time.Since(startedAt).Milliseconds(),
+		})
+		return nil
+	}
+
+	now := time.Now()
+	record := map[string]interface{}{
+		"org_id":       params.OrgID,
+		"workflow_id":  params.WorkflowID,
+		"run_id":       params.RunID,
+		"node_id":      params.NodeID,
+		"node_type":    params.NodeType,
+		"status":       params.Status,
+		"attempt":      1,
+		"input":        params.Input,
+		"output":       params.Output,
+		"started_at":   now,
+		"completed_at": now,
+		"duration_ms":  0,
+	}
+	if params.FlowID != "" {
+		record["flow_id"] = params.FlowID
+	}
+	if params.Error != "" {
+		record["error"] = params.Error
+	}
+	var rows []map[string]interface{}
+	if err := client.DB.From("node_executions").Insert(record).Execute(&rows); err != nil {
+		return err
+	}
+	publishNodeEvent(nodeEventType(params.Status), params.OrgID, params.WorkflowID, params.RunID, params.NodeID, map[string]interface{}{
+		"node_type": params.NodeType,
+		"status":    params.Status,
+	})
+	return nil
+}
+
+// recordNodeOutcome runs RecordNodeExecutionActivity for a node of this run.
+// Failing to record is logged and never fails the node.
+func (e *flowEngine) recordNodeOutcome(ctx workflow.Context, params NodeExecutionParams) {
+	if e.flow.OrgID == "" {
+		return
+	}
+	info := workflow.GetInfo(ctx)
+	params.OrgID = e.flow.OrgID
+	params.FlowID = e.flow.ID
+	params.WorkflowID = info.WorkflowExecution.ID
+	params.RunID = info.WorkflowExecution.RunID
+	if err := workflow.ExecuteActivity(ctx, RecordNodeExecutionActivity, params).Get(ctx, nil); err != nil {
+		workflow.GetLogger(ctx).Warn("Failed to record node execution", "ID", params.NodeID, "Error", err)
+	}
+}
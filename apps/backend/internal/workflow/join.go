@@ -0,0 +1,234 @@
+package workflow
+
+import (
+	"sort"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// Join modes decide when a node with several incoming edges runs, configured under
+// node.Data["join"]:
+//
+//	"join": "all" | "any" | "all-executed" | "first-wins"
+const (
+	JoinAll         = "all"          // every parent completed and routed here (default)
+	JoinAny         = "any"          // the first parent that routes here starts the node
+	JoinAllExecuted = "all-executed" // every parent on a branch that was actually taken
+	JoinFirstWins   = "first-wins"   // like "any", and the other racing branches are canceled
+)
+
+// parseJoinMode reads the join mode of a node. Unknown values fall back to JoinAll.
+func parseJoinMode(data map[string]interface{}) string {
+	switch mode, _ := data["join"].(string); mode {
+	case JoinAny, JoinAllExecuted, JoinFirstWins:
+		return mode
+	}
+	return JoinAll
+}
+
+// States of an edge during a run, as seen from its target.
+const (
+	edgeOpen  = iota // the parent hasn't finished yet
+	edgeTaken        // the parent completed and continued along the edge
+	edgeDead         // the parent was skipped, or completed without taking the edge
+)
+
+// edgeState tells whether the parent of edge has routed the run to its target, judging
+// by the parent's status and the output stored in scope.
+func (e *flowEngine) edgeState(scope *executionScope, edge Edge) int {
+	switch scope.nodeStatus[edge.Source] {
+	case "COMPLETED":
+	case "SKIPPED":
+		return edgeDead
+	default:
+		return edgeOpen
+	}
+
+	parent := e.nodesLookup[edge.Source]
+	if parent.Type == "loop" && isLoopBodyEdge(edge) {
+		// Body nodes only run inside the loop's iteration scopes, where the loop counts as completed
+		return edgeTaken
+	}
+	output := scope.executionState[edge.Source]
+	if target, _ := output["_goto_target"].(string); target != "" {
+		// GOTO jumps instead of following its edges
+		return edgeDead
+	}
+	failed, _ := output["_failed"].(bool)
+	routeToError := failed && parseNodePolicy(parent.Data).OnError == OnErrorRoute
+	if followsEdge(parent, edge, output, routeToError) {
+		return edgeTaken
+	}
+	return edgeDead
+}
+
+// Outcomes of joinDecision.
+const (
+	joinWait = iota // parents are still running
+	joinRun         // the node can start
+	joinSkip        // the node can no longer be reached in this run
+)
+
+// joinDecision applies a node's join mode to the state of its incoming edges.
+// Nodes without incoming edges (triggers) and the target of a GOTO jump always run.
+func (e *flowEngine) joinDecision(scope *executionScope, node Node) int {
+	parents := e.incomingEdges[node.ID]
+	if len(parents) == 0 || scope.jumpedTo[node.ID] {
+		return joinRun
+	}
+
+	// A parent linked by several edges (e.g. both outcomes of a condition) counts once,
+	// as taken if any of them was taken
+	states := make(map[string]int, len(parents))
+	var sources []string
+	for _, edge := range parents {
+		state := e.edgeState(scope, edge)
+		_, seen := states[edge.Source]
+		if !seen {
+			sources = append(sources, edge.Source)
+		}
+		if !seen || state == edgeTaken {
+			states[edge.Source] = state
+		}
+	}
+
+	mode := parseJoinMode(node.Data)
+	var open, taken, dead int
+	for _, source := range sources {
+		switch states[source] {
+		case edgeOpen:
+			open++
+		case edgeTaken:
+			taken++
+		case edgeDead:
+			if mode == JoinAll && isTriggerType(e.nodesLookup[source].Type) {
+				// Triggers that didn't start this run are left out, even for "all"
+				continue
+			}
+			dead++
+		}
+	}
+
+	switch mode {
+	case JoinAny, JoinFirstWins:
+		if taken > 0 {
+			return joinRun
+		}
+		if open > 0 {
+			return joinWait
+		}
+		return joinSkip
+	case JoinAllExecuted:
+		if open > 0 {
+			return joinWait
+		}
+		if taken > 0 {
+			return joinRun
+		}
+		return joinSkip
+	default:
+		// Decided once every parent is done, so a join isn't shown as skipped while one
+		// of its branches still runs
+		if open > 0 {
+			return joinWait
+		}
+		if dead == 0 && taken > 0 {
+			return joinRun
+		}
+		return joinSkip
+	}
+}
+
+// skipNode marks a node that can no longer run as SKIPPED and lets its children
+// re-check their joins: they may be skipped in turn, or no longer wait on this branch.
+func (e *flowEngine) skipNode(ctx workflow.Context, scope *executionScope, nodeID string) {
+	scope.nodeStatus[nodeID] = "SKIPPED"
+	for _, edge := range e.outgoingEdges[nodeID] {
+		e.runNode(ctx, scope, edge.Target)
+	}
+}
+
+// cancelRacers stops the branches that lost a first-wins race to joinID: every unfinished
+// node leading to it that isn't also upstream of a parent that already routed to it.
+// Running losers are canceled, pending ones skipped; both end up SKIPPED.
+func (e *flowEngine) cancelRacers(ctx workflow.Context, scope *executionScope, joinID string) {
+	winners := make(map[string]bool)
+	var losing []string
+	for _, edge := range e.incomingEdges[joinID] {
+		if e.edgeState(scope, edge) == edgeTaken {
+			for id := range e.upstreamOf(edge.Source, nil) {
+				winners[id] = true
+			}
+		} else {
+			losing = append(losing, edge.Source)
+		}
+	}
+
+	racers := make(map[string]bool)
+	for _, start := range losing {
+		for id := range e.upstreamOf(start, winners) {
+			racers[id] = true
+		}
+	}
+	// Definition order, so that replays cancel and schedule in the same order
+	ids := make([]string, 0, len(racers))
+	for id := range racers {
+		ids = append(ids, id)
+	}
+	sortByDefinition(e.flow.Nodes, ids)
+
+	var skipped []string
+	for _, id := range ids {
+		if scope.members != nil && !scope.members[id] {
+			continue
+		}
+		switch scope.nodeStatus[id] {
+		case "RUNNING":
+			if cancel, ok := scope.running[id]; ok {
+				cancel()
+			}
+		case "PENDING":
+		default:
+			continue
+		}
+		workflow.GetLogger(ctx).Info("Branch lost first-wins race", "Join", joinID, "ID", id)
+		scope.nodeStatus[id] = "SKIPPED"
+		skipped = append(skipped, id)
+	}
+
+	// Whatever else hangs off the losing branches is dead too
+	for _, id := range skipped {
+		for _, edge := range e.outgoingEdges[id] {
+			if edge.Target != joinID {
+				e.runNode(ctx, scope, edge.Target)
+			}
+		}
+	}
+}
+
+// upstreamOf returns nodeID and every node leading to it, without walking into stop.
+func (e *flowEngine) upstreamOf(nodeID string, stop map[string]bool) map[string]bool {
+	visited := make(map[string]bool)
+	queue := []string{nodeID}
+	for len(queue) > 0 {
+		curr := queue[0]
+		queue = queue[1:]
+		if stop[curr] || visited[curr] {
+			continue
+		}
+		visited[curr] = true
+		for _, edge := range e.incomingEdges[curr] {
+			queue = append(queue, edge.Source)
+		}
+	}
+	return visited
+}
+
+// sortByDefinition orders ids as their nodes appear in the flow definition.
+func sortByDefinition(flowNodes []Node, ids []string) {
+	position := make(map[string]int, len(flowNodes))
+	for i, n := range flowNodes {
+		position[n.ID] = i
+	}
+	sort.Slice(ids, func(i, j int) bool { return position[ids[i]] < position[ids[j]] })
+}
@@ -0,0 +1,208 @@
+package workflow
+
+import (
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestJoinDecision(t *testing.T) {
+	const (
+		done    = "COMPLETED"
+		skipped = "SKIPPED"
+		running = "RUNNING"
+		pending = "PENDING"
+	)
+	tests := []struct {
+		name     string
+		mode     string
+		statuses [3]string // of the parents P1, P2 and P3
+		want     int
+	}{
+		{"all: every parent done", JoinAll, [3]string{done, done, done}, joinRun},
+		{"all: a parent running", JoinAll, [3]string{done, running, done}, joinWait},
+		{"all: a dead branch, another still running", JoinAll, [3]string{skipped, running, done}, joinWait},
+		{"all: a dead branch, others pending", JoinAll, [3]string{skipped, pending, pending}, joinWait},
+		{"all: a dead branch once the others are done", JoinAll, [3]string{skipped, done, done}, joinSkip},
+		{"all: every branch dead", JoinAll, [3]string{skipped, skipped, skipped}, joinSkip},
+
+		{"any: first parent done", JoinAny, [3]string{done, running, pending}, joinRun},
+		{"any: nothing done yet", JoinAny, [3]string{skipped, running, pending}, joinWait},
+		{"any: every branch dead", JoinAny, [3]string{skipped, skipped, skipped}, joinSkip},
+		{"first-wins: first parent done", JoinFirstWins, [3]string{running, done, running}, joinRun},
+
+		{"all-executed: a parent running", JoinAllExecuted, [3]string{done, running, skipped}, joinWait},
+		{"all-executed: dead branches left out", JoinAllExecuted, [3]string{done, skipped, skipped}, joinRun},
+		{"all-executed: every branch dead", JoinAllExecuted, [3]string{skipped, skipped, skipped}, joinSkip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flow := FlowDefinition{
+				Nodes: []Node{stepNode("P1", nil), stepNode("P2", nil), stepNode("P3", nil), stepNode("J", map[string]interface{}{"join": tt.mode})},
+				Edges: []Edge{testEdge("P1", "J"), testEdge("P2", "J"), testEdge("P3", "J")},
+			}
+			e := newFlowEngine(flow, nil)
+			scope := &executionScope{
+				nodeStatus:     map[string]string{"P1": tt.statuses[0], "P2": tt.statuses[1], "P3": tt.statuses[2], "J": pending},
+				executionState: map[string]map[string]interface{}{},
+			}
+			if got := e.joinDecision(scope, e.nodesLookup["J"]); got != tt.want {
+				t.Errorf("joinDecision = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinDecisionEdgeStates(t *testing.T) {
+	// C is a condition that went "true"; A was not started yet; T is a trigger that
+	// didn't start this run
+	flow := FlowDefinition{
+		Nodes: []Node{
+			triggerNode("T"), triggerNode("T2"), testNode("C", "condition", nil), stepNode("A", nil),
+			stepNode("J", nil), stepNode("K", nil), stepNode("G", nil),
+		},
+		Edges: []Edge{
+			testEdge("C", "J", "true"), testEdge("C", "J", "false"), // both outcomes lead to J
+			testEdge("C", "K", "false"),
+			testEdge("T", "G"), testEdge("T2", "G"),
+			testEdge("A", "J"),
+		},
+	}
+	e := newFlowEngine(flow, nil)
+	scope := &executionScope{
+		nodeStatus: map[string]string{"T": "COMPLETED", "T2": "SKIPPED", "C": "COMPLETED", "A": "PENDING"},
+		executionState: map[string]map[string]interface{}{
+			"C": {"result": true},
+		},
+	}
+
+	// A parent linked twice counts once, taken through either edge; J still waits on A
+	if got := e.joinDecision(scope, e.nodesLookup["J"]); got != joinWait {
+		t.Errorf("J = %d, want joinWait", got)
+	}
+	scope.nodeStatus["A"] = "COMPLETED"
+	if got := e.joinDecision(scope, e.nodesLookup["J"]); got != joinRun {
+		t.Errorf("J = %d, want joinRun", got)
+	}
+	// The branch the condition didn't take is dead
+	if got := e.joinDecision(scope, e.nodesLookup["K"]); got != joinSkip {
+		t.Errorf("K = %d, want joinSkip", got)
+	}
+	// Triggers that didn't start the run don't hold an "all" join
+	if got := e.joinDecision(scope, e.nodesLookup["G"]); got != joinRun {
+		t.Errorf("G = %d, want joinRun", got)
+	}
+
+	// A GOTO target runs whatever its parents say, once
+	scope.nodeStatus["A"] = "PENDING"
+	scope.jumpedTo = map[string]bool{"J": true}
+	if got := e.joinDecision(scope, e.nodesLookup["J"]); got != joinRun {
+		t.Errorf("GOTO target J = %d, want joinRun", got)
+	}
+}
+
+// joinFlow: T -> C (condition), C -true-> A -> J, C -false-> B -> J
+func joinFlow(mode string) FlowDefinition {
+	return FlowDefinition{
+		ID: "join-flow",
+		Nodes: []Node{
+			triggerNode("T"),
+			testNode("C", "condition", map[string]interface{}{
+				"condition": map[string]interface{}{"left": "1", "operator": "==", "right": "1"},
+			}),
+			stepNode("A", nil),
+			stepNode("B", nil),
+			stepNode("J", map[string]interface{}{"join": mode}),
+		},
+		Edges: []Edge{
+			testEdge("T", "C"),
+			testEdge("C", "A", "true"),
+			testEdge("C", "B", "false"),
+			testEdge("A", "J"),
+			testEdge("B", "J"),
+		},
+	}
+}
+
+func TestJoinModesAfterCondition(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{JoinAll, "SKIPPED"},
+		{"", "SKIPPED"},
+		{JoinAllExecuted, "COMPLETED"},
+		{JoinAny, "COMPLETED"},
+		{JoinFirstWins, "COMPLETED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			env, fake := runFlow(t, joinFlow(tt.mode), nil)
+			if err := env.GetWorkflowError(); err != nil {
+				t.Fatalf("workflow failed: %v", err)
+			}
+			expectStatuses(t, nodeStatuses(t, env), map[string]string{
+				"C": "COMPLETED", "A": "COMPLETED", "B": "SKIPPED", "J": tt.want,
+			})
+			if runs := fake.count("J"); (runs == 1) != (tt.want == "COMPLETED") || runs > 1 {
+				t.Errorf("J ran %d times: %v", runs, fake.calls)
+			}
+		})
+	}
+}
+
+func TestFirstWinsCancelsLosingBranch(t *testing.T) {
+	flow := FlowDefinition{
+		ID: "race",
+		Nodes: []Node{
+			triggerNode("T"),
+			stepNode("Fast", nil),
+			stepNode("Slow", map[string]interface{}{"block": true}),
+			stepNode("AfterSlow", nil),
+			stepNode("J", map[string]interface{}{"join": JoinFirstWins}),
+			stepNode("Next", nil),
+		},
+		Edges: []Edge{
+			testEdge("T", "Fast"),
+			testEdge("T", "Slow"),
+			testEdge("Fast", "J"),
+			testEdge("Slow", "AfterSlow"),
+			testEdge("AfterSlow", "J"),
+			testEdge("J", "Next"),
+		},
+	}
+	env, fake := runFlow(t, flow, nil)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	expectStatuses(t, nodeStatuses(t, env), map[string]string{
+		"Fast": "COMPLETED", "Slow": "SKIPPED", "AfterSlow": "SKIPPED", "J": "COMPLETED", "Next": "COMPLETED",
+	})
+	if fake.canceled != 1 {
+		t.Errorf("%d activities canceled, want the losing branch's", fake.canceled)
+	}
+	if fake.ran("AfterSlow") || fake.count("J") != 1 {
+		t.Errorf("calls = %v, want J once and nothing after the loser", fake.calls)
+	}
+}
+
+func TestAllJoinWaitsForRunningBranch(t *testing.T) {
+	// B is dead as soon as C is done, while A2 on the other branch waits for a signal:
+	// J must not be decided (and shown as SKIPPED) before A2 finishes
+	flow := joinFlow(JoinAll)
+	flow.Nodes = append(flow.Nodes, stepNode("A2", map[string]interface{}{"pause": true}))
+	flow.Edges[3] = testEdge("A", "A2")
+	flow.Edges = append(flow.Edges, testEdge("A2", "J"))
+
+	env, _ := runFlow(t, flow, nil, func(env *testsuite.TestWorkflowEnvironment) {
+		env.RegisterDelayedCallback(func() {
+			expectStatuses(t, nodeStatuses(t, env), map[string]string{"A2": "RUNNING", "B": "SKIPPED", "J": "PENDING"})
+			env.SignalWorkflow("Resume-A2", nil)
+		}, time.Hour)
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	expectStatuses(t, nodeStatuses(t, env), map[string]string{"A2": "COMPLETED", "B": "SKIPPED", "J": "SKIPPED"})
+}
@@ -0,0 +1,223 @@
+package workflow
+
+import (
+	"fmt"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/nodes"
+	"go.temporal.io/sdk/workflow"
+)
+
+// isLoopBodyEdge reports whether an edge leaves a LOOP node through its per-item handle.
+// The editor names this handle "item"; "body" is accepted as an alias.
+func isLoopBodyEdge(edge Edge) bool {
+	return edge.SourceHandle != nil && (*edge.SourceHandle == "item" || *edge.SourceHandle == "body")
+}
+
+// loopBodyNodes returns the nodes that make up a LOOP's body: everything reachable from
+// its "item" handle, minus the loop itself and anything also reachable from its "done" branch.
+func (e *flowEngine) loopBodyNodes(loopID string) map[string]bool {
+	var bodyStarts, doneStarts []string
+	for _, edge := range e.outgoingEdges[loopID] {
+		if isLoopBodyEdge(edge) {
+			bodyStarts = append(bodyStarts, edge.Target)
+		} else {
+			doneStarts = append(doneStarts, edge.Target)
+		}
+	}
+
+	body := e.reachableFrom(bodyStarts, loopID)
+	for id := range e.reachableFrom(doneStarts, loopID) {
+		delete(body, id)
+	}
+	return body
+}
+
+// reachableFrom walks outgoing edges from the start nodes without passing through stopID.
+func (e *flowEngine) reachableFrom(starts []string, stopID string) map[string]bool {
+	visited := make(map[string]bool)
+	queue := append([]string{}, starts...)
+	for len(queue) > 0 {
+		curr := queue[0]
+		queue = queue[1:]
+		if curr == stopID || visited[curr] {
+			continue
+		}
+		visited[curr] = true
+		for _, edge := range e.outgoingEdges[curr] {
+			queue = append(queue, edge.Target)
+		}
+	}
+	return visited
+}
+
+// runLoop executes the body of a LOOP node once per item and collects each iteration's outputs.
+// loopOutput is the LoopNode activity result (resolved items, mode and concurrency).
+// The first failing iteration cancels the remaining ones and fails the loop.
+func (e *flowEngine) runLoop(ctx workflow.Context, parent *executionScope, node Node, loopOutput map[string]interface{}) (map[string]interface{}, error) {
+	logger := workflow.GetLogger(ctx)
+
+	items, _ := loopOutput["items"].([]interface{})
+	mode, _ := loopOutput["mode"].(string)
+	concurrency := 1
+	if mode == nodes.LoopModeParallel {
+		if c, ok := loopOutput["concurrency"].(float64); ok && c > 0 {
+			concurrency = int(c)
+		} else if c, ok := loopOutput["concurrency"].(int); ok && c > 0 {
+			concurrency = c
+		}
+	}
+	if concurrency > len(items) {
+		concurrency = len(items)
+	}
+
+	body := e.loopBodyNodes(node.ID)
+	var entries []string
+	for _, edge := range e.outgoingEdges[node.ID] {
+		if isLoopBodyEdge(edge) && body[edge.Target] {
+			entries = append(entries, edge.Target)
+		}
+	}
+
+	logger.Info("Loop started", "ID", node.ID, "Items", len(items), "Mode", mode, "Concurrency", concurrency, "BodyNodes", len(body))
+
+	results := make([]interface{}, len(items))
+	iterationScopes := make([]*executionScope, len(items))
+
+	// Cancelling this context stops in-flight iterations once one of them fails
+	loopCtx, cancel := workflow.WithCancel(ctx)
+	defer cancel()
+
+	var loopErr error
+	next := 0
+
+	runIteration := func(ctx workflow.Context, index int) error {
+		scope := e.newIterationScope(ctx, parent, node.ID, body, items[index], index, loopOutput)
+		iterationScopes[index] = scope
+
+		for _, target := range entries {
+			e.runNode(ctx, scope, target)
+		}
+		scope.wg.Wait(ctx)
+
+		if scope.executionError != nil {
+			return scope.executionError
+		}
+
+		// Collect the outputs of every body node that ran in this iteration
+		outputs := make(map[string]interface{})
+		for id := range body {
+			if scope.nodeStatus[id] != "COMPLETED" {
+				continue
+			}
+			if out, ok := scope.executionState[id]["output"]; ok {
+				outputs[id] = out
+			} else {
+				outputs[id] = scope.executionState[id]
+			}
+		}
+		results[index] = outputs
+		return nil
+	}
+
+	// Each worker pulls the next item index until the list is exhausted or an iteration fails.
+	// Sequential mode is simply a single worker.
+	wg := workflow.NewWaitGroup(loopCtx)
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		workflow.Go(loopCtx, func(ctx workflow.Context) {
+			defer wg.Done()
+			for loopErr == nil && next < len(items) {
+				index := next
+				next++
+				if err := runIteration(ctx, index); err != nil {
+					if loopErr == nil {
+						loopErr = fmt.Errorf("loop %s failed at item %d: %w", node.ID, index, err)
+						cancel()
+					}
+					return
+				}
+			}
+		})
+	}
+	wg.Wait(ctx)
+
+	if loopErr != nil {
+		return nil, loopErr
+	}
+
+	// Expose the last iteration's body outputs in the parent scope so nodes on the
+	// "done" branch can still reference {{ steps.bodyNode.x }}
+	if len(items) > 0 {
+		last := iterationScopes[len(items)-1]
+		for id := range body {
+			switch last.nodeStatus[id] {
+			case "COMPLETED":
+				parent.nodeStatus[id] = "COMPLETED"
+				parent.executionState[id] = last.executionState[id]
+			case "SKIPPED":
+				parent.nodeStatus[id] = "SKIPPED"
+			}
+		}
+	} else {
+		// Nothing to loop over: the body never runs
+		for id := range body {
+			parent.nodeStatus[id] = "SKIPPED"
+		}
+	}
+
+	logger.Info("Loop completed", "ID", node.ID, "Iterations", len(items))
+
+	output := make(map[string]interface{}, len(loopOutput)+1)
+	for k, v := range loopOutput {
+		output[k] = v
+	}
+	output["results"] = results
+	output["message"] = fmt.Sprintf("Looped over %d items", len(items))
+	return output, nil
+}
+
+// newIterationScope prepares an isolated scope for one LOOP iteration.
+// Step outputs and variables from before the loop are visible (copied), body nodes start
+// PENDING, and {{ item }} / {{ index }} are bound to the current element.
+func (e *flowEngine) newIterationScope(ctx workflow.Context, parent *executionScope, loopID string, body map[string]bool, item interface{}, index int, loopOutput map[string]interface{}) *executionScope {
+	scope := &executionScope{
+		nodeStatus:     make(map[string]string, len(parent.nodeStatus)),
+		executionState: make(map[string]map[string]interface{}, len(parent.executionState)+1),
+		variables:      make(map[string]interface{}, len(parent.variables)),
+		loopVars:       make(map[string]interface{}, len(parent.loopVars)+2),
+		members:        body,
+		running:        make(map[string]workflow.CancelFunc),
+		wg:             workflow.NewWaitGroup(ctx),
+	}
+	for k, v := range parent.nodeStatus {
+		scope.nodeStatus[k] = v
+	}
+	for k, v := range parent.executionState {
+		scope.executionState[k] = v
+	}
+	for k, v := range parent.variables {
+		scope.variables[k] = v
+	}
+	for k, v := range parent.loopVars {
+		scope.loopVars[k] = v
+	}
+	scope.loopVars["item"] = item
+	scope.loopVars["index"] = index
+
+	for id := range body {
+		scope.nodeStatus[id] = "PENDING"
+	}
+	scope.nodeStatus[loopID] = "COMPLETED"
+
+	// Also expose the current element as {{ steps.<loopId>.item }}
+	loopState := make(map[string]interface{}, len(loopOutput)+3)
+	for k, v := range loopOutput {
+		loopState[k] = v
+	}
+	loopState["item"] = item
+	loopState["index"] = index
+	loopState["output"] = loopOutput
+	scope.executionState[loopID] = loopState
+
+	return scope
+}
@@ -0,0 +1,140 @@
+package workflow
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// loopFlow: T -> L, L -item-> B1 -> B2 -> M, L -done-> D -> M
+func loopFlow(loop map[string]interface{}, b1 map[string]interface{}) FlowDefinition {
+	return FlowDefinition{
+		ID: "loop-flow",
+		Nodes: []Node{
+			triggerNode("T"),
+			testNode("L", "loop", loop),
+			stepNode("B1", b1),
+			stepNode("B2", nil),
+			stepNode("D", nil),
+			stepNode("M", nil),
+		},
+		Edges: []Edge{
+			testEdge("T", "L"),
+			testEdge("L", "B1", "item"),
+			testEdge("B1", "B2"),
+			testEdge("B2", "M"),
+			testEdge("L", "D", "done"),
+			testEdge("D", "M"),
+		},
+	}
+}
+
+func TestLoopBodyNodes(t *testing.T) {
+	e := newFlowEngine(loopFlow(nil, nil), nil)
+	got := e.loopBodyNodes("L")
+	// M is also reached from the done branch, so it runs once after the loop
+	want := map[string]bool{"B1": true, "B2": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loopBodyNodes = %v, want %v", got, want)
+	}
+
+	// "body" is accepted for the item handle
+	flow := loopFlow(nil, nil)
+	flow.Edges[1] = testEdge("L", "B1", "body")
+	if got := newFlowEngine(flow, nil).loopBodyNodes("L"); !reflect.DeepEqual(got, want) {
+		t.Errorf("loopBodyNodes with a body handle = %v, want %v", got, want)
+	}
+}
+
+func TestLoopRunsBodyOncePerItem(t *testing.T) {
+	env, fake := runFlow(t, loopFlow(map[string]interface{}{"items": []interface{}{"a", "b", "c"}}, nil), nil)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	// Sequential iterations, each running the whole body
+	want := []string{"L", "B1#a", "B2#a", "B1#b", "B2#b", "B1#c", "B2#c", "D", "M"}
+	if !reflect.DeepEqual(fake.calls, want) {
+		t.Errorf("calls = %v, want %v", fake.calls, want)
+	}
+	expectStatuses(t, nodeStatuses(t, env), map[string]string{
+		"L": "COMPLETED", "B1": "COMPLETED", "B2": "COMPLETED", "D": "COMPLETED", "M": "COMPLETED",
+	})
+
+	// Each iteration sees its own item through the loop's step output
+	loopState, _ := fake.inputs["B1#b"]["L"].(map[string]interface{})
+	if loopState["item"] != "b" {
+		t.Errorf("steps.L inside iteration b = %v", loopState)
+	}
+
+	var result map[string]map[string]interface{}
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatal(err)
+	}
+	results, _ := result["L"]["results"].([]interface{})
+	if len(results) != 3 {
+		t.Fatalf("loop results = %v, want one per item", result["L"]["results"])
+	}
+	first, _ := results[0].(map[string]interface{})
+	if b1, _ := first["B1"].(map[string]interface{}); b1["ran"] != "B1#a" {
+		t.Errorf("results[0] = %v, want the outputs of iteration a", first)
+	}
+}
+
+func TestLoopWithoutItemsSkipsBody(t *testing.T) {
+	env, fake := runFlow(t, loopFlow(map[string]interface{}{"items": []interface{}{}}, nil), nil)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if fake.ran("B1") || fake.ran("B2") {
+		t.Errorf("body ran without items: %v", fake.calls)
+	}
+	// M waits on B2, which never ran
+	expectStatuses(t, nodeStatuses(t, env), map[string]string{
+		"L": "COMPLETED", "B1": "SKIPPED", "B2": "SKIPPED", "D": "COMPLETED", "M": "SKIPPED",
+	})
+}
+
+func TestSequentialLoopStopsAtFailedItem(t *testing.T) {
+	env, fake := runFlow(t, loopFlow(
+		map[string]interface{}{"items": []interface{}{"a", "b", "c"}},
+		map[string]interface{}{"fail": "b"},
+	), nil)
+
+	err := env.GetWorkflowError()
+	if err == nil || !strings.Contains(err.Error(), "loop L failed at item 1") {
+		t.Fatalf("workflow error = %v, want the loop to fail at item 1", err)
+	}
+	for _, call := range []string{"B2#b", "B1#c", "D", "M"} {
+		if fake.ran(call) {
+			t.Errorf("%s ran after the failed iteration: %v", call, fake.calls)
+		}
+	}
+	if !fake.ran("B2#a") {
+		t.Errorf("iteration a didn't finish: %v", fake.calls)
+	}
+	expectStatuses(t, nodeStatuses(t, env), map[string]string{"L": "FAILED", "D": "PENDING", "M": "PENDING"})
+}
+
+func TestParallelLoopFailureCancelsOtherIterations(t *testing.T) {
+	env, fake := runFlow(t, loopFlow(
+		map[string]interface{}{"items": []interface{}{"slow", "bad", "late"}, "mode": "parallel", "concurrency": 2.0},
+		map[string]interface{}{"block": "slow", "fail": "bad"},
+	), nil)
+
+	err := env.GetWorkflowError()
+	if err == nil || !strings.Contains(err.Error(), "loop L failed at item 1") {
+		t.Fatalf("workflow error = %v, want the loop to fail at item 1", err)
+	}
+	// The failure stops new iterations from starting
+	if fake.ran("B1#late") {
+		t.Errorf("an iteration started after the failure: %v", fake.calls)
+	}
+	if fake.canceled != 1 {
+		t.Errorf("%d activities canceled, want the one of the in-flight iteration", fake.canceled)
+	}
+	if fake.ran("B2#slow") || fake.ran("D") {
+		t.Errorf("the canceled iteration or the done branch went on: %v", fake.calls)
+	}
+	expectStatuses(t, nodeStatuses(t, env), map[string]string{"L": "FAILED", "D": "PENDING"})
+}
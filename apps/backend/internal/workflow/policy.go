@@ -0,0 +1,202 @@
+package workflow
+
+import (
+	"errors"
+	"time"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/nodes"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// On-error behaviours for a node whose activity ultimately fails (after retries)
+const (
+	OnErrorFail     = "fail"     // fail the whole run (default)
+	OnErrorContinue = "continue" // record the error as the node's output and carry on
+	OnErrorRoute    = "route"    // only follow edges leaving the node's "error" handle
+)
+
+// ErrorHandle is the source handle used by OnErrorRoute.
+const ErrorHandle = "error"
+
+// Upper bounds so a single node can't pin a worker or a run forever
+const (
+	maxNodeTimeout     = 24 * time.Hour
+	maxNodeAttempts    = 20
+	maxRetryInterval   = 1 * time.Hour
+	defaultNodeTimeout = 1 * time.Minute
+	// httpTimeoutMargin covers what an HTTP node does besides its requests (token
+	// fetches, artifact uploads) when its timeout is derived from its settings
+	httpTimeoutMargin = 30 * time.Second
+)
+
+// NodePolicy is the per-node execution policy, configured under node.Data["policy"]:
+//
+//	"policy": {
+//	  "timeoutSeconds": 30,
+//	  "retry": {
+//	    "maxAttempts": 3,
+//	    "initialIntervalSeconds": 1,
+//	    "backoffCoefficient": 2,
+//	    "maximumIntervalSeconds": 60,
+//	    "nonRetryableErrors": ["HTTPServerError"]
+//	  },
+//	  "onError": "fail" | "continue" | "route"
+//	}
+//
+// Anything left out falls back to defaultActivityOptions, except the timeout of an HTTP
+// node, which is derived from its own timeout, rate limit and pagination settings.
+type NodePolicy struct {
+	Timeout            time.Duration
+	MaxAttempts        int32
+	InitialInterval    time.Duration
+	BackoffCoefficient float64
+	MaximumInterval    time.Duration
+	NonRetryableErrors []string
+	OnError            string
+}
+
+// defaultActivityOptions apply to every activity unless a node's policy overrides them.
+func defaultActivityOptions() workflow.ActivityOptions {
+	return workflow.ActivityOptions{
+		StartToCloseTimeout: defaultNodeTimeout,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    1 * time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    1 * time.Minute,
+			MaximumAttempts:    5, // Fail after 5 attempts to save resources
+		},
+	}
+}
+
+// parseNodePolicy reads the "policy" block of a node's data. Invalid values are ignored.
+func parseNodePolicy(data map[string]interface{}) NodePolicy {
+	policy := NodePolicy{OnError: OnErrorFail}
+
+	raw, _ := data["policy"].(map[string]interface{})
+
+	if s, ok := raw["timeoutSeconds"].(float64); ok && s > 0 {
+		policy.Timeout = clampDuration(seconds(s), maxNodeTimeout)
+	} else if isHTTPNode(data) {
+		if budget := nodes.HTTPTimeBudget(data) + httpTimeoutMargin; budget > defaultNodeTimeout {
+			policy.Timeout = clampDuration(budget, maxNodeTimeout)
+		}
+	}
+
+	if retry, ok := raw["retry"].(map[string]interface{}); ok {
+		if n, ok := retry["maxAttempts"].(float64); ok && n >= 1 {
+			policy.MaxAttempts = int32(n)
+			if policy.MaxAttempts > maxNodeAttempts {
+				policy.MaxAttempts = maxNodeAttempts
+			}
+		}
+		if s, ok := retry["initialIntervalSeconds"].(float64); ok && s > 0 {
+			policy.InitialInterval = clampDuration(seconds(s), maxRetryInterval)
+		}
+		if c, ok := retry["backoffCoefficient"].(float64); ok && c >= 1 {
+			policy.BackoffCoefficient = c
+		}
+		if s, ok := retry["maximumIntervalSeconds"].(float64); ok && s > 0 {
+			policy.MaximumInterval = clampDuration(seconds(s), maxRetryInterval)
+		}
+		if list, ok := retry["nonRetryableErrors"].([]interface{}); ok {
+			for _, item := range list {
+				if s, ok := item.(string); ok && s != "" {
+					policy.NonRetryableErrors = append(policy.NonRetryableErrors, s)
+				}
+			}
+		}
+	}
+
+	switch onError, _ := raw["onError"].(string); onError {
+	case OnErrorContinue, OnErrorRoute:
+		policy.OnError = onError
+	}
+
+	return policy
+}
+
+// ActivityOptions applies the policy on top of base.
+func (p NodePolicy) ActivityOptions(base workflow.ActivityOptions) workflow.ActivityOptions {
+	opts := base
+	retry := temporal.RetryPolicy{}
+	if base.RetryPolicy != nil {
+		retry = *base.RetryPolicy
+	}
+
+	if p.Timeout > 0 {
+		opts.StartToCloseTimeout = p.Timeout
+	}
+	if p.MaxAttempts > 0 {
+		retry.MaximumAttempts = p.MaxAttempts
+	}
+	if p.InitialInterval > 0 {
+		retry.InitialInterval = p.InitialInterval
+	}
+	if p.BackoffCoefficient > 0 {
+		retry.BackoffCoefficient = p.BackoffCoefficient
+	}
+	if p.MaximumInterval > 0 {
+		retry.MaximumInterval = p.MaximumInterval
+	}
+	if retry.MaximumInterval < retry.InitialInterval {
+		retry.MaximumInterval = retry.InitialInterval
+	}
+	retry.NonRetryableErrorTypes = append(append([]string{}, retry.NonRetryableErrorTypes...), p.NonRetryableErrors...)
+
+	opts.RetryPolicy = &retry
+	return opts
+}
+
+// errorOutput describes a node failure as step output, so that later nodes can read
+// {{ steps.<id>.error.message }} when the node is set to continue or route on error.
+func errorOutput(nodeID string, err error) map[string]interface{} {
+	details := map[string]interface{}{
+		"node_id": nodeID,
+		"message": err.Error(),
+		"type":    nodes.ErrorTypeNodeFailed,
+	}
+
+	var appErr *temporal.ApplicationError
+	var timeoutErr *temporal.TimeoutError
+	switch {
+	case errors.As(err, &timeoutErr):
+		details["type"] = "Timeout"
+	case errors.As(err, &appErr):
+		details["message"] = appErr.Message()
+		if appErr.Type() != "" {
+			details["type"] = appErr.Type()
+		}
+		var output map[string]interface{}
+		if appErr.HasDetails() && appErr.Details(&output) == nil && output != nil {
+			details["output"] = output
+		}
+	}
+
+	return map[string]interface{}{
+		"error":   details,
+		"_failed": true,
+	}
+}
+
+// isHTTPNode tells whether a node's executor is the HTTP node, aliases included.
+func isHTTPNode(data map[string]interface{}) bool {
+	nodeType, _ := data["type"].(string)
+	executor, err := nodes.GetExecutor(nodeType, data)
+	if err != nil {
+		return false
+	}
+	_, ok := executor.(*nodes.HttpNode)
+	return ok
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func clampDuration(d, max time.Duration) time.Duration {
+	if d > max {
+		return max
+	}
+	return d
+}
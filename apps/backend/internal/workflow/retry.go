@@ -0,0 +1,103 @@
+package workflow
+
+// retryKey carries what a retried run reuses from the previous run in its input.
+const retryKey = "__retry"
+
+// retrySeed is the part of a previous run that a retry starts from.
+type retrySeed struct {
+	RunID    string
+	FromNode string
+	// Outputs of the nodes that completed in the previous run, keyed by node ID
+	Completed map[string]map[string]interface{}
+}
+
+// RetryInput builds the input of a run of flow that retries runID. nodeStatus and outputs
+// are the previous run's StateQuery result and input its (possibly edited) trigger payload.
+// Nodes that completed are reused instead of running again; fromNode, when set, runs again
+// along with everything downstream of it.
+func RetryInput(flow FlowDefinition, runID, fromNode string, input, nodeStatus, outputs map[string]interface{}) map[string]interface{} {
+	retryInput := make(map[string]interface{}, len(input)+2)
+	for k, v := range input {
+		retryInput[k] = v
+	}
+
+	completed := make(map[string]interface{})
+	for _, n := range flow.Nodes {
+		if status, _ := nodeStatus[n.ID].(string); status != "COMPLETED" {
+			continue
+		}
+		if isTriggerType(n.Type) {
+			// Start from the same trigger as the previous run
+			retryInput[triggerNodeKey] = n.ID
+			continue
+		}
+		if out, ok := outputs[n.ID]; ok {
+			completed[n.ID] = out
+		}
+	}
+
+	retryInput[retryKey] = map[string]interface{}{
+		"run_id":    runID,
+		"from_node": fromNode,
+		"completed": completed,
+	}
+	return retryInput
+}
+
+// popRetrySeed removes the retry info from a run's input. It returns nil for regular runs.
+func popRetrySeed(inputData map[string]interface{}) *retrySeed {
+	raw, ok := inputData[retryKey].(map[string]interface{})
+	delete(inputData, retryKey)
+	if !ok {
+		return nil
+	}
+	seed := &retrySeed{Completed: make(map[string]map[string]interface{})}
+	seed.RunID, _ = raw["run_id"].(string)
+	seed.FromNode, _ = raw["from_node"].(string)
+	if completed, ok := raw["completed"].(map[string]interface{}); ok {
+		for id, out := range completed {
+			if outMap, ok := out.(map[string]interface{}); ok {
+				seed.Completed[id] = outMap
+			}
+		}
+	}
+	return seed
+}
+
+// applyRetrySeed restores the completed nodes of the previous run into scope and returns
+// the nodes the retry starts from: seed.FromNode, plus the failed (or otherwise unfinished)
+// nodes whose joins are settled by the completed nodes, to run or to be skipped.
+func (e *flowEngine) applyRetrySeed(scope *executionScope, seed *retrySeed, triggerNodeID string) []string {
+	// 1. Reuse completed outputs. Triggers keep the (possibly edited) input of this run.
+	for id, out := range seed.Completed {
+		node, ok := e.nodesLookup[id]
+		if !ok || isTriggerType(node.Type) {
+			continue
+		}
+		scope.executionState[id] = out
+		scope.nodeStatus[id] = "COMPLETED"
+	}
+	scope.nodeStatus[triggerNodeID] = "COMPLETED"
+
+	// 2. The chosen node and everything after it run again
+	if seed.FromNode != "" {
+		e.resetDownstreamNodes(scope, seed.FromNode, make(map[string]bool))
+	}
+	for _, n := range e.flow.Nodes {
+		if scope.nodeStatus[n.ID] == "PENDING" && !isTriggerType(n.Type) {
+			delete(scope.executionState, n.ID)
+		}
+	}
+
+	// 3. Start from the frontier (in definition order, for deterministic replays)
+	var start []string
+	for _, n := range e.flow.Nodes {
+		if scope.nodeStatus[n.ID] != "PENDING" || isTriggerType(n.Type) {
+			continue
+		}
+		if n.ID == seed.FromNode || (len(e.incomingEdges[n.ID]) > 0 && e.joinDecision(scope, n) != joinWait) {
+			start = append(start, n.ID)
+		}
+	}
+	return start
+}
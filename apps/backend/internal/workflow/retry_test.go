@@ -0,0 +1,140 @@
+package workflow
+
+import (
+	"reflect"
+	"testing"
+)
+
+// retryFlow: T -> C (condition, true), C -true-> A -> B -> D, C -false-> X
+func retryFlow() FlowDefinition {
+	return FlowDefinition{
+		ID: "retry-flow",
+		Nodes: []Node{
+			triggerNode("T"),
+			testNode("C", "condition", map[string]interface{}{
+				"condition": map[string]interface{}{"left": "1", "operator": "==", "right": "1"},
+			}),
+			stepNode("A", map[string]interface{}{"output": map[string]interface{}{"id": "fresh"}}),
+			stepNode("B", nil),
+			stepNode("D", nil),
+			stepNode("X", nil),
+		},
+		Edges: []Edge{
+			testEdge("T", "C"),
+			testEdge("C", "A", "true"),
+			testEdge("A", "B"),
+			testEdge("B", "D"),
+			testEdge("C", "X", "false"),
+		},
+	}
+}
+
+// failedRun is the StateQuery result of a run of retryFlow that failed at B.
+func failedRun() (nodeStatus, outputs map[string]interface{}) {
+	nodeStatus = map[string]interface{}{
+		"T": "COMPLETED", "C": "COMPLETED", "A": "COMPLETED", "B": "FAILED", "D": "PENDING", "X": "SKIPPED",
+	}
+	outputs = map[string]interface{}{
+		"T": map[string]interface{}{"order": 1.0},
+		"C": map[string]interface{}{"result": true, "output": map[string]interface{}{"result": true}},
+		"A": map[string]interface{}{"id": "from-run-1", "output": map[string]interface{}{"id": "from-run-1"}},
+	}
+	return nodeStatus, outputs
+}
+
+func TestRetryInput(t *testing.T) {
+	nodeStatus, outputs := failedRun()
+	input := RetryInput(retryFlow(), "run-1", "", map[string]interface{}{"order": 2.0}, nodeStatus, outputs)
+
+	if input["order"] != 2.0 || input[triggerNodeKey] != "T" {
+		t.Errorf("input = %v, want the new payload and the previous trigger", input)
+	}
+	seed := popRetrySeed(input)
+	if seed == nil || seed.RunID != "run-1" || seed.FromNode != "" {
+		t.Fatalf("seed = %+v", seed)
+	}
+	if _, ok := input[retryKey]; ok {
+		t.Error("popRetrySeed left the retry info in the input")
+	}
+	// Triggers aren't seeded: the retry runs them with its own input
+	want := map[string]map[string]interface{}{"C": outputs["C"].(map[string]interface{}), "A": outputs["A"].(map[string]interface{})}
+	if !reflect.DeepEqual(seed.Completed, want) {
+		t.Errorf("completed = %v, want %v", seed.Completed, want)
+	}
+
+	if popRetrySeed(map[string]interface{}{"order": 1.0}) != nil {
+		t.Error("a regular run has a retry seed")
+	}
+}
+
+func TestRetryResumesFromFailedNode(t *testing.T) {
+	nodeStatus, outputs := failedRun()
+	input := RetryInput(retryFlow(), "run-1", "", map[string]interface{}{"order": 2.0}, nodeStatus, outputs)
+	env, fake := runFlow(t, retryFlow(), input)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	if want := []string{"B", "D"}; !reflect.DeepEqual(fake.calls, want) {
+		t.Errorf("calls = %v, want only the failed node and what follows", fake.calls)
+	}
+	// B reads the outputs the previous run produced
+	a, _ := fake.inputs["B"]["A"].(map[string]interface{})
+	if a["id"] != "from-run-1" {
+		t.Errorf("steps.A seen by B = %v, want the seeded output", a)
+	}
+	trigger, _ := fake.inputs["B"]["T"].(map[string]interface{})
+	if trigger["order"] != 2.0 {
+		t.Errorf("steps.T seen by B = %v, want the retry's input", trigger)
+	}
+	expectStatuses(t, nodeStatuses(t, env), map[string]string{
+		"T": "COMPLETED", "C": "COMPLETED", "A": "COMPLETED", "B": "COMPLETED", "D": "COMPLETED", "X": "SKIPPED",
+	})
+}
+
+func TestRetryFromChosenNode(t *testing.T) {
+	nodeStatus, outputs := failedRun()
+	input := RetryInput(retryFlow(), "run-1", "A", map[string]interface{}{"order": 2.0}, nodeStatus, outputs)
+	env, fake := runFlow(t, retryFlow(), input)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	if want := []string{"A", "B", "D"}; !reflect.DeepEqual(fake.calls, want) {
+		t.Errorf("calls = %v, want the chosen node and everything after it", fake.calls)
+	}
+	a, _ := fake.inputs["B"]["A"].(map[string]interface{})
+	if a["id"] != "fresh" {
+		t.Errorf("steps.A seen by B = %v, want the output of the new attempt", a)
+	}
+	if fake.ran("C") || fake.ran("X") {
+		t.Errorf("nodes before the chosen one ran again: %v", fake.calls)
+	}
+}
+
+func TestRetrySeedFrontier(t *testing.T) {
+	flow := retryFlow()
+	e := newFlowEngine(flow, nil)
+	nodeStatus, outputs := failedRun()
+	input := RetryInput(flow, "run-1", "", map[string]interface{}{}, nodeStatus, outputs)
+	seed := popRetrySeed(input)
+
+	scope := &executionScope{
+		nodeStatus:     map[string]string{},
+		executionState: map[string]map[string]interface{}{},
+	}
+	for _, n := range flow.Nodes {
+		scope.nodeStatus[n.ID] = "PENDING"
+	}
+	start := e.applyRetrySeed(scope, seed, "T")
+
+	// B runs again; X, on the branch C didn't take, is settled (it will be skipped);
+	// D still waits on B
+	if want := []string{"B", "X"}; !reflect.DeepEqual(start, want) {
+		t.Errorf("start = %v, want %v", start, want)
+	}
+	expectStatuses(t, scope.nodeStatus, map[string]string{"T": "COMPLETED", "C": "COMPLETED", "A": "COMPLETED", "B": "PENDING"})
+	if _, ok := scope.executionState["B"]; ok {
+		t.Error("the failed node kept an output")
+	}
+}
@@ -0,0 +1,196 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/workflow"
+)
+
+// ScheduleTriggerType is the node type that starts a published flow on a cron schedule.
+const ScheduleTriggerType = "schedule-trigger"
+
+// Input keys set on scheduled runs; NodalWorkflow fills in the actual fire time.
+const (
+	scheduledRunKey  = "__scheduled"
+	triggerNodeKey   = "__trigger_node"
+	scheduledTimeKey = "fired_at"
+)
+
+// ScheduleTrigger is the config of one schedule-trigger node:
+//
+//	{ "type": "schedule-trigger", "cron": "0 2 * * *", "timezone": "Europe/Paris" }
+type ScheduleTrigger struct {
+	NodeID   string `json:"node_id"`
+	Cron     string `json:"cron"`
+	Timezone string `json:"timezone"`
+}
+
+// ScheduleID is the Temporal schedule ID for a trigger node of a flow.
+func ScheduleID(flowID, nodeID string) string {
+	return "flow-" + flowID + "-" + nodeID
+}
+
+// ScheduleTriggers returns the schedule-trigger nodes of a flow, validating their config.
+func ScheduleTriggers(flow FlowDefinition) ([]ScheduleTrigger, error) {
+	var triggers []ScheduleTrigger
+	for _, n := range flow.Nodes {
+		if n.Type != ScheduleTriggerType {
+			continue
+		}
+		cron, _ := n.Data["cron"].(string)
+		cron = strings.TrimSpace(cron)
+		if cron == "" {
+			return nil, fmt.Errorf("schedule trigger %s: cron expression is required", n.ID)
+		}
+		if !strings.HasPrefix(cron, "@") {
+			if fields := len(strings.Fields(cron)); fields < 5 || fields > 7 {
+				return nil, fmt.Errorf("schedule trigger %s: invalid cron expression %q", n.ID, cron)
+			}
+		}
+		tz, _ := n.Data["timezone"].(string)
+		if tz == "" {
+			tz = "UTC"
+		}
+		if _, err := time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("schedule trigger %s: unknown timezone %q", n.ID, tz)
+		}
+		triggers = append(triggers, ScheduleTrigger{NodeID: n.ID, Cron: cron, Timezone: tz})
+	}
+	return triggers, nil
+}
+
+// SyncFlowSchedules registers (or updates) a Temporal schedule for every schedule-trigger
+// in flow, and removes schedules of triggers that only exist in previous.
+// flow must carry its ID and OrgID. Updating keeps the paused/running state of a schedule.
+func SyncFlowSchedules(ctx context.Context, c client.Client, flow FlowDefinition, previous *FlowDefinition) error {
+	triggers, err := ScheduleTriggers(flow)
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool)
+	for _, t := range triggers {
+		keep[t.NodeID] = true
+		if err := upsertSchedule(ctx, c, flow, t); err != nil {
+			return err
+		}
+	}
+
+	if previous != nil {
+		for _, n := range previous.Nodes {
+			if n.Type == ScheduleTriggerType && !keep[n.ID] {
+				if err := deleteSchedule(ctx, c, ScheduleID(flow.ID, n.ID)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteFlowSchedules removes the schedules of every schedule-trigger in flow.
+func DeleteFlowSchedules(ctx context.Context, c client.Client, flow FlowDefinition) error {
+	for _, n := range flow.Nodes {
+		if n.Type == ScheduleTriggerType {
+			if err := deleteSchedule(ctx, c, ScheduleID(flow.ID, n.ID)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func scheduleAction(flow FlowDefinition, t ScheduleTrigger) *client.ScheduleWorkflowAction {
+	return &client.ScheduleWorkflowAction{
+		// Temporal appends the fire time to this ID for every run
+		ID:        "flow-" + flow.ID + "-scheduled",
+		Workflow:  NodalWorkflow,
+		TaskQueue: TaskQueue,
+		Args: []interface{}{flow, map[string]interface{}{
+			scheduledRunKey: true,
+			triggerNodeKey:  t.NodeID,
+			"schedule_id":   ScheduleID(flow.ID, t.NodeID),
+			"cron":          t.Cron,
+			"timezone":      t.Timezone,
+		}},
+	}
+}
+
+func upsertSchedule(ctx context.Context, c client.Client, flow FlowDefinition, t ScheduleTrigger) error {
+	id := ScheduleID(flow.ID, t.NodeID)
+	spec := client.ScheduleSpec{
+		CronExpressions: []string{t.Cron},
+		TimeZoneName:    t.Timezone,
+	}
+
+	handle := c.ScheduleClient().GetHandle(ctx, id)
+	_, err := handle.Describe(ctx)
+	if err == nil {
+		err = handle.Update(ctx, client.ScheduleUpdateOptions{
+			DoUpdate: func(input client.ScheduleUpdateInput) (*client.ScheduleUpdate, error) {
+				schedule := input.Description.Schedule
+				schedule.Spec = &spec
+				schedule.Action = scheduleAction(flow, t)
+				return &client.ScheduleUpdate{Schedule: &schedule}, nil
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update schedule %s: %w", id, err)
+		}
+		return nil
+	}
+
+	var notFound *serviceerror.NotFound
+	if !errors.As(err, &notFound) {
+		return fmt.Errorf("failed to look up schedule %s: %w", id, err)
+	}
+
+	_, err = c.ScheduleClient().Create(ctx, client.ScheduleOptions{
+		ID:     id,
+		Spec:   spec,
+		Action: scheduleAction(flow, t),
+		// A nightly job that is still running shouldn't be started a second time
+		Overlap: enumspb.SCHEDULE_OVERLAP_POLICY_SKIP,
+		Memo: map[string]interface{}{
+			"flow_id": flow.ID,
+			"org_id":  flow.OrgID,
+			"node_id": t.NodeID,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create schedule %s: %w", id, err)
+	}
+	return nil
+}
+
+func deleteSchedule(ctx context.Context, c client.Client, id string) error {
+	err := c.ScheduleClient().GetHandle(ctx, id).Delete(ctx)
+	var notFound *serviceerror.NotFound
+	if err != nil && !errors.As(err, &notFound) {
+		return fmt.Errorf("failed to delete schedule %s: %w", id, err)
+	}
+	return nil
+}
+
+// scheduledStartTime returns the fire time of a run started by a Temporal schedule
+// (the TemporalScheduledStartTime search attribute), or the workflow start time.
+func scheduledStartTime(ctx workflow.Context) time.Time {
+	info := workflow.GetInfo(ctx)
+	if info.SearchAttributes != nil {
+		if payload, ok := info.SearchAttributes.GetIndexedFields()["TemporalScheduledStartTime"]; ok {
+			var t time.Time
+			if err := converter.GetDefaultDataConverter().FromPayload(payload, &t); err == nil && !t.IsZero() {
+				return t
+			}
+		}
+	}
+	return workflow.Now(ctx)
+}
@@ -0,0 +1,132 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/nodes"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// maxSubflowDepth limits how deeply sub-flows may call each other (a flow calling itself included).
+const maxSubflowDepth = 5
+
+// subflowKey carries the calling run in the input of a sub-flow run.
+const subflowKey = "__subflow"
+
+// subflowParent identifies the run and SUBFLOW node that started a sub-flow.
+type subflowParent struct {
+	Depth      int
+	FlowID     string
+	WorkflowID string
+	RunID      string
+	NodeID     string
+}
+
+// popSubflowParent removes the caller info from a run's input. It returns nil for top-level runs.
+func popSubflowParent(inputData map[string]interface{}) *subflowParent {
+	raw, ok := inputData[subflowKey].(map[string]interface{})
+	delete(inputData, subflowKey)
+	if !ok {
+		return nil
+	}
+	parent := &subflowParent{}
+	if d, ok := raw["depth"].(float64); ok {
+		parent.Depth = int(d)
+	}
+	parent.FlowID, _ = raw["flow_id"].(string)
+	parent.WorkflowID, _ = raw["workflow_id"].(string)
+	parent.RunID, _ = raw["run_id"].(string)
+	parent.NodeID, _ = raw["node_id"].(string)
+	return parent
+}
+
+// runSubflow starts the flow resolved by a SUBFLOW node as a child workflow.
+// In "wait" mode it returns the child's final executionState (plus a "subflow" summary);
+// in "async" mode it returns as soon as the child has started.
+func (e *flowEngine) runSubflow(ctx workflow.Context, node Node, call map[string]interface{}) (map[string]interface{}, error) {
+	logger := workflow.GetLogger(ctx)
+
+	depth := e.subflowDepth + 1
+	if depth > maxSubflowDepth {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("sub-flow depth limit (%d) exceeded — check for flows calling each other", maxSubflowDepth), nodes.ErrorTypeConfig, nil)
+	}
+
+	var child FlowDefinition
+	raw, err := json.Marshal(call["definition"])
+	if err == nil {
+		err = json.Unmarshal(raw, &child)
+	}
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError("invalid sub-flow definition: "+err.Error(), nodes.ErrorTypeConfig, nil)
+	}
+	child.ID, _ = call["flow_id"].(string)
+	child.VersionID, _ = call["version_id"].(string)
+	// Same org by construction (SubflowNode checks it); empty for test runs, which keeps
+	// the child out of action_flows just like its parent
+	child.OrgID = e.flow.OrgID
+
+	mode, _ := call["mode"].(string)
+	childInput, _ := call["input"].(map[string]interface{})
+	if childInput == nil {
+		childInput = make(map[string]interface{})
+	}
+	info := workflow.GetInfo(ctx)
+	childInput[subflowKey] = map[string]interface{}{
+		"depth":       depth,
+		"flow_id":     e.flow.ID,
+		"workflow_id": info.WorkflowExecution.ID,
+		"run_id":      info.WorkflowExecution.RunID,
+		"node_id":     node.ID,
+	}
+
+	// A LOOP can call the same SUBFLOW node many times in one run
+	e.subflowCount++
+	options := workflow.ChildWorkflowOptions{
+		WorkflowID:        fmt.Sprintf("%s-sub-%s-%d", info.WorkflowExecution.ID, node.ID, e.subflowCount),
+		TaskQueue:         TaskQueue,
+		ParentClosePolicy: enumspb.PARENT_CLOSE_POLICY_TERMINATE,
+	}
+	if mode == nodes.SubflowModeAsync {
+		// Fire-and-forget children outlive the caller
+		options.ParentClosePolicy = enumspb.PARENT_CLOSE_POLICY_ABANDON
+	}
+
+	future := workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, options), NodalWorkflow, child, childInput)
+
+	var execution workflow.Execution
+	if err := future.GetChildWorkflowExecution().Get(ctx, &execution); err != nil {
+		return nil, fmt.Errorf("failed to start sub-flow %s: %w", child.ID, err)
+	}
+	logger.Info("Sub-flow started", "ID", node.ID, "FlowID", child.ID, "ChildWorkflowID", execution.ID, "Depth", depth)
+
+	summary := map[string]interface{}{
+		"flow_id":     child.ID,
+		"flow_name":   call["flow_name"],
+		"version_id":  child.VersionID,
+		"workflow_id": execution.ID,
+		"run_id":      execution.RunID,
+		"mode":        mode,
+	}
+
+	if mode == nodes.SubflowModeAsync {
+		summary["status"] = "STARTED"
+		return map[string]interface{}{"subflow": summary}, nil
+	}
+
+	var childState map[string]interface{}
+	if err := future.Get(ctx, &childState); err != nil {
+		return nil, fmt.Errorf("sub-flow %s failed: %w", child.ID, err)
+	}
+
+	output := make(map[string]interface{}, len(childState)+1)
+	for k, v := range childState {
+		output[k] = v
+	}
+	summary["status"] = "COMPLETED"
+	output["subflow"] = summary
+	return output, nil
+}
@@ -6,11 +6,13 @@ import (
 
 	"github.com/teavana/enigmatic_s/apps/backend/internal/audit"
 	"github.com/teavana/enigmatic_s/apps/backend/internal/database"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/events"
 )
 
 type RecordActionFlowParams struct {
 	FlowID      string                   `json:"flow_id"`
 	OrgID       string                   `json:"org_id"` // Added OrgID
+	VersionID   string                   `json:"version_id"`
 	WorkflowID  string                   `json:"workflow_id"`
 	RunID       string                   `json:"run_id"`
 	InputData   map[string]interface{}   `json:"input_data"`
@@ -19,6 +21,14 @@ type RecordActionFlowParams struct {
 	Priority    string                   `json:"priority"`    // Added Priority
 	Assignments []map[string]interface{} `json:"assignments"` // Changed to interface{}
 	InfoFields  []map[string]string      `json:"info_fields"`
+
+	// Set for sub-flow runs: the calling run and its SUBFLOW node
+	ParentRunID  string `json:"parent_run_id"`
+	ParentNodeID string `json:"parent_node_id"`
+
+	// Set for retries: the run being retried and the node it was retried from
+	RetryOfRunID  string `json:"retry_of_run_id"`
+	RetryFromNode string `json:"retry_from_node"`
 }
 
 // RecordActionFlowActivity Inserts a record into the 'action_flows' table
@@ -32,6 +42,39 @@ func RecordActionFlowActivity(ctx context.Context, params RecordActionFlowParams
 	if params.FlowID != "" {
 		flowIDPtr = &params.FlowID
 	}
+	// Runs of published flows are pinned to the version they executed
+	var versionIDPtr *string
+	if params.VersionID != "" {
+		versionIDPtr = &params.VersionID
+	}
+
+	// Sub-flow runs are linked to the action flow of their caller
+	var parentIDPtr, parentNodePtr *string
+	if params.ParentRunID != "" {
+		var parents []struct {
+			ID string `json:"id"`
+		}
+		client.DB.From("action_flows").Select("id").Eq("run_id", params.ParentRunID).Eq("org_id", params.OrgID).Execute(&parents)
+		if len(parents) > 0 {
+			parentIDPtr = &parents[0].ID
+			parentNodePtr = &params.ParentNodeID
+		}
+	}
+
+	// Retries are linked to the action flow they retry
+	var retryOfPtr, retryFromPtr *string
+	if params.RetryOfRunID != "" {
+		var retried []struct {
+			ID string `json:"id"`
+		}
+		client.DB.From("action_flows").Select("id").Eq("run_id", params.RetryOfRunID).Eq("org_id", params.OrgID).Execute(&retried)
+		if len(retried) > 0 {
+			retryOfPtr = &retried[0].ID
+		}
+		if params.RetryFromNode != "" {
+			retryFromPtr = &params.RetryFromNode
+		}
+	}
 
 	// Merge InfoFields into InputData as metadata
 	finalInputData := params.InputData
@@ -64,7 +107,12 @@ func RecordActionFlowActivity(ctx context.Context, params RecordActionFlowParams
 
 	record := struct {
 		FlowID      *string                  `json:"flow_id"`
-		OrgID       string                   `json:"org_id"`               // Added OrgID
+		OrgID       string                   `json:"org_id"` // Added OrgID
+		VersionID   *string                  `json:"version_id,omitempty"`
+		ParentID    *string                  `json:"parent_action_flow_id,omitempty"`
+		ParentNode  *string                  `json:"parent_node_id,omitempty"`
+		RetryOf     *string                  `json:"retry_of_action_flow_id,omitempty"`
+		RetryFrom   *string                  `json:"retry_from_node,omitempty"`
 		TemporalID  string                   `json:"temporal_workflow_id"` // Fixed JSON tag to match DB
 		RunID       string                   `json:"run_id"`
 		Status      string                   `json:"status"`
@@ -76,6 +124,11 @@ func RecordActionFlowActivity(ctx context.Context, params RecordActionFlowParams
 	}{
 		FlowID:      flowIDPtr,
 		OrgID:       params.OrgID,
+		VersionID:   versionIDPtr,
+		ParentID:    parentIDPtr,
+		ParentNode:  parentNodePtr,
+		RetryOf:     retryOfPtr,
+		RetryFrom:   retryFromPtr,
 		TemporalID:  params.WorkflowID,
 		RunID:       params.RunID,
 		Status:      "RUNNING",
@@ -94,6 +147,14 @@ func RecordActionFlowActivity(ctx context.Context, params RecordActionFlowParams
 		return err
 	}
 
+	events.Publish(events.Event{
+		Type:       events.RunStarted,
+		OrgID:      params.OrgID,
+		WorkflowID: params.WorkflowID,
+		RunID:      params.RunID,
+		Data:       map[string]interface{}{"flow_id": params.FlowID, "title": params.Title},
+	})
+
 	// Log Activity: Flow Started
 	audit.LogActivity(ctx, params.OrgID, nil, "flow.started", &params.RunID, map[string]interface{}{
 		"flow_name": params.Title,
@@ -105,6 +166,8 @@ func RecordActionFlowActivity(ctx context.Context, params RecordActionFlowParams
 type UpdateActionFlowStatusParams struct {
 	RunID  string `json:"run_id"`
 	Status string `json:"status"`
+	// NodeStatus is the final status of every node, stored when the run ends
+	NodeStatus map[string]string `json:"node_status,omitempty"`
 }
 
 // UpdateActionFlowStatusActivity Updates the status of an action flow
@@ -115,6 +178,9 @@ func UpdateActionFlowStatusActivity(ctx context.Context, params UpdateActionFlow
 		"status":       params.Status,
 		"completed_at": time.Now(),
 	}
+	if params.NodeStatus != nil {
+		updateData["node_status"] = params.NodeStatus
+	}
 
 	var results []map[string]interface{}
 	err := client.DB.From("action_flows").Update(updateData).Eq("run_id", params.RunID).Execute(&results)
@@ -125,6 +191,14 @@ func UpdateActionFlowStatusActivity(ctx context.Context, params UpdateActionFlow
 	if len(results) > 0 {
 		// Log Activity: Flow Completed
 		orgID, _ := results[0]["org_id"].(string)
+		workflowID, _ := results[0]["temporal_workflow_id"].(string)
+		events.Publish(events.Event{
+			Type:       RunEventType(params.Status),
+			OrgID:      orgID,
+			WorkflowID: workflowID,
+			RunID:      params.RunID,
+			Data:       map[string]interface{}{"status": params.Status},
+		})
 		title, _ := results[0]["title"].(string) // Assuming logic saved title in input_data or elsewhere?
 		// Actually, result might not have title easily if it was in input_data
 		// But let's log what we have.
@@ -136,3 +210,45 @@ func UpdateActionFlowStatusActivity(ctx context.Context, params UpdateActionFlow
 
 	return nil
 }
+
+// CancelPendingWaits marks the open human tasks and automation subscriptions of a run
+// as canceled, so inboxes and webhooks stop offering them.
+func CancelPendingWaits(ctx context.Context, runID string) error {
+	client := database.GetClient()
+
+	var tasks []map[string]interface{}
+	err := client.DB.From("human_tasks").Update(map[string]interface{}{
+		"status":     "CANCELED",
+		"updated_at": time.Now(),
+	}).Eq("run_id", runID).Eq("status", "PENDING").Execute(&tasks)
+	if err != nil {
+		return err
+	}
+
+	var subs []map[string]interface{}
+	return client.DB.From("automation_subscriptions").Update(map[string]interface{}{
+		"status": "canceled",
+	}).Eq("run_id", runID).Eq("status", "active").Execute(&subs)
+}
+
+// CancelPendingWaitsActivity runs CancelPendingWaits when a run is canceled.
+func CancelPendingWaitsActivity(ctx context.Context, runID string) error {
+	return CancelPendingWaits(ctx, runID)
+}
+
+// RunEventType maps an action_flows status to the live event announcing it.
+func RunEventType(status string) string {
+	switch status {
+	case "COMPLETED":
+		return events.RunCompleted
+	case "FAILED":
+		return events.RunFailed
+	case "CANCELED":
+		return events.RunCanceled
+	case "TERMINATED":
+		return events.RunTerminated
+	case "PAUSED":
+		return events.RunPaused
+	}
+	return events.RunResumed
+}
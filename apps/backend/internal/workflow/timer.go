@@ -0,0 +1,73 @@
+package workflow
+
+import (
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// SkipWaitSignal ends the wait of a DELAY / WAIT-UNTIL node early.
+func SkipWaitSignal(nodeID string) string {
+	return "SkipWait-" + nodeID
+}
+
+// runTimer waits for the timer resolved by a DELAY or WAIT-UNTIL node. The wait is a
+// Temporal timer, so it survives worker restarts; SkipWaitSignal cuts it short.
+// Test runs don't wait.
+func (e *flowEngine) runTimer(ctx workflow.Context, node Node, timer map[string]interface{}) (map[string]interface{}, error) {
+	logger := workflow.GetLogger(ctx)
+	now := workflow.Now(ctx)
+
+	var until time.Time
+	if ms, ok := timer["duration_ms"].(float64); ok {
+		until = now.Add(time.Duration(ms) * time.Millisecond)
+	} else if raw, ok := timer["until"].(string); ok {
+		parsed, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid wait time %q: %w", raw, err)
+		}
+		until = parsed
+	} else {
+		return nil, fmt.Errorf("node %s resolved no wait", node.ID)
+	}
+
+	skipped := false
+	if until.After(now) && e.mockData == nil {
+		logger.Info("Waiting on timer", "ID", node.ID, "Until", until)
+		e.waiting[node.ID] = until
+
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		selector := workflow.NewSelector(ctx)
+		selector.AddFuture(workflow.NewTimer(timerCtx, until.Sub(now)), func(f workflow.Future) {})
+		selector.AddReceive(workflow.GetSignalChannel(ctx, SkipWaitSignal(node.ID)), func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, nil)
+			skipped = true
+		})
+		selector.Select(ctx)
+		cancelTimer()
+		delete(e.waiting, node.ID)
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if skipped {
+			logger.Info("Wait skipped", "ID", node.ID)
+		}
+	}
+
+	return map[string]interface{}{
+		"until":     until.UTC().Format(time.RFC3339),
+		"waited_ms": workflow.Now(ctx).Sub(now).Milliseconds(),
+		"skipped":   skipped,
+	}, nil
+}
+
+// waitingUntil lists the nodes waiting on a timer, for the state query.
+func (e *flowEngine) waitingUntil() map[string]string {
+	waiting := make(map[string]string, len(e.waiting))
+	for id, until := range e.waiting {
+		waiting[id] = until.UTC().Format(time.RFC3339)
+	}
+	return waiting
+}
@@ -0,0 +1,533 @@
+package workflow
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/compare"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/nodes"
+	"github.com/teavana/enigmatic_s/apps/backend/internal/webhook"
+)
+
+// Diagnostic severities. Errors block publishing; warnings are shown in the editor only.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Diagnostic is one problem found in a flow definition.
+// NodeID / EdgeID point the editor at what to highlight (both empty for flow-level issues).
+type Diagnostic struct {
+	NodeID   string `json:"node_id,omitempty"`
+	EdgeID   string `json:"edge_id,omitempty"`
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+// HasErrors reports whether any diagnostic has error severity.
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateFlow statically checks a flow definition for problems that would otherwise
+// only surface at runtime: missing triggers, dangling edges and GOTO targets, unknown
+// node types, branch handles that can never fire, cycles and expressions that read
+// steps which don't exist.
+func ValidateFlow(flow FlowDefinition) []Diagnostic {
+	v := &flowValidator{diags: []Diagnostic{}, nodes: make(map[string]Node)}
+
+	if len(flow.Nodes) == 0 {
+		v.add("", "", SeverityError, "empty_flow", "Flow has no nodes")
+		return v.diags
+	}
+
+	// Index nodes, dropping duplicates so the graph checks below stay meaningful
+	var unique []Node
+	for _, n := range flow.Nodes {
+		if n.ID == "" {
+			v.add("", "", SeverityError, "missing_node_id", fmt.Sprintf("A %s node has no ID", n.Type))
+			continue
+		}
+		if _, dup := v.nodes[n.ID]; dup {
+			v.add(n.ID, "", SeverityError, "duplicate_node_id", fmt.Sprintf("Node ID %q is used more than once", n.ID))
+			continue
+		}
+		v.nodes[n.ID] = n
+		unique = append(unique, n)
+	}
+
+	// Edges must connect existing nodes; only those take part in the graph checks
+	outgoing := make(map[string][]Edge)
+	incoming := make(map[string][]Edge)
+	var edges []Edge
+	for _, e := range flow.Edges {
+		valid := true
+		if _, ok := v.nodes[e.Source]; !ok {
+			v.add("", e.ID, SeverityError, "unknown_edge_source", fmt.Sprintf("Edge %s starts at unknown node %q", e.ID, e.Source))
+			valid = false
+		}
+		if _, ok := v.nodes[e.Target]; !ok {
+			v.add(e.Source, e.ID, SeverityError, "unknown_edge_target", fmt.Sprintf("Edge %s points to unknown node %q", e.ID, e.Target))
+			valid = false
+		}
+		if valid {
+			edges = append(edges, e)
+			outgoing[e.Source] = append(outgoing[e.Source], e)
+			incoming[e.Target] = append(incoming[e.Target], e)
+		}
+	}
+
+	var triggers []string
+	gotoTargets := make(map[string]bool)
+	for _, n := range unique {
+		if isTriggerType(n.Type) {
+			triggers = append(triggers, n.ID)
+			if n.Type == ScheduleTriggerType {
+				if _, err := ScheduleTriggers(FlowDefinition{Nodes: []Node{n}}); err != nil {
+					v.add(n.ID, "", SeverityError, "invalid_schedule", err.Error())
+				}
+			}
+			if n.Type == WebhookTriggerType {
+				if parsed, err := WebhookTriggers(FlowDefinition{Nodes: []Node{n}}); err != nil {
+					v.add(n.ID, "", SeverityError, "invalid_webhook_trigger", err.Error())
+				} else if parsed[0].Signature == nil {
+					v.add(n.ID, "", SeverityWarning, "unsigned_webhook", "Webhook trigger doesn't verify signatures; anyone who has its URL can start the flow")
+				}
+			}
+		} else {
+			v.checkExecutor(n)
+		}
+
+		switch n.Type {
+		case "goto":
+			if target := gotoTarget(n); target == "" {
+				v.add(n.ID, "", SeverityError, "goto_missing_target", "GOTO node has no target")
+			} else if _, ok := v.nodes[target]; !ok {
+				v.add(n.ID, "", SeverityError, "goto_unknown_target", fmt.Sprintf("GOTO target %q does not exist", target))
+			} else {
+				gotoTargets[target] = true
+			}
+		case "condition", "if-else":
+			v.checkConditionHandles(n, outgoing[n.ID])
+			if group, ok := n.Data["group"].(map[string]interface{}); ok {
+				for _, problem := range nodes.CheckConditionGroup(group) {
+					v.add(n.ID, "", SeverityError, "invalid_condition_group", "Condition group: "+problem)
+				}
+			} else if cond, ok := n.Data["condition"].(map[string]interface{}); ok {
+				v.checkOperator(n, "Condition", cond["operator"])
+			}
+		case "switch":
+			v.checkSwitchHandles(n, outgoing[n.ID])
+		case "loop":
+			hasBody := false
+			for _, e := range outgoing[n.ID] {
+				if isLoopBodyEdge(e) {
+					hasBody = true
+				}
+			}
+			if !hasBody {
+				v.add(n.ID, "", SeverityWarning, "loop_without_body", "Loop has no steps connected to its item handle")
+			}
+		}
+
+		// Sub-flows can sit on an "action" node, so go by the executor type
+		if dataType, _ := n.Data["type"].(string); strings.EqualFold(dataType, "subflow") {
+			flowID, _ := n.Data["flowId"].(string)
+			if flowID == "" {
+				flowID, _ = n.Data["flow_id"].(string)
+			}
+			if flowID == "" {
+				v.add(n.ID, "", SeverityError, "subflow_missing_flow", "Sub-flow node has no flow selected")
+			}
+		}
+		switch dataType, _ := n.Data["type"].(string); strings.ToLower(dataType) {
+		case "http":
+			for _, problem := range nodes.CheckHTTPConfig(n.Data) {
+				v.add(n.ID, "", SeverityError, "invalid_http_config", "HTTP request: "+problem)
+			}
+		case "automation":
+			if _, err := webhook.ParseSignature(n.Data["signature"]); err != nil {
+				v.add(n.ID, "", SeverityError, "invalid_webhook_signature", "Automation webhook: "+err.Error())
+			}
+		case "delay":
+			if n.Data["duration"] == nil || n.Data["duration"] == "" {
+				v.add(n.ID, "", SeverityError, "delay_missing_duration", "Delay has no duration")
+			}
+		case "wait-until":
+			if n.Data["until"] == nil || n.Data["until"] == "" {
+				v.add(n.ID, "", SeverityError, "wait_missing_until", "Wait Until has no time to wait for")
+			}
+		case "filter":
+			settings, _ := n.Data["settings"].(map[string]interface{})
+			if conditions, ok := settings["conditions"].([]interface{}); ok {
+				for _, c := range conditions {
+					cond, _ := c.(map[string]interface{})
+					v.checkOperator(n, "Filter", cond["operator"])
+				}
+			} else if settings != nil {
+				// Legacy flat settings: one condition
+				v.checkOperator(n, "Filter", settings["operator"])
+			}
+		}
+
+		v.checkErrorRoute(n, outgoing[n.ID])
+		v.checkJoin(n, incoming[n.ID], outgoing, unique)
+		v.checkExpressions(n)
+		v.checkPlaintextCredentials(n)
+	}
+
+	if len(triggers) == 0 {
+		v.add("", "", SeverityError, "no_trigger", "Flow has no trigger node")
+	}
+
+	// The engine waits for every parent to complete, so a cycle of edges never runs.
+	// Repeating steps is done with GOTO or LOOP instead.
+	sorted, err := TopologicalSort(FlowDefinition{Nodes: unique, Edges: edges})
+	if err != nil {
+		ordered := make(map[string]bool)
+		for _, n := range sorted {
+			ordered[n.ID] = true
+		}
+		for _, n := range unique {
+			if !ordered[n.ID] {
+				v.add(n.ID, "", SeverityError, "cycle", "Node is part of (or depends on) a cycle; use a GOTO or LOOP node to repeat steps")
+			}
+		}
+	}
+
+	// Nodes that no trigger (or GOTO) can reach never run
+	if len(triggers) > 0 {
+		starts := append([]string{}, triggers...)
+		for id := range gotoTargets {
+			starts = append(starts, id)
+		}
+		reached := make(map[string]bool)
+		for len(starts) > 0 {
+			id := starts[0]
+			starts = starts[1:]
+			if reached[id] {
+				continue
+			}
+			reached[id] = true
+			for _, e := range outgoing[id] {
+				starts = append(starts, e.Target)
+			}
+		}
+		for _, n := range unique {
+			if !reached[n.ID] {
+				v.add(n.ID, "", SeverityWarning, "unreachable", "Node is not connected to any trigger and will never run")
+			}
+		}
+	}
+
+	sort.SliceStable(v.diags, func(i, j int) bool {
+		return v.diags[i].Severity == SeverityError && v.diags[j].Severity != SeverityError
+	})
+	return v.diags
+}
+
+type flowValidator struct {
+	nodes map[string]Node
+	diags []Diagnostic
+}
+
+func (v *flowValidator) add(nodeID, edgeID, severity, code, message string) {
+	v.diags = append(v.diags, Diagnostic{NodeID: nodeID, EdgeID: edgeID, Severity: severity, Code: code, Message: message})
+}
+
+// checkExecutor resolves the executor the same way NodeExecutionActivity does.
+func (v *flowValidator) checkExecutor(n Node) {
+	nodeType, _ := n.Data["type"].(string)
+	if nodeType == "" {
+		v.add(n.ID, "", SeverityError, "missing_node_type", "Node has no type in its configuration")
+		return
+	}
+	if _, err := nodes.GetExecutor(nodeType, n.Data); err != nil {
+		v.add(n.ID, "", SeverityError, "unknown_node_type", fmt.Sprintf("Unsupported node type %q", nodeType))
+	}
+}
+
+func (v *flowValidator) checkConditionHandles(n Node, out []Edge) {
+	seen := make(map[string]bool)
+	for _, e := range out {
+		if e.SourceHandle == nil || *e.SourceHandle == "" {
+			// Unlabelled edges fire on both outcomes
+			seen["true"], seen["false"] = true, true
+			continue
+		}
+		switch h := *e.SourceHandle; h {
+		case "true", "false", ErrorHandle:
+			seen[h] = true
+		default:
+			v.add(n.ID, e.ID, SeverityError, "unknown_handle", fmt.Sprintf("Condition has no %q output", h))
+		}
+	}
+	if !seen["true"] && !seen["false"] {
+		v.add(n.ID, "", SeverityError, "condition_without_branches", "Condition has neither a true nor a false branch")
+		return
+	}
+	for _, h := range []string{"true", "false"} {
+		if !seen[h] {
+			v.add(n.ID, "", SeverityWarning, "missing_branch", fmt.Sprintf("Condition has no %s branch; the flow ends there when the result is %s", h, h))
+		}
+	}
+}
+
+func (v *flowValidator) checkSwitchHandles(n Node, out []Edge) {
+	handles := map[string]bool{"default": true, ErrorHandle: true}
+	var caseIDs, caseLabels []string
+	casesRaw, _ := n.Data["cases"].([]interface{})
+	for _, c := range casesRaw {
+		caseMap, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _ := caseMap["id"].(string)
+		if id == "" {
+			v.add(n.ID, "", SeverityError, "switch_case_without_id", "Switch has a case without an ID")
+			continue
+		}
+		label, _ := caseMap["label"].(string)
+		if label == "" {
+			label = id
+		}
+		handles[id] = true
+		caseIDs = append(caseIDs, id)
+		caseLabels = append(caseLabels, label)
+
+		rawOp, _ := caseMap["operator"].(string)
+		if rawOp == "" {
+			rawOp = nodes.SwitchOpEquals
+		}
+		op, ok := compare.Lookup(rawOp)
+		if !ok {
+			v.checkOperator(n, fmt.Sprintf("Switch case %q", label), rawOp)
+			continue
+		}
+		switch op.Name {
+		case "matches":
+			if pattern, _ := caseMap["value"].(string); !strings.Contains(pattern, "{{") {
+				if _, err := regexp.Compile(pattern); err != nil {
+					v.add(n.ID, "", SeverityError, "invalid_switch_case", fmt.Sprintf("Switch case %q has an invalid regex: %v", label, err))
+				}
+			}
+		case "between":
+			if isBlank(caseMap["min"]) && isBlank(caseMap["max"]) {
+				v.add(n.ID, "", SeverityError, "invalid_switch_case", fmt.Sprintf("Switch case %q needs a min or a max", label))
+			}
+		}
+	}
+	if mode, _ := n.Data["mode"].(string); mode != "" && mode != nodes.SwitchModeFirst && mode != nodes.SwitchModeAll {
+		v.add(n.ID, "", SeverityError, "invalid_switch_mode", fmt.Sprintf("Unknown switch mode %q (use first or all)", mode))
+	}
+
+	connected := make(map[string]bool)
+	for _, e := range out {
+		if e.SourceHandle == nil || *e.SourceHandle == "" {
+			continue
+		}
+		h := *e.SourceHandle
+		if !handles[h] {
+			v.add(n.ID, e.ID, SeverityError, "unknown_handle", fmt.Sprintf("Switch edge uses handle %q which matches no case", h))
+			continue
+		}
+		connected[h] = true
+	}
+	for i, id := range caseIDs {
+		if !connected[id] {
+			v.add(n.ID, "", SeverityWarning, "missing_branch", fmt.Sprintf("Switch case %q is not connected to any step", caseLabels[i]))
+		}
+	}
+}
+
+// checkOperator flags a missing or unknown comparison operator. At run time such a
+// comparison is false, so the flow would run but never take that branch or keep an item.
+func (v *flowValidator) checkOperator(n Node, what string, raw interface{}) {
+	op, _ := raw.(string)
+	if strings.TrimSpace(op) == "" {
+		v.add(n.ID, "", SeverityError, "unknown_operator", what+" has no operator")
+		return
+	}
+	if _, known := compare.Lookup(op); !known {
+		v.add(n.ID, "", SeverityError, "unknown_operator", fmt.Sprintf("%s uses unknown operator %q", what, op))
+	}
+}
+
+// checkErrorRoute flags "error" edges and onError: "route" policies without each other.
+func (v *flowValidator) checkErrorRoute(n Node, out []Edge) {
+	hasErrorEdge := false
+	for _, e := range out {
+		if e.SourceHandle != nil && *e.SourceHandle == ErrorHandle {
+			hasErrorEdge = true
+		}
+	}
+	onError := parseNodePolicy(n.Data).OnError
+	if onError == OnErrorRoute && !hasErrorEdge {
+		v.add(n.ID, "", SeverityWarning, "error_route_unconnected", `On-error policy is "route" but nothing is connected to the error handle`)
+	}
+	if hasErrorEdge && onError != OnErrorRoute {
+		v.add(n.ID, "", SeverityWarning, "error_edge_unused", `Error handle is connected but the on-error policy is not "route", so it never fires`)
+	}
+}
+
+// checkJoin flags unknown join modes, and "all" joins fed by different branches of the
+// same condition or switch: only one of those parents ever runs, so the node never would.
+func (v *flowValidator) checkJoin(n Node, in []Edge, outgoing map[string][]Edge, flowNodes []Node) {
+	if raw, ok := n.Data["join"]; ok {
+		if mode, _ := raw.(string); mode != "" && parseJoinMode(n.Data) != mode {
+			v.add(n.ID, "", SeverityError, "invalid_join_mode", fmt.Sprintf("Unknown join mode %v (use all, any, all-executed or first-wins)", raw))
+			return
+		}
+	}
+	if parseJoinMode(n.Data) != JoinAll || len(in) < 2 {
+		return
+	}
+
+	for _, b := range flowNodes {
+		if b.Type != "condition" && b.Type != "if-else" && b.Type != "switch" {
+			continue
+		}
+		if mode, _ := b.Data["mode"].(string); b.Type == "switch" && mode == nodes.SwitchModeAll {
+			// Several cases of a match-all switch can fire together
+			continue
+		}
+		// Which branches of b lead to each incoming edge of n
+		branchesOf := make([]map[string]bool, len(in))
+		for i, edge := range in {
+			branchesOf[i] = make(map[string]bool)
+			for _, out := range outgoing[b.ID] {
+				if out.SourceHandle == nil || *out.SourceHandle == "" || *out.SourceHandle == ErrorHandle {
+					continue
+				}
+				if (edge.Source == b.ID && out.ID == edge.ID) || (edge.Source != b.ID && reaches(outgoing, out.Target, edge.Source, b.ID)) {
+					branchesOf[i][*out.SourceHandle] = true
+				}
+			}
+		}
+		for i := range in {
+			for j := i + 1; j < len(in); j++ {
+				if in[i].Source != in[j].Source && exclusive(branchesOf[i], branchesOf[j]) {
+					v.add(n.ID, "", SeverityWarning, "join_across_branches", fmt.Sprintf(`Node joins branches of %s that never both run, so with join mode "all" it is always skipped; use "all-executed" or "any"`, b.ID))
+					return
+				}
+			}
+		}
+	}
+}
+
+// reaches reports whether to can be reached from from without passing through stop.
+func reaches(outgoing map[string][]Edge, from, to, stop string) bool {
+	visited := make(map[string]bool)
+	queue := []string{from}
+	for len(queue) > 0 {
+		curr := queue[0]
+		queue = queue[1:]
+		if curr == to {
+			return true
+		}
+		if curr == stop || visited[curr] {
+			continue
+		}
+		visited[curr] = true
+		for _, e := range outgoing[curr] {
+			queue = append(queue, e.Target)
+		}
+	}
+	return false
+}
+
+// isBlank reports whether a config value is missing or an empty string.
+func isBlank(v interface{}) bool {
+	return v == nil || v == ""
+}
+
+// exclusive reports whether two non-empty sets of branches have no branch in common.
+func exclusive(a, b map[string]bool) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	for h := range a {
+		if b[h] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkExpressions walks every string in the node config looking for {{ }} blocks.
+func (v *flowValidator) checkExpressions(n Node) {
+	reported := make(map[string]bool)
+	var walk func(val interface{})
+	walk = func(val interface{}) {
+		switch t := val.(type) {
+		case string:
+			for _, block := range nodes.ExpressionBlocks(t) {
+				if block.ParseErr != nil && !reported["parse:"+block.Content] {
+					reported["parse:"+block.Content] = true
+					v.add(n.ID, "", SeverityWarning, "invalid_expression", fmt.Sprintf("Expression {{ %s }} is not valid: %v", block.Content, block.ParseErr))
+				}
+				for _, step := range block.Steps {
+					if _, ok := v.nodes[step]; ok || step == "trigger" || reported["step:"+step] {
+						continue
+					}
+					reported["step:"+step] = true
+					v.add(n.ID, "", SeverityError, "unknown_step_reference", fmt.Sprintf("Expression {{ %s }} references unknown step %q", block.Content, step))
+				}
+			}
+		case map[string]interface{}:
+			for _, item := range t {
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range t {
+				walk(item)
+			}
+		}
+	}
+	walk(n.Data)
+}
+
+// credentialHeaders are request headers that usually carry a credential.
+var credentialHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"x-api-key":           true,
+	"api-key":             true,
+	"x-auth-token":        true,
+}
+
+// checkPlaintextCredentials warns about credentials typed into a node's headers or
+// webhook signature: the definition is readable by every member, so they belong in the
+// org's secrets.
+func (v *flowValidator) checkPlaintextCredentials(n Node) {
+	headers, _ := n.Data["headers"].(map[string]interface{})
+	for name, value := range headers {
+		s, _ := value.(string)
+		if !credentialHeaders[strings.ToLower(name)] || strings.TrimSpace(s) == "" || strings.Contains(s, "{{") {
+			continue
+		}
+		v.add(n.ID, "", SeverityWarning, "plaintext_credential", fmt.Sprintf("Header %q holds a credential in plain text; store it as a secret and use {{ secrets.NAME }}", name))
+	}
+	signature, _ := n.Data["signature"].(map[string]interface{})
+	if s, _ := signature["secret"].(string); strings.TrimSpace(s) != "" && !strings.Contains(s, "{{") {
+		v.add(n.ID, "", SeverityWarning, "plaintext_credential", "Webhook signing secret is in plain text; store it as a secret and use {{ secrets.NAME }}")
+	}
+}
+
+// gotoTarget reads the target of a GOTO node, accepting the same keys as GotoNode.
+func gotoTarget(n Node) string {
+	for _, key := range []string{"targetId", "target_id", "target"} {
+		if t, ok := n.Data[key].(string); ok && strings.TrimSpace(t) != "" {
+			return t
+		}
+	}
+	return ""
+}
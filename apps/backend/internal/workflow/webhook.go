@@ -0,0 +1,62 @@
+package workflow
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/webhook"
+)
+
+// WebhookTriggerType is the node type that starts a published flow from an HTTP call
+// to its own URL.
+const WebhookTriggerType = "webhook-trigger"
+
+// WebhookTrigger is the config of one webhook-trigger node:
+//
+//	{ "type": "webhook-trigger",
+//	  "signature": { "preset": "github", "secret": "{{ secrets.GITHUB_WEBHOOK_SECRET }}" },
+//	  "responseCode": 202, "responseBody": { "received": true } }
+//
+// See package webhook for the signature block. Without one, the URL's token is the
+// only authentication.
+type WebhookTrigger struct {
+	NodeID       string             `json:"node_id"`
+	Signature    *webhook.Signature `json:"-"`
+	ResponseCode int                `json:"response_code"`
+	ResponseBody interface{}        `json:"response_body,omitempty"`
+}
+
+// WebhookTriggers returns the webhook-trigger nodes of a flow, validating their config.
+func WebhookTriggers(flow FlowDefinition) ([]WebhookTrigger, error) {
+	var triggers []WebhookTrigger
+	for _, n := range flow.Nodes {
+		if n.Type != WebhookTriggerType {
+			continue
+		}
+		signature, err := webhook.ParseSignature(n.Data["signature"])
+		if err != nil {
+			return nil, fmt.Errorf("webhook trigger %s: %v", n.ID, err)
+		}
+		t := WebhookTrigger{NodeID: n.ID, Signature: signature, ResponseCode: http.StatusAccepted, ResponseBody: n.Data["responseBody"]}
+		if raw, ok := n.Data["responseCode"]; ok && raw != nil {
+			code, ok := raw.(float64)
+			if !ok || code != float64(int(code)) || code < 200 || code > 599 {
+				return nil, fmt.Errorf("webhook trigger %s: responseCode must be an HTTP status between 200 and 599", n.ID)
+			}
+			t.ResponseCode = int(code)
+		}
+		triggers = append(triggers, t)
+	}
+	return triggers, nil
+}
+
+// WebhookInput is the input of a run started by the webhook-trigger nodeID with the
+// details of the request.
+func WebhookInput(nodeID string, request map[string]interface{}) map[string]interface{} {
+	input := make(map[string]interface{}, len(request)+1)
+	for k, v := range request {
+		input[k] = v
+	}
+	input[triggerNodeKey] = nodeID
+	return input
+}
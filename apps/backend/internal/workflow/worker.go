@@ -9,6 +9,23 @@ import (
 	"go.temporal.io/sdk/worker"
 )
 
+// TaskQueue is the task queue of NodalWorkflow runs and their activities.
+//
+// Temporal replays a run from its history on whichever worker picks it up, so a release
+// that changes the commands NodalWorkflow issues (activities, timers, child workflows...)
+// breaks the runs started before it. Rather than versioning every branch of the engine in
+// place, such a release moves to a new queue: every run it starts lands on TaskQueue,
+// while the runs already on LegacyTaskQueue drain on a worker of the previous release.
+// Keep one running until
+//
+//	temporal workflow count --query "TaskQueue='nodal-task-queue' AND ExecutionStatus='Running'"
+//
+// reports 0. The next breaking change bumps TaskQueue again.
+const TaskQueue = "nodal-task-queue-v2"
+
+// LegacyTaskQueue is the queue of runs started by releases before TaskQueue.
+const LegacyTaskQueue = "nodal-task-queue"
+
 // StartWorker starts the Temporal worker
 func StartWorker() {
 	// The client and worker are heavyweight objects that should be created once per process.
@@ -38,12 +55,14 @@ func StartWorker() {
 	}
 	defer c.Close()
 
-	w := worker.New(c, "nodal-task-queue", worker.Options{})
+	w := worker.New(c, TaskQueue, worker.Options{})
 
 	w.RegisterWorkflow(NodalWorkflow)
 	w.RegisterActivity(NodeExecutionActivity)
 	w.RegisterActivity(RecordActionFlowActivity)
 	w.RegisterActivity(UpdateActionFlowStatusActivity)
+	w.RegisterActivity(CancelPendingWaitsActivity)
+	w.RegisterActivity(RecordNodeExecutionActivity)
 
 	err = w.Run(worker.InterruptCh())
 	if err != nil {
@@ -5,22 +5,26 @@ import (
 	"time"
 
 	"github.com/teavana/enigmatic_s/apps/backend/internal/nodes"
-	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
+// ResponseStateKey holds the output of the RESPONSE node (if any) in the run result,
+// next to "__node_status". Synchronous executions turn it into the HTTP response.
+const ResponseStateKey = "__response"
+
+// StateQuery is the query that returns a run's node statuses and outputs while it runs.
+const StateQuery = "state"
+
+// Signals that hold and release a run between nodes.
+const (
+	PauseSignal  = "pause"
+	ResumeSignal = "resume"
+)
+
 // NodalWorkflow executes a graph of nodes with support for parallel execution and smart merges
 func NodalWorkflow(ctx workflow.Context, flowDefinition FlowDefinition, inputData map[string]interface{}) (interface{}, error) {
-	ao := workflow.ActivityOptions{
-		StartToCloseTimeout: 1 * time.Minute, // Default timeout
-		RetryPolicy: &temporal.RetryPolicy{
-			InitialInterval:    1 * time.Second,
-			BackoffCoefficient: 2.0,
-			MaximumInterval:    1 * time.Minute,
-			MaximumAttempts:    5, // Fail after 5 attempts to save resources
-		},
-	}
-	ctx = workflow.WithActivityOptions(ctx, ao)
+	// Run-wide defaults; each node can override them via its "policy" (see policy.go)
+	ctx = workflow.WithActivityOptions(ctx, defaultActivityOptions())
 
 	logger := workflow.GetLogger(ctx)
 	logger.Info("Nodal workflow started (Parallel Engine)", "Nodes", len(flowDefinition.Nodes))
@@ -40,30 +44,73 @@ func NodalWorkflow(ctx workflow.Context, flowDefinition FlowDefinition, inputDat
 		delete(inputData, "__mock_data") // Remove from input so it doesn't pollute trigger body
 	}
 
+	// Runs started by a schedule get the fire time as input
+	if scheduled, _ := inputData[scheduledRunKey].(bool); scheduled {
+		delete(inputData, scheduledRunKey)
+		inputData[scheduledTimeKey] = scheduledStartTime(ctx).UTC().Format(time.RFC3339)
+	}
+
+	// Sub-flow runs know the run and node that called them
+	parent := popSubflowParent(inputData)
+
+	// Retried runs reuse the completed nodes of the run they retry
+	retry := popRetrySeed(inputData)
+
+	// Flows with several triggers say which one started this run
+	startNodeID, _ := inputData[triggerNodeKey].(string)
+	delete(inputData, triggerNodeKey)
+
 	// Initialize 'trigger' scope
 	executionState["trigger"] = map[string]interface{}{
 		"body": inputData,
 	}
 
 	// 1. Build Graph & Lookup Maps
-	nodesLookup := make(map[string]Node)
-	incomingEdges := make(map[string][]Edge)
-	outgoingEdges := make(map[string][]Edge)
+	engine := newFlowEngine(flowDefinition, mockData)
+	if parent != nil {
+		engine.subflowDepth = parent.Depth
+	}
+
+	// Live view of a running run: per-node statuses and the outputs so far
+	if err := workflow.SetQueryHandler(ctx, StateQuery, func() (map[string]interface{}, error) {
+		return map[string]interface{}{
+			"node_status": nodeStatus,
+			"outputs":     executionState,
+			"paused":      engine.paused,
+			"waiting":     engine.waitingUntil(),
+		}, nil
+	}); err != nil {
+		logger.Warn("Failed to register state query", "Error", err)
+	}
+
+	// Operators can pause the run between nodes and resume it later
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		pauseCh := workflow.GetSignalChannel(ctx, PauseSignal)
+		resumeCh := workflow.GetSignalChannel(ctx, ResumeSignal)
+		for ctx.Err() == nil {
+			selector := workflow.NewSelector(ctx)
+			selector.AddReceive(pauseCh, func(c workflow.ReceiveChannel, more bool) {
+				c.Receive(ctx, nil)
+				engine.paused = true
+				logger.Info("Run paused")
+			})
+			selector.AddReceive(resumeCh, func(c workflow.ReceiveChannel, more bool) {
+				c.Receive(ctx, nil)
+				engine.paused = false
+				logger.Info("Run resumed")
+			})
+			selector.Select(ctx)
+		}
+	})
 
 	for _, n := range flowDefinition.Nodes {
-		nodesLookup[n.ID] = n
 		nodeStatus[n.ID] = "PENDING"
 		// Optimization: Pre-fill API trigger data
-		if n.Type == "api-trigger" || n.Type == "manual-trigger" || n.Type == "webhook" || n.Type == "trigger" {
+		if isTriggerType(n.Type) {
 			executionState[n.ID] = inputData
 		}
 	}
 
-	for _, e := range flowDefinition.Edges {
-		outgoingEdges[e.Source] = append(outgoingEdges[e.Source], e)
-		incomingEdges[e.Target] = append(incomingEdges[e.Target], e)
-	}
-
 	// 2. Prepare Template Variables & Record Start
 	// (Keeping the original logic for recording the workflow start)
 	var titleTemplate, descTemplate string
@@ -74,10 +121,10 @@ func NodalWorkflow(ctx workflow.Context, flowDefinition FlowDefinition, inputDat
 	// Locate Trigger Node for Config
 	var triggerNodeID string
 	for _, n := range flowDefinition.Nodes {
-		if n.Type == "api-trigger" || n.Type == "manual-trigger" || n.Type == "webhook" || n.Type == "trigger" {
+		if isTriggerType(n.Type) && (startNodeID == "" || n.ID == startNodeID) {
 			triggerNodeID = n.ID
 			// Extract config (same as before)
-			if n.Type == "api-trigger" {
+			if n.Type == "api-trigger" || n.Type == ScheduleTriggerType || n.Type == WebhookTriggerType {
 				if t, ok := n.Data["instanceNameTemplate"].(string); ok {
 					titleTemplate = t
 				}
@@ -162,6 +209,7 @@ func NodalWorkflow(ctx workflow.Context, flowDefinition FlowDefinition, inputDat
 	recordParams := RecordActionFlowParams{
 		FlowID:      flowDefinition.ID,
 		OrgID:       flowDefinition.OrgID, // Added OrgID
+		VersionID:   flowDefinition.VersionID,
 		WorkflowID:  info.WorkflowExecution.ID,
 		RunID:       info.WorkflowExecution.RunID,
 		InputData:   inputData,
@@ -171,6 +219,14 @@ func NodalWorkflow(ctx workflow.Context, flowDefinition FlowDefinition, inputDat
 		Assignments: assignments,
 		InfoFields:  infoFields,
 	}
+	if parent != nil {
+		recordParams.ParentRunID = parent.RunID
+		recordParams.ParentNodeID = parent.NodeID
+	}
+	if retry != nil {
+		recordParams.RetryOfRunID = retry.RunID
+		recordParams.RetryFromNode = retry.FromNode
+	}
 	// Only record action flow if we have a valid OrgID (skip for unsaved test flows)
 	hasActionFlowRecord := flowDefinition.OrgID != ""
 	if hasActionFlowRecord {
@@ -181,320 +237,71 @@ func NodalWorkflow(ctx workflow.Context, flowDefinition FlowDefinition, inputDat
 	}
 
 	// 3. Parallel Execution Engine
-	// We use a WaitGroup to wait for all branches to finish
-	wg := workflow.NewWaitGroup(ctx)
-	// We assume at least the trigger node starts
-	wg.Add(1)
-
-	// executionError handles failing the whole workflow if one node fails
-	var executionError error
-
-	// GOTO loop protection: limit maximum jump iterations
-	gotoCounter := 0
-	const maxGotoHops = 50
-
-	// Helper: Reset Downstream Nodes (Recursive)
-	// Used for Loop/Goto to signal that nodes can run again
-	var resetDownstreamNodes func(nodeID string)
-	visitedReset := make(map[string]bool)
-
-	resetDownstreamNodes = func(nodeID string) {
-		if visitedReset[nodeID] {
-			return
-		}
-		visitedReset[nodeID] = true
-		nodeStatus[nodeID] = "PENDING"
-		// We do NOT clear executionState, to preserve history?
-		// Actually, for a clean rerun, we might want to?
-		// But in a parallel graph, maybe not.
-		// For now, let's just reset status.
-
-		// Follow all outgoing edges
-		for _, edge := range outgoingEdges[nodeID] {
-			resetDownstreamNodes(edge.Target)
-		}
+	scope := &executionScope{
+		nodeStatus:     nodeStatus,
+		executionState: executionState,
+		variables:      variables,
+		running:        make(map[string]workflow.CancelFunc),
+		wg:             workflow.NewWaitGroup(ctx),
 	}
 
-	// Helper: Try to execute a node
-	// This function is RECURSIVE (via workflow.Go)
-	var tryExecuteNode func(ctx workflow.Context, nodeID string)
-	tryExecuteNode = func(ctx workflow.Context, nodeID string) {
-		defer wg.Done()
-
-		node, exists := nodesLookup[nodeID]
-		nodeType := ""
-		if exists {
-			nodeType = node.Type
-		}
-		logger.Info("tryExecuteNode called", "ID", nodeID, "Type", nodeType, "Status", nodeStatus[nodeID])
-
-		// Safety check
-		if executionError != nil {
-			logger.Info("Skipping node (executionError set)", "ID", nodeID)
-			return
-		}
-
-		// A. Check Status
-		// In Temporal's single-threaded event loop, this is safe without locks
-		if nodeStatus[nodeID] == "COMPLETED" || nodeStatus[nodeID] == "RUNNING" {
-			logger.Info("Skipping node (already done/running)", "ID", nodeID, "Status", nodeStatus[nodeID])
-			return
-		}
-
-		// B. Dependency Check (Smart Merge)
-		// "Are ALL my parents completed?"
-		parents := incomingEdges[nodeID]
-		for _, edge := range parents {
-			parentStatus := nodeStatus[edge.Source]
-			if parentStatus != "COMPLETED" {
-				// Dependency not met yet. Wait.
-				// When the missing parent finishes, it will trigger this node again.
-				logger.Info("Dependency not met, deferring", "ID", nodeID, "WaitingOn", edge.Source, "ParentStatus", parentStatus)
-				return
-			}
-		}
-
-		// C. Execute Node
-		nodeStatus[nodeID] = "RUNNING"
-		if !exists {
-			logger.Error("Node not found", "ID", nodeID)
-			return
-		}
-
-		// Special case for Trigger: It's technically already "Done" as it triggered the flow
-		// But if it has logic, we run it. Usually Triggers just pass data.
-		// For simplicity, we treat Trigger as an immediate success if it was the entry point.
-
-		var result nodes.NodeResult
-
-		// Skip execution for triggers, just mark success as we did init above
-		isTrigger := node.Type == "api-trigger" || node.Type == "manual-trigger" || node.Type == "webhook" || node.Type == "trigger"
-
-		if isTrigger {
-			result = nodes.NodeResult{Status: nodes.StatusSuccess, Output: executionState[nodeID]}
-		} else {
-			// Prepare Context
-			nodeInputData := map[string]interface{}{
-				"steps":     executionState,
-				"variables": variables,
-			}
-			if mockData != nil {
-				nodeInputData["__mock_data"] = mockData
-			}
-
-			nodeCtx := nodes.NodeContext{
-				FlowID:     flowDefinition.ID,
-				OrgID:      flowDefinition.OrgID, // Populate OrgID
-				WorkflowID: workflow.GetInfo(ctx).WorkflowExecution.ID,
-				RunID:      workflow.GetInfo(ctx).WorkflowExecution.RunID,
-				StepID:     node.ID,
-				InputData:  nodeInputData,
-				Config:     node.Data,
-			}
-
-			logger.Info("Executing Node", "ID", node.ID, "Type", node.Type)
-			err := workflow.ExecuteActivity(ctx, NodeExecutionActivity, nodeCtx).Get(ctx, &result)
-			if err != nil {
-				logger.Error("Node execution failed", "ID", node.ID, "Error", err)
-				executionError = err
-				nodeStatus[nodeID] = "FAILED"
-				return
-			}
-		}
-
-		// D. Handle Result (Pause/Resume)
-		if result.Status == nodes.StatusPaused {
-			logger.Info("Node requested suspension", "ID", node.ID)
-			signalName := "Resume-" + node.ID
-			if tid, ok := result.Output["task_id"].(string); ok {
-				signalName = "HumanTask-" + tid
-			}
-			if actionID, ok := result.Output["action_id"].(string); ok {
-				signalName = "AutomationSignal-" + actionID
-			}
-
-			// Configurable timeout: default 7 days, override via node config "timeout" (in minutes)
-			timeoutDuration := 7 * 24 * time.Hour
-			if t, ok := node.Data["timeout"].(float64); ok && t > 0 {
-				timeoutDuration = time.Duration(t) * time.Minute
-			}
-
-			var signalData interface{}
-			timedOut := false
-			selector := workflow.NewSelector(ctx)
-			selector.AddReceive(workflow.GetSignalChannel(ctx, signalName), func(c workflow.ReceiveChannel, more bool) {
-				c.Receive(ctx, &signalData)
-			})
-			selector.AddFuture(workflow.NewTimer(ctx, timeoutDuration), func(f workflow.Future) {
-				timedOut = true
-			})
-			selector.Select(ctx)
-
-			if timedOut {
-				logger.Error("Node timed out waiting for signal", "ID", node.ID, "Timeout", timeoutDuration)
-				executionError = fmt.Errorf("node %s timed out waiting for signal after %v", node.ID, timeoutDuration)
-				nodeStatus[nodeID] = "FAILED"
-				return
-			}
-
-			// Update result
-			if signalMap, ok := signalData.(map[string]interface{}); ok {
-				if result.Output == nil {
-					result.Output = make(map[string]interface{})
-				}
-
-				// Flatten "output" key if present
-				// The signal sends { "task_id": "...", "output": { "key": "val" } }
-				// We want result.Output["key"] = "val"
-				if outputMap, ok := signalMap["output"].(map[string]interface{}); ok {
-					for k, v := range outputMap {
-						result.Output[k] = v
-					}
-				}
-
-				// Also allow top-level keys if needed (like task_id)
-				for k, v := range signalMap {
-					if k != "output" {
-						result.Output[k] = v
-					}
-				}
-			}
-			result.Status = nodes.StatusSuccess
-		}
+	// 4. Kickoff
+	if triggerNodeID == "" {
+		return nil, fmt.Errorf("no trigger node found")
+	}
 
-		if result.Status == nodes.StatusFailed {
-			executionError = fmt.Errorf("node %s failed: %s", node.ID, result.Error)
-			nodeStatus[nodeID] = "FAILED"
-			return
+	// Triggers that didn't start this run never will
+	for _, n := range flowDefinition.Nodes {
+		if isTriggerType(n.Type) && n.ID != triggerNodeID {
+			engine.skipNode(ctx, scope, n.ID)
 		}
+	}
 
-		// Save State
-		// Store with both flat access and nested "output" key so expressions
-		// like {{ steps.nodeId.fieldName }} AND {{ steps.nodeId.output.fieldName }} both work.
-		merged := make(map[string]interface{})
-		if result.Output != nil {
-			for k, v := range result.Output {
-				merged[k] = v
-			}
-			merged["output"] = result.Output
-		}
-		executionState[node.ID] = merged
-		nodeStatus[nodeID] = "COMPLETED"
-		if node.Type == "set" || node.Type == "variable" {
-			for k, v := range result.Output {
-				if k != "_debug_message" {
-					variables[k] = v
-				}
-			}
+	// Start from trigger (or, for a retry, from where the previous run stopped)
+	if retry != nil {
+		for _, nodeID := range engine.applyRetrySeed(scope, retry, triggerNodeID) {
+			engine.runNode(ctx, scope, nodeID)
 		}
+	} else {
+		engine.runNode(ctx, scope, triggerNodeID)
+	}
 
-		// Handle GOTO / LOOP
-		if gotoTarget, ok := result.Output["_goto_target"].(string); ok && gotoTarget != "" {
-			// In test mode, treat reaching a Retry/Revisit node as a successful terminal state.
-			// This prevents infinite loops during testing since there is no real event to
-			// satisfy the jump-back target.
-			if mockData != nil {
-				logger.Info("GOTO reached in test mode — stopping as success", "From", nodeID, "To", gotoTarget)
-				// Surface a marker so the test results UI can show a clear message
-				// instead of a generic success.
-				executionState[nodeID]["_test_stopped_at_goto"] = true
-				executionState[nodeID]["_goto_target"] = gotoTarget
-				return
+	// Wait for all to finish
+	scope.wg.Wait(ctx)
+
+	// Canceled runs clean up after themselves: close the record and any open waits
+	if ctx.Err() != nil {
+		logger.Info("Nodal workflow canceled, cleaning up")
+		if hasActionFlowRecord {
+			cleanupCtx, _ := workflow.NewDisconnectedContext(ctx)
+			if err := workflow.ExecuteActivity(cleanupCtx, CancelPendingWaitsActivity, info.WorkflowExecution.RunID).Get(cleanupCtx, nil); err != nil {
+				logger.Error("Failed to cancel pending waits", "Error", err)
 			}
-
-			gotoCounter++
-			if gotoCounter > maxGotoHops {
-				executionError = fmt.Errorf("maximum GOTO iterations (%d) exceeded — possible infinite loop detected", maxGotoHops)
-				logger.Error("GOTO loop limit reached", "From", nodeID, "To", gotoTarget, "Count", gotoCounter)
-				return
+			cancelParams := UpdateActionFlowStatusParams{RunID: info.WorkflowExecution.RunID, Status: "CANCELED", NodeStatus: nodeStatus}
+			if err := workflow.ExecuteActivity(cleanupCtx, UpdateActionFlowStatusActivity, cancelParams).Get(cleanupCtx, nil); err != nil {
+				logger.Error("Failed to mark action flow as CANCELED", "Error", err)
 			}
-			logger.Info("GOTO signal received", "From", nodeID, "To", gotoTarget, "Hop", gotoCounter)
-			// 1. Reset status of target and its children so they can run again
-			visitedReset = make(map[string]bool) // Clear visited map for this run
-			resetDownstreamNodes(gotoTarget)
-
-			// 2. Trigger the target immediately
-			wg.Add(1)
-			workflow.Go(ctx, func(ctx workflow.Context) {
-				tryExecuteNode(ctx, gotoTarget)
-			})
-
-			// 3. Stop normal propagation (Do not trigger children of this node)
-			return
 		}
+		return nil, ctx.Err()
+	}
 
-		// E. Trigger Children (Parallel Split)
-		childrenEdges := outgoingEdges[nodeID]
-		logger.Info("Triggering children", "ParentID", nodeID, "ParentType", node.Type, "ChildEdges", len(childrenEdges))
-		for _, edge := range childrenEdges {
-			// Branching Logic (Condition / Switch)
-			// If node is a conditional, we only trigger specific children
-			shouldTrigger := true
-
-			if node.Type == "condition" || node.Type == "if-else" {
-				resVal, _ := result.Output["result"].(bool)
-				targetHandle := "false"
-				if resVal {
-					targetHandle = "true"
-				}
-
-				// Only follow edge if handle matches
-				if edge.SourceHandle != nil && *edge.SourceHandle != targetHandle {
-					shouldTrigger = false
-				}
-			} else if node.Type == "switch" {
-				selectedCase, _ := result.Output["selected_case"].(string)
-				// 1. Exact match
-				// 2. Default if no exact match (simplified: just check handle)
-				if edge.SourceHandle != nil && *edge.SourceHandle != selectedCase {
-					// Check if it's default? Complex.
-					// For MVP, strict match or "default" if selectedCase is empty/unmatched?
-					// Let's stick to strict match for now.
-					if *edge.SourceHandle != "default" {
-						shouldTrigger = false
-					}
-					// If selectedCase matched a different handle, we shouldn't trigger default
-					// This logic usually requires knowing *all* edges to decide if default runs.
-					// For now: Simple switch.
-				}
-			}
-
-			if shouldTrigger {
-				logger.Info("Triggering child node", "Parent", nodeID, "Child", edge.Target)
-				wg.Add(1)
-				// Launch child in new routine
-				targetID := edge.Target // Capture for closure
-				workflow.Go(ctx, func(ctx workflow.Context) {
-					tryExecuteNode(ctx, targetID)
-				})
-			} else {
-				logger.Info("Skipping child (branching)", "Parent", nodeID, "Child", edge.Target)
+	if scope.executionError != nil {
+		// Failed runs stay retryable (see RetryInput), so record the failure
+		if hasActionFlowRecord {
+			failParams := UpdateActionFlowStatusParams{RunID: info.WorkflowExecution.RunID, Status: "FAILED", NodeStatus: nodeStatus}
+			if err := workflow.ExecuteActivity(ctx, UpdateActionFlowStatusActivity, failParams).Get(ctx, nil); err != nil {
+				logger.Error("Failed to mark action flow as FAILED", "Error", err)
 			}
 		}
-	}
-
-	// 4. Kickoff
-	if triggerNodeID == "" {
-		return nil, fmt.Errorf("no trigger node found")
-	}
-
-	// Start from trigger
-	workflow.Go(ctx, func(ctx workflow.Context) {
-		tryExecuteNode(ctx, triggerNodeID)
-	})
-
-	// Wait for all to finish
-	wg.Wait(ctx)
-
-	if executionError != nil {
-		return nil, executionError
+		return nil, scope.executionError
 	}
 
 	// 5. Mark Flow as COMPLETED (only if we recorded the action flow)
 	if hasActionFlowRecord {
 		completeParams := UpdateActionFlowStatusParams{
-			RunID:  info.WorkflowExecution.RunID,
-			Status: "COMPLETED",
+			RunID:      info.WorkflowExecution.RunID,
+			Status:     "COMPLETED",
+			NodeStatus: nodeStatus,
 		}
 		if err := workflow.ExecuteActivity(ctx, UpdateActionFlowStatusActivity, completeParams).Get(ctx, nil); err != nil {
 			logger.Error("Failed to mark action flow as COMPLETED", "Error", err)
@@ -513,6 +320,9 @@ func NodalWorkflow(ctx workflow.Context, flowDefinition FlowDefinition, inputDat
 		nodeStatusConverted[k] = v
 	}
 	executionState["__node_status"] = nodeStatusConverted
+	if engine.response != nil {
+		executionState[ResponseStateKey] = engine.response
+	}
 
 	logger.Info("Nodal workflow completed successfully", "ExecutionStateKeys", stateKeys, "NodeStatuses", nodeStatus)
 	return executionState, nil
@@ -0,0 +1,173 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/teavana/enigmatic_s/apps/backend/internal/nodes"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// fakeNodes stands in for NodeExecutionActivity. Loops, conditions and switches run their
+// real executors; "step" nodes return their "output" data, and fail or block when told to:
+//
+//	"fail":  true | "<item>"  fail (every time, or in the iteration of that loop item)
+//	"block": "<item>"         never finish in the iteration of that loop item
+//	"pause": true             wait for the Resume-<id> signal
+type fakeNodes struct {
+	mu       sync.Mutex
+	calls    []string                          // node ID, with "#<item>" inside a loop
+	inputs   map[string]map[string]interface{} // steps visible to each call
+	canceled int                               // activities the workflow canceled
+	release  chan struct{}                     // unblocks "block" nodes once the test ends
+}
+
+func (f *fakeNodes) execute(ctx context.Context, input nodes.NodeContext) (*nodes.NodeResult, error) {
+	call := input.StepID
+	item, inLoop := input.InputData["item"]
+	if inLoop {
+		call = fmt.Sprintf("%s#%v", input.StepID, item)
+	}
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	if steps, ok := input.InputData["steps"].(map[string]interface{}); ok {
+		f.inputs[call] = steps
+	}
+	f.mu.Unlock()
+
+	nodeType, _ := input.Config["type"].(string)
+	if nodeType != "step" {
+		executor, err := nodes.GetExecutor(nodeType, input.Config)
+		if err != nil {
+			return nil, err
+		}
+		return executor.Execute(ctx, input)
+	}
+
+	matches := func(v interface{}) bool {
+		return v == true || (inLoop && v != nil && fmt.Sprint(v) == fmt.Sprint(item))
+	}
+	if matches(input.Config["block"]) {
+		select {
+		case <-ctx.Done():
+		case <-f.release:
+		}
+		return nil, fmt.Errorf("%s never finishes", call)
+	}
+	if matches(input.Config["fail"]) {
+		return nil, temporal.NewNonRetryableApplicationError(call+" failed", nodes.ErrorTypeNodeFailed, nil)
+	}
+	output, _ := input.Config["output"].(map[string]interface{})
+	if output == nil {
+		output = map[string]interface{}{"ran": call}
+	}
+	if input.Config["pause"] == true {
+		return &nodes.NodeResult{Status: nodes.StatusPaused, Output: output}, nil
+	}
+	return &nodes.NodeResult{Status: nodes.StatusSuccess, Output: output}, nil
+}
+
+func (f *fakeNodes) ran(call string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.calls {
+		if c == call {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeNodes) count(call string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, c := range f.calls {
+		if c == call {
+			n++
+		}
+	}
+	return n
+}
+
+// runFlow runs flow in a test environment, with fakeNodes executing the nodes. setup can
+// register delayed callbacks (signals, queries) before the run starts.
+func runFlow(t *testing.T, flow FlowDefinition, input map[string]interface{}, setup ...func(*testsuite.TestWorkflowEnvironment)) (*testsuite.TestWorkflowEnvironment, *fakeNodes) {
+	t.Helper()
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	fake := &fakeNodes{inputs: make(map[string]map[string]interface{}), release: make(chan struct{})}
+	t.Cleanup(func() { close(fake.release) })
+	env.RegisterWorkflow(NodalWorkflow)
+	env.RegisterActivityWithOptions(fake.execute, activity.RegisterOptions{Name: "NodeExecutionActivity"})
+	env.SetOnActivityCanceledListener(func(*activity.Info) {
+		fake.mu.Lock()
+		fake.canceled++
+		fake.mu.Unlock()
+	})
+	for _, fn := range setup {
+		fn(env)
+	}
+	if input == nil {
+		input = map[string]interface{}{}
+	}
+	env.ExecuteWorkflow(NodalWorkflow, flow, input)
+	if !env.IsWorkflowCompleted() {
+		t.Fatal("workflow didn't complete")
+	}
+	return env, fake
+}
+
+// nodeStatuses returns the node statuses the run ended with.
+func nodeStatuses(t *testing.T, env *testsuite.TestWorkflowEnvironment) map[string]string {
+	t.Helper()
+	value, err := env.QueryWorkflow(StateQuery)
+	if err != nil {
+		t.Fatalf("state query: %v", err)
+	}
+	var state struct {
+		NodeStatus map[string]string `json:"node_status"`
+	}
+	if err := value.Get(&state); err != nil {
+		t.Fatalf("state query result: %v", err)
+	}
+	return state.NodeStatus
+}
+
+func expectStatuses(t *testing.T, got map[string]string, want map[string]string) {
+	t.Helper()
+	for id, status := range want {
+		if got[id] != status {
+			t.Errorf("%s is %s, want %s (all: %v)", id, got[id], status, got)
+		}
+	}
+}
+
+func testNode(id, nodeType string, data map[string]interface{}) Node {
+	d := map[string]interface{}{"type": nodeType}
+	for k, v := range data {
+		d[k] = v
+	}
+	return Node{ID: id, Type: nodeType, Data: d}
+}
+
+func stepNode(id string, data map[string]interface{}) Node {
+	return testNode(id, "step", data)
+}
+
+func triggerNode(id string) Node {
+	return testNode(id, "manual-trigger", nil)
+}
+
+func testEdge(source, target string, handle ...string) Edge {
+	e := Edge{ID: source + "->" + target, Source: source, Target: target}
+	if len(handle) > 0 {
+		e.SourceHandle = &handle[0]
+		e.ID += ":" + handle[0]
+	}
+	return e
+}
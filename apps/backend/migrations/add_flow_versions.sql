@@ -0,0 +1,48 @@
+-- Migration: Immutable flow versions
+-- Every publish (and rollback) inserts a numbered snapshot of the definition.
+-- action_flows.version_id records which snapshot a run executed.
+
+CREATE TABLE IF NOT EXISTS flow_versions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    flow_id UUID NOT NULL REFERENCES flows(id) ON DELETE CASCADE,
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    definition JSONB NOT NULL,
+    note TEXT,
+    rolled_back_from UUID REFERENCES flow_versions(id) ON DELETE SET NULL,
+    published_by UUID,
+    published_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (flow_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_flow_versions_flow_id ON flow_versions(flow_id);
+
+ALTER TABLE flows ADD COLUMN IF NOT EXISTS current_version_id UUID REFERENCES flow_versions(id) ON DELETE SET NULL;
+ALTER TABLE action_flows ADD COLUMN IF NOT EXISTS version_id UUID REFERENCES flow_versions(id) ON DELETE SET NULL;
+
+-- Versions are append-only: refuse edits to the snapshot itself
+CREATE OR REPLACE FUNCTION prevent_flow_version_update()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    IF NEW.definition IS DISTINCT FROM OLD.definition
+       OR NEW.version IS DISTINCT FROM OLD.version
+       OR NEW.flow_id IS DISTINCT FROM OLD.flow_id THEN
+        RAISE EXCEPTION 'flow_versions rows are immutable';
+    END IF;
+    RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS flow_versions_immutable ON flow_versions;
+CREATE TRIGGER flow_versions_immutable
+    BEFORE UPDATE ON flow_versions
+    FOR EACH ROW EXECUTE FUNCTION prevent_flow_version_update();
+
+ALTER TABLE flow_versions ENABLE ROW LEVEL SECURITY;
+
+COMMENT ON TABLE flow_versions IS
+'Immutable snapshots of published flow definitions, numbered per flow.';
+COMMENT ON COLUMN action_flows.version_id IS
+'The flow version this run executed.';
@@ -0,0 +1,43 @@
+-- Migration: Webhook triggers and signed webhooks
+-- flow_webhooks gives every webhook-trigger node a stable public URL,
+-- POST /api/webhooks/flows/{token}, created on the first publish and kept after.
+-- webhook_deliveries remembers the deliveries a signed webhook accepted (flow triggers
+-- and AUTOMATION resume URLs alike), so the same request can't be played twice.
+
+CREATE TABLE IF NOT EXISTS flow_webhooks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    flow_id UUID NOT NULL REFERENCES flows(id) ON DELETE CASCADE,
+    node_id TEXT NOT NULL,
+    token TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (flow_id, node_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_flow_webhooks_flow_id ON flow_webhooks(flow_id);
+
+ALTER TABLE flow_webhooks ENABLE ROW LEVEL SECURITY;
+
+COMMENT ON TABLE flow_webhooks IS
+'Public URLs of webhook-trigger nodes. The token authenticates callers unless the node also verifies a signature.';
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    scope TEXT NOT NULL,
+    delivery_id TEXT NOT NULL,
+    expires_at TIMESTAMPTZ,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (scope, delivery_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_expires_at ON webhook_deliveries(scope, expires_at);
+
+ALTER TABLE webhook_deliveries ENABLE ROW LEVEL SECURITY;
+
+COMMENT ON COLUMN webhook_deliveries.delivery_id IS
+'SHA-256 of the verified signature. Sender delivery IDs are not signed, so they are not trusted.';
+COMMENT ON COLUMN webhook_deliveries.expires_at IS
+'When a replay would fail the timestamp check anyway. NULL for schemes without a signed timestamp: kept for good.';
+
+-- Signature settings of an AUTOMATION node, copied when it starts waiting. The secret
+-- stays a {{ secrets.NAME }} reference.
+ALTER TABLE automation_subscriptions ADD COLUMN IF NOT EXISTS signature JSONB;
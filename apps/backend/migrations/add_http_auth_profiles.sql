@@ -0,0 +1,25 @@
+-- Migration: HTTP authentication profiles
+-- Named credentials an HTTP node references by id. settings holds the non-secret parts
+-- (username, key name, token URL, client id...); the password / token / key / client
+-- secret is encrypted by the backend with SECRETS_MASTER_KEY.
+
+CREATE TABLE IF NOT EXISTS http_auth_profiles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('basic', 'bearer', 'api_key', 'oauth2_client_credentials')),
+    settings JSONB NOT NULL DEFAULT '{}'::jsonb,
+    secret_ciphertext TEXT NOT NULL,
+    created_by UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (org_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_http_auth_profiles_org_id ON http_auth_profiles(org_id);
+
+-- Only the backend (service role) reads credentials
+ALTER TABLE http_auth_profiles ENABLE ROW LEVEL SECURITY;
+
+COMMENT ON TABLE http_auth_profiles IS
+'Authentication profiles applied by the HTTP node at request time.';
@@ -0,0 +1,23 @@
+-- Migration: Per-organization mail transport settings for the EMAIL node
+-- One row per organization. If an org has no row, the server falls back to SMTP_* env vars.
+
+CREATE TABLE IF NOT EXISTS mail_settings (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL UNIQUE REFERENCES organizations(id) ON DELETE CASCADE,
+    provider TEXT NOT NULL DEFAULT 'smtp',
+    smtp_host TEXT,
+    smtp_port INTEGER,
+    smtp_username TEXT,
+    smtp_password TEXT,
+    smtp_security TEXT NOT NULL DEFAULT 'starttls' CHECK (smtp_security IN ('starttls', 'tls', 'none')),
+    from_address TEXT NOT NULL,
+    from_name TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Only the backend (service role) reads credentials
+ALTER TABLE mail_settings ENABLE ROW LEVEL SECURITY;
+
+COMMENT ON TABLE mail_settings IS
+'Mail transport configuration per organization, used by the EMAIL workflow node.';
@@ -0,0 +1,33 @@
+-- Migration: Per-node execution history
+-- NodalWorkflow records every attempt of every node of a run: the resolved
+-- config it ran with, its output or error, and timings.
+-- Served by GET /api/action-flows/{id}/steps.
+
+CREATE TABLE IF NOT EXISTS node_executions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    flow_id UUID REFERENCES flows(id) ON DELETE SET NULL,
+    workflow_id TEXT NOT NULL,
+    run_id TEXT NOT NULL,
+    node_id TEXT NOT NULL,
+    node_type TEXT,
+    status TEXT NOT NULL,
+    attempt INTEGER NOT NULL DEFAULT 1,
+    loop_index INTEGER,
+    input JSONB,
+    output JSONB,
+    error TEXT,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    duration_ms BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_node_executions_run_id ON node_executions(run_id);
+CREATE INDEX IF NOT EXISTS idx_node_executions_org_id ON node_executions(org_id);
+
+COMMENT ON TABLE node_executions IS
+'One row per node attempt of a run (retries get their own rows). Rows of nodes waiting on a human task or signal stay WAITING until the wait ends.';
+COMMENT ON COLUMN node_executions.input IS
+'The node config with its expressions resolved, as the node saw it.';
+COMMENT ON COLUMN node_executions.loop_index IS
+'Index of the LOOP item, for nodes of a loop body.';
@@ -0,0 +1,8 @@
+-- Migration: Keep the final per-node status of a run
+-- Written when the run ends, so run details can tell nodes that were skipped
+-- (on a branch a condition or switch didn't take) from nodes never reached.
+
+ALTER TABLE action_flows ADD COLUMN IF NOT EXISTS node_status JSONB;
+
+COMMENT ON COLUMN action_flows.node_status IS
+'Node ID -> PENDING, COMPLETED, FAILED or SKIPPED, as of the end of the run (NULL while running).';
@@ -0,0 +1,18 @@
+-- Migration: Per-organization egress policy
+-- Allow and deny lists applied to every outbound call of a flow (HTTP node, OAuth2
+-- token endpoints, org SMTP servers). Entries are host names, *.wildcards, IPs or
+-- CIDRs. Private, loopback and metadata ranges are blocked whatever these lists say.
+-- Blocked attempts are logged in audit_logs as egress.blocked.
+
+CREATE TABLE IF NOT EXISTS org_egress_policies (
+    org_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+    allow JSONB NOT NULL DEFAULT '[]'::jsonb,
+    deny JSONB NOT NULL DEFAULT '[]'::jsonb,
+    updated_by UUID,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE org_egress_policies ENABLE ROW LEVEL SECURITY;
+
+COMMENT ON TABLE org_egress_policies IS
+'Destinations an organization''s flows may call. An empty allow list allows any public destination.';
@@ -0,0 +1,24 @@
+-- Migration: Organization secrets
+-- Values are encrypted by the backend with SECRETS_MASTER_KEY (AES-256-GCM) before they
+-- reach the database, and are never returned by the API once stored.
+-- Flows reference them as {{ secrets.NAME }}.
+
+CREATE TABLE IF NOT EXISTS org_secrets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name TEXT NOT NULL CHECK (name ~ '^[A-Za-z_][A-Za-z0-9_]{0,63}$'),
+    description TEXT NOT NULL DEFAULT '',
+    ciphertext TEXT NOT NULL,
+    created_by UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (org_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_org_secrets_org_id ON org_secrets(org_id);
+
+-- Only the backend (service role) reads secrets
+ALTER TABLE org_secrets ENABLE ROW LEVEL SECURITY;
+
+COMMENT ON TABLE org_secrets IS
+'Encrypted organization secrets, resolved by workflow activities for {{ secrets.NAME }}.';
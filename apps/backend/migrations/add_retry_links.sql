@@ -0,0 +1,13 @@
+-- Migration: Link retried runs to the run they retry
+-- POST /api/action-flows/{id}/retry starts a new run that reuses the completed
+-- nodes of a failed run; the new action flow points back at the original.
+
+ALTER TABLE action_flows ADD COLUMN IF NOT EXISTS retry_of_action_flow_id UUID REFERENCES action_flows(id) ON DELETE SET NULL;
+ALTER TABLE action_flows ADD COLUMN IF NOT EXISTS retry_from_node TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_action_flows_retry_of ON action_flows(retry_of_action_flow_id);
+
+COMMENT ON COLUMN action_flows.retry_of_action_flow_id IS
+'The action flow this run retries (NULL for first attempts).';
+COMMENT ON COLUMN action_flows.retry_from_node IS
+'Node the retry was explicitly restarted from (NULL when it resumed from the failed nodes).';
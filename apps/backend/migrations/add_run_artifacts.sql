@@ -0,0 +1,29 @@
+-- Migration: Run artifacts
+-- Files produced by a run, e.g. the binary response of an HTTP node (PDF labels,
+-- images, archives). Step outputs hold a reference ({ artifact_id, filename, ... })
+-- and the bytes live here, base64-encoded.
+-- Served by GET /api/orgs/{orgId}/artifacts/{id}.
+
+CREATE TABLE IF NOT EXISTS run_artifacts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    workflow_id TEXT,
+    run_id TEXT,
+    node_id TEXT,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL DEFAULT 'application/octet-stream',
+    size BIGINT NOT NULL,
+    sha256 TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_run_artifacts_org_id ON run_artifacts(org_id);
+CREATE INDEX IF NOT EXISTS idx_run_artifacts_run_id ON run_artifacts(run_id);
+
+ALTER TABLE run_artifacts ENABLE ROW LEVEL SECURITY;
+
+COMMENT ON TABLE run_artifacts IS
+'Binary files produced by runs. Step outputs reference them by id.';
+COMMENT ON COLUMN run_artifacts.content IS
+'File content, base64. Bounded by the maxResponseBytes of the node that produced it.';
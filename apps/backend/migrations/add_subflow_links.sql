@@ -0,0 +1,13 @@
+-- Migration: Link sub-flow runs to their caller
+-- A SUBFLOW node starts another flow as a child workflow; its action flow
+-- points back at the calling action flow and node.
+
+ALTER TABLE action_flows ADD COLUMN IF NOT EXISTS parent_action_flow_id UUID REFERENCES action_flows(id) ON DELETE SET NULL;
+ALTER TABLE action_flows ADD COLUMN IF NOT EXISTS parent_node_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_action_flows_parent ON action_flows(parent_action_flow_id);
+
+COMMENT ON COLUMN action_flows.parent_action_flow_id IS
+'The action flow whose SUBFLOW node started this run (NULL for top-level runs).';
+COMMENT ON COLUMN action_flows.parent_node_id IS
+'ID of the SUBFLOW node in the parent flow that started this run.';
@@ -0,0 +1,9 @@
+-- Migration: Encrypt SMTP passwords at rest
+-- Passwords are sealed with the secrets master key (SECRETS_MASTER_KEY) like org secrets.
+-- A password saved in plain text before this keeps working and is encrypted, and the
+-- plain-text column cleared, the next time the org's mail settings are saved.
+
+ALTER TABLE mail_settings ADD COLUMN IF NOT EXISTS smtp_password_ciphertext TEXT;
+
+COMMENT ON COLUMN mail_settings.smtp_password_ciphertext IS
+'SMTP password encrypted with the secrets master key. smtp_password is only read for rows saved before encryption.';